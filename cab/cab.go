@@ -2,8 +2,9 @@ package cab
 
 import (
 	"context"
-	"errors"
 	"fmt"
+	"maps"
+	"slices"
 	"strconv"
 
 	"github.com/roosterfish/dcc-ex-go/channel"
@@ -23,7 +24,8 @@ type FunctionState uint8
 
 type Cab struct {
 	address Address
-	channel *channel.Channel
+	channel channel.Interface
+	shared  *sharedState
 }
 
 type CabStatus struct {
@@ -45,6 +47,21 @@ const (
 	CabCommand rune = 't'
 )
 
+// Conventional function assignments of sound decoders. Check the decoder's manual as they might differ.
+const (
+	FunctionHeadlight Function = 0
+	FunctionBell      Function = 1
+	FunctionHorn      Function = 2
+)
+
+const (
+	// MaxFunction is the highest function supported by the command station (F0-F68).
+	MaxFunction Function = command.MaxCabFunction
+	// maxMappedFunction is the highest function reported in the cab's function map (F0-F28).
+	// Higher functions are sent to the decoder but not remembered by the command station.
+	maxMappedFunction Function = 28
+)
+
 func (d Direction) Opposite() Direction {
 	if d == DirectionForward {
 		return DirectionBackward
@@ -53,22 +70,47 @@ func (d Direction) Opposite() Direction {
 	return DirectionForward
 }
 
-func NewCab(address Address, channel *channel.Channel) *Cab {
+func NewCab(address Address, channel channel.Interface) *Cab {
 	return &Cab{
 		address: address,
 		channel: channel,
+		shared:  &sharedState{},
 	}
 }
 
+// Address returns the cab's address.
+func (c *Cab) Address() Address {
+	return c.address
+}
+
 func (c *Cab) equalsCommandParams(cmd *command.Command) error {
-	params, err := cmd.ParametersStrings()
+	// <l cab reg speed functions>
+	if !command.NewMatcher(command.OpCodeCabResponse, c.address, command.Wildcard, command.Wildcard, command.Wildcard).Match(cmd) {
+		return fmt.Errorf("invalid response for cab %d: %q", c.address, cmd.String())
+	}
+
+	return nil
+}
+
+// writeAndReadResponse writes the given command and waits for the command station to broadcast the cab's state.
+// The command station responds with <X> instead in case the command cannot be run (e.g. all cab slots are used).
+func (c *Cab) writeAndReadResponse(ctx context.Context, cmd *command.Command) error {
+	broadcasted := false
+	err := c.channel.WriteAndReadOpCode(ctx, cmd, command.OpCodeCabResponse, func(cmd *command.Command) error {
+		err := c.equalsCommandParams(cmd)
+		if err != nil {
+			return err
+		}
+
+		broadcasted = true
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed getting cab command parameters: %w", err)
+		return err
 	}
 
-	cabMatch := len(params) == 4 && params[0] == strconv.FormatUint(uint64(c.address), 10)
-	if !cabMatch {
-		return fmt.Errorf("invalid response for cab %d: %q", c.address, cmd.String())
+	if !broadcasted {
+		return fmt.Errorf("failed to run %q for cab %d: %w", cmd.String(), c.address, channel.ErrCommandFailed)
 	}
 
 	return nil
@@ -108,7 +150,12 @@ func (c *Cab) speedUnchanged(status *CabStatus, newSpeed Speed, newDirection Dir
 
 // Speed sets the cabs speed and direction.
 // It first checks whether or not the speed and direction is already set.
+// The previous speed and direction are recorded in the cab's history and can be restored using Revert.
 func (c *Cab) Speed(ctx context.Context, speed Speed, direction Direction) error {
+	return c.speed(ctx, speed, direction, true)
+}
+
+func (c *Cab) speed(ctx context.Context, speed Speed, direction Direction, record bool) error {
 	return c.channel.SessionContext(ctx, func(ctx context.Context) error {
 		// Check if already at the requested speed.
 		// There isn't a broadcast sent if the cab is already at the requested speed and direction.
@@ -122,13 +169,72 @@ func (c *Cab) Speed(ctx context.Context, speed Speed, direction Direction) error
 		}
 
 		speedCommand := command.NewCommand(command.OpCodeCabSpeed, "%d %d %d", c.address, speed, direction)
-		return c.channel.WriteAndReadOpCode(ctx, speedCommand, command.OpCodeCabResponse, c.equalsCommandParams)
+		err = c.writeAndReadResponse(ctx, speedCommand)
+		if err != nil {
+			return err
+		}
+
+		if record {
+			previousSpeed, previousDirection := status.SpeedDirection()
+			c.history().push(previousSpeed, previousDirection, c.channel.Clock().Now())
+		}
+
+		c.history().observe(speed, direction, c.channel.Clock().Now())
+		return nil
 	})
 }
 
-// Function sets the respective cab's function to either on or off.
-// It first checks whether or not the function's state is already set.
+// EStop stops the cab immediately using the emergency stop speed -1 while preserving its direction.
+// The stop is written as a safety command (see channel.Interface.WriteSafety) right away, it doesn't wait for
+// other sessions. The direction is the latest one read from or written to the command station. In case it isn't
+// known yet, the cab's status is read first which waits for the other sessions.
+// The latest known speed and direction are recorded in the cab's history and can be restored using Revert.
+func (c *Cab) EStop(ctx context.Context) error {
+	latest, ok := c.history().last()
+	if !ok {
+		status, err := c.Status(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to emergency stop cab %d: %w", c.address, err)
+		}
+
+		latest.Speed, latest.Direction = status.SpeedDirection()
+	}
+
+	stopCommand := command.NewCommand(command.OpCodeCabSpeed, "%d %d %d", c.address, -1, latest.Direction)
+	err := c.channel.WriteSafety(stopCommand)
+	if err != nil {
+		return fmt.Errorf("failed to emergency stop cab %d: %w", c.address, err)
+	}
+
+	c.history().push(latest.Speed, latest.Direction, c.channel.Clock().Now())
+	c.history().observe(-1, latest.Direction, c.channel.Clock().Now())
+	return nil
+}
+
+// Forget removes the cab from the command station's reminders using <- cab> which frees its slot (see SupportedCabs).
+// The cab stops receiving the periodic speed and function packets. Setting its speed adds it again.
+func (c *Cab) Forget(ctx context.Context) error {
+	err := c.channel.Write(ctx, command.NewCommand(command.OpCodeCabForget, "%d", c.address))
+	if err != nil {
+		return fmt.Errorf("failed to forget cab %d: %w", c.address, err)
+	}
+
+	return nil
+}
+
+// Function sets the respective cab's function (F0-F68) to either on or off.
+// For the functions F0-F28 it first checks whether or not the function's state is already set.
+// The functions F29-F68 aren't reported by the command station and are therefore always sent.
 func (c *Cab) Function(ctx context.Context, funct Function, state FunctionState) error {
+	if funct > MaxFunction {
+		return fmt.Errorf("invalid function F%d of cab %d: must be F0-F%d", funct, c.address, MaxFunction)
+	}
+
+	functionCommand := command.NewCommand(command.OpCodeCabFunction, "%d %d %d", c.address, funct, state)
+	if funct > maxMappedFunction {
+		return c.channel.Write(ctx, functionCommand)
+	}
+
 	return c.channel.SessionContext(ctx, func(ctx context.Context) error {
 		// Check if the requested function already has the requested state.
 		// There isn't a broadcast sent if the function already has the requested state.
@@ -139,15 +245,52 @@ func (c *Cab) Function(ctx context.Context, funct Function, state FunctionState)
 
 		// The function map uses a bit for each func starting from LSB.
 		// The bit is 1 in case the function is already on, 0 if it is off.
-		if status.FunctMap == (uint32(state) << funct) {
+		if FunctionState((status.FunctMap>>funct)&1) == state {
 			return nil
 		}
 
-		functionCommand := command.NewCommand(command.OpCodeCabFunction, "%d %d %d", c.address, funct, state)
-		return c.channel.WriteAndReadOpCode(ctx, functionCommand, command.OpCodeCabResponse, c.equalsCommandParams)
+		return c.writeAndReadResponse(ctx, functionCommand)
 	})
 }
 
+// Functions sets several of the cab's functions within a single session, e.g. when selecting a loco on a throttle.
+// All of the functions are validated before any of them is set. They are set in ascending order.
+func (c *Cab) Functions(ctx context.Context, states map[Function]FunctionState) error {
+	functs := slices.Sorted(maps.Keys(states))
+	if len(functs) > 0 && functs[len(functs)-1] > MaxFunction {
+		return fmt.Errorf("invalid function F%d of cab %d: must be F0-F%d", functs[len(functs)-1], c.address, MaxFunction)
+	}
+
+	return c.channel.SessionContext(ctx, func(ctx context.Context) error {
+		for _, funct := range functs {
+			err := c.Function(ctx, funct, states[funct])
+			if err != nil {
+				return fmt.Errorf("failed to set function F%d of cab %d: %w", funct, c.address, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// SpeedDirection decodes the status' speed byte into speed and direction.
+func (s *CabStatus) SpeedDirection() (Speed, Direction) {
+	switch {
+	case s.SpeedByte == 0:
+		return 0, DirectionBackward
+	case s.SpeedByte == 1:
+		return -1, DirectionBackward
+	case s.SpeedByte <= 127:
+		return Speed(s.SpeedByte - 1), DirectionBackward
+	case s.SpeedByte == 128:
+		return 0, DirectionForward
+	case s.SpeedByte == 129:
+		return -1, DirectionForward
+	default:
+		return Speed(s.SpeedByte - 129), DirectionForward
+	}
+}
+
 func (c *Cab) Status(ctx context.Context) (*CabStatus, error) {
 	var status *CabStatus
 
@@ -167,7 +310,7 @@ func (c *Cab) Status(ctx context.Context) (*CabStatus, error) {
 			return fmt.Errorf("invalid speed byte %q: %w", params[2], err)
 		}
 
-		functMap, err := strconv.ParseUint(params[3], 10, 16)
+		functMap, err := strconv.ParseUint(params[3], 10, 32)
 		if err != nil {
 			return fmt.Errorf("invalid funct map %q: %w", params[3], err)
 		}
@@ -177,6 +320,9 @@ func (c *Cab) Status(ctx context.Context) (*CabStatus, error) {
 			FunctMap:  uint32(functMap),
 		}
 
+		speed, direction := status.SpeedDirection()
+		c.history().observe(speed, direction, c.channel.Clock().Now())
+
 		return nil
 	})
 	if err != nil {
@@ -184,8 +330,37 @@ func (c *Cab) Status(ctx context.Context) (*CabStatus, error) {
 	}
 
 	if status == nil {
-		return nil, errors.New("status response is missing")
+		return nil, fmt.Errorf("status response of cab %d is missing: %w", c.address, channel.ErrCommandFailed)
 	}
 
 	return status, nil
 }
+
+// State is the decoded state of a cab as reported by the command station.
+type State struct {
+	Speed     Speed
+	Direction Direction
+	// FunctMap uses a bit for each function starting from LSB (see Function).
+	FunctMap uint32
+}
+
+// Function returns the state of the given function.
+func (s *State) Function(funct Function) FunctionState {
+	return FunctionState((s.FunctMap >> funct) & 1)
+}
+
+// State queries the command station using <t cab> and returns the cab's current speed, direction and functions.
+func (c *Cab) State(ctx context.Context) (*State, error) {
+	status, err := c.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	speed, direction := status.SpeedDirection()
+
+	return &State{
+		Speed:     speed,
+		Direction: direction,
+		FunctMap:  status.FunctMap,
+	}, nil
+}
@@ -0,0 +1,502 @@
+package cab_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/cab"
+	"github.com/roosterfish/dcc-ex-go/channel"
+	"github.com/roosterfish/dcc-ex-go/channeltest"
+	"github.com/roosterfish/dcc-ex-go/errs"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+	"github.com/roosterfish/dcc-ex-go/simulator"
+)
+
+func TestRevert(t *testing.T) {
+	p := protocol.NewProtocol(simulator.NewSimulator(), &protocol.Config{})
+	defer p.Close()
+
+	ch := channel.NewChannel(p)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	registry := cab.NewRegistry()
+	loc := cab.NewCab(3, ch)
+	loc.SetRegistry(registry)
+
+	err := loc.Speed(ctx, 50, cab.DirectionForward)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Cabs derived later using the same registry share the history.
+	derived := cab.NewCab(3, ch)
+	derived.SetRegistry(registry)
+
+	err = derived.Speed(ctx, 80, cab.DirectionBackward)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(loc.History()) != 2 {
+		t.Fatalf("Expected 2 history entries but got %v", loc.History())
+	}
+
+	expectF := func(speed cab.Speed, direction cab.Direction) {
+		status, err := loc.Status(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		gotSpeed, gotDirection := status.SpeedDirection()
+		if gotSpeed != speed || gotDirection != direction {
+			t.Errorf("Expected speed %d and direction %d but got %d and %d", speed, direction, gotSpeed, gotDirection)
+		}
+	}
+
+	err = loc.Revert(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectF(50, cab.DirectionForward)
+
+	err = loc.Revert(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// New cabs are stopped going forward.
+	expectF(0, cab.DirectionForward)
+
+	err = loc.Revert(ctx)
+	if !errors.Is(err, cab.ErrNoHistory) {
+		t.Errorf("Expected %v but got %v", cab.ErrNoHistory, err)
+	}
+}
+
+func TestEStop(t *testing.T) {
+	p := protocol.NewProtocol(simulator.NewSimulator(), &protocol.Config{})
+	defer p.Close()
+
+	ch := channel.NewChannel(p)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	loc := cab.NewCab(3, ch)
+
+	err := loc.Speed(ctx, 50, cab.DirectionBackward)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = loc.EStop(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := loc.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	speed, direction := status.SpeedDirection()
+	if speed != -1 || direction != cab.DirectionBackward {
+		t.Errorf("Expected emergency stop backward but got speed %d and direction %d", speed, direction)
+	}
+
+	err = loc.Revert(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEStopReverseWithoutHistory(t *testing.T) {
+	mock := channeltest.NewChannel()
+	defer mock.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// The cab was set running backward at speed 50 by another throttle.
+	mock.Respond("<t 3>", "<l 3 0 51 0>")
+
+	loc := cab.NewCab(3, mock)
+
+	err := loc.EStop(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The stop must not flip the direction.
+	written := mock.Written()
+	if len(written) != 2 || written[0].String() != "<t 3>" || written[1].String() != "<t 3 -1 0>" {
+		t.Errorf("Unexpected written commands %v", written)
+	}
+}
+
+func TestState(t *testing.T) {
+	p := protocol.NewProtocol(simulator.NewSimulator(), &protocol.Config{})
+	defer p.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	loc := cab.NewCab(3, channel.NewChannel(p))
+
+	err := loc.Speed(ctx, 40, cab.DirectionBackward)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = loc.Function(ctx, 2, cab.FunctionOn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := loc.State(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if state.Speed != 40 || state.Direction != cab.DirectionBackward {
+		t.Errorf("Expected speed 40 backward but got speed %d and direction %d", state.Speed, state.Direction)
+	}
+
+	if state.Function(2) != cab.FunctionOn || state.Function(1) != cab.FunctionOff {
+		t.Errorf("Expected only F2 to be on but got funct map %b", state.FunctMap)
+	}
+}
+
+func TestRampTo(t *testing.T) {
+	tests := []struct {
+		name      string
+		start     cab.Speed
+		direction cab.Direction
+		profile   cab.RampProfile
+	}{
+		{name: "linear", start: 0, direction: cab.DirectionForward, profile: cab.RampProfile{Duration: 50 * time.Millisecond}},
+		{name: "exponential", start: 10, direction: cab.DirectionForward, profile: cab.RampProfile{Duration: 50 * time.Millisecond, Steps: 5, Curve: cab.RampExponential}},
+		{name: "reverse", start: 20, direction: cab.DirectionBackward, profile: cab.RampProfile{Duration: 50 * time.Millisecond, Steps: 4}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := protocol.NewProtocol(simulator.NewSimulator(), &protocol.Config{})
+			defer p.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			loc := cab.NewCab(3, channel.NewChannel(p))
+
+			err := loc.Speed(ctx, test.start, test.direction)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = loc.RampTo(ctx, 30, cab.DirectionForward, test.profile)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			state, err := loc.State(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if state.Speed != 30 || state.Direction != cab.DirectionForward {
+				t.Errorf("Expected speed 30 forward but got speed %d and direction %d", state.Speed, state.Direction)
+			}
+		})
+	}
+}
+
+func TestRampToCancel(t *testing.T) {
+	p := protocol.NewProtocol(simulator.NewSimulator(), &protocol.Config{})
+	defer p.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := cab.NewCab(3, channel.NewChannel(p)).RampTo(ctx, 100, cab.DirectionForward, cab.RampProfile{Duration: time.Minute})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the ramp to be cancelled but got %v", err)
+	}
+}
+
+func TestFunctions(t *testing.T) {
+	p := protocol.NewProtocol(simulator.NewSimulator(), &protocol.Config{})
+	defer p.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	loc := cab.NewCab(3, channel.NewChannel(p))
+
+	err := loc.Functions(ctx, map[cab.Function]cab.FunctionState{
+		cab.FunctionHeadlight: cab.FunctionOn,
+		cab.FunctionHorn:      cab.FunctionOn,
+		20:                    cab.FunctionOn,
+		68:                    cab.FunctionOn,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Setting an already set function is skipped.
+	err = loc.Function(ctx, cab.FunctionHorn, cab.FunctionOn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := loc.State(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, funct := range []cab.Function{cab.FunctionHeadlight, cab.FunctionHorn, 20} {
+		if state.Function(funct) != cab.FunctionOn {
+			t.Errorf("Expected F%d to be on but got funct map %b", funct, state.FunctMap)
+		}
+	}
+
+	if state.Function(cab.FunctionBell) != cab.FunctionOff {
+		t.Errorf("Expected F1 to be off but got funct map %b", state.FunctMap)
+	}
+
+	err = loc.Functions(ctx, map[cab.Function]cab.FunctionState{
+		cab.FunctionBell: cab.FunctionOn,
+		69:               cab.FunctionOn,
+	})
+	if err == nil {
+		t.Error("Expected F69 to be invalid")
+	}
+}
+
+func TestThrottleConfigSpeed(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   cab.ThrottleConfig
+		percent  float64
+		expected cab.Speed
+	}{
+		{name: "stopped", config: cab.ThrottleConfig{}, percent: 0, expected: 0},
+		{name: "full", config: cab.ThrottleConfig{}, percent: 100, expected: 126},
+		{name: "half", config: cab.ThrottleConfig{}, percent: 50, expected: 63},
+		{name: "lowest step", config: cab.ThrottleConfig{}, percent: 0.1, expected: 1},
+		{name: "clamped", config: cab.ThrottleConfig{}, percent: 150, expected: 126},
+		{name: "28 steps", config: cab.ThrottleConfig{SpeedSteps: cab.SpeedSteps28}, percent: 50, expected: 63},
+		{name: "28 steps quantized", config: cab.ThrottleConfig{SpeedSteps: cab.SpeedSteps28}, percent: 1, expected: 5},
+		{name: "curve", config: cab.ThrottleConfig{Curve: cab.RampExponential}, percent: 50, expected: 23},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			speed := test.config.Speed(test.percent)
+			if speed != test.expected {
+				t.Errorf("Expected speed %d but got %d", test.expected, speed)
+			}
+		})
+	}
+}
+
+func TestSpeedPercent(t *testing.T) {
+	p := protocol.NewProtocol(simulator.NewSimulator(), &protocol.Config{})
+	defer p.Close()
+
+	ch := channel.NewChannel(p)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	registry := cab.NewRegistry()
+	configured := cab.NewCab(3, ch)
+	configured.SetRegistry(registry)
+	configured.SetThrottleConfig(cab.ThrottleConfig{SpeedSteps: cab.SpeedSteps28})
+
+	// Cabs derived later using the same registry share the configuration.
+	loc := cab.NewCab(3, ch)
+	loc.SetRegistry(registry)
+
+	if loc.ThrottleConfig().SpeedSteps != cab.SpeedSteps28 {
+		t.Fatalf("Expected the shared throttle configuration but got %+v", loc.ThrottleConfig())
+	}
+
+	// A cab without the registry has its own configuration.
+	if cab.NewCab(3, ch).ThrottleConfig().SpeedSteps != 0 {
+		t.Error("Expected the default throttle configuration of a cab without registry")
+	}
+
+	err := loc.SpeedPercent(ctx, 25, cab.DirectionForward)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := loc.State(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if state.Speed != 32 || state.Direction != cab.DirectionForward {
+		t.Errorf("Expected speed 32 forward but got speed %d and direction %d", state.Speed, state.Direction)
+	}
+}
+
+func TestMomentum(t *testing.T) {
+	p := protocol.NewProtocol(simulator.NewSimulator(), &protocol.Config{})
+	defer p.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	loc := cab.NewCab(3, channel.NewChannel(p))
+
+	momentum, cleanupF := loc.Momentum(cab.MomentumConfig{
+		Acceleration: 500,
+		Braking:      1000,
+		ErrorF: func(err error) {
+			t.Error(err)
+		},
+	})
+	defer cleanupF()
+
+	expectF := func(speed cab.Speed, direction cab.Direction) {
+		for {
+			gotSpeed, gotDirection := momentum.Speed()
+			if gotSpeed == speed && gotDirection == direction {
+				break
+			}
+
+			select {
+			case <-ctx.Done():
+				t.Fatalf("Expected speed %d and direction %d but got %d and %d", speed, direction, gotSpeed, gotDirection)
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+
+		state, err := loc.State(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if state.Speed != speed || state.Direction != direction {
+			t.Errorf("Expected cab speed %d and direction %d but got %d and %d", speed, direction, state.Speed, state.Direction)
+		}
+	}
+
+	momentum.SetTarget(20, cab.DirectionForward)
+	expectF(20, cab.DirectionForward)
+
+	// Reversing brakes to a stop first.
+	momentum.SetTarget(10, cab.DirectionBackward)
+	expectF(10, cab.DirectionBackward)
+
+	err := momentum.EStop(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	speed, _ := momentum.Speed()
+	if speed != 0 {
+		t.Errorf("Expected the momentum to be stopped but got speed %d", speed)
+	}
+}
+
+func TestEStopBypassesSessions(t *testing.T) {
+	p := protocol.NewProtocol(simulator.NewSimulator(), &protocol.Config{})
+	defer p.Close()
+
+	ch := channel.NewChannel(p)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	loc := cab.NewCab(3, ch)
+
+	err := loc.Speed(ctx, 50, cab.DirectionBackward)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Hold the channel like a stuck session.
+	heldC := make(chan struct{})
+	releaseC := make(chan struct{})
+	go func() {
+		_ = ch.Session(func(protocol protocol.ReadWriteCloser) error {
+			close(heldC)
+			<-releaseC
+			return nil
+		})
+	}()
+
+	<-heldC
+
+	start := time.Now()
+	err = loc.EStop(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if time.Since(start) > time.Second {
+		t.Errorf("Expected the emergency stop to bypass the held session but it took %v", time.Since(start))
+	}
+
+	close(releaseC)
+
+	state, err := loc.State(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if state.Speed != -1 || state.Direction != cab.DirectionBackward {
+		t.Errorf("Expected emergency stop backward but got speed %d and direction %d", state.Speed, state.Direction)
+	}
+}
+
+func TestFullSpeed(t *testing.T) {
+	p := protocol.NewProtocol(simulator.NewSimulator(), &protocol.Config{})
+	defer p.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	loc := cab.NewCab(3, channel.NewChannel(p))
+
+	err := loc.Speed(ctx, 127, cab.DirectionForward)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = loc.RampTo(ctx, 0, cab.DirectionForward, cab.RampProfile{Steps: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSpeedFailed(t *testing.T) {
+	ch := channeltest.NewChannel()
+	defer ch.Close()
+
+	// The speed command isn't answered which resembles the command station responding with <X>.
+	ch.Respond("<t 3>", "<l 3 0 128 0>")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	loc := cab.NewCab(3, ch)
+
+	err := loc.Speed(ctx, 50, cab.DirectionForward)
+	if !errors.Is(err, errs.ErrStationFailure) {
+		t.Errorf("Expected %v but got %v", errs.ErrStationFailure, err)
+	}
+
+	err = loc.Function(ctx, 0, cab.FunctionOn)
+	if !errors.Is(err, errs.ErrStationFailure) {
+		t.Errorf("Expected %v but got %v", errs.ErrStationFailure, err)
+	}
+}
@@ -0,0 +1,116 @@
+package cab
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// HistorySize is the number of speed changes recorded for every cab.
+const HistorySize = 16
+
+// ErrNoHistory is returned when reverting a cab without any recorded speed change.
+var ErrNoHistory = errors.New("no recorded speed change")
+
+// HistoryEntry is the speed and direction of a cab before it got changed.
+type HistoryEntry struct {
+	Speed     Speed
+	Direction Direction
+	// Time is the time the speed got changed.
+	Time time.Time
+}
+
+type history struct {
+	entries []HistoryEntry
+	// latest is the latest speed and direction read from or written to the command station.
+	// It's used for emergency stops which must not wait for reading the cab's status.
+	latest      HistoryEntry
+	latestKnown bool
+	lock        sync.Mutex
+}
+
+// history returns the cab's history.
+func (c *Cab) history() *history {
+	return &c.shared.history
+}
+
+func (h *history) push(speed Speed, direction Direction, t time.Time) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.entries = append(h.entries, HistoryEntry{
+		Speed:     speed,
+		Direction: direction,
+		Time:      t,
+	})
+
+	if len(h.entries) > HistorySize {
+		h.entries = h.entries[len(h.entries)-HistorySize:]
+	}
+}
+
+// observe remembers the latest known speed and direction of the cab.
+func (h *history) observe(speed Speed, direction Direction, t time.Time) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.latest = HistoryEntry{
+		Speed:     speed,
+		Direction: direction,
+		Time:      t,
+	}
+	h.latestKnown = true
+}
+
+// last returns the latest known speed and direction of the cab.
+func (h *history) last() (HistoryEntry, bool) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	return h.latest, h.latestKnown
+}
+
+func (h *history) pop() (HistoryEntry, bool) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if len(h.entries) == 0 {
+		return HistoryEntry{}, false
+	}
+
+	entry := h.entries[len(h.entries)-1]
+	h.entries = h.entries[:len(h.entries)-1]
+
+	return entry, true
+}
+
+// History returns the recorded speed changes of the cab starting with the oldest.
+func (c *Cab) History() []HistoryEntry {
+	h := c.history()
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	return slices.Clone(h.entries)
+}
+
+// Revert restores the speed and direction the cab had before the latest speed change.
+// Calling it repeatedly walks back the history (e.g. for an "oops" button or recovering from an interrupted maneuver).
+func (c *Cab) Revert(ctx context.Context) error {
+	entry, ok := c.history().pop()
+	if !ok {
+		return fmt.Errorf("failed to revert cab %d: %w", c.address, ErrNoHistory)
+	}
+
+	err := c.speed(ctx, entry.Speed, entry.Direction, false)
+	if err != nil {
+		// Keep the entry to allow retrying.
+		c.history().push(entry.Speed, entry.Direction, entry.Time)
+		return fmt.Errorf("failed to revert cab %d: %w", c.address, err)
+	}
+
+	return nil
+}
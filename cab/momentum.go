@@ -0,0 +1,268 @@
+package cab
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/protocol"
+)
+
+// MomentumConfig defines how quickly a cab with momentum changes its speed.
+type MomentumConfig struct {
+	// Acceleration is the number of speed steps per second when speeding up. If zero, the speed is changed right away.
+	Acceleration float64
+	// Braking is the number of speed steps per second when slowing down. If zero, the speed is changed right away.
+	Braking float64
+	// ErrorF is called for every speed change which couldn't be written. The speed change is retried on the next step.
+	ErrorF func(err error)
+}
+
+// Momentum emulates the momentum of a prototype train for decoders without momentum configured (CV 3 and 4).
+// Requested speeds are approached step by step in a background goroutine.
+type Momentum struct {
+	cab    *Cab
+	config MomentumConfig
+
+	lock sync.Mutex
+	// current and target are signed speeds, see signedSpeed.
+	current         int
+	target          int
+	targetDirection Direction
+	// known is set once the cab's speed got read from the command station.
+	known bool
+
+	// stops counts the emergency stops. A speed step written while the counter changed might have
+	// overtaken the emergency stop.
+	stops uint64
+
+	updateC chan struct{}
+}
+
+// Momentum starts emulating the cab's momentum using the given config.
+// Use the returned Momentum to set the cab's target speed instead of calling Speed.
+// Never use the Momentum after calling the cleanup function which stops the cab at its current speed.
+func (c *Cab) Momentum(config MomentumConfig) (*Momentum, protocol.CleanupF) {
+	m := &Momentum{
+		cab:     c,
+		config:  config,
+		updateC: make(chan struct{}, 1),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := sync.WaitGroup{}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		m.run(ctx)
+	}()
+
+	return m, func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+// SetTarget sets the speed and direction the cab approaches using the configured rates.
+// In case the direction changes, the cab first brakes to a stop before accelerating in the new direction.
+func (m *Momentum) SetTarget(speed Speed, direction Direction) {
+	m.lock.Lock()
+	m.target = signedSpeed(speed, direction)
+	m.targetDirection = direction
+	m.lock.Unlock()
+
+	m.notify()
+}
+
+// Speed returns the speed and direction last written to the cab.
+func (m *Momentum) Speed() (Speed, Direction) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.current == 0 {
+		return 0, m.targetDirection
+	}
+
+	return Speed(abs(m.current)), directionOf(m.current)
+}
+
+// EStop stops the cab immediately bypassing the momentum (see Cab.EStop).
+// The target speed is reset to zero.
+func (m *Momentum) EStop(ctx context.Context) error {
+	m.lock.Lock()
+	m.target = 0
+	m.current = 0
+	m.known = true
+	m.stops++
+	m.lock.Unlock()
+
+	m.notify()
+
+	return m.cab.EStop(ctx)
+}
+
+// notify wakes up the background goroutine without blocking.
+func (m *Momentum) notify() {
+	select {
+	case m.updateC <- struct{}{}:
+	default:
+	}
+}
+
+// directionOf returns the direction of the signed speed.
+func directionOf(signed int) Direction {
+	if signed < 0 {
+		return DirectionBackward
+	}
+
+	return DirectionForward
+}
+
+// next returns the next signed speed and direction and how long to wait before writing it.
+// It returns false in case the target is reached.
+func (m *Momentum) next() (int, Direction, time.Duration, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.current == m.target {
+		return 0, 0, 0, false
+	}
+
+	next := m.current + 1
+	if m.target < m.current {
+		next = m.current - 1
+	}
+
+	// Moving towards zero is braking, moving away from it accelerating.
+	rate := m.config.Acceleration
+	if abs(next) < abs(m.current) {
+		rate = m.config.Braking
+	}
+
+	wait := time.Duration(0)
+	if rate > 0 {
+		wait = time.Duration(float64(time.Second) / rate)
+	} else if m.current != 0 && m.target != 0 && (m.current > 0) != (m.target > 0) {
+		// Without momentum the cab still stops before reversing.
+		next = 0
+	} else {
+		next = m.target
+	}
+
+	direction := directionOf(next)
+	if next == 0 {
+		direction = m.targetDirection
+	}
+
+	return next, direction, wait, true
+}
+
+func (m *Momentum) run(ctx context.Context) {
+	for {
+		if !m.init(ctx) {
+			// Retry reading the cab's speed.
+			select {
+			case <-m.cab.channel.Clock().After(time.Second):
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		_, _, wait, ok := m.next()
+		if !ok {
+			select {
+			case <-m.updateC:
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		select {
+		case <-m.cab.channel.Clock().After(wait):
+		case <-ctx.Done():
+			return
+		}
+
+		if !m.step(ctx) {
+			return
+		}
+	}
+}
+
+// step writes the next speed step. It returns false once the context is cancelled.
+func (m *Momentum) step(ctx context.Context) bool {
+	m.lock.Lock()
+	stops := m.stops
+	m.lock.Unlock()
+
+	// The target might have changed while waiting.
+	next, direction, _, ok := m.next()
+	if !ok {
+		return true
+	}
+
+	err := m.cab.speed(ctx, Speed(abs(next)), direction, false)
+	if err != nil {
+		if ctx.Err() != nil {
+			return false
+		}
+
+		if m.config.ErrorF != nil {
+			m.config.ErrorF(err)
+		}
+
+		return true
+	}
+
+	m.lock.Lock()
+	overtaken := m.stops != stops
+	if !overtaken {
+		m.current = next
+	}
+	m.lock.Unlock()
+
+	if overtaken {
+		// The step was in flight while stopping, stop the cab again.
+		err := m.cab.EStop(ctx)
+		if err != nil && m.config.ErrorF != nil {
+			m.config.ErrorF(err)
+		}
+	}
+
+	return true
+}
+
+// init reads the cab's current speed from the command station once.
+func (m *Momentum) init(ctx context.Context) bool {
+	m.lock.Lock()
+	known := m.known
+	m.lock.Unlock()
+
+	if known {
+		return true
+	}
+
+	status, err := m.cab.Status(ctx)
+	if err != nil {
+		if ctx.Err() == nil && m.config.ErrorF != nil {
+			m.config.ErrorF(err)
+		}
+
+		return false
+	}
+
+	speed, direction := status.SpeedDirection()
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if !m.known {
+		m.current = signedSpeed(speed, direction)
+		m.known = true
+	}
+
+	return true
+}
@@ -0,0 +1,120 @@
+package cab
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/errs"
+)
+
+// RampCurve maps the progress of a ramp (0-1) onto the progress of the speed change (0-1).
+// Custom curves must return 0 for 0 and 1 for 1.
+type RampCurve func(progress float64) float64
+
+// RampProfile defines how the speed of a cab is changed over time by RampTo.
+type RampProfile struct {
+	// Duration is the time it takes to reach the target speed.
+	Duration time.Duration
+	// Steps is the number of speed updates. If zero, the speed is updated once for every speed step to change.
+	Steps int
+	// Curve is the acceleration curve. If nil, RampLinear is used.
+	Curve RampCurve
+}
+
+// RampLinear changes the speed at a constant rate.
+func RampLinear(progress float64) float64 {
+	return progress
+}
+
+// RampExponential changes the speed slowly at first and quicker towards the target speed.
+func RampExponential(progress float64) float64 {
+	const k = 3
+	return (math.Exp(k*progress) - 1) / (math.Exp(k) - 1)
+}
+
+// signedSpeed returns the speed as a positive value for forward and a negative value for backward movement.
+// An emergency stop is treated as being stopped.
+func signedSpeed(speed Speed, direction Direction) int {
+	if speed <= 0 {
+		return 0
+	}
+
+	if direction == DirectionBackward {
+		return -int(speed)
+	}
+
+	return int(speed)
+}
+
+// RampTo changes the cab's speed step by step until reaching the target speed and direction.
+// In case the direction changes, the cab is first slowed down to a stop before accelerating in the new direction.
+// Cancelling the context stops the ramp at its current speed.
+// The speed and direction before the ramp are recorded in the cab's history and can be restored using Revert.
+func (c *Cab) RampTo(ctx context.Context, target Speed, direction Direction, profile RampProfile) error {
+	if target < 0 {
+		return fmt.Errorf("failed to ramp cab %d: invalid target speed %d", c.address, target)
+	}
+
+	curve := profile.Curve
+	if curve == nil {
+		curve = RampLinear
+	}
+
+	status, err := c.Status(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to ramp cab %d: %w", c.address, err)
+	}
+
+	previousSpeed, previousDirection := status.SpeedDirection()
+	start := signedSpeed(previousSpeed, previousDirection)
+	end := signedSpeed(target, direction)
+
+	steps := profile.Steps
+	if steps <= 0 {
+		steps = max(1, abs(end-start))
+	}
+
+	c.history().push(previousSpeed, previousDirection, c.channel.Clock().Now())
+
+	ticker := c.channel.Clock().NewTicker(max(profile.Duration/time.Duration(steps), time.Nanosecond))
+	defer ticker.Stop()
+
+	currentDirection := previousDirection
+	for step := 1; step <= steps; step++ {
+		select {
+		case <-ticker.C():
+		case <-ctx.Done():
+			return fmt.Errorf("failed to ramp cab %d: %w", c.address, errs.Context(context.Cause(ctx)))
+		}
+
+		value := start + int(math.Round(float64(end-start)*curve(float64(step)/float64(steps))))
+
+		switch {
+		case step == steps:
+			// Always finish at the requested speed and direction, even when stopping.
+			value = end
+			currentDirection = direction
+		case value > 0:
+			currentDirection = DirectionForward
+		case value < 0:
+			currentDirection = DirectionBackward
+		}
+
+		err := c.speed(ctx, Speed(min(abs(value), 127)), currentDirection, false)
+		if err != nil {
+			return fmt.Errorf("failed to ramp cab %d: %w", c.address, err)
+		}
+	}
+
+	return nil
+}
+
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+
+	return value
+}
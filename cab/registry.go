@@ -0,0 +1,49 @@
+package cab
+
+import (
+	"sync"
+)
+
+// Registry keeps the state shared by the cabs of the same address, i.e. their history and throttle configuration.
+// Cabs are derived on demand (e.g. using connection.Connection.Cab), so they share their state using a registry
+// per command station.
+type Registry struct {
+	cabs map[Address]*sharedState
+	lock sync.Mutex
+}
+
+// sharedState is the state shared by the cabs of the same address.
+type sharedState struct {
+	history history
+
+	throttleConfig     ThrottleConfig
+	throttleConfigLock sync.Mutex
+}
+
+// NewRegistry returns a new empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		cabs: make(map[Address]*sharedState),
+	}
+}
+
+// get returns the state of the cabs with the given address.
+func (r *Registry) get(address Address) *sharedState {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	shared, ok := r.cabs[address]
+	if !ok {
+		shared = &sharedState{}
+		r.cabs[address] = shared
+	}
+
+	return shared
+}
+
+// SetRegistry makes the cab share its state with the other cabs of the same address using the registry.
+// Without a registry, every cab has its own state.
+// Set it before using the cab.
+func (c *Cab) SetRegistry(registry *Registry) {
+	c.shared = registry.get(c.address)
+}
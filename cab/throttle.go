@@ -0,0 +1,69 @@
+package cab
+
+import (
+	"context"
+	"math"
+)
+
+// SpeedSteps is the number of speed steps the cab's decoder is configured for.
+type SpeedSteps int
+
+const (
+	SpeedSteps128 SpeedSteps = 128
+	SpeedSteps28  SpeedSteps = 28
+)
+
+// ThrottleCurve maps the throttle's position (0-1) onto the cab's speed (0-1).
+// RampLinear and RampExponential can be used as curves too.
+type ThrottleCurve func(position float64) float64
+
+// ThrottleConfig defines how percentage throttles are converted into the cab's speed.
+type ThrottleConfig struct {
+	// SpeedSteps defaults to SpeedSteps128.
+	SpeedSteps SpeedSteps
+	// Curve defaults to a linear mapping.
+	Curve ThrottleCurve
+}
+
+// SetThrottleConfig sets the cab's throttle configuration used by SpeedPercent.
+func (c *Cab) SetThrottleConfig(config ThrottleConfig) {
+	c.shared.throttleConfigLock.Lock()
+	defer c.shared.throttleConfigLock.Unlock()
+
+	c.shared.throttleConfig = config
+}
+
+// ThrottleConfig returns the cab's throttle configuration.
+func (c *Cab) ThrottleConfig() ThrottleConfig {
+	c.shared.throttleConfigLock.Lock()
+	defer c.shared.throttleConfigLock.Unlock()
+
+	return c.shared.throttleConfig
+}
+
+// Speed converts the throttle's position in percent (0-100) into the cab's speed (0-126).
+// Any position above zero results in at least the lowest speed step.
+// With SpeedSteps28 the speed is quantized to the decoder's 28 steps.
+func (t ThrottleConfig) Speed(percent float64) Speed {
+	position := min(max(percent/100, 0), 1)
+	if t.Curve != nil {
+		position = min(max(t.Curve(position), 0), 1)
+	}
+
+	if position == 0 {
+		return 0
+	}
+
+	if t.SpeedSteps == SpeedSteps28 {
+		step := max(1, math.Round(position*28))
+		return Speed(math.Round(step * 126 / 28))
+	}
+
+	return Speed(max(1, math.Round(position*126)))
+}
+
+// SpeedPercent sets the cab's speed using the throttle's position in percent (0-100) and the given direction.
+// The position is converted using the cab's throttle configuration (see SetThrottleConfig).
+func (c *Cab) SpeedPercent(ctx context.Context, percent float64, direction Direction) error {
+	return c.Speed(ctx, c.ThrottleConfig().Speed(percent), direction)
+}
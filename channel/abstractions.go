@@ -3,64 +3,101 @@ package channel
 import (
 	"context"
 	"fmt"
+	"slices"
 
 	"github.com/roosterfish/dcc-ex-go/command"
+	"github.com/roosterfish/dcc-ex-go/errs"
 	"github.com/roosterfish/dcc-ex-go/protocol"
 )
 
 type ValidateF func(cmd *command.Command) error
 
-func (c *Channel) writeAndReadOpCode(ctx context.Context, cmd *command.Command, o *command.OpCode, f ValidateF) error {
-	sessionF := func(protocol protocol.ReadWriteCloser) error {
-		commandC, cleanupF := protocol.Read()
+func (c *Channel) writeAndReadOpCodes(ctx context.Context, cmd *command.Command, o []command.OpCode, f ValidateF) error {
+	sessionF := func(sessionProtocol protocol.ReadWriteCloser) error {
+		commandC, cleanupF := protocol.ReadWithOptions(sessionProtocol, ownerReadOptions)
 		defer cleanupF()
 
 		// Derive a new control command.
 		controlCommand := command.NewControlCommand(cmd.OpCode(), cmd.Format(), cmd.Parameters()...)
-		err := protocol.Write(controlCommand)
+		err := sessionProtocol.Write(controlCommand)
 		if err != nil {
 			return err
 		}
 
+		c.record(cmd)
+
 		// When sending <X>, the command stations replies with <* Opcode=X params=0 *><X>.
 		describeCommandStr := command.NewCommand(command.OpCodeDescribe, "%s %s %s", "Opcode=X", "params=0", "*").String()
 		describeCommandObserved := false
 
 		for {
 			select {
-			case cmd := <-commandC:
-				if o != nil && cmd.OpCode() == *o {
+			case cmd, ok := <-commandC:
+				if !ok {
+					return ErrClosed
+				}
+
+				// Check for the control command first as its op code might be the one the caller is waiting for.
+				if cmd.String() == describeCommandStr {
+					// About to be done, waiting for <X>.
+					describeCommandObserved = true
+				} else if slices.Contains(o, cmd.OpCode()) {
 					err := f(cmd)
 					if err != nil {
 						return fmt.Errorf("failed to run function: %w", err)
 					}
-				} else if cmd.String() == describeCommandStr {
-					// About to be done, waiting for <X>.
-					describeCommandObserved = true
 				} else if cmd.OpCode() == command.OpCodeFail && describeCommandObserved {
 					// <X> observed, return the session cleanly.
 					return nil
 				}
 			case <-ctx.Done():
-				return ctx.Err()
+				return errs.Context(context.Cause(ctx))
 			}
 		}
 	}
 
+	// Invalid commands are rejected by the protocol before they are written (see command.Validate).
 	// Try to obtain an active session from the passed context.
 	// If present, don't start a new session but reuse the existing one.
-	sessionProtocol, ok := ctx.Value(sessionProtocolCtxKey).(protocol.ReadWriteCloser)
+	var err error
+	sessionProtocol, ok := c.contextSession(ctx)
 	if !ok {
-		return c.Session(sessionF)
+		err = c.sessionContext(ctx, sessionF)
+	} else {
+		err = sessionF(sessionProtocol)
+	}
+
+	if err != nil && c.label != "" {
+		return fmt.Errorf("%s: %w", c.label, err)
 	}
 
-	return sessionF(sessionProtocol)
+	return err
 }
 
 // Write abstracts an underlying write session by writing the given command.
 // It will continue to read commands until the context is cancelled or the control command is observed.
 func (c *Channel) Write(ctx context.Context, cmd *command.Command) error {
-	return c.writeAndReadOpCode(ctx, cmd, nil, nil)
+	return c.writeAndReadOpCodes(ctx, cmd, nil, nil)
+}
+
+// WriteWithAck works like Write but waits for the command station to acknowledge the command with <O>.
+// In case the command station responds with <X> instead, a *CommandFailedError is returned which matches ErrCommandFailed.
+// Use it for commands answered by either <O> or <X>, e.g. definitions of sensors, turnouts and outputs.
+func (c *Channel) WriteWithAck(ctx context.Context, cmd *command.Command) error {
+	acknowledged := false
+	err := c.writeAndReadOpCodes(ctx, cmd, []command.OpCode{command.OpCodeSuccess}, func(cmd *command.Command) error {
+		acknowledged = true
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if !acknowledged {
+		return c.commandFailed(cmd)
+	}
+
+	return nil
 }
 
 // WriteAndReadOpCode abstracts an underlying read/write session by writing the given command and waiting for a response with the given op code.
@@ -68,5 +105,23 @@ func (c *Channel) Write(ctx context.Context, cmd *command.Command) error {
 // It will continue to read commands until the function f returns an error, the context is cancelled or the control command is observed.
 // In case the function f returns an error, those are accumulated and only returned once
 func (c *Channel) WriteAndReadOpCode(ctx context.Context, cmd *command.Command, o command.OpCode, f ValidateF) error {
-	return c.writeAndReadOpCode(ctx, cmd, &o, f)
+	return c.writeAndReadOpCodes(ctx, cmd, []command.OpCode{o}, f)
+}
+
+// WriteAndReadOpCodes works like WriteAndReadOpCode but calls the function f for any of the given op codes.
+// This is helpful if a command causes different kinds of responses (e.g. a result and a diagnostic message).
+func (c *Channel) WriteAndReadOpCodes(ctx context.Context, cmd *command.Command, o []command.OpCode, f ValidateF) error {
+	return c.writeAndReadOpCodes(ctx, cmd, o, f)
+}
+
+// WriteAndReadMultiOpCode works like WriteAndReadOpCode but only calls the function f for responses with the
+// given multi rune op code. This allows telling apart the J-series responses (e.g. <jT> and <jR>).
+func (c *Channel) WriteAndReadMultiOpCode(ctx context.Context, cmd *command.Command, o command.MultiOpCode, f ValidateF) error {
+	return c.writeAndReadOpCodes(ctx, cmd, []command.OpCode{o.OpCode()}, func(cmd *command.Command) error {
+		if cmd.MultiOpCode() != o {
+			return nil
+		}
+
+		return f(cmd)
+	})
 }
@@ -0,0 +1,107 @@
+package channel_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/channel"
+	"github.com/roosterfish/dcc-ex-go/command"
+	"github.com/roosterfish/dcc-ex-go/errs"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+	"github.com/roosterfish/dcc-ex-go/simulator"
+)
+
+func TestWriteWithAck(t *testing.T) {
+	p := protocol.NewProtocol(simulator.NewSimulator(), &protocol.Config{})
+	defer p.Close()
+
+	ch := channel.NewChannel(p)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := ch.WriteWithAck(ctx, command.NewCommand(command.OpCodeSensorCreate, "%d %d %d", 1, 101, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Deleting an unknown sensor is refused with <X>.
+	failing := command.NewCommand(command.OpCodeSensorCreate, "%d", 2)
+	err = ch.WriteWithAck(ctx, failing)
+
+	var failedErr *channel.CommandFailedError
+	if !errors.As(err, &failedErr) || failedErr.Command != failing {
+		t.Fatalf("Expected a failed command but got %v", err)
+	}
+
+	if !errors.Is(err, channel.ErrCommandFailed) || !errors.Is(err, errs.ErrStationFailure) {
+		t.Errorf("Expected %v but got %v", channel.ErrCommandFailed, err)
+	}
+}
+
+func TestSessionContextOtherChannel(t *testing.T) {
+	pA := protocol.NewProtocol(simulator.NewSimulator(), &protocol.Config{})
+	defer pA.Close()
+
+	pB := protocol.NewProtocol(simulator.NewSimulator(), &protocol.Config{})
+	defer pB.Close()
+
+	chA := channel.NewChannel(pA)
+	chB := channel.NewChannel(pB)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// The session of channel A must not be reused by channel B.
+	err := chA.SessionContext(ctx, func(ctx context.Context) error {
+		return chB.SessionContext(ctx, func(ctx context.Context) error {
+			return chB.WriteWithAck(ctx, command.NewCommand(command.OpCodeSensorCreate, "%d %d %d", 1, 101, 1))
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The sensor only exists on B's command station, deleting it on A is refused with <X>.
+	err = chA.WriteWithAck(ctx, command.NewCommand(command.OpCodeSensorCreate, "%d", 1))
+	if !errors.Is(err, channel.ErrCommandFailed) {
+		t.Errorf("Expected %v but got %v", channel.ErrCommandFailed, err)
+	}
+
+	err = chB.WriteWithAck(ctx, command.NewCommand(command.OpCodeSensorCreate, "%d", 1))
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWriteStuckSession(t *testing.T) {
+	p := protocol.NewProtocol(simulator.NewSimulator(), &protocol.Config{})
+	defer p.Close()
+
+	ch := channel.NewChannel(p)
+
+	// Hold the channel like a stuck session.
+	heldC := make(chan struct{})
+	releaseC := make(chan struct{})
+	defer close(releaseC)
+
+	go func() {
+		_ = ch.Session(func(protocol protocol.ReadWriteCloser) error {
+			close(heldC)
+			<-releaseC
+			return nil
+		})
+	}()
+
+	<-heldC
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := ch.Write(ctx, command.NewCommand(command.OpCodeStatus, ""))
+	if !errors.Is(err, ctx.Err()) || !errors.Is(err, errs.ErrTimeout) {
+		t.Errorf("Expected %v but got %v", ctx.Err(), err)
+	}
+}
@@ -2,19 +2,44 @@ package channel
 
 import (
 	"context"
-	"sync"
+	"fmt"
+	"log/slog"
+	"time"
 
+	"github.com/roosterfish/dcc-ex-go/clock"
 	"github.com/roosterfish/dcc-ex-go/command"
 	"github.com/roosterfish/dcc-ex-go/protocol"
 )
 
 const sessionProtocolCtxKey = "session-protocol"
 
+// ErrClosed is returned by the channel abstraction functions once the underlying protocol got closed.
+var ErrClosed = protocol.ErrClosed
+
+// ownerReadOptions are used by the abstraction functions to own the responses to their commands.
+// Concurrent read sessions don't observe them.
+var ownerReadOptions = &protocol.ReadOptions{
+	Ownership: protocol.OwnershipOwner,
+}
+
 type WriteF func(ctx context.Context, command *command.Command) error
 
 type Channel struct {
-	protocol    protocol.ReadWriteCloser
-	sessionLock sync.Mutex
+	protocol protocol.ReadWriteCloser
+	label    string
+	clock    clock.Clock
+	logger   *slog.Logger
+	watchdog *watchdog
+	metrics  protocol.Metrics
+	// sessionQueue serves the sessions in order and allows giving up on acquiring them (see SessionWithContext).
+	sessionQueue sessionQueue
+	history      history
+	preemption   preemption
+	// sessionStart is the time the current session acquired the channel.
+	sessionStart time.Time
+
+	// byteDelay is the per byte serialization delay used by WriteSynchronized.
+	byteDelay time.Duration
 }
 
 // NewChannel returns a new channel using the given protocol.
@@ -24,6 +49,36 @@ func NewChannel(protocol protocol.ReadWriteCloser) *Channel {
 	}
 }
 
+// SetLabel sets a label identifying the channel's command station.
+// Errors returned by the channel abstraction functions are prefixed with the label.
+// Set it before using the channel.
+func (c *Channel) SetLabel(label string) {
+	c.label = label
+}
+
+// Label returns the label identifying the channel's command station.
+func (c *Channel) Label() string {
+	return c.label
+}
+
+// SetClock sets the source of time used by the channel and all of the entities derived from it.
+// Set it before using the channel.
+func (c *Channel) SetClock(clock clock.Clock) {
+	c.clock = clock
+}
+
+// Clock returns the source of time used by the channel.
+// It defaults to the real time.
+func (c *Channel) Clock() clock.Clock {
+	return clock.Or(c.clock)
+}
+
+// SetLogger sets the logger used to log the start and end of every session at debug level.
+// Set it before using the channel.
+func (c *Channel) SetLogger(logger *slog.Logger) {
+	c.logger = logger
+}
+
 // Consider using the channel abstraction functions instead as those perform additional control command handling to gate
 // the beginning and end of a session and can ensure that no response is leaked into follow-up sessions.
 //
@@ -32,8 +87,8 @@ func NewChannel(protocol protocol.ReadWriteCloser) *Channel {
 // Session is thread safe and allows exclusive read and write from and to the channel.
 // There can be other read sessions in parallel.
 func (c *Channel) Session(sessionF func(protocol protocol.ReadWriteCloser) error) error {
-	c.sessionLock.Lock()
-	defer c.sessionLock.Unlock()
+	c.lock()
+	defer c.unlock()
 
 	return sessionF(c.protocol)
 }
@@ -44,17 +99,52 @@ func (c *Channel) Session(sessionF func(protocol protocol.ReadWriteCloser) error
 // from the passed context.
 // With this, atomic operations can be implemented which first require reading some content and then performing
 // an action based on the read values.
+// In case the passed context already contains a session of this channel, it is reused.
 func (c *Channel) SessionContext(ctx context.Context, f func(ctx context.Context) error) error {
-	c.sessionLock.Lock()
-	defer c.sessionLock.Unlock()
+	_, ok := c.contextSession(ctx)
+	if ok {
+		return f(ctx)
+	}
+
+	err := c.lockContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire session: %w", err)
+	}
+
+	defer c.unlock()
 
 	ctx = context.WithValue(ctx, sessionProtocolCtxKey, c.protocol)
-	ctx, cancel := context.WithCancel(ctx)
-	defer cancel()
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	defer c.preemption.set(cancel)()
 
 	return f(ctx)
 }
 
+// sessionContext works like Session but gives up waiting for the session once the context is done.
+// It's used by the channel abstraction functions so that a stuck session cannot block their callers forever.
+func (c *Channel) sessionContext(ctx context.Context, sessionF func(protocol protocol.ReadWriteCloser) error) error {
+	err := c.lockContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire session: %w", err)
+	}
+
+	defer c.unlock()
+
+	return sessionF(c.protocol)
+}
+
+// contextSession returns the protocol of the session contained in the given context.
+// Sessions of other channels (e.g. a second command station) are ignored as they don't hold this channel's lock.
+func (c *Channel) contextSession(ctx context.Context) (protocol.ReadWriteCloser, bool) {
+	sessionProtocol, ok := ctx.Value(sessionProtocolCtxKey).(protocol.ReadWriteCloser)
+	if !ok || sessionProtocol != c.protocol {
+		return nil, false
+	}
+
+	return sessionProtocol, true
+}
+
 // RSession allows having a short-term read-only session on the connection's channel to interact with the underlying protocol.
 // Unlike Session it only allows reading.
 // It allows multiple concurrent reader sessions independent whether or not there is an active read and write session.
@@ -0,0 +1,217 @@
+package channel
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/roosterfish/dcc-ex-go/command"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+)
+
+// EventType identifies the kind of a typed broadcast.
+type EventType uint8
+
+const (
+	EventSensorChanged EventType = iota + 1
+	EventTurnoutChanged
+	EventPowerChanged
+	EventCabUpdate
+)
+
+// opCodes returns the op codes of the broadcasts the event is decoded from.
+func (t EventType) opCodes() []command.OpCode {
+	switch t {
+	case EventSensorChanged:
+		return []command.OpCode{command.OpCodeSensorActive, command.OpCodeSensorInactive}
+	case EventTurnoutChanged:
+		return []command.OpCode{command.OpCodeTurnoutResponse}
+	case EventPowerChanged:
+		return []command.OpCode{command.OpCodePower}
+	case EventCabUpdate:
+		return []command.OpCode{command.OpCodeCabResponse}
+	}
+
+	return nil
+}
+
+// Event is a broadcast of the command station decoded into one of the typed events below.
+type Event interface {
+	Type() EventType
+}
+
+// EventC receives the typed broadcasts of the command station.
+type EventC chan Event
+
+// SensorChanged is decoded from <Q id> and <q id>.
+type SensorChanged struct {
+	ID     uint16
+	Active bool
+}
+
+// TurnoutChanged is decoded from <H id state>.
+type TurnoutChanged struct {
+	ID     uint16
+	Thrown bool
+}
+
+// PowerChanged is decoded from <p state> and <p state track>.
+// The track is empty in case the power of all tracks changed.
+type PowerChanged struct {
+	On    bool
+	Track string
+}
+
+// CabUpdate is decoded from <l cab reg speedByte functMap>.
+// Use cab.CabStatus to decode the speed byte into speed and direction.
+type CabUpdate struct {
+	Address   uint16
+	SpeedByte uint8
+	FunctMap  uint32
+}
+
+func (SensorChanged) Type() EventType {
+	return EventSensorChanged
+}
+
+func (TurnoutChanged) Type() EventType {
+	return EventTurnoutChanged
+}
+
+func (PowerChanged) Type() EventType {
+	return EventPowerChanged
+}
+
+func (CabUpdate) Type() EventType {
+	return EventCabUpdate
+}
+
+// decodeEvent decodes the broadcast into its typed event.
+// It returns nil for commands which aren't state changes, e.g. the <Q id vpin pullup> responses listing the sensors.
+func decodeEvent(cmd *command.Command) (Event, error) {
+	params, err := cmd.ParametersStrings()
+	if err != nil {
+		return nil, err
+	}
+
+	switch cmd.OpCode() {
+	case command.OpCodeSensorActive, command.OpCodeSensorInactive:
+		if len(params) != 1 {
+			return nil, nil
+		}
+
+		id, err := strconv.ParseUint(params[0], 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sensor ID %q: %w", params[0], err)
+		}
+
+		return SensorChanged{ID: uint16(id), Active: cmd.OpCode() == command.OpCodeSensorActive}, nil
+	case command.OpCodeTurnoutResponse:
+		if len(params) != 2 {
+			return nil, nil
+		}
+
+		id, err := strconv.ParseUint(params[0], 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid turnout ID %q: %w", params[0], err)
+		}
+
+		return TurnoutChanged{ID: uint16(id), Thrown: params[1] == "1"}, nil
+	case command.OpCodePower:
+		if len(params) == 0 || len(params) > 2 {
+			return nil, nil
+		}
+
+		event := PowerChanged{On: params[0] == "1"}
+		if len(params) == 2 {
+			event.Track = params[1]
+		}
+
+		return event, nil
+	case command.OpCodeCabResponse:
+		if len(params) != 4 {
+			return nil, nil
+		}
+
+		address, err := strconv.ParseUint(params[0], 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cab address %q: %w", params[0], err)
+		}
+
+		speedByte, err := strconv.ParseUint(params[2], 10, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid speed byte %q: %w", params[2], err)
+		}
+
+		functMap, err := strconv.ParseUint(params[3], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid funct map %q: %w", params[3], err)
+		}
+
+		return CabUpdate{Address: uint16(address), SpeedByte: uint8(speedByte), FunctMap: uint32(functMap)}, nil
+	}
+
+	return nil, nil
+}
+
+// Events returns a channel which receives the command station's broadcasts decoded into typed events.
+// Only the events of the given types are sent, all of them if no type is given.
+// Broadcasts which cannot be decoded are skipped.
+// Never close the channel manually but instead call the cleanup function.
+func (c *Channel) Events(types ...EventType) (EventC, protocol.CleanupF) {
+	if len(types) == 0 {
+		types = []EventType{EventSensorChanged, EventTurnoutChanged, EventPowerChanged, EventCabUpdate}
+	}
+
+	opCodes := []command.OpCode{}
+	for _, t := range types {
+		opCodes = append(opCodes, t.opCodes()...)
+	}
+
+	var commandC protocol.CommandC
+	var cleanupF protocol.CleanupF
+
+	_ = c.RSession(func(sessionProtocol protocol.Reader) error {
+		commandC, cleanupF = protocol.ReadFiltered(sessionProtocol, opCodes...)
+		return nil
+	})
+
+	eventC := make(EventC)
+	doneC := make(chan struct{})
+	wg := sync.WaitGroup{}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		for {
+			select {
+			case cmd, ok := <-commandC:
+				if !ok {
+					return
+				}
+
+				event, err := decodeEvent(cmd)
+				if err != nil || event == nil || !slices.Contains(types, event.Type()) {
+					continue
+				}
+
+				select {
+				case eventC <- event:
+				case <-doneC:
+					return
+				}
+			case <-doneC:
+				return
+			}
+		}
+	}()
+
+	return eventC, sync.OnceFunc(func() {
+		close(doneC)
+		wg.Wait()
+		cleanupF()
+		close(eventC)
+	})
+}
@@ -0,0 +1,67 @@
+package channel_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/channel"
+	"github.com/roosterfish/dcc-ex-go/command"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+	"github.com/roosterfish/dcc-ex-go/sensor"
+	"github.com/roosterfish/dcc-ex-go/simulator"
+)
+
+func TestEvents(t *testing.T) {
+	sim := simulator.NewSimulator()
+
+	p := protocol.NewProtocol(sim, &protocol.Config{})
+	defer p.Close()
+
+	ch := channel.NewChannel(p)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	eventC, cleanupF := ch.Events(channel.EventSensorChanged, channel.EventCabUpdate)
+	defer cleanupF()
+
+	// Neither the power broadcast nor the sensor listing are sent.
+	err := ch.Write(ctx, command.NewCommand(command.OpCodePowerOn, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ch.Write(ctx, command.NewCommand(command.OpCodeSensorCreate, "%d %d %d", 7, 7, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ch.Write(ctx, command.NewCommand(command.OpCodeSensorCreate, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sim.SetSensorState(5, sensor.StateActive)
+
+	err = ch.Write(ctx, command.NewCommand(command.OpCodeCabSpeed, "%d %d %d", 3, 50, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []channel.Event{
+		channel.SensorChanged{ID: 5, Active: true},
+		channel.CabUpdate{Address: 3, SpeedByte: 179},
+	}
+
+	for _, expectedEvent := range expected {
+		select {
+		case event := <-eventC:
+			if event != expectedEvent {
+				t.Errorf("Expected %+v but got %+v", expectedEvent, event)
+			}
+		case <-ctx.Done():
+			t.Fatalf("Expected %+v", expectedEvent)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package channel
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/command"
+)
+
+// DefaultHistorySize is the default number of written commands remembered by a channel.
+const DefaultHistorySize = 8
+
+// HistoryEntry is a command written using one of the channel abstraction functions.
+type HistoryEntry struct {
+	Time    time.Time
+	Command *command.Command
+}
+
+func (e HistoryEntry) String() string {
+	return fmt.Sprintf("%s %s", e.Time.Format(time.TimeOnly), e.Command.String())
+}
+
+// history is a ring buffer of the recently written commands.
+type history struct {
+	lock    sync.Mutex
+	size    int
+	entries []HistoryEntry
+	next    int
+}
+
+func (h *history) add(entry HistoryEntry) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	size := h.size
+	if size == 0 {
+		size = DefaultHistorySize
+	}
+
+	if len(h.entries) < size {
+		h.entries = append(h.entries, entry)
+		return
+	}
+
+	h.entries[h.next] = entry
+	h.next = (h.next + 1) % size
+}
+
+// list returns the entries ordered from the oldest to the most recent one.
+func (h *history) list() []HistoryEntry {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	entries := make([]HistoryEntry, 0, len(h.entries))
+	entries = append(entries, h.entries[h.next:]...)
+	return append(entries, h.entries[:h.next]...)
+}
+
+// CommandFailedError is returned if the command station responded with <X>.
+// As <X> doesn't reference the command it's caused by, the error carries the commands written recently.
+// Within a session of multiple writes the <X> might have been caused by an earlier command.
+// It matches ErrCommandFailed.
+type CommandFailedError struct {
+	Command *command.Command
+	// Recent are the commands written recently ordered from the oldest to the most recent one.
+	Recent []HistoryEntry
+}
+
+func (e *CommandFailedError) Error() string {
+	recent := make([]string, 0, len(e.Recent))
+	for _, entry := range e.Recent {
+		recent = append(recent, entry.String())
+	}
+
+	return fmt.Sprintf("%q: %v (recent commands: %s)", e.Command.String(), ErrCommandFailed, strings.Join(recent, ", "))
+}
+
+func (e *CommandFailedError) Unwrap() error {
+	return ErrCommandFailed
+}
+
+// SetHistorySize sets the number of written commands remembered by the channel.
+// It defaults to DefaultHistorySize.
+// Set it before using the channel.
+func (c *Channel) SetHistorySize(size int) {
+	c.history.size = size
+}
+
+// History returns the commands recently written using the channel abstraction functions
+// ordered from the oldest to the most recent one.
+func (c *Channel) History() []HistoryEntry {
+	return c.history.list()
+}
+
+// record adds the command to the channel's history.
+func (c *Channel) record(cmd *command.Command) {
+	c.history.add(HistoryEntry{
+		Time:    c.Clock().Now(),
+		Command: cmd,
+	})
+}
+
+// commandFailed returns the error for a command the command station responded to with <X>.
+func (c *Channel) commandFailed(cmd *command.Command) error {
+	return &CommandFailedError{
+		Command: cmd,
+		Recent:  c.History(),
+	}
+}
@@ -0,0 +1,53 @@
+package channel_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/channel"
+	"github.com/roosterfish/dcc-ex-go/command"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+	"github.com/roosterfish/dcc-ex-go/simulator"
+)
+
+func TestHistory(t *testing.T) {
+	p := protocol.NewProtocol(simulator.NewSimulator(), &protocol.Config{})
+	defer p.Close()
+
+	ch := channel.NewChannel(p)
+	ch.SetHistorySize(3)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for id := range 4 {
+		err := ch.Write(ctx, command.NewCommand(command.OpCodeSensorCreate, "%d %d %d", id, id+100, 1))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Deleting an unknown sensor fails.
+	results, err := ch.Pipeline(ctx, []*command.Command{command.NewCommand(command.OpCodeSensorCreate, "%d", 999)}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var failedErr *channel.CommandFailedError
+	if !errors.As(results[0], &failedErr) || !errors.Is(results[0], channel.ErrCommandFailed) {
+		t.Fatalf("Expected a failed command but got %v", results[0])
+	}
+
+	expected := []string{"<S 2 102 1>", "<S 3 103 1>", "<S 999>"}
+	if len(failedErr.Recent) != len(expected) {
+		t.Fatalf("Expected %d recent commands but got %d", len(expected), len(failedErr.Recent))
+	}
+
+	for i, entry := range failedErr.Recent {
+		if entry.Command.String() != expected[i] || entry.Time.IsZero() {
+			t.Errorf("Expected recent command %q but got %v", expected[i], entry)
+		}
+	}
+}
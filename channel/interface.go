@@ -0,0 +1,26 @@
+package channel
+
+import (
+	"context"
+
+	"github.com/roosterfish/dcc-ex-go/clock"
+	"github.com/roosterfish/dcc-ex-go/command"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+)
+
+// Interface is the subset of Channel used by the entity packages (e.g. sensor, cab and turnout).
+// Accepting it instead of *Channel allows testing the entities without a command station (see channeltest).
+type Interface interface {
+	Session(sessionF func(protocol protocol.ReadWriteCloser) error) error
+	SessionContext(ctx context.Context, f func(ctx context.Context) error) error
+	RSession(sessionF func(protocol protocol.Reader) error) error
+	Write(ctx context.Context, cmd *command.Command) error
+	WriteWithAck(ctx context.Context, cmd *command.Command) error
+	WriteAndReadOpCode(ctx context.Context, cmd *command.Command, o command.OpCode, f ValidateF) error
+	WriteAndReadOpCodes(ctx context.Context, cmd *command.Command, o []command.OpCode, f ValidateF) error
+	WriteAndReadMultiOpCode(ctx context.Context, cmd *command.Command, o command.MultiOpCode, f ValidateF) error
+	WriteSafety(cmd *command.Command) error
+	Clock() clock.Clock
+}
+
+var _ Interface = &Channel{}
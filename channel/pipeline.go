@@ -0,0 +1,138 @@
+package channel
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/roosterfish/dcc-ex-go/command"
+	"github.com/roosterfish/dcc-ex-go/errs"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+)
+
+// ErrCommandFailed is returned for pipelined commands to which the command station responded with <X>.
+var ErrCommandFailed = errs.New("command failed", errs.ErrStationFailure)
+
+// DefaultPipelineWindow is the default number of pipelined commands written without having received their response.
+// It's chosen to not overflow the command station's serial buffer with typical definition commands.
+const DefaultPipelineWindow = 4
+
+// Pipeline writes the given commands without waiting for each of them to be answered individually.
+// It is meant for bursts of commands (e.g. sensor definitions) which are answered with either <O> or <X>.
+// The responses are correlated with the commands positionally.
+// At most window commands are written without having received their response.
+// The returned slice contains a nil error for every successful command and a *CommandFailedError for every failed one.
+// The returned error is only set in case the pipeline itself failed (e.g. the context is cancelled).
+func (c *Channel) Pipeline(ctx context.Context, cmds []*command.Command, window int) ([]error, error) {
+	if window <= 0 {
+		window = DefaultPipelineWindow
+	}
+
+	results := make([]error, len(cmds))
+
+	sessionF := func(sessionProtocol protocol.ReadWriteCloser) error {
+		commandC, cleanupF := protocol.ReadWithOptions(sessionProtocol, ownerReadOptions)
+		defer cleanupF()
+
+		written := 0
+		answered := 0
+		fenceWritten := false
+
+		// writeF writes as many commands as the window allows.
+		// Once all of the commands are written, a single <X> is written to fence the end of the pipeline.
+		writeF := func() error {
+			for written < len(cmds) && written-answered < window {
+				err := sessionProtocol.Write(cmds[written])
+				if err != nil {
+					return err
+				}
+
+				c.record(cmds[written])
+
+				written++
+			}
+
+			if written == len(cmds) && !fenceWritten {
+				err := sessionProtocol.Write(command.NewCommand(command.OpCodeFail, ""))
+				if err != nil {
+					return err
+				}
+
+				fenceWritten = true
+			}
+
+			return nil
+		}
+
+		err := writeF()
+		if err != nil {
+			return err
+		}
+
+		// When sending <X>, the command stations replies with <* Opcode=X params=0 *><X>.
+		describeCommandStr := command.NewCommand(command.OpCodeDescribe, "%s %s %s", "Opcode=X", "params=0", "*").String()
+		describeCommandObserved := false
+
+		for {
+			select {
+			case cmd, ok := <-commandC:
+				if !ok {
+					return ErrClosed
+				}
+
+				if cmd.String() == describeCommandStr {
+					// About to be done, waiting for <X>.
+					describeCommandObserved = true
+					continue
+				}
+
+				if cmd.OpCode() != command.OpCodeSuccess && cmd.OpCode() != command.OpCodeFail {
+					continue
+				}
+
+				if describeCommandObserved && cmd.OpCode() == command.OpCodeFail {
+					// <X> observed, return the session cleanly.
+					if answered < len(cmds) {
+						return fmt.Errorf("missing responses for %d of %d commands", len(cmds)-answered, len(cmds))
+					}
+
+					return nil
+				}
+
+				if answered < len(cmds) {
+					if cmd.OpCode() == command.OpCodeFail {
+						results[answered] = c.commandFailed(cmds[answered])
+					}
+
+					answered++
+
+					err := writeF()
+					if err != nil {
+						return err
+					}
+				}
+			case <-ctx.Done():
+				return errs.Context(context.Cause(ctx))
+			}
+		}
+	}
+
+	// Try to obtain an active session from the passed context.
+	// If present, don't start a new session but reuse the existing one.
+	var err error
+	sessionProtocol, ok := c.contextSession(ctx)
+	if !ok {
+		err = c.sessionContext(ctx, sessionF)
+	} else {
+		err = sessionF(sessionProtocol)
+	}
+
+	if err != nil {
+		if c.label != "" {
+			return nil, fmt.Errorf("%s: %w", c.label, err)
+		}
+
+		return nil, err
+	}
+
+	return results, nil
+}
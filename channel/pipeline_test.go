@@ -0,0 +1,55 @@
+package channel_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/channel"
+	"github.com/roosterfish/dcc-ex-go/command"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+	"github.com/roosterfish/dcc-ex-go/simulator"
+)
+
+func TestPipeline(t *testing.T) {
+	p := protocol.NewProtocol(simulator.NewSimulator(), &protocol.Config{})
+	defer p.Close()
+
+	ch := channel.NewChannel(p)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cmds := []*command.Command{}
+	for id := range 40 {
+		cmds = append(cmds, command.NewCommand(command.OpCodeSensorCreate, "%d %d %d", id, id+100, 1))
+	}
+
+	// Deleting an unknown sensor fails.
+	cmds = append(cmds, command.NewCommand(command.OpCodeSensorCreate, "%d", 999))
+	cmds = append(cmds, command.NewCommand(command.OpCodeSensorCreate, "%d", 0))
+
+	results, err := ch.Pipeline(ctx, cmds, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != len(cmds) {
+		t.Fatalf("Expected %d results but got %d", len(cmds), len(results))
+	}
+
+	for i, result := range results {
+		if i == 40 {
+			if !errors.Is(result, channel.ErrCommandFailed) {
+				t.Errorf("Expected command %d to fail but got %v", i, result)
+			}
+
+			continue
+		}
+
+		if result != nil {
+			t.Errorf("Expected command %d to succeed but got %v", i, result)
+		}
+	}
+}
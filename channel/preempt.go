@@ -0,0 +1,92 @@
+package channel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/roosterfish/dcc-ex-go/command"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+)
+
+// ErrPreempted is the cause of the cancelled context of a session interrupted by a safety command (see Preempt).
+var ErrPreempted = errors.New("session preempted by safety command")
+
+// preemption holds the cancel function of the session currently holding the channel.
+type preemption struct {
+	lock    sync.Mutex
+	cancelF context.CancelCauseFunc
+	// generation identifies the registered session.
+	// Abandoned sessions (see SessionWithContext) might end after the next session registered itself.
+	generation uint64
+}
+
+// set registers the cancel function of the session which acquired the channel.
+// The returned function unregisters it once the session ends.
+func (p *preemption) set(cancelF context.CancelCauseFunc) func() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.generation++
+	p.cancelF = cancelF
+	generation := p.generation
+
+	return func() {
+		p.lock.Lock()
+		defer p.lock.Unlock()
+
+		if p.generation == generation {
+			p.cancelF = nil
+		}
+	}
+}
+
+// interrupt cancels the context of the session currently holding the channel.
+func (p *preemption) interrupt() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.cancelF != nil {
+		p.cancelF(ErrPreempted)
+	}
+}
+
+// IsSafety returns true for the safety commands which are allowed to bypass the sessions:
+// emergency stops (<!> or speed -1) and powering off (<0>).
+func IsSafety(cmd *command.Command) bool {
+	return protocol.PriorityOf(cmd) == protocol.PriorityEmergency
+}
+
+// WriteSafety writes the safety command right away instead of queueing behind the session currently holding
+// the channel (e.g. a stuck Examine call). It's written with protocol.PriorityEmergency so it also jumps ahead of
+// the queued writes. The command is only written, its response isn't awaited.
+// Commands which aren't safety commands (see IsSafety) are refused.
+func (c *Channel) WriteSafety(cmd *command.Command) error {
+	if !IsSafety(cmd) {
+		return fmt.Errorf("failed to write %q: not a safety command", cmd.String())
+	}
+
+	err := c.protocol.Write(cmd)
+	if err != nil {
+		return fmt.Errorf("failed to write safety command %q: %w", cmd.String(), err)
+	}
+
+	c.record(cmd)
+
+	return nil
+}
+
+// Preempt works like WriteSafety but additionally interrupts the session currently holding the channel.
+// The context of the session is cancelled with ErrPreempted as its cause. This only applies to sessions started
+// using SessionContext or SessionWithContext, other sessions keep running.
+func (c *Channel) Preempt(cmd *command.Command) error {
+	err := c.WriteSafety(cmd)
+	if err != nil {
+		return err
+	}
+
+	c.preemption.interrupt()
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package channel_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/channel"
+	"github.com/roosterfish/dcc-ex-go/command"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+	"github.com/roosterfish/dcc-ex-go/simulator"
+)
+
+func TestPreempt(t *testing.T) {
+	sim := simulator.NewSimulator()
+
+	p := protocol.NewProtocol(sim, &protocol.Config{})
+	defer p.Close()
+
+	ch := channel.NewChannel(p)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := ch.WriteSafety(command.NewCommand(command.OpCodeStatus, ""))
+	if err == nil {
+		t.Error("Expected status command to be refused")
+	}
+
+	// The stuck session never observes the end of its command.
+	sim.InjectFault(simulator.FaultUnresponsive)
+
+	startedC := make(chan struct{})
+	errC := make(chan error, 1)
+	go func() {
+		errC <- ch.SessionContext(ctx, func(ctx context.Context) error {
+			close(startedC)
+			return ch.Write(ctx, command.NewCommand(command.OpCodeStatus, ""))
+		})
+	}()
+
+	<-startedC
+
+	err = ch.Preempt(command.NewCommand(command.OpCodeEmergencyStop, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-errC:
+		if !errors.Is(err, channel.ErrPreempted) {
+			t.Errorf("Expected %v but got %v", channel.ErrPreempted, err)
+		}
+	case <-ctx.Done():
+		t.Fatal("Expected the session to be interrupted")
+	}
+
+	history := ch.History()
+	if !slices.ContainsFunc(history, func(entry channel.HistoryEntry) bool {
+		return entry.Command.OpCode() == command.OpCodeEmergencyStop
+	}) {
+		t.Errorf("Expected the emergency stop to be written but got %v", history)
+	}
+}
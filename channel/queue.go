@@ -0,0 +1,104 @@
+package channel
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/roosterfish/dcc-ex-go/errs"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+)
+
+type sessionTicket struct {
+	readyC chan struct{}
+}
+
+// sessionQueue hands out the session in the order it got requested.
+// Unlike a mutex it guarantees that waiting sessions are served first come, first served.
+type sessionQueue struct {
+	lock    sync.Mutex
+	busy    bool
+	waiting []*sessionTicket
+}
+
+// acquire blocks until it's the caller's turn or the context is done.
+// In case the caller has to wait, queuedF is called after it got queued.
+func (q *sessionQueue) acquire(ctx context.Context, queuedF func()) error {
+	q.lock.Lock()
+	if !q.busy {
+		q.busy = true
+		q.lock.Unlock()
+		return nil
+	}
+
+	ticket := &sessionTicket{
+		readyC: make(chan struct{}),
+	}
+
+	q.waiting = append(q.waiting, ticket)
+	q.lock.Unlock()
+
+	queuedF()
+
+	select {
+	case <-ticket.readyC:
+		return nil
+	case <-ctx.Done():
+		q.lock.Lock()
+		i := slices.Index(q.waiting, ticket)
+		if i >= 0 {
+			q.waiting = slices.Delete(q.waiting, i, i+1)
+		}
+
+		q.lock.Unlock()
+
+		// The session got handed over in the meantime, pass it on to the next one in line.
+		if i < 0 {
+			q.release()
+		}
+
+		return errs.Context(context.Cause(ctx))
+	}
+}
+
+// release hands the session over to the longest waiting caller.
+func (q *sessionQueue) release() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if len(q.waiting) == 0 {
+		q.busy = false
+		return
+	}
+
+	ticket := q.waiting[0]
+	q.waiting = slices.Delete(q.waiting, 0, 1)
+	close(ticket.readyC)
+}
+
+// depth returns the number of callers waiting for the session.
+func (q *sessionQueue) depth() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	return len(q.waiting)
+}
+
+// SetMetrics sets the receiver of the session metrics: the number of sessions waiting
+// (protocol.GaugeSessionQueueDepth) and the time spent waiting for a session (protocol.HistogramSessionWait).
+// Set it before using the channel.
+func (c *Channel) SetMetrics(metrics protocol.Metrics) {
+	c.metrics = metrics
+}
+
+// QueueDepth returns the number of sessions waiting for the channel.
+func (c *Channel) QueueDepth() int {
+	return c.sessionQueue.depth()
+}
+
+// setQueueDepth updates the gauge of waiting sessions in case metrics are configured.
+func (c *Channel) setQueueDepth() {
+	if c.metrics != nil {
+		c.metrics.Set(protocol.GaugeSessionQueueDepth, float64(c.sessionQueue.depth()))
+	}
+}
@@ -0,0 +1,101 @@
+package channel
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/protocol"
+)
+
+type queueMetrics struct {
+	lock     sync.Mutex
+	maxDepth float64
+	waits    int
+}
+
+func (m *queueMetrics) Inc(counter protocol.Counter) {}
+
+func (m *queueMetrics) Set(gauge protocol.Gauge, value float64) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if gauge == protocol.GaugeSessionQueueDepth {
+		m.maxDepth = max(m.maxDepth, value)
+	}
+}
+
+func (m *queueMetrics) Observe(histogram protocol.Histogram, d time.Duration) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if histogram == protocol.HistogramSessionWait {
+		m.waits++
+	}
+}
+
+func waitForQueueDepth(t *testing.T, c *Channel, depth int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for c.QueueDepth() != depth {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for queue depth %d", depth)
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSessionQueueOrder(t *testing.T) {
+	c := NewChannel(nil)
+	metrics := &queueMetrics{}
+	c.SetMetrics(metrics)
+
+	c.lock()
+
+	orderLock := sync.Mutex{}
+	order := []int{}
+	wg := sync.WaitGroup{}
+
+	// The second session gives up while waiting and must not block the ones queued after it.
+	ctx, cancel := context.WithCancel(context.Background())
+
+	for i := range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			sessionCtx := context.Background()
+			if i == 1 {
+				sessionCtx = ctx
+			}
+
+			_ = c.SessionWithContext(sessionCtx, func(ctx context.Context, protocol protocol.ReadWriteCloser) error {
+				orderLock.Lock()
+				defer orderLock.Unlock()
+
+				order = append(order, i)
+				return nil
+			})
+		}()
+
+		waitForQueueDepth(t, c, i+1)
+	}
+
+	cancel()
+	waitForQueueDepth(t, c, 4)
+
+	c.unlock()
+	wg.Wait()
+
+	if !slices.Equal(order, []int{0, 2, 3, 4}) {
+		t.Errorf("Expected sessions to be served in order but got %v", order)
+	}
+
+	if c.QueueDepth() != 0 || metrics.maxDepth != 5 || metrics.waits != 5 {
+		t.Errorf("Unexpected queue metrics: depth %d, max depth %v, waits %d", c.QueueDepth(), metrics.maxDepth, metrics.waits)
+	}
+}
@@ -0,0 +1,170 @@
+package channel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/command"
+	"github.com/roosterfish/dcc-ex-go/errs"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+)
+
+// DefaultByteDelay is the serialization delay of a single byte on a serial link using 115200 baud (8N1).
+const DefaultByteDelay = 10 * time.Second / 115200
+
+// SyncReport contains the measurements of a synchronized write.
+type SyncReport struct {
+	// Started is the time the first command was written.
+	Started time.Time
+	// Durations contains the time it took to hand each of the commands to the protocol in the order they were passed.
+	// The protocol buffers the writes, so it doesn't reflect the transmission on the link.
+	Durations []time.Duration
+	// Offsets contains the estimated point in time relative to Started at which each command got executed
+	// in the order they were passed.
+	Offsets []time.Duration
+	// Spread is the estimated duration between the execution of the first and the last command.
+	Spread time.Duration
+	// ByteDelay is the per byte serialization delay used for the estimations (see SetByteDelay).
+	ByteDelay time.Duration
+}
+
+// SetByteDelay sets the per byte serialization delay of the link used by WriteSynchronized.
+// It defaults to DefaultByteDelay. Set it before using the channel.
+func (c *Channel) SetByteDelay(delay time.Duration) {
+	c.byteDelay = delay
+}
+
+// ByteDelay returns the per byte serialization delay of the link used by WriteSynchronized.
+func (c *Channel) ByteDelay() time.Duration {
+	if c.byteDelay == 0 {
+		return DefaultByteDelay
+	}
+
+	return c.byteDelay
+}
+
+// WriteSynchronized writes the given commands back-to-back so that they get executed by the command station as
+// simultaneously as the link allows (e.g. a sound function together with a light output).
+// The command station executes each command once it got received completely, so the commands are written
+// starting with the longest one to reduce the spread between the first and the last execution.
+// In case at is set, writing starts early by the estimated serialization delay so that the commands get executed
+// centered around at.
+// The serialization delay can't be observed as the protocol buffers the writes, set it using SetByteDelay in case the
+// link isn't a serial one using 115200 baud.
+func (c *Channel) WriteSynchronized(ctx context.Context, at time.Time, cmds ...*command.Command) (*SyncReport, error) {
+	if len(cmds) == 0 {
+		return nil, errors.New("failed to write synchronized: no commands")
+	}
+
+	byteDelay := c.ByteDelay()
+	order := make([]int, len(cmds))
+	for i := range order {
+		order[i] = i
+	}
+
+	slices.SortStableFunc(order, func(a int, b int) int {
+		return len(cmds[b].Bytes()) - len(cmds[a].Bytes())
+	})
+
+	report := &SyncReport{
+		Durations: make([]time.Duration, len(cmds)),
+		Offsets:   make([]time.Duration, len(cmds)),
+		ByteDelay: byteDelay,
+	}
+
+	sessionF := func(sessionProtocol protocol.ReadWriteCloser) error {
+		commandC, cleanupF := protocol.ReadWithOptions(sessionProtocol, ownerReadOptions)
+		defer cleanupF()
+
+		if !at.IsZero() {
+			// Execution of the first command happens after it got serialized completely.
+			// Center the executions around at.
+			lead := time.Duration(len(cmds[order[0]].Bytes())) * byteDelay
+			for _, i := range order[1:] {
+				lead += time.Duration(len(cmds[i].Bytes())) * byteDelay / 2
+			}
+
+			timer := c.Clock().NewTimer(c.Clock().Until(at.Add(-lead)))
+			defer timer.Stop()
+
+			select {
+			case <-timer.C():
+			case <-ctx.Done():
+				return errs.Context(context.Cause(ctx))
+			}
+		}
+
+		report.Started = c.Clock().Now()
+
+		for _, i := range order {
+			start := c.Clock().Now()
+			err := sessionProtocol.Write(cmds[i])
+			if err != nil {
+				return err
+			}
+
+			report.Durations[i] = c.Clock().Since(start)
+		}
+
+		// Estimate when each of the commands got executed on the command station.
+		offset := time.Duration(0)
+		for _, i := range order {
+			offset += time.Duration(len(cmds[i].Bytes())) * byteDelay
+			report.Offsets[i] = offset
+		}
+
+		report.Spread = report.Offsets[order[len(order)-1]] - report.Offsets[order[0]]
+
+		// Fence the end of the synchronized write so that no response is leaked into follow-up sessions.
+		err := sessionProtocol.Write(command.NewCommand(command.OpCodeFail, ""))
+		if err != nil {
+			return err
+		}
+
+		// When sending <X>, the command stations replies with <* Opcode=X params=0 *><X>.
+		describeCommandStr := command.NewCommand(command.OpCodeDescribe, "%s %s %s", "Opcode=X", "params=0", "*").String()
+		describeCommandObserved := false
+
+		for {
+			select {
+			case cmd, ok := <-commandC:
+				if !ok {
+					return ErrClosed
+				}
+
+				if cmd.String() == describeCommandStr {
+					// About to be done, waiting for <X>.
+					describeCommandObserved = true
+				} else if cmd.OpCode() == command.OpCodeFail && describeCommandObserved {
+					// <X> observed, return the session cleanly.
+					return nil
+				}
+			case <-ctx.Done():
+				return errs.Context(context.Cause(ctx))
+			}
+		}
+	}
+
+	// Try to obtain an active session from the passed context.
+	// If present, don't start a new session but reuse the existing one.
+	var err error
+	sessionProtocol, ok := c.contextSession(ctx)
+	if !ok {
+		err = c.sessionContext(ctx, sessionF)
+	} else {
+		err = sessionF(sessionProtocol)
+	}
+
+	if err != nil {
+		if c.label != "" {
+			return nil, fmt.Errorf("%s: %w", c.label, err)
+		}
+
+		return nil, err
+	}
+
+	return report, nil
+}
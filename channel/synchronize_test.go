@@ -0,0 +1,91 @@
+package channel_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/channel"
+	"github.com/roosterfish/dcc-ex-go/command"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+	"github.com/roosterfish/dcc-ex-go/simulator"
+)
+
+func TestWriteSynchronized(t *testing.T) {
+	p := protocol.NewProtocol(simulator.NewSimulator(), &protocol.Config{})
+	defer p.Close()
+
+	ch := channel.NewChannel(p)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cmds := []*command.Command{
+		command.NewCommand(command.OpCodeCabFunction, "%d %d %d", 3, 8, 1),
+		command.NewCommand(command.OpCodeOutput, "%d %d", 1, 1),
+	}
+
+	at := time.Now().Add(20 * time.Millisecond)
+	report, err := ch.WriteSynchronized(ctx, at, cmds...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Started.Before(at.Add(-time.Second)) || report.Started.After(at) {
+		t.Errorf("Expected the write to start shortly before %v but got %v", at, report.Started)
+	}
+
+	if len(report.Offsets) != len(cmds) || len(report.Durations) != len(cmds) {
+		t.Fatalf("Expected measurements for %d commands but got %+v", len(cmds), report)
+	}
+
+	// The longer command is written first.
+	if report.Offsets[0] >= report.Offsets[1] {
+		t.Errorf("Expected the longest command to be executed first but got offsets %v", report.Offsets)
+	}
+
+	if report.Spread != report.Offsets[1]-report.Offsets[0] {
+		t.Errorf("Unexpected spread %v for offsets %v", report.Spread, report.Offsets)
+	}
+}
+
+func TestWriteSynchronizedNoCommands(t *testing.T) {
+	p := protocol.NewProtocol(simulator.NewSimulator(), &protocol.Config{})
+	defer p.Close()
+
+	ch := channel.NewChannel(p)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := ch.WriteSynchronized(ctx, time.Now())
+	if err == nil {
+		t.Error("Expected an error when writing no commands")
+	}
+}
+
+func TestWriteSynchronizedByteDelay(t *testing.T) {
+	p := protocol.NewProtocol(simulator.NewSimulator(), &protocol.Config{})
+	defer p.Close()
+
+	ch := channel.NewChannel(p)
+	ch.SetByteDelay(time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cmd := command.NewCommand(command.OpCodeOutput, "%d %d", 1, 1)
+	report, err := ch.WriteSynchronized(ctx, time.Time{}, cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.ByteDelay != time.Millisecond {
+		t.Errorf("Expected byte delay %v but got %v", time.Millisecond, report.ByteDelay)
+	}
+
+	expected := time.Duration(len(cmd.Bytes())) * time.Millisecond
+	if report.Offsets[0] != expected {
+		t.Errorf("Expected offset %v but got %v", expected, report.Offsets[0])
+	}
+}
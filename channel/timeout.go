@@ -0,0 +1,175 @@
+package channel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/command"
+	"github.com/roosterfish/dcc-ex-go/errs"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+)
+
+// ErrSessionTimeout is returned by SessionWithTimeout if the session didn't finish in time.
+// It matches errs.ErrTimeout.
+var ErrSessionTimeout = errs.New("session timed out", errs.ErrTimeout)
+
+// ErrSessionAbandoned is returned when writing using the protocol of a session which got abandoned.
+var ErrSessionAbandoned = errors.New("session abandoned")
+
+// SessionWithContext works like Session but gives up once the context is done.
+// This applies to both waiting for the session and running sessionF.
+// In case sessionF doesn't return in time, the session is released and sessionF is abandoned.
+// It continues to run in its own routine but its writes fail with ErrSessionAbandoned so that it cannot interfere
+// with follow-up sessions. sessionF should stop using the protocol once the passed context is done.
+func (c *Channel) SessionWithContext(ctx context.Context, sessionF func(ctx context.Context, protocol protocol.ReadWriteCloser) error) error {
+	err := c.lockContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire session: %w", err)
+	}
+
+	unlock := sync.OnceFunc(c.unlock)
+	defer unlock()
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	defer c.preemption.set(cancel)()
+
+	sessionProtocol := newAbandonableProtocol(c.protocol)
+	release := func() {
+		sessionProtocol.abandon()
+		unlock()
+	}
+
+	return c.runContext(ctx, release, func() error {
+		return sessionF(ctx, sessionProtocol)
+	})
+}
+
+// RSessionWithContext works like RSession but gives up once the context is done.
+// In case sessionF doesn't return in time, it's abandoned the same way as in SessionWithContext.
+func (c *Channel) RSessionWithContext(ctx context.Context, sessionF func(ctx context.Context, protocol protocol.Reader) error) error {
+	return c.runContext(ctx, func() {}, func() error {
+		return sessionF(ctx, c.protocol)
+	})
+}
+
+// SessionWithTimeout works like Session but gives up after the duration d.
+// See SessionWithContext for the details.
+func (c *Channel) SessionWithTimeout(d time.Duration, sessionF func(protocol protocol.ReadWriteCloser) error) error {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+
+	timer := c.Clock().AfterFunc(d, func() {
+		cancel(ErrSessionTimeout)
+	})
+	defer timer.Stop()
+
+	return c.SessionWithContext(ctx, func(_ context.Context, protocol protocol.ReadWriteCloser) error {
+		return sessionF(protocol)
+	})
+}
+
+// runContext runs f until it returns or the context is done.
+// In the latter case release is called before returning and f is abandoned.
+func (c *Channel) runContext(ctx context.Context, release func(), f func() error) error {
+	errC := make(chan error, 1)
+	go func() {
+		errC <- f()
+	}()
+
+	select {
+	case err := <-errC:
+		return err
+	case <-ctx.Done():
+		release()
+
+		err := errs.Context(context.Cause(ctx))
+		if c.logger != nil {
+			c.logger.Warn("session abandoned", slog.String("label", c.label), slog.Any("error", err))
+		}
+
+		return fmt.Errorf("session abandoned: %w", err)
+	}
+}
+
+// abandonableProtocol wraps the protocol of a session and fails all writes once the session got abandoned.
+type abandonableProtocol struct {
+	protocol.ReadWriteCloser
+
+	// lock is held while writing so that no write is in flight after abandoning.
+	lock      sync.RWMutex
+	abandoned bool
+
+	// abandonedCtx is cancelled before abandoning to interrupt responses being waited for.
+	abandonedCtx    context.Context
+	cancelAbandoned context.CancelFunc
+}
+
+func newAbandonableProtocol(protocol protocol.ReadWriteCloser) *abandonableProtocol {
+	abandonedCtx, cancelAbandoned := context.WithCancel(context.Background())
+
+	return &abandonableProtocol{
+		ReadWriteCloser: protocol,
+		abandonedCtx:    abandonedCtx,
+		cancelAbandoned: cancelAbandoned,
+	}
+}
+
+func (a *abandonableProtocol) abandon() {
+	a.cancelAbandoned()
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.abandoned = true
+}
+
+func (a *abandonableProtocol) Write(command *command.Command) error {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+
+	if a.abandoned {
+		return ErrSessionAbandoned
+	}
+
+	return a.ReadWriteCloser.Write(command)
+}
+
+func (a *abandonableProtocol) WriteBatch(batch *command.Batch) error {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+
+	if a.abandoned {
+		return ErrSessionAbandoned
+	}
+
+	return protocol.WriteBatch(a.ReadWriteCloser, batch)
+}
+
+// WriteAndReadOpCode holds the lock while waiting for the response too.
+// Abandoning the session cancels the wait so that it doesn't block abandoning.
+func (a *abandonableProtocol) WriteAndReadOpCode(ctx context.Context, command *command.Command, opCode command.OpCode) (*command.Command, error) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	defer context.AfterFunc(a.abandonedCtx, func() {
+		cancel(ErrSessionAbandoned)
+	})()
+
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+
+	if a.abandoned {
+		return nil, ErrSessionAbandoned
+	}
+
+	response, err := protocol.WriteAndReadOpCode(ctx, a.ReadWriteCloser, command, opCode)
+	if err != nil && errors.Is(context.Cause(ctx), ErrSessionAbandoned) {
+		return nil, fmt.Errorf("%w: %w", ErrSessionAbandoned, err)
+	}
+
+	return response, err
+}
@@ -0,0 +1,113 @@
+package channel
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/command"
+	"github.com/roosterfish/dcc-ex-go/errs"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+)
+
+func TestSessionWithTimeout(t *testing.T) {
+	c := NewChannel(nil)
+
+	blockC := make(chan struct{})
+	defer close(blockC)
+
+	// The misbehaving session never returns.
+	err := c.SessionWithTimeout(10*time.Millisecond, func(protocol protocol.ReadWriteCloser) error {
+		<-blockC
+		return nil
+	})
+	if !errors.Is(err, ErrSessionTimeout) || !errors.Is(err, errs.ErrTimeout) {
+		t.Fatalf("Expected %v but got %v", ErrSessionTimeout, err)
+	}
+
+	// The session got released.
+	called := false
+	err = c.SessionWithTimeout(time.Second, func(protocol protocol.ReadWriteCloser) error {
+		called = true
+		return nil
+	})
+	if err != nil || !called {
+		t.Errorf("Expected session to succeed but got %v", err)
+	}
+}
+
+func TestSessionWithTimeoutAbandoned(t *testing.T) {
+	c := NewChannel(nil)
+
+	releasedC := make(chan struct{})
+	errC := make(chan error, 1)
+
+	err := c.SessionWithTimeout(10*time.Millisecond, func(protocol protocol.ReadWriteCloser) error {
+		// Keep on writing after the session got abandoned.
+		<-releasedC
+		errC <- protocol.Write(command.NewCommand(command.OpCodeStatus, ""))
+		return nil
+	})
+	if !errors.Is(err, ErrSessionTimeout) {
+		t.Fatalf("Expected %v but got %v", ErrSessionTimeout, err)
+	}
+
+	close(releasedC)
+
+	err = <-errC
+	if !errors.Is(err, ErrSessionAbandoned) {
+		t.Errorf("Expected %v but got %v", ErrSessionAbandoned, err)
+	}
+}
+
+func TestSessionWithContextAcquire(t *testing.T) {
+	c := NewChannel(nil)
+
+	c.lock()
+	defer c.unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := c.SessionWithContext(ctx, func(ctx context.Context, protocol protocol.ReadWriteCloser) error {
+		t.Error("Expected session not to be acquired")
+		return nil
+	})
+	if !errors.Is(err, errs.ErrTimeout) {
+		t.Errorf("Expected %v but got %v", errs.ErrTimeout, err)
+	}
+}
+
+func TestSessionWithTimeoutAbandonedRead(t *testing.T) {
+	station, port := net.Pipe()
+	defer station.Close()
+
+	// The command station never answers.
+	go func() {
+		_, _ = io.Copy(io.Discard, station)
+	}()
+
+	p := protocol.NewProtocol(port, &protocol.Config{})
+	defer p.Close()
+
+	c := NewChannel(p)
+
+	errC := make(chan error, 1)
+	err := c.SessionWithTimeout(10*time.Millisecond, func(sessionProtocol protocol.ReadWriteCloser) error {
+		_, err := protocol.WriteAndReadOpCode(context.Background(), sessionProtocol, command.NewCommand(command.OpCodeStatus, ""), command.OpCodeStatusResponse)
+		errC <- err
+		return err
+	})
+	if !errors.Is(err, ErrSessionTimeout) {
+		t.Fatalf("Expected %v but got %v", ErrSessionTimeout, err)
+	}
+
+	// Abandoning the session interrupts waiting for the response.
+	err = <-errC
+	if !errors.Is(err, ErrSessionAbandoned) {
+		t.Errorf("Expected %v but got %v", ErrSessionAbandoned, err)
+	}
+}
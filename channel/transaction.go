@@ -0,0 +1,78 @@
+package channel
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/roosterfish/dcc-ex-go/command"
+)
+
+// TransactionError describes the command which failed a transaction.
+type TransactionError struct {
+	// Index is the position of the failed command within the transaction.
+	Index   int
+	Command *command.Command
+	// Applied are the commands which succeeded before the failed command.
+	Applied []*command.Command
+	Err     error
+}
+
+func (e *TransactionError) Error() string {
+	return fmt.Sprintf("transaction failed at command %d %q: %v", e.Index, e.Command.String(), e.Err)
+}
+
+func (e *TransactionError) Unwrap() error {
+	return e.Err
+}
+
+// Transaction writes the given commands in succession using a single session and verifies each of them
+// by awaiting its response (see command.Command.ResponseMatcher), e.g. <O> for definitions or <H id state> for turnouts.
+// A command is failed if the command station doesn't respond with the expected response but <X> instead.
+// Commands without a response are only written.
+// The transaction stops at the first failed command and returns a *TransactionError which matches ErrCommandFailed
+// in case the command station refused the command.
+// The command station cannot roll back the commands which got applied before. End the transaction with <E> to
+// only persist the definitions in the EEPROM if all of them succeeded.
+func (c *Channel) Transaction(ctx context.Context, cmds ...*command.Command) error {
+	return c.SessionContext(ctx, func(ctx context.Context) error {
+		for i, cmd := range cmds {
+			err := c.writeVerified(ctx, cmd)
+			if err != nil {
+				return &TransactionError{
+					Index:   i,
+					Command: cmd,
+					Applied: cmds[:i],
+					Err:     err,
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// writeVerified writes the command and awaits its response.
+func (c *Channel) writeVerified(ctx context.Context, cmd *command.Command) error {
+	matcher, ok := cmd.ResponseMatcher()
+	if !ok {
+		return c.Write(ctx, cmd)
+	}
+
+	verified := false
+	err := c.WriteAndReadOpCode(ctx, cmd, matcher.OpCode, func(response *command.Command) error {
+		if matcher.Match(response) {
+			verified = true
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if !verified {
+		return c.commandFailed(cmd)
+	}
+
+	return nil
+}
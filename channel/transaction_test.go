@@ -0,0 +1,60 @@
+package channel_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/channel"
+	"github.com/roosterfish/dcc-ex-go/command"
+	"github.com/roosterfish/dcc-ex-go/errs"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+	"github.com/roosterfish/dcc-ex-go/simulator"
+)
+
+func TestTransaction(t *testing.T) {
+	p := protocol.NewProtocol(simulator.NewSimulator(), &protocol.Config{})
+	defer p.Close()
+
+	ch := channel.NewChannel(p)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := ch.Transaction(ctx,
+		command.NewCommand(command.OpCodeSensorCreate, "%d %d %d", 1, 101, 1),
+		command.NewCommand(command.OpCodeTurnout, "%d SERVO %d %d %d %d", 2, 102, 400, 200, 2),
+		command.NewCommand(command.OpCodeTurnout, "%d %c", 2, 'T'),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Throwing an unknown turnout is refused with <X>.
+	failing := command.NewCommand(command.OpCodeTurnout, "%d %c", 3, 'T')
+	err = ch.Transaction(ctx,
+		command.NewCommand(command.OpCodeSensorCreate, "%d %d %d", 4, 104, 1),
+		failing,
+		command.NewCommand(command.OpCodeSensorCreate, "%d %d %d", 5, 105, 1),
+	)
+
+	var transactionErr *channel.TransactionError
+	if !errors.As(err, &transactionErr) {
+		t.Fatalf("Expected a transaction error but got %v", err)
+	}
+
+	if transactionErr.Index != 1 || transactionErr.Command != failing || len(transactionErr.Applied) != 1 {
+		t.Errorf("Unexpected transaction error %+v", transactionErr)
+	}
+
+	if !errors.Is(err, channel.ErrCommandFailed) || !errors.Is(err, errs.ErrStationFailure) {
+		t.Errorf("Expected %v but got %v", channel.ErrCommandFailed, err)
+	}
+
+	// The command following the failed one isn't written, so deleting its sensor fails.
+	err = ch.Transaction(ctx, command.NewCommand(command.OpCodeSensorCreate, "%d", 5))
+	if !errors.Is(err, channel.ErrCommandFailed) {
+		t.Errorf("Expected %v but got %v", channel.ErrCommandFailed, err)
+	}
+}
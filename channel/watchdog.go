@@ -0,0 +1,152 @@
+package channel
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/clock"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+)
+
+// StuckSession describes a session which held the channel for longer than the watchdog's threshold.
+type StuckSession struct {
+	// Label is the label of the channel.
+	Label string
+	// Held is the duration the session held the channel when it was reported.
+	Held time.Duration
+	// GoroutineID is the ID of the goroutine holding the session.
+	GoroutineID uint64
+	// AcquiredStack is the stack of the goroutine when it acquired the session.
+	AcquiredStack string
+	// Stack is the stack of the goroutine when the session was reported.
+	// It's empty in case the goroutine couldn't be found.
+	Stack string
+}
+
+// WatchdogF is called for every session which got stuck.
+type WatchdogF func(session *StuckSession)
+
+type watchdog struct {
+	threshold time.Duration
+	f         WatchdogF
+	timer     clock.Timer
+}
+
+// SetWatchdog enables the watchdog which reports sessions holding the channel for longer than threshold.
+// Every stuck session is reported once by calling f in its own routine.
+// Capturing the stacks is costly, only enable the watchdog for diagnostics.
+// Set it before using the channel.
+func (c *Channel) SetWatchdog(threshold time.Duration, f WatchdogF) {
+	c.watchdog = &watchdog{
+		threshold: threshold,
+		f:         f,
+	}
+}
+
+// lock acquires the session lock and arms the watchdog if enabled.
+func (c *Channel) lock() {
+	_ = c.lockContext(context.Background())
+}
+
+// lockContext works like lock but gives up once the context is done.
+// Sessions are acquired in the order they got requested.
+func (c *Channel) lockContext(ctx context.Context) error {
+	start := c.Clock().Now()
+
+	err := c.sessionQueue.acquire(ctx, c.setQueueDepth)
+	c.setQueueDepth()
+	if err != nil {
+		return err
+	}
+
+	if c.metrics != nil {
+		c.metrics.Observe(protocol.HistogramSessionWait, c.Clock().Since(start))
+	}
+
+	c.locked()
+	return nil
+}
+
+// locked arms the watchdog if enabled after the session lock got acquired.
+func (c *Channel) locked() {
+	if c.logger != nil {
+		c.sessionStart = c.Clock().Now()
+		c.logger.Debug("session started", slog.String("label", c.label))
+	}
+
+	if c.watchdog == nil {
+		return
+	}
+
+	acquiredStack := stack(false)
+	acquired := c.Clock().Now()
+
+	c.watchdog.timer = c.Clock().AfterFunc(c.watchdog.threshold, func() {
+		id := goroutineID(acquiredStack)
+
+		c.watchdog.f(&StuckSession{
+			Label:         c.label,
+			Held:          c.Clock().Since(acquired),
+			GoroutineID:   id,
+			AcquiredStack: string(acquiredStack),
+			Stack:         goroutineStack(stack(true), id),
+		})
+	})
+}
+
+// unlock disarms the watchdog if enabled and releases the session lock.
+func (c *Channel) unlock() {
+	if c.watchdog != nil {
+		c.watchdog.timer.Stop()
+	}
+
+	if c.logger != nil {
+		c.logger.Debug("session ended", slog.String("label", c.label), slog.Duration("duration", c.Clock().Since(c.sessionStart)))
+	}
+
+	c.sessionQueue.release()
+	c.setQueueDepth()
+}
+
+// stack returns the stack of the current or all goroutines.
+func stack(all bool) []byte {
+	buf := make([]byte, 4096)
+	for {
+		n := runtime.Stack(buf, all)
+		if n < len(buf) {
+			return buf[:n]
+		}
+
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// goroutineID parses the goroutine's ID from the header of its stack (goroutine 1 [running]:).
+func goroutineID(stack []byte) uint64 {
+	fields := bytes.Fields(stack)
+	if len(fields) < 2 {
+		return 0
+	}
+
+	id, err := strconv.ParseUint(string(fields[1]), 10, 64)
+	if err != nil {
+		return 0
+	}
+
+	return id
+}
+
+// goroutineStack returns the stack of the goroutine with the given ID out of the stacks of all goroutines.
+func goroutineStack(stacks []byte, id uint64) string {
+	for _, goroutine := range bytes.Split(stacks, []byte("\n\n")) {
+		if goroutineID(goroutine) == id {
+			return string(goroutine)
+		}
+	}
+
+	return ""
+}
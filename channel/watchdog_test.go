@@ -0,0 +1,47 @@
+package channel
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/protocol"
+)
+
+func TestWatchdog(t *testing.T) {
+	c := NewChannel(nil)
+	c.SetLabel("layout")
+
+	stuckC := make(chan *StuckSession, 1)
+	c.SetWatchdog(10*time.Millisecond, func(session *StuckSession) {
+		stuckC <- session
+	})
+
+	_ = c.Session(func(protocol protocol.ReadWriteCloser) error {
+		select {
+		case session := <-stuckC:
+			if session.Label != "layout" || session.GoroutineID == 0 {
+				t.Errorf("Unexpected stuck session %+v", session)
+			}
+
+			if !strings.Contains(session.Stack, "TestWatchdog") {
+				t.Errorf("Expected the holder's stack but got %q", session.Stack)
+			}
+		case <-time.After(5 * time.Second):
+			t.Error("Timed out waiting for the stuck session")
+		}
+
+		return nil
+	})
+
+	// Quick sessions aren't reported.
+	_ = c.Session(func(protocol protocol.ReadWriteCloser) error {
+		return nil
+	})
+
+	select {
+	case <-stuckC:
+		t.Error("Expected session not to be reported")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
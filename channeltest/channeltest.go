@@ -0,0 +1,248 @@
+package channeltest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"sync"
+
+	"github.com/roosterfish/dcc-ex-go/channel"
+	"github.com/roosterfish/dcc-ex-go/clock"
+	"github.com/roosterfish/dcc-ex-go/command"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+)
+
+type sessionCtxKey struct{}
+
+// Channel is a mock implementing channel.Interface.
+// Instead of a command station it answers the written commands using scripted responses.
+// Responses which are broadcasts (see command.OpCode.IsBroadcast) are additionally sent to the readers of the
+// sessions, so entities watching the broadcasts (e.g. sensor callbacks) observe them too.
+type Channel struct {
+	lock      sync.Mutex
+	responses map[string][]*command.Command
+	written   []*command.Command
+	clock     clock.Clock
+
+	sessionLock sync.Mutex
+	protocol    *protocol.Protocol
+	// station is the command station's end of the connection used by the sessions' protocol.
+	station   net.Conn
+	writeLock sync.Mutex
+}
+
+var _ channel.Interface = &Channel{}
+
+// NewChannel returns a new mock without any scripted responses.
+// Close it once done.
+func NewChannel() *Channel {
+	station, port := net.Pipe()
+
+	c := &Channel{
+		responses: make(map[string][]*command.Command),
+		protocol:  protocol.NewProtocol(port, &protocol.Config{}),
+		station:   station,
+	}
+
+	go c.answer()
+
+	return c
+}
+
+// mustParse parses the given command and panics in case it's invalid.
+func mustParse(cmd string) *command.Command {
+	parsed, err := command.NewCommandFromString(cmd)
+	if err != nil {
+		panic(fmt.Sprintf("channeltest: %v", err))
+	}
+
+	return parsed
+}
+
+// Respond scripts the responses to the given request (e.g. <T 5 T> is answered with <H 5 1>).
+// Requests are compared using their string representation. Requests without responses aren't answered which
+// resembles a command station responding with <X>.
+// It panics in case one of the commands cannot be parsed.
+func (c *Channel) Respond(request string, responses ...string) {
+	parsed := make([]*command.Command, 0, len(responses))
+	for _, response := range responses {
+		parsed = append(parsed, mustParse(response))
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.responses[mustParse(request).String()] = parsed
+}
+
+// Broadcast sends the given command to the readers of the sessions.
+// It panics in case the command cannot be parsed.
+func (c *Channel) Broadcast(broadcast string) {
+	c.send(mustParse(broadcast))
+}
+
+// Written returns the commands written so far.
+func (c *Channel) Written() []*command.Command {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return slices.Clone(c.written)
+}
+
+// SetClock sets the clock returned by Clock.
+func (c *Channel) SetClock(clock clock.Clock) {
+	c.clock = clock
+}
+
+// Close closes the sessions' protocol.
+func (c *Channel) Close() error {
+	err := c.protocol.Close()
+	_ = c.station.Close()
+
+	return err
+}
+
+// answer answers the commands written using the sessions' protocol.
+func (c *Channel) answer() {
+	scanner := bufio.NewScanner(c.station)
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		end := bytes.IndexByte(data, '>')
+		if end < 0 {
+			return 0, nil, nil
+		}
+
+		return end + 1, data[:end+1], nil
+	})
+
+	for scanner.Scan() {
+		cmd, err := command.NewCommandFromString(scanner.Text())
+		if err != nil {
+			continue
+		}
+
+		for _, response := range c.respond(cmd) {
+			c.send(response)
+		}
+	}
+}
+
+// send writes the command to the sessions' protocol.
+func (c *Channel) send(cmd *command.Command) {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	_, _ = c.station.Write(cmd.Bytes())
+}
+
+// respond records the written command and returns its responses.
+func (c *Channel) respond(cmd *command.Command) []*command.Command {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.written = append(c.written, cmd)
+	return c.responses[cmd.String()]
+}
+
+// writeAndRead answers the command like the channel abstraction functions do.
+func (c *Channel) writeAndRead(cmd *command.Command, o []command.OpCode, f channel.ValidateF) error {
+	err := cmd.Validate()
+	if err != nil {
+		return err
+	}
+
+	for _, response := range c.respond(cmd) {
+		if response.OpCode().IsBroadcast() {
+			c.send(response)
+		}
+
+		if f == nil || !slices.Contains(o, response.OpCode()) {
+			continue
+		}
+
+		err := f(response)
+		if err != nil {
+			return fmt.Errorf("failed to run function: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (c *Channel) Session(sessionF func(protocol protocol.ReadWriteCloser) error) error {
+	c.sessionLock.Lock()
+	defer c.sessionLock.Unlock()
+
+	return sessionF(c.protocol)
+}
+
+func (c *Channel) SessionContext(ctx context.Context, f func(ctx context.Context) error) error {
+	if ctx.Value(sessionCtxKey{}) != nil {
+		return f(ctx)
+	}
+
+	c.sessionLock.Lock()
+	defer c.sessionLock.Unlock()
+
+	return f(context.WithValue(ctx, sessionCtxKey{}, c))
+}
+
+func (c *Channel) RSession(sessionF func(protocol protocol.Reader) error) error {
+	return sessionF(c.protocol)
+}
+
+func (c *Channel) Write(ctx context.Context, cmd *command.Command) error {
+	return c.writeAndRead(cmd, nil, nil)
+}
+
+func (c *Channel) WriteWithAck(ctx context.Context, cmd *command.Command) error {
+	acknowledged := false
+	err := c.writeAndRead(cmd, []command.OpCode{command.OpCodeSuccess}, func(cmd *command.Command) error {
+		acknowledged = true
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if !acknowledged {
+		return &channel.CommandFailedError{Command: cmd}
+	}
+
+	return nil
+}
+
+func (c *Channel) WriteAndReadOpCode(ctx context.Context, cmd *command.Command, o command.OpCode, f channel.ValidateF) error {
+	return c.writeAndRead(cmd, []command.OpCode{o}, f)
+}
+
+func (c *Channel) WriteAndReadOpCodes(ctx context.Context, cmd *command.Command, o []command.OpCode, f channel.ValidateF) error {
+	return c.writeAndRead(cmd, o, f)
+}
+
+func (c *Channel) WriteAndReadMultiOpCode(ctx context.Context, cmd *command.Command, o command.MultiOpCode, f channel.ValidateF) error {
+	return c.writeAndRead(cmd, []command.OpCode{o.OpCode()}, func(cmd *command.Command) error {
+		if cmd.MultiOpCode() != o {
+			return nil
+		}
+
+		return f(cmd)
+	})
+}
+
+// WriteSafety answers the safety command right away without acquiring the sessions.
+// Commands which aren't safety commands (see channel.IsSafety) are refused.
+func (c *Channel) WriteSafety(cmd *command.Command) error {
+	if !channel.IsSafety(cmd) {
+		return fmt.Errorf("failed to write %q: not a safety command", cmd.String())
+	}
+
+	return c.writeAndRead(cmd, nil, nil)
+}
+
+// Clock returns the clock set using SetClock or the real time.
+func (c *Channel) Clock() clock.Clock {
+	return clock.Or(c.clock)
+}
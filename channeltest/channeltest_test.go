@@ -0,0 +1,57 @@
+package channeltest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/channel"
+	"github.com/roosterfish/dcc-ex-go/channeltest"
+	"github.com/roosterfish/dcc-ex-go/command"
+	"github.com/roosterfish/dcc-ex-go/output"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+	"github.com/roosterfish/dcc-ex-go/station"
+)
+
+func TestChannel(t *testing.T) {
+	mock := channeltest.NewChannel()
+	defer mock.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	mock.Respond("<Z 5 1>", "<Y 5 1>")
+
+	err := output.NewOutput(5, mock).High(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Unscripted commands aren't acknowledged.
+	_, err = station.PersistDefinition(ctx, mock, command.NewCommand(command.OpCodeOutput, "%d %d %d", 6, 106, 0))
+	if !errors.Is(err, channel.ErrCommandFailed) {
+		t.Errorf("Expected %v but got %v", channel.ErrCommandFailed, err)
+	}
+
+	written := mock.Written()
+	if len(written) != 2 || written[0].String() != "<Z 5 1>" || written[1].String() != "<Z 6 106 0>" {
+		t.Errorf("Unexpected written commands %v", written)
+	}
+
+	// Sessions use the same script.
+	mock.Respond("<s>", "<iDCC-EX V-5.4.0 / MEGA / STANDARD_MOTOR_SHIELD G-devel>")
+
+	var response *command.Command
+	err = mock.Session(func(sessionProtocol protocol.ReadWriteCloser) error {
+		response, err = protocol.WriteAndReadOpCode(ctx, sessionProtocol, command.NewCommand(command.OpCodeStatus, ""), command.OpCodeStatusResponse)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.OpCode() != command.OpCodeStatusResponse {
+		t.Errorf("Unexpected response %q", response)
+	}
+}
@@ -0,0 +1,199 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Mock is a clock which only advances when told to.
+// It allows testing time dependent functions deterministically.
+type Mock struct {
+	now    time.Time
+	timers map[*mockTimer]struct{}
+	lock   sync.Mutex
+	cond   *sync.Cond
+}
+
+// mockTicker adapts the timer's Stop to the Ticker interface.
+type mockTicker struct {
+	*mockTimer
+}
+
+type mockTimer struct {
+	mock     *Mock
+	deadline time.Time
+	period   time.Duration
+	c        chan time.Time
+	f        func()
+}
+
+// NewMock returns a new mock clock starting at the given time.
+func NewMock(now time.Time) *Mock {
+	m := &Mock{
+		now:    now,
+		timers: make(map[*mockTimer]struct{}),
+	}
+
+	m.cond = sync.NewCond(&m.lock)
+	return m
+}
+
+// Advance moves the clock forward by the given duration.
+// All of the timers and tickers which expire in the meantime are fired in order.
+func (m *Mock) Advance(d time.Duration) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	target := m.now.Add(d)
+
+	for {
+		var next *mockTimer
+		for timer := range m.timers {
+			if timer.deadline.After(target) {
+				continue
+			}
+
+			if next == nil || timer.deadline.Before(next.deadline) {
+				next = timer
+			}
+		}
+
+		if next == nil {
+			break
+		}
+
+		m.now = next.deadline
+		next.fire()
+
+		if next.period > 0 {
+			next.deadline = next.deadline.Add(next.period)
+		} else {
+			delete(m.timers, next)
+		}
+	}
+
+	m.now = target
+	m.cond.Broadcast()
+}
+
+// BlockUntil blocks until at least n timers or tickers are waiting to expire.
+// Use it to ensure the function under test is waiting before advancing the clock.
+func (m *Mock) BlockUntil(n int) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for len(m.timers) < n {
+		m.cond.Wait()
+	}
+}
+
+func (m *Mock) Now() time.Time {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return m.now
+}
+
+func (m *Mock) Since(t time.Time) time.Duration {
+	return m.Now().Sub(t)
+}
+
+func (m *Mock) Until(t time.Time) time.Duration {
+	return t.Sub(m.Now())
+}
+
+func (m *Mock) After(d time.Duration) <-chan time.Time {
+	return m.NewTimer(d).C()
+}
+
+func (m *Mock) Sleep(d time.Duration) {
+	<-m.After(d)
+}
+
+func (m *Mock) NewTimer(d time.Duration) Timer {
+	return m.add(d, 0, nil)
+}
+
+func (m *Mock) NewTicker(d time.Duration) Ticker {
+	return mockTicker{m.add(d, d, nil)}
+}
+
+func (m *Mock) AfterFunc(d time.Duration, f func()) Timer {
+	return m.add(d, 0, f)
+}
+
+// add registers a new timer expiring after the given duration.
+func (m *Mock) add(d time.Duration, period time.Duration, f func()) *mockTimer {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	timer := &mockTimer{
+		mock:     m,
+		deadline: m.now.Add(d),
+		period:   period,
+		c:        make(chan time.Time, 1),
+		f:        f,
+	}
+
+	if d <= 0 && period == 0 {
+		timer.fire()
+		return timer
+	}
+
+	m.timers[timer] = struct{}{}
+	m.cond.Broadcast()
+
+	return timer
+}
+
+// fire expires the timer.
+// The caller must hold the mock's lock.
+func (t *mockTimer) fire() {
+	if t.f != nil {
+		go t.f()
+		return
+	}
+
+	// Like tickers drop the tick in case the previous one wasn't consumed yet.
+	select {
+	case t.c <- t.mock.now:
+	default:
+	}
+}
+
+func (t mockTicker) Stop() {
+	t.mockTimer.Stop()
+}
+
+func (t *mockTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *mockTimer) Stop() bool {
+	t.mock.lock.Lock()
+	defer t.mock.lock.Unlock()
+
+	_, active := t.mock.timers[t]
+	delete(t.mock.timers, t)
+
+	return active
+}
+
+func (t *mockTimer) Reset(d time.Duration) bool {
+	t.mock.lock.Lock()
+	defer t.mock.lock.Unlock()
+
+	_, active := t.mock.timers[t]
+	t.deadline = t.mock.now.Add(d)
+
+	if d <= 0 && t.period == 0 {
+		delete(t.mock.timers, t)
+		t.fire()
+		return active
+	}
+
+	t.mock.timers[t] = struct{}{}
+	t.mock.cond.Broadcast()
+
+	return active
+}
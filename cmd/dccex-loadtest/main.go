@@ -0,0 +1,161 @@
+// Command dccex-loadtest drives the simulator with broadcasts at a configurable rate and reports the
+// throughput, latency percentiles and allocation rate of the protocol's fan-out to the subscribers.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"runtime"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/protocol"
+	"github.com/roosterfish/dcc-ex-go/sensor"
+	"github.com/roosterfish/dcc-ex-go/simulator"
+)
+
+// sensorIDs is the number of distinct sensor IDs used to correlate broadcasts with their send time.
+const sensorIDs = 4096
+
+type result struct {
+	received  int
+	latencies []time.Duration
+}
+
+func main() {
+	rate := flag.Int("rate", 1000, "broadcasts per second (0 sends as fast as possible)")
+	subscribers := flag.Int("subscribers", 8, "number of subscribers")
+	duration := flag.Duration("duration", 5*time.Second, "duration of the load test")
+	buffer := flag.Int("buffer", 0, "number of commands buffered for every subscriber")
+	overflow := flag.String("overflow", "block", "overflow policy of the subscribers (block, drop-oldest, drop-newest)")
+	flag.Parse()
+
+	policy, err := parseOverflow(*overflow)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	sim := simulator.NewSimulator()
+	p := protocol.NewProtocol(sim, &protocol.Config{
+		SubscriptionBuffer: *buffer,
+		Overflow:           policy,
+	})
+
+	defer p.Close()
+
+	// The send time of the latest broadcast of every sensor ID.
+	sent := make([]atomic.Int64, sensorIDs)
+
+	results := make([]*result, *subscribers)
+	wg := sync.WaitGroup{}
+	doneC := make(chan struct{})
+
+	for i := range *subscribers {
+		commandC, cleanupF := p.ReadFiltered(sensor.StateActive.OpCode())
+		results[i] = &result{}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer cleanupF()
+
+			for {
+				select {
+				case cmd, ok := <-commandC:
+					if !ok {
+						return
+					}
+
+					params, err := cmd.ParametersStrings()
+					if err != nil || len(params) != 1 {
+						continue
+					}
+
+					id, err := strconv.Atoi(params[0])
+					if err != nil || id >= sensorIDs {
+						continue
+					}
+
+					results[i].received++
+					results[i].latencies = append(results[i].latencies, time.Duration(time.Now().UnixNano()-sent[id].Load()))
+				case <-doneC:
+					return
+				}
+			}
+		}()
+	}
+
+	var before runtime.MemStats
+	runtime.ReadMemStats(&before)
+
+	start := time.Now()
+	broadcasts := 0
+
+	var ticker *time.Ticker
+	if *rate > 0 {
+		ticker = time.NewTicker(time.Second / time.Duration(*rate))
+		defer ticker.Stop()
+	}
+
+	for time.Since(start) < *duration {
+		if ticker != nil {
+			<-ticker.C
+		}
+
+		id := broadcasts % sensorIDs
+		sent[id].Store(time.Now().UnixNano())
+		sim.SetSensorState(sensor.ID(id), sensor.StateActive)
+		broadcasts++
+	}
+
+	elapsed := time.Since(start)
+
+	// Give the subscribers a moment to drain the remaining broadcasts.
+	time.Sleep(100 * time.Millisecond)
+	close(doneC)
+	wg.Wait()
+
+	var after runtime.MemStats
+	runtime.ReadMemStats(&after)
+
+	received := 0
+	var latencies []time.Duration
+	for _, result := range results {
+		received += result.received
+		latencies = append(latencies, result.latencies...)
+	}
+
+	slices.Sort(latencies)
+
+	fmt.Printf("broadcasts:  %d (%.0f/s)\n", broadcasts, float64(broadcasts)/elapsed.Seconds())
+	// Broadcasts still queued when the test ends are counted as missed too.
+	fmt.Printf("received:    %d (%.0f/s), missed %d\n", received, float64(received)/elapsed.Seconds(), broadcasts**subscribers-received)
+	fmt.Printf("latency:     p50 %s, p95 %s, p99 %s, max %s\n", percentile(latencies, 50), percentile(latencies, 95), percentile(latencies, 99), percentile(latencies, 100))
+	fmt.Printf("allocations: %.1f/broadcast, %.0f B/broadcast\n", float64(after.Mallocs-before.Mallocs)/float64(max(broadcasts, 1)), float64(after.TotalAlloc-before.TotalAlloc)/float64(max(broadcasts, 1)))
+}
+
+func parseOverflow(overflow string) (protocol.OverflowPolicy, error) {
+	switch overflow {
+	case "block":
+		return protocol.OverflowBlock, nil
+	case "drop-oldest":
+		return protocol.OverflowDropOldest, nil
+	case "drop-newest":
+		return protocol.OverflowDropNewest, nil
+	default:
+		return 0, fmt.Errorf("unknown overflow policy %q", overflow)
+	}
+}
+
+// percentile returns the p-th percentile of the sorted latencies.
+func percentile(latencies []time.Duration, p int) time.Duration {
+	if len(latencies) == 0 {
+		return 0
+	}
+
+	return latencies[(len(latencies)-1)*p/100]
+}
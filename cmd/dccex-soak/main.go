@@ -0,0 +1,50 @@
+// Command dccex-soak runs randomized operations against the simulator for a long time while checking
+// invariants (no deadlocks, no goroutine growth, the state read back matches the expected state).
+// It exits with status 1 in case any invariant got violated.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/roosterfish/dcc-ex-go/soak"
+)
+
+func main() {
+	config := soak.NewDefaultConfig()
+
+	flag.DurationVar(&config.Duration, "duration", config.Duration, "duration of the soak test")
+	flag.Int64Var(&config.Seed, "seed", config.Seed, "seed of the randomized operations")
+	flag.IntVar(&config.Workers, "workers", config.Workers, "number of concurrent workers")
+	flag.DurationVar(&config.OpTimeout, "op-timeout", config.OpTimeout, "duration after which an operation is considered to be deadlocked")
+	flag.IntVar(&config.MaxGoroutineGrowth, "max-goroutine-growth", config.MaxGoroutineGrowth, "number of goroutines the run may grow")
+	flag.Parse()
+
+	// Allow stopping the run early while still getting the report.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	fmt.Printf("seed:        %d\n", config.Seed)
+
+	report, err := soak.Run(ctx, config)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	fmt.Printf("duration:    %s\n", report.Duration)
+	fmt.Printf("operations:  %d (%.0f/s)\n", report.Operations, float64(report.Operations)/report.Duration.Seconds())
+	fmt.Printf("goroutines:  %d after setup, %d max\n", report.Goroutines, report.MaxGoroutines)
+	fmt.Printf("violations:  %d\n", len(report.Violations))
+
+	for _, violation := range report.Violations {
+		fmt.Printf("  %s %s\n", violation.Time.Format("15:04:05.000"), violation)
+	}
+
+	if report.Failed() {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,38 @@
+package command
+
+// Batch is an ordered list of commands which are written at once.
+type Batch struct {
+	commands []*Command
+}
+
+// NewBatch returns a new batch of the given commands.
+func NewBatch(commands ...*Command) *Batch {
+	return &Batch{
+		commands: commands,
+	}
+}
+
+// Add appends the commands to the batch.
+func (b *Batch) Add(commands ...*Command) {
+	b.commands = append(b.commands, commands...)
+}
+
+// Commands returns the commands of the batch in order.
+func (b *Batch) Commands() []*Command {
+	return b.commands
+}
+
+// Len returns the number of commands in the batch.
+func (b *Batch) Len() int {
+	return len(b.commands)
+}
+
+// Bytes returns the serialized commands of the batch as they are written.
+func (b *Batch) Bytes() []byte {
+	data := []byte{}
+	for _, cmd := range b.commands {
+		data = append(data, cmd.Bytes()...)
+	}
+
+	return data
+}
@@ -2,8 +2,6 @@ package command
 
 import (
 	"fmt"
-	"slices"
-	"strings"
 )
 
 type OpCode rune
@@ -18,23 +16,170 @@ const (
 	OpCodeStatus               OpCode = 's'
 	OpCodeStatusResponse       OpCode = 'i'
 	OpCodeEEPROM               OpCode = 'E'
+	OpCodeEEPROMResponse       OpCode = 'e'
 	OpCodeCabSpeed             OpCode = 't'
 	OpCodeCabFunction          OpCode = 'F'
 	OpCodeCabResponse          OpCode = 'l'
+	OpCodeCabForget            OpCode = '-'
 	OpCodeStationSupportedCabs OpCode = '#'
 	OpCodeSensorCreate         OpCode = 'S'
+	OpCodeSensorActive         OpCode = 'Q'
+	OpCodeSensorInactive       OpCode = 'q'
 	OpCodeTurnout              OpCode = 'T'
 	OpCodeTurnoutResponse      OpCode = 'H'
 	OpCodeOutput               OpCode = 'Z'
 	OpCodeOutputResponse       OpCode = 'Y'
 	OpCodeOutputControl        OpCode = 'z'
+	OpCodeAccessory            OpCode = 'a'
+	OpCodePowerOff             OpCode = '0'
+	OpCodePowerOn              OpCode = '1'
 	OpCodePower                OpCode = 'p'
+	OpCodeDiagnostic           OpCode = 'D'
+	OpCodeEmergencyStop        OpCode = '!'
+	OpCodeTrackInfo            OpCode = 'J'
+	OpCodeTrackInfoResponse    OpCode = 'j'
+	OpCodeTrackManager         OpCode = '='
+	OpCodeCurrent              OpCode = 'c'
+	OpCodeTurntable            OpCode = 'I'
+	// CV operations on the programming track.
+	OpCodeReadCV           OpCode = 'R'
+	OpCodeReadCVResponse   OpCode = 'r'
+	OpCodeVerifyCV         OpCode = 'V'
+	OpCodeVerifyCVResponse OpCode = 'v'
+	OpCodeWriteCV          OpCode = 'W'
+	OpCodeWriteCVBit       OpCode = 'B'
+	// CV operations on the main track (programming on main).
+	OpCodeWriteCVMain    OpCode = 'w'
+	OpCodeWriteCVBitMain OpCode = 'b'
+	// Raw DCC packets sent to the main and programming track.
+	OpCodePacketMain OpCode = 'M'
+	OpCodePacketProg OpCode = 'P'
+	OpCodeEXRAIL     OpCode = '/'
+	// Erasing the EEPROM shares its op code with the EEPROM response.
+	OpCodeEEPROMErase OpCode = 'e'
+	// Listing the sensors using <S> is answered with <Q id vpin pullup> sharing its op code with active sensors.
+	OpCodeSensorListResponse OpCode = 'Q'
+)
+
+// opCodeInfo is the metadata of a documented op code.
+type opCodeInfo struct {
+	// name is the human-readable name of the op code.
+	name string
+	// response is the op code of the command station's response, if any.
+	response OpCode
+	// isResponse is set for op codes sent by the command station.
+	isResponse bool
+	// isBroadcast is set for op codes the command station sends unrequested,
+	// e.g. state changes caused by other throttles.
+	isBroadcast bool
+}
+
+// opCodeInfos are the metadata of the documented op codes.
+// The op code '0' is named after powering off the tracks as it's only used internally as OpCodeNull.
+// Sensor definitions are answered with <O> whereas listing the sensors using <S> is answered with <Q>.
+var opCodeInfos = map[OpCode]opCodeInfo{
+	OpCodePowerOff:             {name: "power off", response: OpCodePower},
+	OpCodePowerOn:              {name: "power on", response: OpCodePower},
+	OpCodePower:                {name: "power state", isResponse: true, isBroadcast: true},
+	OpCodeInfo:                 {name: "info", isResponse: true, isBroadcast: true},
+	OpCodeDescribe:             {name: "diagnostic message", isResponse: true, isBroadcast: true},
+	OpCodeSuccess:              {name: "success", isResponse: true},
+	OpCodeFail:                 {name: "fail", isResponse: true},
+	OpCodeStatus:               {name: "status", response: OpCodeStatusResponse},
+	OpCodeStatusResponse:       {name: "status response", isResponse: true},
+	OpCodeEEPROM:               {name: "store EEPROM", response: OpCodeEEPROMResponse},
+	OpCodeEEPROMResponse:       {name: "EEPROM response", isResponse: true},
+	OpCodeCabSpeed:             {name: "cab speed", response: OpCodeCabResponse},
+	OpCodeCabFunction:          {name: "cab function", response: OpCodeCabResponse},
+	OpCodeCabResponse:          {name: "cab state", isResponse: true, isBroadcast: true},
+	OpCodeCabForget:            {name: "forget cab"},
+	OpCodeStationSupportedCabs: {name: "supported cabs", response: OpCodeStationSupportedCabs, isResponse: true},
+	OpCodeSensorCreate:         {name: "sensor", response: OpCodeSuccess},
+	OpCodeSensorActive:         {name: "sensor active", isResponse: true, isBroadcast: true},
+	OpCodeSensorInactive:       {name: "sensor inactive", isResponse: true, isBroadcast: true},
+	OpCodeTurnout:              {name: "turnout", response: OpCodeTurnoutResponse},
+	OpCodeTurnoutResponse:      {name: "turnout state", isResponse: true, isBroadcast: true},
+	OpCodeOutput:               {name: "output", response: OpCodeOutputResponse},
+	OpCodeOutputResponse:       {name: "output state", isResponse: true, isBroadcast: true},
+	OpCodeOutputControl:        {name: "vpin control"},
+	OpCodeAccessory:            {name: "accessory"},
+	OpCodeDiagnostic:           {name: "diagnostics"},
+	OpCodeEmergencyStop:        {name: "emergency stop"},
+	OpCodeTrackInfo:            {name: "track info", response: OpCodeTrackInfoResponse},
+	OpCodeTrackInfoResponse:    {name: "track info response", isResponse: true, isBroadcast: true},
+	OpCodeTrackManager:         {name: "track manager", response: OpCodeTrackManager, isResponse: true, isBroadcast: true},
+	OpCodeCurrent:              {name: "current", response: OpCodeCurrent, isResponse: true},
+	OpCodeTurntable:            {name: "turntable", response: OpCodeTurntable, isResponse: true, isBroadcast: true},
+	OpCodeReadCV:               {name: "read CV", response: OpCodeReadCVResponse},
+	OpCodeReadCVResponse:       {name: "CV response", isResponse: true},
+	OpCodeVerifyCV:             {name: "verify CV", response: OpCodeVerifyCVResponse},
+	OpCodeVerifyCVResponse:     {name: "verify CV response", isResponse: true},
+	OpCodeWriteCV:              {name: "write CV", response: OpCodeReadCVResponse},
+	OpCodeWriteCVBit:           {name: "write CV bit", response: OpCodeReadCVResponse},
+	OpCodeWriteCVMain:          {name: "write CV on main"},
+	OpCodeWriteCVBitMain:       {name: "write CV bit on main"},
+	OpCodePacketMain:           {name: "DCC packet on main"},
+	OpCodePacketProg:           {name: "DCC packet on prog"},
+	OpCodeEXRAIL:               {name: "EX-RAIL"},
+}
+
+// Name returns the human-readable name of the op code.
+// For undocumented op codes it's the op code itself.
+func (o OpCode) Name() string {
+	info, ok := opCodeInfos[o]
+	if !ok {
+		return string(o)
+	}
+
+	return info.name
+}
+
+// IsResponse returns true if the op code is sent by the command station.
+// Some op codes are used in both directions (e.g. <#>).
+func (o OpCode) IsResponse() bool {
+	return opCodeInfos[o].isResponse
+}
+
+// IsBroadcast returns true if the command station might send the op code unrequested (e.g. <Q 5> or <l ...>).
+func (o OpCode) IsBroadcast() bool {
+	return opCodeInfos[o].isBroadcast
+}
+
+// ExpectedResponse returns the op code of the command station's response to commands with the op code (e.g. <H> for <T>).
+// It returns false for unknown op codes and for commands the command station doesn't respond to.
+func (o OpCode) ExpectedResponse() (OpCode, bool) {
+	info := opCodeInfos[o]
+	return info.response, info.response != 0
+}
+
+// MultiOpCode is an op code consisting of multiple runes like the J-series commands <JT> or <jT>.
+// Its first rune is the command's op code whereas the remaining runes are represented
+// as the command's first parameter (see NewCommandFromString).
+type MultiOpCode string
+
+const (
+	OpCodeAutomations                MultiOpCode = "JA"
+	OpCodeAutomationsResponse        MultiOpCode = "jA"
+	OpCodeFastClock                  MultiOpCode = "JC"
+	OpCodeFastClockResponse          MultiOpCode = "jC"
+	OpCodeTrackCurrents              MultiOpCode = "JI"
+	OpCodeTrackCurrentsResponse      MultiOpCode = "jI"
+	OpCodeRoster                     MultiOpCode = "JR"
+	OpCodeRosterResponse             MultiOpCode = "jR"
+	OpCodeTurntables                 MultiOpCode = "JO"
+	OpCodeTurntablesResponse         MultiOpCode = "jO"
+	OpCodeTurntablePositions         MultiOpCode = "JP"
+	OpCodeTurntablePositionsResponse MultiOpCode = "jP"
+	OpCodeTurnouts                   MultiOpCode = "JT"
+	OpCodeTurnoutsResponse           MultiOpCode = "jT"
 )
 
 type Command struct {
 	opCode     OpCode
 	format     string
 	parameters []any
+	// raw is the original frame the command was parsed from.
+	raw string
 }
 
 // NewCommand returns a new memory representation of an opcode together with parameters.
@@ -46,6 +191,17 @@ func NewCommand(opCode OpCode, format string, parameters ...any) *Command {
 	}
 }
 
+// NewMultiCommand returns a new memory representation of a multi rune op code together with parameters.
+// The command <JT> is represented as <J T>.
+func NewMultiCommand(opCode MultiOpCode, format string, parameters ...any) *Command {
+	subOpCode := opCode.subOpCode()
+	if format == "" {
+		return NewCommand(opCode.OpCode(), "%s", subOpCode)
+	}
+
+	return NewCommand(opCode.OpCode(), "%s "+format, append([]any{subOpCode}, parameters...)...)
+}
+
 // NewControlCommand returns a command's memory representation including a control command.
 // This control command cannot be interpreted by DCC-EX which causes a <*...><X> sent at the end
 // of the output of the preceeding valid command.
@@ -68,65 +224,13 @@ func NewControlCommand(opCode OpCode, format string, parameters ...any) *Command
 // first paramter is always performed using a separating whitespace.
 // A good example is the <JT> command whose internal representation is <J T>.
 // Also its response <jT> is represented as <j T> within the Command struct.
+// Quoted parameters can contain whitespaces, < and > as well as escaped quotes (\") and backslashes (\\).
+// They are escaped the same way by String.
 func NewCommandFromString(command string) (*Command, error) {
-	commandTrimmed := strings.Trim(command, "<>")
-	if len(commandTrimmed) == 0 {
-		return nil, fmt.Errorf("invalid command length: %q", command)
-	}
-
-	opCode := commandTrimmed[0]
+	parser := parserPool.Get().(*Parser)
+	defer parserPool.Put(parser)
 
-	// Trim unwanted whitespaces from left and right.
-	commandWithoutOpCode := strings.Trim(commandTrimmed[1:], " ")
-
-	formatStrings := []string{}
-	parameters := []any{}
-	readingParameter := []rune{}
-	readingQuotedString := false
-
-	storeParameterF := func() {
-		// In case the parameter was quoted persist this information by
-		// setting its format string to %q.
-		if slices.Contains(readingParameter, '"') {
-			formatStrings = append(formatStrings, "%q")
-		} else {
-			formatStrings = append(formatStrings, "%s")
-		}
-
-		// Now trim off the quotes if present.
-		parameters = append(parameters, strings.Trim(string(readingParameter), `"`))
-	}
-
-	for _, commandRune := range commandWithoutOpCode {
-		// The end of the parameter is reached.
-		// Insert it into the list of command parameters.
-		if commandRune == ' ' && !readingQuotedString {
-			storeParameterF()
-
-			readingParameter = []rune{}
-			continue
-		}
-
-		if commandRune == '"' {
-			if !readingQuotedString {
-				readingQuotedString = true
-			} else {
-				readingQuotedString = false
-			}
-		}
-
-		readingParameter = append(readingParameter, commandRune)
-	}
-
-	if len(readingParameter) > 0 {
-		storeParameterF()
-	}
-
-	return &Command{
-		opCode:     OpCode(opCode),
-		format:     strings.Join(formatStrings, " "),
-		parameters: parameters,
-	}, nil
+	return parser.ParseString(command)
 }
 
 func (c *Command) String() string {
@@ -138,6 +242,27 @@ func (c *Command) String() string {
 	return fmt.Sprintf(fmt.Sprintf("<%c %s>", c.opCode, c.format), c.parameters...)
 }
 
+// Raw returns the original frame the command was parsed from (e.g. <jT 1 2>) exactly as it was received.
+// For commands which weren't parsed from a string, it falls back to the command's string representation.
+func (c *Command) Raw() string {
+	if c.raw != "" {
+		return c.raw
+	}
+
+	return c.String()
+}
+
+// WithOpCode returns a copy of the command using the given op code.
+// The original frame is preserved.
+func (c *Command) WithOpCode(opCode OpCode) *Command {
+	return &Command{
+		opCode:     opCode,
+		format:     c.format,
+		parameters: c.parameters,
+		raw:        c.raw,
+	}
+}
+
 func (c *Command) Bytes() []byte {
 	return []byte(fmt.Sprintf("%s\n", c.String()))
 }
@@ -176,3 +301,37 @@ func (c *Command) ParametersStrings() ([]string, error) {
 func (c *Command) Append(command *Command) *Command {
 	return NewCommand(c.opCode, c.format+"%s%c "+command.format, append(append(c.parameters, "><", command.OpCode()), command.parameters...)...)
 }
+
+// OpCode returns the op code of the command.
+// It's OpCodeNull for an empty multi rune op code.
+func (o MultiOpCode) OpCode() OpCode {
+	if o == "" {
+		return OpCodeNull
+	}
+
+	return OpCode([]rune(o)[0])
+}
+
+// subOpCode returns the runes following the op code.
+func (o MultiOpCode) subOpCode() string {
+	if o == "" {
+		return ""
+	}
+
+	return string([]rune(o)[1:])
+}
+
+// MultiOpCode returns the command's multi rune op code.
+// For commands of the J-series like <j T 1 2> it's jT.
+// For any other command it's the op code only.
+func (c *Command) MultiOpCode() MultiOpCode {
+	if (c.opCode == OpCodeTrackInfo || c.opCode == OpCodeTrackInfoResponse) && len(c.parameters) > 0 {
+		subOpCode, ok := c.parameters[0].(string)
+		// Sub op codes are a single uppercase letter.
+		if ok && len(subOpCode) == 1 && subOpCode[0] >= 'A' && subOpCode[0] <= 'Z' {
+			return MultiOpCode([]rune{rune(c.opCode), rune(subOpCode[0])})
+		}
+	}
+
+	return MultiOpCode(string(c.opCode))
+}
@@ -0,0 +1,43 @@
+package command
+
+import (
+	"testing"
+)
+
+var benchmarkFrames = []string{
+	"<Q 5>",
+	"<l 3 0 178 1>",
+	`<iDCC-EX V-5.0.7 / MEGA / STANDARD_MOTOR_SHIELD G-9db6d36>`,
+	`<@ 0 3 "Ready">`,
+	"<H 5 SERVO 100 400 200 2 1>",
+}
+
+// BenchmarkNewCommandFromString measures parsing of typical broadcasts.
+func BenchmarkNewCommandFromString(b *testing.B) {
+	b.ReportAllocs()
+
+	for i := range b.N {
+		_, err := NewCommandFromString(benchmarkFrames[i%len(benchmarkFrames)])
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkParser measures parsing of typical broadcasts using a single parser like the protocol does.
+func BenchmarkParser(b *testing.B) {
+	b.ReportAllocs()
+
+	parser := NewParser()
+	frames := make([][]byte, 0, len(benchmarkFrames))
+	for _, frame := range benchmarkFrames {
+		frames = append(frames, []byte(frame))
+	}
+
+	for i := range b.N {
+		_, err := parser.Parse(frames[i%len(frames)])
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -117,6 +117,13 @@ func TestNewCommandFromString(t *testing.T) {
 			format:     "%q",
 			parameters: []any{"b"},
 		},
+		{
+			name:       "quoted string parameter with escaped quotes and delimiters",
+			command:    `<@ 0 2 "say \"hi\" <now>">`,
+			opCode:     '@',
+			format:     "%s %s %q",
+			parameters: []any{"0", "2", `say "hi" <now>`},
+		},
 		// Real examples from DCC-EX.
 		{
 			name:    "DCC-EX status command",
@@ -164,3 +171,211 @@ func TestNewCommandFromString(t *testing.T) {
 		}
 	}
 }
+
+func TestMultiOpCode(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		opCode  MultiOpCode
+	}{
+		{
+			name:    "request",
+			command: "<JT>",
+			opCode:  OpCodeTurnouts,
+		},
+		{
+			name:    "response with parameters",
+			command: "<jT 1 2 3>",
+			opCode:  OpCodeTurnoutsResponse,
+		},
+		{
+			name:    "response with separated sub op code",
+			command: "<j I 100 0>",
+			opCode:  OpCodeTrackCurrentsResponse,
+		},
+		{
+			name:    "single rune op code",
+			command: "<Q 1>",
+			opCode:  "Q",
+		},
+		{
+			name:    "numeric parameter",
+			command: "<j 1>",
+			opCode:  "j",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cmd, err := NewCommandFromString(test.command)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if cmd.MultiOpCode() != test.opCode {
+				t.Errorf("Expected op code %q but got %q", test.opCode, cmd.MultiOpCode())
+			}
+		})
+	}
+}
+
+func TestNewMultiCommand(t *testing.T) {
+	cmd := NewMultiCommand(OpCodeRoster, "")
+	if cmd.String() != "<J R>" {
+		t.Errorf("Expected <J R> but got %s", cmd.String())
+	}
+
+	cmd = NewMultiCommand(OpCodeRosterResponse, "%d %d", 1, 2)
+	if cmd.String() != "<j R 1 2>" || cmd.MultiOpCode() != OpCodeRosterResponse {
+		t.Errorf("Expected <j R 1 2> but got %s", cmd.String())
+	}
+}
+
+func TestOpCodeName(t *testing.T) {
+	tests := map[OpCode]string{
+		OpCodePowerOn:  "power on",
+		OpCodePowerOff: "power off",
+		OpCodeReadCV:   "read CV",
+		OpCodeEXRAIL:   "EX-RAIL",
+		'~':            "~",
+	}
+
+	for opCode, name := range tests {
+		if opCode.Name() != name {
+			t.Errorf("Expected name %q for op code %c but got %q", name, opCode, opCode.Name())
+		}
+	}
+}
+
+func TestRaw(t *testing.T) {
+	cmd, err := NewCommandFromString("<jT 1 2>")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cmd.Raw() != "<jT 1 2>" {
+		t.Errorf("Expected <jT 1 2> but got %s", cmd.Raw())
+	}
+
+	if cmd.WithOpCode(OpCodeTurnoutResponse).Raw() != "<jT 1 2>" {
+		t.Error("Expected the raw frame to be preserved")
+	}
+
+	cmd = NewCommand(OpCodeCabSpeed, "%d %d %d", 3, 50, 1)
+	if cmd.Raw() != "<t 3 50 1>" {
+		t.Errorf("Expected <t 3 50 1> but got %s", cmd.Raw())
+	}
+}
+
+func TestParserReuse(t *testing.T) {
+	parser := NewParser()
+	frame := []byte(`<@ 0 3 "Ready">`)
+
+	first, err := parser.Parse(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Overwrite the frame and the parser's buffers.
+	copy(frame, "<H 5 SERVO 1>  ")
+	_, err = parser.Parse(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first.String() != `<@ 0 3 "Ready">` {
+		t.Errorf("Expected <@ 0 3 \"Ready\"> but got %s", first)
+	}
+}
+
+func TestQuotedRoundTrip(t *testing.T) {
+	frame := `<@ 0 2 "say \"hi\" > C:\\ now">`
+
+	cmd, err := NewCommandFromString(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cmd.String() != frame {
+		t.Errorf("Expected %s but got %s", frame, cmd.String())
+	}
+}
+
+func TestOpCodeClassification(t *testing.T) {
+	if !OpCodeSensorActive.IsResponse() || !OpCodeSensorActive.IsBroadcast() {
+		t.Error("Expected <Q> to be a broadcast response")
+	}
+
+	if OpCodeTurnout.IsResponse() || OpCodeTurnout.IsBroadcast() {
+		t.Error("Expected <T> not to be a response")
+	}
+
+	if OpCodeSuccess.IsBroadcast() {
+		t.Error("Expected <O> not to be a broadcast")
+	}
+
+	response, ok := OpCodeTurnout.ExpectedResponse()
+	if !ok || response != OpCodeTurnoutResponse {
+		t.Errorf("Expected response %c for <T> but got %c", OpCodeTurnoutResponse, response)
+	}
+
+	_, ok = OpCodeAccessory.ExpectedResponse()
+	if ok {
+		t.Error("Expected no response for <a>")
+	}
+}
+
+func TestNewInfoMessage(t *testing.T) {
+	tests := []struct {
+		command  string
+		expected InfoMessage
+		err      bool
+	}{
+		{
+			command:  `<@ 0 3 "Ready">`,
+			expected: InfoMessage{Display: 0, Line: 3, Text: "Ready"},
+		},
+		{
+			command:  `<@ 1 2 "PWR On JOIN">`,
+			expected: InfoMessage{Display: 1, Line: 2, Text: "PWR On JOIN"},
+		},
+		{
+			command:  `<@ 0 4>`,
+			expected: InfoMessage{Display: 0, Line: 4},
+		},
+		{
+			command: `<@ x 4 "Ready">`,
+			err:     true,
+		},
+		{
+			command: `<H 1 0>`,
+			err:     true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.command, func(t *testing.T) {
+			cmd, err := NewCommandFromString(test.command)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			info, err := NewInfoMessage(cmd)
+			if test.err {
+				if err == nil {
+					t.Errorf("Expected an error for %q", test.command)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if *info != test.expected {
+				t.Errorf("Expected %+v, got %+v", test.expected, *info)
+			}
+		})
+	}
+}
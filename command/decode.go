@@ -0,0 +1,91 @@
+package command
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// decodeTag is the struct tag selecting the parameter decoded into a field.
+const decodeTag = "dccex"
+
+// Decode unmarshals the command's parameters into the struct pointed to by target.
+// Every field to decode is tagged with the index of its parameter, e.g. `dccex:"2"`.
+// Untagged fields and fields tagged with `dccex:"-"` are left untouched.
+// Supported are fields of kind string, bool (0 or 1) and any signed or unsigned integer
+// including named types like turnout.VPin.
+// An error is returned if the command has fewer parameters than referenced by the tags
+// or if a parameter cannot be converted into its field's type.
+func Decode(cmd *Command, target any) error {
+	value := reflect.ValueOf(target)
+	if value.Kind() != reflect.Pointer || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("failed to decode %q: target must be a pointer to a struct", cmd.String())
+	}
+
+	params, err := cmd.ParametersStrings()
+	if err != nil {
+		return fmt.Errorf("failed to decode %q: %w", cmd.String(), err)
+	}
+
+	structValue := value.Elem()
+	structType := structValue.Type()
+
+	for i := range structType.NumField() {
+		field := structType.Field(i)
+
+		tag, ok := field.Tag.Lookup(decodeTag)
+		if !ok || tag == "-" {
+			continue
+		}
+
+		index, err := strconv.Atoi(tag)
+		if err != nil || index < 0 {
+			return fmt.Errorf("failed to decode %q: invalid tag %q of field %s", cmd.String(), tag, field.Name)
+		}
+
+		if index >= len(params) {
+			return fmt.Errorf("failed to decode %q: missing parameter %d for field %s", cmd.String(), index, field.Name)
+		}
+
+		err = decodeParameter(params[index], structValue.Field(i))
+		if err != nil {
+			return fmt.Errorf("failed to decode %q: invalid parameter %d for field %s: %w", cmd.String(), index, field.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func decodeParameter(param string, field reflect.Value) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(param)
+	case reflect.Bool:
+		switch param {
+		case "0":
+			field.SetBool(false)
+		case "1":
+			field.SetBool(true)
+		default:
+			return fmt.Errorf("%q is neither 0 nor 1", param)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		value, err := strconv.ParseInt(param, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+
+		field.SetInt(value)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		value, err := strconv.ParseUint(param, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+
+		field.SetUint(value)
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package command
+
+import (
+	"testing"
+)
+
+type vpin uint16
+
+type decodeTarget struct {
+	ID      uint16 `dccex:"0"`
+	Kind    string `dccex:"1"`
+	VPin    vpin   `dccex:"2"`
+	Offset  int8   `dccex:"3"`
+	Active  bool   `dccex:"4"`
+	Ignored string
+}
+
+func TestDecode(t *testing.T) {
+	cmd, err := NewCommandFromString("<H 5 SERVO 100 -3 1>")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	target := &decodeTarget{Ignored: "kept"}
+	err = Decode(cmd, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := decodeTarget{ID: 5, Kind: "SERVO", VPin: 100, Offset: -3, Active: true, Ignored: "kept"}
+	if *target != expected {
+		t.Errorf("Expected %+v but got %+v", expected, *target)
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+	}{
+		{
+			name:    "missing parameter",
+			command: "<H 5 SERVO 100 -3>",
+		},
+		{
+			name:    "overflow",
+			command: "<H 5 SERVO 70000 -3 1>",
+		},
+		{
+			name:    "invalid bool",
+			command: "<H 5 SERVO 100 -3 T>",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cmd, err := NewCommandFromString(test.command)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = Decode(cmd, &decodeTarget{})
+			if err == nil {
+				t.Error("Expected decoding to fail")
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+)
+
+// responseParameters are the names of the parameters of the command station's responses.
+// The names of the parameters of the other commands are taken from their forms (see Validate).
+var responseParameters = map[OpCode][]string{
+	OpCodePower:                {"state", "track"},
+	OpCodeInfo:                 {"display", "line", "text"},
+	OpCodeStatusResponse:       {"version"},
+	OpCodeEEPROMResponse:       {"turnouts", "sensors", "outputs"},
+	OpCodeCabResponse:          {"cab address", "register", "speed", "functions"},
+	OpCodeSensorActive:         {"id", "vpin", "pullup"},
+	OpCodeSensorInactive:       {"id"},
+	OpCodeTurnoutResponse:      {"id", "state"},
+	OpCodeOutputResponse:       {"id", "state"},
+	OpCodeTrackInfoResponse:    {"type"},
+	OpCodeReadCVResponse:       {"cv", "value"},
+	OpCodeVerifyCVResponse:     {"cv", "value"},
+	OpCodeTrackManager:         {"track", "mode"},
+	OpCodeStationSupportedCabs: {"cabs"},
+	OpCodeCurrent:              {"meter", "current", "type", "unit", "", "max", "", "trip"},
+	OpCodeTurntable:            {"id", "position", "moving"},
+}
+
+// DescribedParameter is a single parameter of a described command.
+type DescribedParameter struct {
+	// Name is the meaning of the parameter (e.g. "cab address"). It's empty if unknown.
+	Name  string
+	Value string
+}
+
+// Description is a human-readable explanation of a command.
+type Description struct {
+	OpCode OpCode
+	// Name is the name of the op code (see OpCode.Name).
+	Name       string
+	Response   bool
+	Broadcast  bool
+	Parameters []DescribedParameter
+}
+
+// Describe explains the given command using the op code catalog, e.g. <t 3 50 1> is described as
+// "cab speed (cab address=3 speed=50 direction=1)".
+// Diagnostic messages (<* ... *>) are described using their message as a single parameter.
+func Describe(cmd *Command) *Description {
+	description := &Description{
+		OpCode:    cmd.OpCode(),
+		Name:      cmd.OpCode().Name(),
+		Response:  cmd.OpCode().IsResponse(),
+		Broadcast: cmd.OpCode().IsBroadcast(),
+	}
+
+	if cmd.OpCode() == OpCodeDescribe {
+		diagnostic, err := NewDiagnostic(cmd)
+		if err == nil {
+			description.Parameters = []DescribedParameter{{Name: "message", Value: diagnostic.Message}}
+		}
+
+		return description
+	}
+
+	params, err := cmd.wireParameters()
+	if err != nil {
+		params = nil
+	}
+
+	names := parameterNames(cmd.OpCode(), params)
+	for i, param := range params {
+		described := DescribedParameter{Value: param}
+		if i < len(names) {
+			described.Name = names[i]
+		}
+
+		description.Parameters = append(description.Parameters, described)
+	}
+
+	return description
+}
+
+// parameterNames returns the names of the given parameters.
+func parameterNames(opCode OpCode, params []string) []string {
+	names, ok := responseParameters[opCode]
+	if ok {
+		return names
+	}
+
+	for _, form := range forms[opCode] {
+		if len(form) != len(params) || !selectorsMatch(form, params) {
+			continue
+		}
+
+		names := make([]string, 0, len(form))
+		for _, p := range form {
+			names = append(names, p.name)
+		}
+
+		return names
+	}
+
+	return nil
+}
+
+func (d *Description) String() string {
+	if len(d.Parameters) == 0 {
+		return d.Name
+	}
+
+	params := make([]string, 0, len(d.Parameters))
+	for _, param := range d.Parameters {
+		if param.Name == "" {
+			params = append(params, param.Value)
+			continue
+		}
+
+		params = append(params, fmt.Sprintf("%s=%s", param.Name, param.Value))
+	}
+
+	return fmt.Sprintf("%s (%s)", d.Name, strings.Join(params, " "))
+}
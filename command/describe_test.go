@@ -0,0 +1,55 @@
+package command
+
+import (
+	"testing"
+)
+
+func TestDescribe(t *testing.T) {
+	tests := []struct {
+		command  string
+		expected string
+	}{
+		{
+			command:  "<t 3 50 1>",
+			expected: "cab speed (cab address=3 speed=50 direction=1)",
+		},
+		{
+			command:  "<T 5 SERVO 100 400 200 2>",
+			expected: "turnout (id=5 type=SERVO vpin=100 position=400 position=200 profile=2)",
+		},
+		{
+			command:  "<l 3 0 178 0>",
+			expected: "cab state (cab address=3 register=0 speed=178 functions=0)",
+		},
+		{
+			command:  `<@ 0 3 "Ready">`,
+			expected: "info (display=0 line=3 text=Ready)",
+		},
+		{
+			command:  "<* Sensor 5 active *>",
+			expected: "diagnostic message (message=Sensor 5 active)",
+		},
+		{
+			command:  "<s>",
+			expected: "status",
+		},
+		{
+			command:  "<t 3 50 1 2 7>",
+			expected: "cab speed (3 50 1 2 7)",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.command, func(t *testing.T) {
+			cmd, err := NewCommandFromString(test.command)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			description := Describe(cmd)
+			if description.String() != test.expected {
+				t.Errorf("Expected %q, got %q", test.expected, description.String())
+			}
+		})
+	}
+}
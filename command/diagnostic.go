@@ -0,0 +1,41 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+)
+
+// controlDiagnostic is the message caused by control commands (see NewControlCommand).
+const controlDiagnostic = "Opcode=X params=0"
+
+// Diagnostic is a diagnostic message sent by the command station: <* message *>.
+type Diagnostic struct {
+	// Message is the text between the markers as it was received.
+	Message string
+}
+
+// NewDiagnostic returns the diagnostic message of the given <* ... *> command.
+// Unlike the command's parameters, the message doesn't contain the trailing marker.
+func NewDiagnostic(cmd *Command) (*Diagnostic, error) {
+	if cmd.OpCode() != OpCodeDescribe {
+		return nil, fmt.Errorf("failed to get diagnostic from %q: invalid op code %q", cmd.String(), cmd.OpCode())
+	}
+
+	// Use the raw frame to preserve the message's original whitespaces and quotes.
+	message := strings.Trim(cmd.Raw(), "<>")
+	message = strings.TrimPrefix(message, string(OpCodeDescribe))
+	message = strings.TrimSuffix(message, string(OpCodeDescribe))
+
+	return &Diagnostic{
+		Message: strings.TrimSpace(message),
+	}, nil
+}
+
+// Control returns true if the diagnostic was caused by a control command.
+func (d *Diagnostic) Control() bool {
+	return d.Message == controlDiagnostic
+}
+
+func (d *Diagnostic) String() string {
+	return fmt.Sprintf("<* %s *>", d.Message)
+}
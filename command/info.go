@@ -0,0 +1,55 @@
+package command
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// InfoMessage is an info broadcast of the command station which is shown on its displays: <@ display line "text">.
+type InfoMessage struct {
+	Display int
+	Line    int
+	Text    string
+}
+
+// NewInfoMessage returns the info message of the given <@ ...> command.
+func NewInfoMessage(cmd *Command) (*InfoMessage, error) {
+	if cmd.OpCode() != OpCodeInfo {
+		return nil, fmt.Errorf("failed to get info message from %q: invalid op code %q", cmd.String(), cmd.OpCode())
+	}
+
+	params, err := cmd.ParametersStrings()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get info message from %q: %w", cmd.String(), err)
+	}
+
+	if len(params) < 2 || len(params) > 3 {
+		return nil, fmt.Errorf("failed to get info message from %q: invalid parameter length %d", cmd.String(), len(params))
+	}
+
+	display, err := strconv.Atoi(params[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert info message display to int: %w", err)
+	}
+
+	line, err := strconv.Atoi(params[1])
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert info message line to int: %w", err)
+	}
+
+	info := &InfoMessage{
+		Display: display,
+		Line:    line,
+	}
+
+	// Cleared lines are sent without any text.
+	if len(params) == 3 {
+		info.Text = params[2]
+	}
+
+	return info, nil
+}
+
+func (i *InfoMessage) String() string {
+	return fmt.Sprintf("<@ %d %d %q>", i.Display, i.Line, i.Text)
+}
@@ -0,0 +1,76 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// commandJSON is the JSON representation of a command:
+// {"opCode":"t","params":["3","50","1"],"raw":"<t 3 50 1>"}
+type commandJSON struct {
+	OpCode string   `json:"opCode"`
+	Params []string `json:"params"`
+	Raw    string   `json:"raw"`
+}
+
+// MarshalJSON implements json.Marshaler.
+// The parameters are formatted using their default format.
+func (c *Command) MarshalJSON() ([]byte, error) {
+	params := make([]string, 0, len(c.parameters))
+	for _, parameter := range c.parameters {
+		params = append(params, fmt.Sprint(parameter))
+	}
+
+	return json.Marshal(commandJSON{
+		OpCode: string(c.opCode),
+		Params: params,
+		Raw:    c.Raw(),
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+// If present the raw command takes precedence, otherwise the command is built from the op code and parameters.
+func (c *Command) UnmarshalJSON(data []byte) error {
+	var decoded commandJSON
+	err := json.Unmarshal(data, &decoded)
+	if err != nil {
+		return err
+	}
+
+	if decoded.Raw != "" {
+		cmd, err := NewCommandFromString(decoded.Raw)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal command: %w", err)
+		}
+
+		if decoded.OpCode != "" && decoded.OpCode != string(cmd.opCode) {
+			return fmt.Errorf("failed to unmarshal command: op code %q does not match raw command %q", decoded.OpCode, decoded.Raw)
+		}
+
+		*c = *cmd
+		return nil
+	}
+
+	opCode, size := utf8.DecodeRuneInString(decoded.OpCode)
+	if size == 0 || size != len(decoded.OpCode) {
+		return fmt.Errorf("failed to unmarshal command: invalid op code %q", decoded.OpCode)
+	}
+
+	formatStrings := make([]string, 0, len(decoded.Params))
+	parameters := make([]any, 0, len(decoded.Params))
+	for _, param := range decoded.Params {
+		// Parameters which cannot be represented without quotes are quoted like DCC-EX does.
+		if param == "" || strings.ContainsAny(param, " <>\"\\") {
+			formatStrings = append(formatStrings, "%q")
+		} else {
+			formatStrings = append(formatStrings, "%s")
+		}
+
+		parameters = append(parameters, param)
+	}
+
+	*c = *NewCommand(OpCode(opCode), strings.Join(formatStrings, " "), parameters...)
+	return nil
+}
@@ -0,0 +1,82 @@
+package command
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCommandJSON(t *testing.T) {
+	cmd := NewCommand(OpCodeInfo, "%d %d %q", 0, 3, "Ready")
+
+	data, err := json.Marshal(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fields := map[string]any{}
+	err = json.Unmarshal(data, &fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fields["opCode"] != "@" || fields["raw"] != `<@ 0 3 "Ready">` {
+		t.Errorf("Unexpected JSON representation %s", data)
+	}
+
+	decoded := &Command{}
+	err = json.Unmarshal(data, decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.String() != cmd.String() {
+		t.Errorf("Expected %s but got %s", cmd, decoded)
+	}
+}
+
+func TestCommandJSONWithoutRaw(t *testing.T) {
+	tests := []struct {
+		data     string
+		expected string
+	}{
+		{
+			data:     `{"opCode":"t","params":["3","50","1"]}`,
+			expected: "<t 3 50 1>",
+		},
+		{
+			data:     `{"opCode":"s"}`,
+			expected: "<s>",
+		},
+		{
+			data:     `{"opCode":"@","params":["0","3","Ready now"]}`,
+			expected: `<@ 0 3 "Ready now">`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expected, func(t *testing.T) {
+			cmd := &Command{}
+			err := json.Unmarshal([]byte(test.data), cmd)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if cmd.String() != test.expected {
+				t.Errorf("Expected %s but got %s", test.expected, cmd)
+			}
+		})
+	}
+}
+
+func TestCommandJSONInvalid(t *testing.T) {
+	for _, data := range []string{
+		`{"opCode":"ts"}`,
+		`{"opCode":""}`,
+		`{"opCode":"s","raw":"<t 3>"}`,
+	} {
+		err := json.Unmarshal([]byte(data), &Command{})
+		if err == nil {
+			t.Errorf("Expected unmarshaling %s to fail", data)
+		}
+	}
+}
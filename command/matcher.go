@@ -0,0 +1,83 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Wildcard matches any parameter value.
+const Wildcard = "*"
+
+// Matcher matches commands by op code and a subset of their parameters.
+// Parameters are compared by position. Wildcards match any value and parameters following
+// the matcher's parameters are ignored, so <H 5 *> matches <H 5 1> as well as <H 5 SERVO 100 400 200 2 1>.
+// This keeps matching stable in case DCC-EX appends parameters to its responses.
+type Matcher struct {
+	OpCode     OpCode
+	Parameters []string
+}
+
+// NewMatcher returns a new matcher for the given op code and parameters.
+// The parameters are formatted using their default format, use Wildcard to match any value.
+func NewMatcher(opCode OpCode, parameters ...any) *Matcher {
+	matcher := &Matcher{
+		OpCode:     opCode,
+		Parameters: make([]string, 0, len(parameters)),
+	}
+
+	for _, parameter := range parameters {
+		matcher.Parameters = append(matcher.Parameters, fmt.Sprint(parameter))
+	}
+
+	return matcher
+}
+
+// NewMatcherFromString parses a matcher from its string representation (e.g. <H 5 * 1>).
+func NewMatcherFromString(matcher string) (*Matcher, error) {
+	cmd, err := NewCommandFromString(matcher)
+	if err != nil {
+		return nil, fmt.Errorf("invalid matcher: %w", err)
+	}
+
+	params, err := cmd.ParametersStrings()
+	if err != nil {
+		return nil, fmt.Errorf("invalid matcher: %w", err)
+	}
+
+	return &Matcher{
+		OpCode:     cmd.OpCode(),
+		Parameters: params,
+	}, nil
+}
+
+// Match returns true if the command has the matcher's op code and parameters.
+func (m *Matcher) Match(cmd *Command) bool {
+	if cmd.OpCode() != m.OpCode {
+		return false
+	}
+
+	params := cmd.Parameters()
+	if len(params) < len(m.Parameters) {
+		return false
+	}
+
+	for i, expected := range m.Parameters {
+		if expected == Wildcard {
+			continue
+		}
+
+		if fmt.Sprint(params[i]) != expected {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (m *Matcher) String() string {
+	if len(m.Parameters) == 0 {
+		return fmt.Sprintf("<%c>", m.OpCode)
+	}
+
+	return fmt.Sprintf("<%c %s>", m.OpCode, strings.Join(m.Parameters, " "))
+}
@@ -0,0 +1,75 @@
+package command
+
+import (
+	"testing"
+)
+
+func TestMatcher(t *testing.T) {
+	tests := []struct {
+		name     string
+		matcher  *Matcher
+		command  string
+		expected bool
+	}{
+		{
+			name:     "wildcard",
+			matcher:  NewMatcher(OpCodeTurnoutResponse, 5, Wildcard),
+			command:  "<H 5 1>",
+			expected: true,
+		},
+		{
+			name:     "additional parameters",
+			matcher:  NewMatcher(OpCodeTurnoutResponse, 5, Wildcard),
+			command:  "<H 5 SERVO 100 400 200 2 1>",
+			expected: true,
+		},
+		{
+			name:     "different parameter",
+			matcher:  NewMatcher(OpCodeTurnoutResponse, 5, Wildcard),
+			command:  "<H 6 1>",
+			expected: false,
+		},
+		{
+			name:     "missing parameter",
+			matcher:  NewMatcher(OpCodeTurnoutResponse, 5, Wildcard),
+			command:  "<H 5>",
+			expected: false,
+		},
+		{
+			name:     "different op code",
+			matcher:  NewMatcher(OpCodeTurnoutResponse, 5, Wildcard),
+			command:  "<Y 5 1>",
+			expected: false,
+		},
+		{
+			name:     "quoted parameter",
+			matcher:  NewMatcher(OpCodeInfo, 0, 3, "Ready"),
+			command:  `<@ 0 3 "Ready">`,
+			expected: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cmd, err := NewCommandFromString(test.command)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if test.matcher.Match(cmd) != test.expected {
+				t.Errorf("Expected %s matching %s to be %t", test.matcher, test.command, test.expected)
+			}
+		})
+	}
+}
+
+func TestNewMatcherFromString(t *testing.T) {
+	matcher, err := NewMatcherFromString("<H 5 *>")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if matcher.String() != "<H 5 *>" {
+		t.Errorf("Expected <H 5 *> but got %s", matcher)
+	}
+}
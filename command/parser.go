@@ -0,0 +1,152 @@
+package command
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/roosterfish/dcc-ex-go/errs"
+)
+
+// plainFormats caches the format strings of commands without quoted parameters (e.g. "%s %s").
+var plainFormats = func() []string {
+	formats := make([]string, 16)
+	for i := 1; i < len(formats); i++ {
+		formats[i] = strings.TrimSpace(strings.Repeat("%s ", i))
+	}
+
+	return formats
+}()
+
+// parserPool provides the parsers used by NewCommandFromString.
+var parserPool = sync.Pool{
+	New: func() any {
+		return NewParser()
+	},
+}
+
+// Parser parses frames into commands.
+// It reuses its internal buffers between calls, so only the returned command and its parameters are allocated.
+// It isn't allocation free as the parameters of the returned commands reference the frame's string.
+// A Parser is not safe for concurrent use.
+type Parser struct {
+	parameters []any
+	format     []byte
+	quoted     bool
+}
+
+// NewParser returns a new parser.
+func NewParser() *Parser {
+	return &Parser{
+		parameters: make([]any, 0, len(plainFormats)),
+		format:     make([]byte, 0, 3*len(plainFormats)),
+	}
+}
+
+// Parse parses the given frame into a command.
+// The frame isn't retained and can be reused by the caller, therefore it's copied into a string once
+// which is referenced by the command (see Command.Raw). Use ParseString for frames which are strings already.
+func (p *Parser) Parse(frame []byte) (*Command, error) {
+	return p.ParseString(string(frame))
+}
+
+// ParseString works like Parse but takes the frame as string.
+// See NewCommandFromString for the details of parsing.
+func (p *Parser) ParseString(frame string) (*Command, error) {
+	commandTrimmed := strings.Trim(frame, "<>")
+	if len(commandTrimmed) == 0 {
+		return nil, fmt.Errorf("invalid command length %q: %w", frame, errs.ErrMalformedCommand)
+	}
+
+	opCode := commandTrimmed[0]
+
+	// Trim unwanted whitespaces from left and right.
+	commandWithoutOpCode := strings.Trim(commandTrimmed[1:], " ")
+
+	p.parameters = p.parameters[:0]
+	p.format = p.format[:0]
+	p.quoted = false
+
+	start := 0
+	readingQuotedString := false
+	readingQuotes := false
+	readingEscapes := false
+
+	// Quotes, escapes and whitespaces are single bytes in UTF-8 so there is no need to decode runes.
+	for i := 0; i < len(commandWithoutOpCode); i++ {
+		switch commandWithoutOpCode[i] {
+		case '\\':
+			// Skip the escaped character (e.g. \" within quoted text).
+			if readingQuotedString {
+				readingEscapes = true
+				i++
+			}
+		case '"':
+			readingQuotedString = !readingQuotedString
+			readingQuotes = true
+		case ' ':
+			// The end of the parameter is reached.
+			// Insert it into the list of command parameters.
+			if !readingQuotedString {
+				p.storeParameter(commandWithoutOpCode[start:i], readingQuotes, readingEscapes)
+				start = i + 1
+				readingQuotes = false
+				readingEscapes = false
+			}
+		}
+	}
+
+	if start < len(commandWithoutOpCode) {
+		p.storeParameter(commandWithoutOpCode[start:], readingQuotes, readingEscapes)
+	}
+
+	// Copy the parameters as the parser's buffer gets reused.
+	parameters := make([]any, len(p.parameters))
+	copy(parameters, p.parameters)
+
+	return &Command{
+		opCode:     OpCode(opCode),
+		format:     p.formatString(),
+		parameters: parameters,
+		raw:        frame,
+	}, nil
+}
+
+func (p *Parser) storeParameter(parameter string, quoted bool, escaped bool) {
+	if len(p.format) > 0 {
+		p.format = append(p.format, ' ')
+	}
+
+	// In case the parameter was quoted persist this information by
+	// setting its format string to %q.
+	if quoted {
+		p.format = append(p.format, "%q"...)
+		p.quoted = true
+	} else {
+		p.format = append(p.format, "%s"...)
+	}
+
+	p.parameters = append(p.parameters, unquote(parameter, escaped))
+}
+
+// unquote trims off the quotes of a parameter if present.
+// Escaped parameters are unquoted the way %q quotes them which allows a round trip using String.
+func unquote(parameter string, escaped bool) string {
+	if escaped && len(parameter) >= 2 && parameter[0] == '"' && parameter[len(parameter)-1] == '"' {
+		unquoted, err := strconv.Unquote(parameter)
+		if err == nil {
+			return unquoted
+		}
+	}
+
+	return strings.Trim(parameter, `"`)
+}
+
+func (p *Parser) formatString() string {
+	if !p.quoted && len(p.parameters) < len(plainFormats) {
+		return plainFormats[len(p.parameters)]
+	}
+
+	return string(p.format)
+}
@@ -0,0 +1,71 @@
+package command
+
+// ResponseMatcher returns a matcher for the command station's response to the command.
+// Unlike OpCode.ExpectedResponse it considers the command's form, e.g. <T id T> is answered
+// with <H id 1> whereas turnout definitions are answered with <O>.
+// It returns false if the command station doesn't respond to the command.
+func (c *Command) ResponseMatcher() (*Matcher, bool) {
+	response, ok := c.opCode.ExpectedResponse()
+	if !ok {
+		return nil, false
+	}
+
+	params, err := c.wireParameters()
+	if err != nil {
+		return nil, false
+	}
+
+	switch c.opCode {
+	case OpCodeTurnout:
+		return c.stateResponseMatcher(params, OpCodeTurnoutResponse), true
+	case OpCodeOutput:
+		return c.stateResponseMatcher(params, OpCodeOutputResponse), true
+	case OpCodeSensorCreate:
+		// <S> lists the sensors whereas definitions and deletions are answered with <O>.
+		if len(params) == 0 {
+			return NewMatcher(OpCodeSensorActive), true
+		}
+	case OpCodeCabSpeed:
+		// The legacy form <t reg cab speed dir> contains the register first.
+		if len(params) == 4 {
+			return NewMatcher(response, params[1]), true
+		}
+
+		if len(params) > 0 {
+			return NewMatcher(response, params[0]), true
+		}
+	case OpCodeCabFunction, OpCodeVerifyCV, OpCodeTrackInfo:
+		if len(params) > 0 {
+			return NewMatcher(response, params[0]), true
+		}
+	// Only the current forms of the CV commands respond with the CV first.
+	case OpCodeReadCV:
+		if len(params) == 1 {
+			return NewMatcher(response, params[0]), true
+		}
+	case OpCodeWriteCV:
+		if len(params) == 2 {
+			return NewMatcher(response, params[0]), true
+		}
+	case OpCodeWriteCVBit:
+		if len(params) == 3 {
+			return NewMatcher(response, params[0]), true
+		}
+	}
+
+	return NewMatcher(response), true
+}
+
+// stateResponseMatcher returns the matcher for turnouts and outputs.
+// Setting the state (<T id state>) is answered with the state, listing (<T>) with the states of all of them
+// and any other form (definitions and deletions) with <O>.
+func (c *Command) stateResponseMatcher(params []string, response OpCode) *Matcher {
+	switch len(params) {
+	case 0:
+		return NewMatcher(response)
+	case 2:
+		return NewMatcher(response, params[0])
+	}
+
+	return NewMatcher(OpCodeSuccess)
+}
@@ -0,0 +1,51 @@
+package command
+
+import (
+	"testing"
+)
+
+func TestResponseMatcher(t *testing.T) {
+	tests := []struct {
+		command  *Command
+		expected string
+	}{
+		{
+			command:  NewCommand(OpCodeTurnout, "%d %c", 5, 'T'),
+			expected: "<H 5>",
+		},
+		{
+			command:  NewCommand(OpCodeTurnout, "%d SERVO %d %d %d %d", 5, 100, 400, 200, 2),
+			expected: "<O>",
+		},
+		{
+			command:  NewControlCommand(OpCodeSensorCreate, ""),
+			expected: "<Q>",
+		},
+		{
+			command:  NewCommand(OpCodeCabSpeed, "%d %d %d", 3, 50, 1),
+			expected: "<l 3>",
+		},
+		{
+			command:  NewCommand(OpCodeWriteCV, "%d %d", 29, 6),
+			expected: "<r 29>",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.command.String(), func(t *testing.T) {
+			matcher, ok := test.command.ResponseMatcher()
+			if !ok {
+				t.Fatal("Expected a response")
+			}
+
+			if matcher.String() != test.expected {
+				t.Errorf("Expected %s but got %s", test.expected, matcher)
+			}
+		})
+	}
+
+	_, ok := NewCommand(OpCodeAccessory, "%d %d %d", 1, 0, 1).ResponseMatcher()
+	if ok {
+		t.Error("Expected no response for <a>")
+	}
+}
@@ -0,0 +1,218 @@
+package command
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/roosterfish/dcc-ex-go/errs"
+)
+
+// ErrInvalidCommand is returned by Validate for commands not accepted by DCC-EX.
+// It matches errs.ErrMalformedCommand.
+var ErrInvalidCommand = errs.New("invalid command", errs.ErrMalformedCommand)
+
+// Ranges of the parameters accepted by DCC-EX.
+const (
+	MaxCabAddress       = 10293
+	MaxCabSpeed         = 127
+	MaxCabFunction      = 68
+	MaxCV               = 1024
+	MaxAccessoryAddress = 511
+	MaxLinearAddress    = 2044
+	MaxServoProfile     = 4
+	MaxID               = 32767
+	MaxVPin             = 65534
+)
+
+// parameter describes a single parameter of a command.
+// Either the parameter has to be one of the keywords or a number within the range.
+// Selector parameters pick the form of a command (e.g. SERVO in <T id SERVO ...>).
+type parameter struct {
+	name     string
+	keywords []string
+	selector bool
+	minimum  int
+	maximum  int
+}
+
+func numeric(name string, minimum int, maximum int) parameter {
+	return parameter{name: name, minimum: minimum, maximum: maximum}
+}
+
+func keyword(name string, keywords ...string) parameter {
+	return parameter{name: name, keywords: keywords}
+}
+
+func selector(keywords ...string) parameter {
+	return parameter{name: "type", keywords: keywords, selector: true}
+}
+
+var (
+	paramCabAddress = numeric("cab address", 1, MaxCabAddress)
+	paramSpeed      = numeric("speed", -1, MaxCabSpeed)
+	paramDirection  = numeric("direction", 0, 1)
+	paramFunction   = numeric("function", 0, MaxCabFunction)
+	paramBool       = numeric("state", 0, 1)
+	paramID         = numeric("id", 0, MaxID)
+	paramVPin       = numeric("vpin", 0, MaxVPin)
+	paramCV         = numeric("cv", 1, MaxCV)
+	paramCVValue    = numeric("value", 0, 255)
+	paramCVBit      = numeric("bit", 0, 7)
+	paramCallback   = numeric("callback", 0, MaxID)
+	paramAccessory  = numeric("accessory address", 0, MaxAccessoryAddress)
+	paramSubAddress = numeric("accessory sub address", 0, 3)
+	paramPosition   = numeric("position", 0, 4095)
+	paramTrack      = keyword("track", "MAIN", "PROG", "JOIN", "A", "B", "C", "D", "E", "F", "G", "H")
+)
+
+// forms lists the accepted parameter lists of every op code validated by Validate.
+// Op codes not listed here are not validated.
+var forms = map[OpCode][][]parameter{
+	OpCodeStatus:        {{}},
+	OpCodeEmergencyStop: {{}},
+	OpCodeEEPROM:        {{}},
+	OpCodePowerOn:       {{}, {paramTrack}},
+	OpCodePowerOff:      {{}, {paramTrack}},
+	OpCodeCabSpeed: {
+		{paramCabAddress},
+		{paramCabAddress, paramSpeed, paramDirection},
+		// Legacy form including the register.
+		{numeric("register", 0, MaxID), paramCabAddress, paramSpeed, paramDirection},
+	},
+	OpCodeCabFunction: {{paramCabAddress, paramFunction, paramBool}},
+	OpCodeCabForget:   {{}, {paramCabAddress}},
+	OpCodeSensorCreate: {
+		{},
+		{paramID},
+		{paramID, paramVPin, numeric("pullup", 0, 1)},
+	},
+	OpCodeTurnout: {
+		{},
+		{paramID},
+		{paramID, keyword("state", "T", "C", "0", "1", "X")},
+		{paramID, selector("SERVO"), paramVPin, paramPosition, paramPosition, numeric("profile", 0, MaxServoProfile)},
+		{paramID, selector("DCC"), paramAccessory, paramSubAddress},
+		{paramID, selector("DCC"), numeric("linear address", 1, MaxLinearAddress)},
+		{paramID, selector("VPIN"), paramVPin},
+		// Legacy DCC form without the type.
+		{paramID, paramAccessory, paramSubAddress},
+	},
+	OpCodeOutput: {
+		{},
+		{paramID},
+		{paramID, paramBool},
+		{paramID, paramVPin, numeric("iflag", 0, 7)},
+	},
+	OpCodeAccessory: {
+		{numeric("linear address", 1, MaxLinearAddress), paramBool},
+		{paramAccessory, paramSubAddress, paramBool},
+	},
+	OpCodeReadCV: {
+		{},
+		{paramCV},
+		{paramCV, paramCallback, paramCallback},
+	},
+	OpCodeWriteCV: {
+		{paramCabAddress},
+		{paramCV, paramCVValue},
+		{paramCV, paramCVValue, paramCallback, paramCallback},
+	},
+	OpCodeWriteCVBit: {
+		{paramCV, paramCVBit, paramBool},
+		{paramCV, paramCVBit, paramBool, paramCallback, paramCallback},
+	},
+	OpCodeWriteCVMain:    {{paramCabAddress, paramCV, paramCVValue}},
+	OpCodeWriteCVBitMain: {{paramCabAddress, paramCV, paramCVBit, paramBool}},
+}
+
+func (p parameter) validate(value string) error {
+	if len(p.keywords) > 0 {
+		if !slices.Contains(p.keywords, value) {
+			return fmt.Errorf("%s %q must be one of %s", p.name, value, strings.Join(p.keywords, ", "))
+		}
+
+		return nil
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("%s %q is not a number", p.name, value)
+	}
+
+	if number < p.minimum || number > p.maximum {
+		return fmt.Errorf("%s %d must be between %d and %d", p.name, number, p.minimum, p.maximum)
+	}
+
+	return nil
+}
+
+// selectorsMatch returns true if all of the form's selector parameters are present.
+func selectorsMatch(form []parameter, params []string) bool {
+	for i, p := range form {
+		if p.selector && !slices.Contains(p.keywords, params[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func validateForm(form []parameter, params []string) error {
+	for i, p := range form {
+		err := p.validate(params[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// wireParameters returns the parameters as they are sent as the format string might contain some of them (e.g. SERVO).
+// Both control commands (><X) and appended commands (><) are cut off.
+func (c *Command) wireParameters() ([]string, error) {
+	raw, _, _ := strings.Cut(c.String(), "><")
+	wire, err := NewCommandFromString(raw + ">")
+	if err != nil {
+		return nil, err
+	}
+
+	return wire.ParametersStrings()
+}
+
+// Validate checks the command's parameters against the DCC-EX specification of its op code.
+// It checks the number of parameters and their ranges (e.g. speed, cab address and vpin) which allows
+// rejecting a command before it's sent instead of waiting for the <X> response.
+// Parameters of control commands and appended commands are not validated.
+// Op codes which are unknown or responses of the command station are accepted as is.
+func (c *Command) Validate() error {
+	accepted, ok := forms[c.opCode]
+	if !ok {
+		return nil
+	}
+
+	params, err := c.wireParameters()
+	if err != nil {
+		return fmt.Errorf("%w %s: %w", ErrInvalidCommand, c.String(), err)
+	}
+
+	for _, form := range accepted {
+		if len(form) != len(params) || !selectorsMatch(form, params) {
+			continue
+		}
+
+		// The first matching form is accepted.
+		err = validateForm(form, params)
+		if err == nil {
+			return nil
+		}
+	}
+
+	if err != nil {
+		return fmt.Errorf("%w %s: %w", ErrInvalidCommand, c.String(), err)
+	}
+
+	return fmt.Errorf("%w %s: unexpected number of parameters %d", ErrInvalidCommand, c.String(), len(params))
+}
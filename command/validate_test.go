@@ -0,0 +1,88 @@
+package command
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		command *Command
+		valid   bool
+	}{
+		{
+			name:    "cab speed",
+			command: NewCommand(OpCodeCabSpeed, "%d %d %d", 3, 50, 1),
+			valid:   true,
+		},
+		{
+			name:    "cab emergency stop",
+			command: NewCommand(OpCodeCabSpeed, "%d %d %d", 3, -1, 0),
+			valid:   true,
+		},
+		{
+			name:    "cab full speed",
+			command: NewCommand(OpCodeCabSpeed, "%d %d %d", 3, 127, 1),
+			valid:   true,
+		},
+		{
+			name:    "cab speed too high",
+			command: NewCommand(OpCodeCabSpeed, "%d %d %d", 3, 128, 1),
+		},
+		{
+			name:    "cab address out of range",
+			command: NewCommand(OpCodeCabSpeed, "%d %d %d", 10294, 50, 1),
+		},
+		{
+			name:    "servo turnout",
+			command: NewCommand(OpCodeTurnout, "%d SERVO %d %d %d %d", 5, 100, 400, 200, 2),
+			valid:   true,
+		},
+		{
+			name:    "servo turnout missing profile",
+			command: NewCommand(OpCodeTurnout, "%d SERVO %d %d %d", 5, 100, 400, 200),
+		},
+		{
+			name:    "throw turnout",
+			command: NewCommand(OpCodeTurnout, "%d %c", 5, 'T'),
+			valid:   true,
+		},
+		{
+			name:    "invalid turnout state",
+			command: NewCommand(OpCodeTurnout, "%d %c", 5, 'Y'),
+		},
+		{
+			name:    "sensor vpin out of range",
+			command: NewCommand(OpCodeSensorCreate, "%d %d %d", 5, 65535, 1),
+		},
+		{
+			name:    "control command",
+			command: NewControlCommand(OpCodeSensorCreate, ""),
+			valid:   true,
+		},
+		{
+			name:    "power track",
+			command: NewCommand(OpCodePowerOn, "%s", "MAIN"),
+			valid:   true,
+		},
+		{
+			name:    "unknown op code",
+			command: NewCommand(OpCode('U'), "%s", "anything"),
+			valid:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.command.Validate()
+			if test.valid && err != nil {
+				t.Errorf("Expected %s to be valid but got %v", test.command, err)
+			}
+
+			if !test.valid && !errors.Is(err, ErrInvalidCommand) {
+				t.Errorf("Expected %s to be invalid but got %v", test.command, err)
+			}
+		})
+	}
+}
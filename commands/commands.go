@@ -0,0 +1,314 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/roosterfish/dcc-ex-go/command"
+)
+
+// ErrInvalidArgument is returned for arguments outside of the range accepted by DCC-EX.
+var ErrInvalidArgument = errors.New("invalid argument")
+
+// Ranges of the arguments accepted by DCC-EX.
+const (
+	MaxCabAddress       = command.MaxCabAddress
+	MaxCabSpeed         = command.MaxCabSpeed
+	MaxCabFunction      = command.MaxCabFunction
+	MaxCV               = command.MaxCV
+	MaxAccessoryAddress = command.MaxAccessoryAddress
+	MaxServoProfile     = command.MaxServoProfile
+)
+
+// Directions of a cab.
+const (
+	DirectionBackward uint8 = 0
+	DirectionForward  uint8 = 1
+)
+
+// Tracks which can be powered individually.
+const (
+	TrackMain = "MAIN"
+	TrackProg = "PROG"
+	TrackJoin = "JOIN"
+)
+
+func invalid(name string, value any, constraint string) error {
+	return fmt.Errorf("%w: %s %v %s", ErrInvalidArgument, name, value, constraint)
+}
+
+func checkRange(name string, value int, minimum int, maximum int) error {
+	if value < minimum || value > maximum {
+		return invalid(name, value, fmt.Sprintf("must be between %d and %d", minimum, maximum))
+	}
+
+	return nil
+}
+
+func checkCabAddress(address uint16) error {
+	return checkRange("cab address", int(address), 1, MaxCabAddress)
+}
+
+func checkCV(cv uint16) error {
+	return checkRange("cv", int(cv), 1, MaxCV)
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+
+	return 0
+}
+
+// Power powers all of the tracks on or off: <1> or <0>.
+func Power(on bool) *command.Command {
+	if on {
+		return command.NewCommand(command.OpCodePowerOn, "")
+	}
+
+	return command.NewCommand(command.OpCodePowerOff, "")
+}
+
+// PowerTrack powers the given track (MAIN, PROG or JOIN) on or off: <1 MAIN>.
+func PowerTrack(on bool, track string) (*command.Command, error) {
+	switch track {
+	case TrackMain, TrackProg, TrackJoin:
+	default:
+		return nil, invalid("track", track, "must be MAIN, PROG or JOIN")
+	}
+
+	return command.NewCommand(Power(on).OpCode(), "%s", track), nil
+}
+
+// EmergencyStop stops all of the cabs immediately: <!>.
+func EmergencyStop() *command.Command {
+	return command.NewCommand(command.OpCodeEmergencyStop, "")
+}
+
+// Status requests the command station's status: <s>.
+func Status() *command.Command {
+	return command.NewCommand(command.OpCodeStatus, "")
+}
+
+// StoreEEPROM stores the definitions of sensors, turnouts and outputs in the EEPROM: <E>.
+func StoreEEPROM() *command.Command {
+	return command.NewCommand(command.OpCodeEEPROM, "")
+}
+
+// CabSpeed sets the speed (0-127 or -1 for an emergency stop) and direction of a cab: <t addr speed dir>.
+func CabSpeed(address uint16, speed int, direction uint8) (*command.Command, error) {
+	err := checkCabAddress(address)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkRange("speed", speed, -1, MaxCabSpeed)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkRange("direction", int(direction), int(DirectionBackward), int(DirectionForward))
+	if err != nil {
+		return nil, err
+	}
+
+	return command.NewCommand(command.OpCodeCabSpeed, "%d %d %d", address, speed, direction), nil
+}
+
+// CabStatus requests the state of a cab: <t addr>.
+func CabStatus(address uint16) (*command.Command, error) {
+	err := checkCabAddress(address)
+	if err != nil {
+		return nil, err
+	}
+
+	return command.NewCommand(command.OpCodeCabSpeed, "%d", address), nil
+}
+
+// CabFunction turns a function (F0-F68) of a cab on or off: <F addr function state>.
+func CabFunction(address uint16, function uint8, on bool) (*command.Command, error) {
+	err := checkCabAddress(address)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkRange("function", int(function), 0, MaxCabFunction)
+	if err != nil {
+		return nil, err
+	}
+
+	return command.NewCommand(command.OpCodeCabFunction, "%d %d %d", address, function, boolToInt(on)), nil
+}
+
+// ForgetCab removes a cab from the command station's reminders: <- addr>.
+func ForgetCab(address uint16) (*command.Command, error) {
+	err := checkCabAddress(address)
+	if err != nil {
+		return nil, err
+	}
+
+	return command.NewCommand(command.OpCodeCabForget, "%d", address), nil
+}
+
+// DefineSensor defines a sensor on the given vpin: <S id vpin pullup>.
+func DefineSensor(id uint16, vpin uint16, pullUp bool) *command.Command {
+	return command.NewCommand(command.OpCodeSensorCreate, "%d %d %d", id, vpin, boolToInt(pullUp))
+}
+
+// DeleteSensor deletes the definition of a sensor: <S id>.
+func DeleteSensor(id uint16) *command.Command {
+	return command.NewCommand(command.OpCodeSensorCreate, "%d", id)
+}
+
+// DefineServoTurnout defines a turnout driven by a servo: <T id SERVO vpin thrown closed profile>.
+// The profile is one of 0 (instant), 1 (fast), 2 (medium), 3 (slow) or 4 (bounce).
+func DefineServoTurnout(id uint16, vpin uint16, thrownPosition uint16, closedPosition uint16, profile uint8) (*command.Command, error) {
+	err := checkRange("profile", int(profile), 0, MaxServoProfile)
+	if err != nil {
+		return nil, err
+	}
+
+	return command.NewCommand(command.OpCodeTurnout, "%d SERVO %d %d %d %d", id, vpin, thrownPosition, closedPosition, profile), nil
+}
+
+// DefineDCCTurnout defines a turnout driven by a DCC accessory decoder: <T id DCC addr subaddr>.
+func DefineDCCTurnout(id uint16, address uint16, subAddress uint8) (*command.Command, error) {
+	err := checkRange("accessory address", int(address), 0, MaxAccessoryAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkRange("accessory sub address", int(subAddress), 0, 3)
+	if err != nil {
+		return nil, err
+	}
+
+	return command.NewCommand(command.OpCodeTurnout, "%d DCC %d %d", id, address, subAddress), nil
+}
+
+// DefineVPinTurnout defines a turnout driven by a vpin: <T id VPIN vpin>.
+func DefineVPinTurnout(id uint16, vpin uint16) *command.Command {
+	return command.NewCommand(command.OpCodeTurnout, "%d VPIN %d", id, vpin)
+}
+
+// DeleteTurnout deletes the definition of a turnout: <T id>.
+func DeleteTurnout(id uint16) *command.Command {
+	return command.NewCommand(command.OpCodeTurnout, "%d", id)
+}
+
+// ThrowTurnout throws a turnout: <T id T>.
+func ThrowTurnout(id uint16) *command.Command {
+	return command.NewCommand(command.OpCodeTurnout, "%d %c", id, 'T')
+}
+
+// CloseTurnout closes a turnout: <T id C>.
+func CloseTurnout(id uint16) *command.Command {
+	return command.NewCommand(command.OpCodeTurnout, "%d %c", id, 'C')
+}
+
+// DefineOutput defines an output on the given vpin: <Z id vpin iflag>.
+func DefineOutput(id uint16, vpin uint16, iFlag uint8) (*command.Command, error) {
+	// Only the bits 0-2 are used by DCC-EX.
+	err := checkRange("iflag", int(iFlag), 0, 7)
+	if err != nil {
+		return nil, err
+	}
+
+	return command.NewCommand(command.OpCodeOutput, "%d %d %d", id, vpin, iFlag), nil
+}
+
+// DeleteOutput deletes the definition of an output: <Z id>.
+func DeleteOutput(id uint16) *command.Command {
+	return command.NewCommand(command.OpCodeOutput, "%d", id)
+}
+
+// SetOutput sets an output high or low: <Z id state>.
+func SetOutput(id uint16, high bool) *command.Command {
+	return command.NewCommand(command.OpCodeOutput, "%d %d", id, boolToInt(high))
+}
+
+// Accessory activates or deactivates a DCC accessory decoder output: <a addr subaddr activate>.
+func Accessory(address uint16, subAddress uint8, activate bool) (*command.Command, error) {
+	err := checkRange("accessory address", int(address), 0, MaxAccessoryAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkRange("accessory sub address", int(subAddress), 0, 3)
+	if err != nil {
+		return nil, err
+	}
+
+	return command.NewCommand(command.OpCodeAccessory, "%d %d %d", address, subAddress, boolToInt(activate)), nil
+}
+
+// ReadCV reads a CV on the programming track: <R cv>.
+func ReadCV(cv uint16) (*command.Command, error) {
+	err := checkCV(cv)
+	if err != nil {
+		return nil, err
+	}
+
+	return command.NewCommand(command.OpCodeReadCV, "%d", cv), nil
+}
+
+// WriteCV writes a CV on the programming track: <W cv value>.
+func WriteCV(cv uint16, value uint8) (*command.Command, error) {
+	err := checkCV(cv)
+	if err != nil {
+		return nil, err
+	}
+
+	return command.NewCommand(command.OpCodeWriteCV, "%d %d", cv, value), nil
+}
+
+// WriteCVBit writes a single bit (0-7) of a CV on the programming track: <B cv bit value>.
+func WriteCVBit(cv uint16, bit uint8, value bool) (*command.Command, error) {
+	err := checkCV(cv)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkRange("bit", int(bit), 0, 7)
+	if err != nil {
+		return nil, err
+	}
+
+	return command.NewCommand(command.OpCodeWriteCVBit, "%d %d %d", cv, bit, boolToInt(value)), nil
+}
+
+// WriteCVMain writes a CV of a cab on the main track (programming on main): <w addr cv value>.
+func WriteCVMain(address uint16, cv uint16, value uint8) (*command.Command, error) {
+	err := checkCabAddress(address)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkCV(cv)
+	if err != nil {
+		return nil, err
+	}
+
+	return command.NewCommand(command.OpCodeWriteCVMain, "%d %d %d", address, cv, value), nil
+}
+
+// WriteCVBitMain writes a single bit (0-7) of a CV of a cab on the main track: <b addr cv bit value>.
+func WriteCVBitMain(address uint16, cv uint16, bit uint8, value bool) (*command.Command, error) {
+	err := checkCabAddress(address)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkCV(cv)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkRange("bit", int(bit), 0, 7)
+	if err != nil {
+		return nil, err
+	}
+
+	return command.NewCommand(command.OpCodeWriteCVBitMain, "%d %d %d %d", address, cv, bit, boolToInt(value)), nil
+}
@@ -0,0 +1,96 @@
+package commands
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/roosterfish/dcc-ex-go/command"
+)
+
+func TestCommands(t *testing.T) {
+	tests := []struct {
+		name     string
+		build    func() (*command.Command, error)
+		expected string
+	}{
+		{
+			name:     "cab speed",
+			build:    func() (*command.Command, error) { return CabSpeed(3, 50, DirectionForward) },
+			expected: "<t 3 50 1>",
+		},
+		{
+			name:     "cab emergency stop",
+			build:    func() (*command.Command, error) { return CabSpeed(3, -1, DirectionBackward) },
+			expected: "<t 3 -1 0>",
+		},
+		{
+			name:     "servo turnout",
+			build:    func() (*command.Command, error) { return DefineServoTurnout(5, 100, 400, 200, 2) },
+			expected: "<T 5 SERVO 100 400 200 2>",
+		},
+		{
+			name:     "power track",
+			build:    func() (*command.Command, error) { return PowerTrack(true, TrackJoin) },
+			expected: "<1 JOIN>",
+		},
+		{
+			name:     "write cv bit on main",
+			build:    func() (*command.Command, error) { return WriteCVBitMain(3, 29, 5, true) },
+			expected: "<b 3 29 5 1>",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cmd, err := test.build()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if cmd.String() != test.expected {
+				t.Errorf("Expected %s but got %s", test.expected, cmd.String())
+			}
+		})
+	}
+}
+
+func TestCommandsInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func() (*command.Command, error)
+	}{
+		{
+			name:  "cab address zero",
+			build: func() (*command.Command, error) { return CabSpeed(0, 50, DirectionForward) },
+		},
+		{
+			name:  "speed too high",
+			build: func() (*command.Command, error) { return CabSpeed(3, 128, DirectionForward) },
+		},
+		{
+			name:  "invalid direction",
+			build: func() (*command.Command, error) { return CabSpeed(3, 50, 2) },
+		},
+		{
+			name:  "invalid profile",
+			build: func() (*command.Command, error) { return DefineServoTurnout(5, 100, 400, 200, 5) },
+		},
+		{
+			name:  "invalid track",
+			build: func() (*command.Command, error) { return PowerTrack(true, "A") },
+		},
+		{
+			name:  "cv out of range",
+			build: func() (*command.Command, error) { return ReadCV(1025) },
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := test.build()
+			if !errors.Is(err, ErrInvalidArgument) {
+				t.Errorf("Expected %v but got %v", ErrInvalidArgument, err)
+			}
+		})
+	}
+}
@@ -1,13 +1,21 @@
 package connection
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
 
 	"github.com/roosterfish/dcc-ex-go/cab"
 	"github.com/roosterfish/dcc-ex-go/channel"
+	"github.com/roosterfish/dcc-ex-go/clock"
+	"github.com/roosterfish/dcc-ex-go/command"
 	"github.com/roosterfish/dcc-ex-go/output"
 	"github.com/roosterfish/dcc-ex-go/protocol"
+	"github.com/roosterfish/dcc-ex-go/record"
 	"github.com/roosterfish/dcc-ex-go/sensor"
 	"github.com/roosterfish/dcc-ex-go/station"
 	"github.com/roosterfish/dcc-ex-go/turnout"
@@ -17,23 +25,129 @@ import (
 type Mode *serial.Mode
 
 type Config struct {
+	// Label identifies the command station in case multiple connections are used (e.g. multiple layouts).
+	// It's added to the logs, metrics, errors and events caused by the connection.
+	Label  string
 	Device string
 	Mode   Mode
+	// Address is the network address (host:port) of a command station reachable over TCP (e.g. WiFi).
+	// If set, the connection is established over the network instead of the serial Device.
+	Address string
+	// WebSocketURL is the URL (ws:// or wss://) of a command station or relay reachable over WebSocket.
+	// If set, the connection is established over the WebSocket instead of the network Address or serial Device.
+	// Every command is sent as a single text message.
+	WebSocketURL string
 	// RequireSubscriber sets whether or not the connections protocol listener starts to consume
 	// messages before there is a single subscriber reading commands.
 	// The default is true which allows waiting until the command station is ready.
 	RequireSubscriber bool
+	// ReadOnly refuses all writes with protocol.ErrReadOnly while still distributing broadcasts.
+	// Use it for observers (e.g. dashboards) which must never interfere with the command station.
+	// The heartbeat is disabled in read-only mode.
+	ReadOnly bool
+	// Reconnect sets whether or not the connection is reopened in case it got lost (e.g. USB unplug or station reboot).
+	// Existing readers (e.g. sensor callbacks) stay attached and continue to receive commands after reconnecting.
+	Reconnect bool
+	// ReconnectBackoff is the initial duration to wait before reconnecting.
+	// It is doubled after every failed attempt up to ReconnectMaxBackoff.
+	ReconnectBackoff    time.Duration
+	ReconnectMaxBackoff time.Duration
+	// OnDisconnect is called once the connection got lost and Reconnect is set.
+	OnDisconnect func(err error)
+	// OnReconnect is called once the connection was reopened.
+	// Use it to rerun any setup like waiting for the command station to be ready.
+	OnReconnect func()
+	// HeartbeatInterval sets how often the command station is probed to check the connection's health.
+	// The heartbeat is disabled if not set.
+	HeartbeatInterval time.Duration
+	// HeartbeatTimeout is the duration after which a probe is considered to be failed.
+	// It defaults to the HeartbeatInterval.
+	HeartbeatTimeout time.Duration
+	// HeartbeatCommand is the probe written to the command station.
+	// It defaults to <s>.
+	HeartbeatCommand *command.Command
+	// WatchdogThreshold enables reporting sessions which hold the connection for longer than the threshold.
+	// The stuck sessions including the stacks of their goroutines are reported to OnStuckSession.
+	WatchdogThreshold time.Duration
+	OnStuckSession    channel.WatchdogF
+	// OpCodeAliases maps op codes used by firmware forks to the op codes of DCC-EX.
+	// See protocol.Config for details.
+	OpCodeAliases map[command.OpCode]command.OpCode
+	// WriteRate and WriteByteRate limit the number of commands and bytes written per second.
+	// Use them to protect the command station's input buffer from bursts (e.g. throttle updates from UIs).
+	// See protocol.Config for details.
+	WriteRate      float64
+	WriteBurst     int
+	WriteByteRate  float64
+	WriteByteBurst int
+	// InboundInterceptors and OutboundInterceptors allow logging, filtering or rewriting commands.
+	// See protocol.Config for details.
+	InboundInterceptors  []protocol.InterceptorF
+	OutboundInterceptors []protocol.InterceptorF
+	// SubscriptionBuffer and Overflow define how commands are buffered for readers which don't keep up.
+	// See protocol.Config for details.
+	SubscriptionBuffer int
+	Overflow           protocol.OverflowPolicy
+	// Clock is the source of time used by the connection and all of the entities derived from it.
+	// It defaults to the real time and allows tests to advance time deterministically.
+	Clock clock.Clock
+	// Transport is used to connect to the command station if set.
+	// It takes precedence over the WebSocketURL, network Address and serial Device.
+	// Use it to supply custom transports like PTYs, SSH tunnels or test doubles.
+	Transport Transport
+	// Record is used to record the traffic in both directions if set.
+	// The recording can be replayed using record.NewReplayer.
+	Record io.Writer
+	// Logger logs every written and received command, malformed frames and the start and end of sessions.
+	// See protocol.Config for details.
+	Logger *slog.Logger
+	// Metrics receives counters, gauges and latencies of the connection if set.
+	// See protocol.Config for details.
+	Metrics protocol.Metrics
+	// OnParseError is called for every malformed frame which gets discarded.
+	// See protocol.Config for details.
+	OnParseError func(frame string, err error)
+	// Safety rejects movement commands (cab speeds above zero and powering on the tracks) with ErrDisarmed
+	// until Arm is called. Stopping cabs and powering off the tracks is always allowed.
+	// Use it to prevent test scripts from accidentally moving trains on the layout.
+	Safety bool
+	// SafetyTimeout is the duration without any movement command after which the connection gets disarmed.
+	// It defaults to DefaultSafetyTimeout.
+	SafetyTimeout time.Duration
 }
 
 type Connection struct {
 	config  *Config
 	channel *channel.Channel
+	health  *health
+	events  *events
+	stopF   func()
+
+	subscriptions *subscriptions
+	safety        *safety
+
+	// cabs is shared by the cabs derived from the connection.
+	cabs *cab.Registry
+
+	// commandStation is created on first use and shared by all of the callers.
+	commandStation     *station.CommandStation
+	commandStationLock sync.Mutex
+
+	transportLock    sync.Mutex
+	activeTransport  Transport
+	transportSession *transportSession
 }
 
 var DefaultMode Mode = &serial.Mode{
 	BaudRate: 115200,
 }
 
+// DefaultPort is the TCP port DCC-EX command stations are listening on by default.
+const DefaultPort = "2560"
+
+// webSocketDialTimeout is the maximum duration of the WebSocket handshake.
+const webSocketDialTimeout = 10 * time.Second
+
 func NewDefaultConfig(device string) *Config {
 	return &Config{
 		Device:            device,
@@ -42,40 +156,242 @@ func NewDefaultConfig(device string) *Config {
 	}
 }
 
-func NewConnection(config *Config) (*Connection, error) {
+// NewDefaultNetworkConfig returns a config to connect to the command station at the given network address.
+// In case the address doesn't contain a port, the DefaultPort is used.
+func NewDefaultNetworkConfig(address string) *Config {
+	_, _, err := net.SplitHostPort(address)
+	if err != nil {
+		address = net.JoinHostPort(address, DefaultPort)
+	}
+
+	return &Config{
+		Address:           address,
+		RequireSubscriber: true,
+	}
+}
+
+// NewDefaultWebSocketConfig returns a config to connect to the command station using the given WebSocket URL.
+func NewDefaultWebSocketConfig(url string) *Config {
+	return &Config{
+		WebSocketURL:      url,
+		RequireSubscriber: true,
+	}
+}
+
+// NewConnection opens up a new connection using the given config.
+// The options allow retrying to open the connection and waiting for the command station to be ready.
+func NewConnection(config *Config, opts ...Option) (*Connection, error) {
 	conn := &Connection{
 		config: config,
+		cabs:   cab.NewRegistry(),
+	}
+
+	o := &options{
+		openRetryDelay: DefaultOpenRetryDelay,
+	}
+
+	for _, opt := range opts {
+		opt(o)
 	}
 
-	// Open up a new serial connection.
-	port, err := conn.open()
+	// Open up a new connection using the transport.
+	port, err := conn.openWithOptions(o)
 	if err != nil {
 		return nil, err
 	}
 
-	// Wrap the serial connection with the protocol utilities.
-	connectionProtocol := protocol.NewProtocol(port, &protocol.Config{
-		RequireSubscriber: config.RequireSubscriber,
-	})
+	conn.start(port)
+
+	if o.readyTimeout > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), o.readyTimeout)
+		defer cancel()
+
+		err := conn.CommandStation().Ready(ctx)
+		if err != nil {
+			_ = conn.Close()
+			return nil, conn.labelError(fmt.Errorf("Failed waiting for the command station to be ready: %w", err))
+		}
+	}
 
-	// Expose the protocol utilities using a channel.
-	// The channel offers various entities to interact with the underlying serial connection.
-	conn.channel = channel.NewChannel(connectionProtocol)
 	return conn, nil
 }
 
-// open tries to open up a new serial connection using the given device.
+// open tries to open up a new connection and wraps it with a recorder if configured.
 func (c *Connection) open() (io.ReadWriteCloser, error) {
-	port, err := serial.Open(c.config.Device, c.config.Mode)
+	port, err := c.openPort()
 	if err != nil {
-		return nil, fmt.Errorf("Failed to open %q: %w", c.config.Device, err)
+		return nil, err
+	}
+
+	if c.config.Record != nil {
+		recorder := record.NewRecorder(port, c.config.Record)
+		recorder.SetClock(c.config.Clock)
+		return recorder, nil
 	}
 
 	return port, nil
 }
 
+// start wraps the given port with the protocol and channel utilities and starts the heartbeat.
+func (c *Connection) start(port io.ReadWriteCloser) {
+	c.health = newHealth(c.config.Label)
+	c.events = newEvents(c.config.Label, clock.Or(c.config.Clock))
+	c.subscriptions = newSubscriptions()
+
+	outboundInterceptors := c.config.OutboundInterceptors
+	if c.config.Safety {
+		c.safety = newSafety(clock.Or(c.config.Clock), c.config.SafetyTimeout, func() {
+			c.events.emit(EventDisarmed, nil)
+		})
+
+		// Check for movement commands before any of the user's interceptors.
+		outboundInterceptors = append([]protocol.InterceptorF{c.safety.intercept}, outboundInterceptors...)
+	}
+
+	// Attribute the protocol's logs and metrics to the command station.
+	logger := c.config.Logger
+	metrics := c.config.Metrics
+	if c.config.Label != "" {
+		if logger != nil {
+			logger = logger.With(slog.String("label", c.config.Label))
+		}
+
+		if metrics != nil {
+			metrics = protocol.WithLabel(metrics, c.config.Label)
+		}
+	}
+
+	protocolConfig := &protocol.Config{
+		RequireSubscriber:    c.config.RequireSubscriber,
+		ReadOnly:             c.config.ReadOnly,
+		OpCodeAliases:        c.config.OpCodeAliases,
+		WriteRate:            c.config.WriteRate,
+		WriteBurst:           c.config.WriteBurst,
+		WriteByteRate:        c.config.WriteByteRate,
+		WriteByteBurst:       c.config.WriteByteBurst,
+		InboundInterceptors:  c.config.InboundInterceptors,
+		OutboundInterceptors: outboundInterceptors,
+		SubscriptionBuffer:   c.config.SubscriptionBuffer,
+		Overflow:             c.config.Overflow,
+		Clock:                c.config.Clock,
+		Logger:               logger,
+		Metrics:              metrics,
+		ParseErrorF:          c.config.OnParseError,
+		DisconnectF: func(err error) {
+			c.health.set(false)
+			c.events.emit(EventReadError, err)
+			c.events.emit(EventDisconnected, err)
+
+			// Detach the user's callback from the protocol's listener.
+			if c.config.Reconnect && c.config.OnDisconnect != nil {
+				go c.config.OnDisconnect(err)
+			}
+		},
+	}
+
+	if c.config.Reconnect {
+		protocolConfig.ReopenF = c.open
+		protocolConfig.ReopenBackoff = c.config.ReconnectBackoff
+		protocolConfig.ReopenMaxBackoff = c.config.ReconnectMaxBackoff
+		protocolConfig.ReopeningF = func(attempt int) {
+			c.events.emit(EventReconnecting, nil)
+		}
+
+		protocolConfig.ReconnectF = func() {
+			c.health.set(true)
+			c.events.emit(EventConnected, nil)
+			c.subscriptions.gap()
+
+			// Detach the user's callback from the protocol's listener.
+			// This allows the callback to read from the connection (e.g. waiting until the station is ready).
+			if c.config.OnReconnect != nil {
+				go c.config.OnReconnect()
+			}
+		}
+	}
+
+	// Wrap the connection with the protocol utilities.
+	connectionProtocol := protocol.NewProtocol(port, protocolConfig)
+
+	// Expose the protocol utilities using a channel.
+	// The channel offers various entities to interact with the underlying serial connection.
+	c.channel = channel.NewChannel(connectionProtocol)
+	c.channel.SetLabel(c.config.Label)
+	c.channel.SetClock(c.config.Clock)
+	// The channel adds the label to its logs itself.
+	c.channel.SetLogger(c.config.Logger)
+
+	if c.config.WatchdogThreshold > 0 && c.config.OnStuckSession != nil {
+		c.channel.SetWatchdog(c.config.WatchdogThreshold, c.config.OnStuckSession)
+	}
+
+	c.stopF = c.heartbeat()
+}
+
+// transport returns the configured transport or derives one from the WebSocket URL, network address or device.
+func (c *Connection) transport() Transport {
+	if c.config.Transport != nil {
+		return c.config.Transport
+	}
+
+	if c.config.WebSocketURL != "" {
+		return NewWebSocketTransport(c.config.WebSocketURL)
+	}
+
+	if c.config.Address != "" {
+		return NewNetworkTransport(c.config.Address)
+	}
+
+	return NewSerialTransport(c.config.Device, c.config.Mode)
+}
+
+// openPort tries to (re)open the connection's transport.
+func (c *Connection) openPort() (io.ReadWriteCloser, error) {
+	c.transportLock.Lock()
+	defer c.transportLock.Unlock()
+
+	if c.activeTransport == nil {
+		c.activeTransport = c.transport()
+	}
+
+	// Close the superseded session before reopening the transport in place.
+	if c.transportSession != nil {
+		_ = c.transportSession.Close()
+	}
+
+	err := c.activeTransport.Open()
+	if err != nil {
+		return nil, c.labelError(err)
+	}
+
+	c.transportSession = &transportSession{
+		Transport: c.activeTransport,
+	}
+
+	return c.transportSession, nil
+}
+
+// labelError prefixes the given error with the connection's label.
+func (c *Connection) labelError(err error) error {
+	if c.config.Label == "" {
+		return err
+	}
+
+	return fmt.Errorf("%s: %w", c.config.Label, err)
+}
+
+// Label returns the label identifying the connection's command station.
+func (c *Connection) Label() string {
+	return c.config.Label
+}
+
+// Cab returns the cab with the given address.
+// The cabs of the same address share their history and throttle configuration (see cab.Registry).
 func (c *Connection) Cab(address cab.Address) *cab.Cab {
-	return cab.NewCab(address, c.channel)
+	loc := cab.NewCab(address, c.channel)
+	loc.SetRegistry(c.cabs)
+
+	return loc
 }
 
 func (c *Connection) Sensor(id sensor.ID) *sensor.Sensor {
@@ -94,12 +410,32 @@ func (c *Connection) OutputHeadless() *output.OutputHeadless {
 	return output.NewOutputHeadless(c.channel)
 }
 
+// CommandStation returns the connection's command station.
+// It's created on first use and shared by all of the callers.
 func (c *Connection) CommandStation() *station.CommandStation {
-	return station.NewStation(c.channel)
+	c.commandStationLock.Lock()
+	defer c.commandStationLock.Unlock()
+
+	if c.commandStation == nil {
+		c.commandStation = station.NewStation(c.channel)
+	}
+
+	return c.commandStation
 }
 
 func (c *Connection) Close() error {
-	return c.channel.Session(func(protocol protocol.ReadWriteCloser) error {
+	c.stopF()
+
+	c.commandStationLock.Lock()
+	if c.commandStation != nil {
+		c.commandStation.Close()
+	}
+	c.commandStationLock.Unlock()
+
+	err := c.channel.Session(func(protocol protocol.ReadWriteCloser) error {
 		return protocol.Close()
 	})
+
+	c.events.emit(EventClosed, err)
+	return err
 }
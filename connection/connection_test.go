@@ -0,0 +1,432 @@
+package connection
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/roosterfish/dcc-ex-go/cab"
+	"github.com/roosterfish/dcc-ex-go/clock"
+	"github.com/roosterfish/dcc-ex-go/command"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+	"github.com/roosterfish/dcc-ex-go/simulator"
+	"github.com/roosterfish/dcc-ex-go/station"
+)
+
+func TestWebSocket(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			return
+		}
+
+		// Relay the WebSocket to a simulated command station.
+		stream := websocket.NetConn(r.Context(), conn, websocket.MessageText)
+		sim := simulator.NewSimulator()
+		defer sim.Close()
+
+		go func() {
+			_, _ = io.Copy(stream, sim)
+		}()
+
+		_, _ = io.Copy(sim, stream)
+	}))
+	defer server.Close()
+
+	conn, err := NewConnection(NewDefaultWebSocketConfig("ws" + strings.TrimPrefix(server.URL, "http")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	commandStation := conn.CommandStation()
+
+	err = commandStation.Ready(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := commandStation.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status.Version != simulator.Version {
+		t.Errorf("Expected version %q but got %q", simulator.Version, status.Version)
+	}
+}
+
+func TestEvents(t *testing.T) {
+	sim := simulator.NewSimulator()
+
+	conn := &Connection{
+		config: &Config{
+			Label: "layout",
+		},
+	}
+
+	conn.start(sim)
+
+	eventC, cleanupF := conn.Events()
+	defer cleanupF()
+
+	// Unplug the command station.
+	_ = sim.Close()
+
+	for _, expected := range []EventType{EventReadError, EventDisconnected} {
+		select {
+		case event := <-eventC:
+			if event.Type != expected || event.Label != "layout" {
+				t.Errorf("Expected %q event but got %q", expected, event.Type)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for %q event", expected)
+		}
+	}
+
+	_ = conn.Close()
+
+	event := <-eventC
+	if event.Type != EventClosed {
+		t.Errorf("Expected %q event but got %q", EventClosed, event.Type)
+	}
+
+	// Calling the cleanup function again is a no-op.
+	cleanupF()
+	cleanupF()
+}
+
+// simulatorTransport is a transport opening a new simulated command station every time.
+type simulatorTransport struct {
+	*simulator.Simulator
+
+	opened chan *simulator.Simulator
+}
+
+func (s *simulatorTransport) Open() error {
+	s.Simulator = simulator.NewSimulator()
+	s.opened <- s.Simulator
+	return nil
+}
+
+func TestTransport(t *testing.T) {
+	transport := &simulatorTransport{
+		opened: make(chan *simulator.Simulator, 2),
+	}
+
+	conn, err := NewConnection(&Config{
+		Transport:         transport,
+		RequireSubscriber: true,
+		Reconnect:         true,
+		ReconnectBackoff:  time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	commandStation := conn.CommandStation()
+
+	err = commandStation.Ready(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	messageC, cleanupF := conn.Subscribe()
+
+	// Unplug the command station.
+	_ = (<-transport.opened).Close()
+
+	select {
+	case <-transport.opened:
+	case <-ctx.Done():
+		t.Fatal("Timed out waiting for the transport to be reopened")
+	}
+
+	// The subscription survives the reconnect and marks the gap before the new station's ready message.
+	gap := false
+	for ready := false; !ready; {
+		select {
+		case message := <-messageC:
+			if message.Gap {
+				gap = true
+			} else if message.Command.OpCode() == command.OpCodeInfo {
+				ready = true
+			}
+		case <-ctx.Done():
+			t.Fatal("Timed out waiting for the ready message")
+		}
+	}
+
+	if !gap {
+		t.Error("Expected a gap marker before the ready message")
+	}
+
+	// Stop consuming the subscription before interacting with the command station.
+	// Calling the cleanup function again is a no-op.
+	cleanupF()
+	cleanupF()
+
+	_, err = commandStation.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// flakyTransport is a transport failing to open the given number of times (e.g. USB enumeration on boot).
+type flakyTransport struct {
+	simulatorTransport
+
+	failures int
+}
+
+func (f *flakyTransport) Open() error {
+	if f.failures > 0 {
+		f.failures--
+		return errors.New("no such device")
+	}
+
+	return f.simulatorTransport.Open()
+}
+
+func TestOpenRetries(t *testing.T) {
+	config := &Config{
+		RequireSubscriber: true,
+	}
+
+	config.Transport = &flakyTransport{
+		simulatorTransport: simulatorTransport{
+			opened: make(chan *simulator.Simulator, 1),
+		},
+		// The first attempt of each of the two connections fails.
+		failures: 2,
+	}
+
+	_, err := NewConnection(config)
+	if err == nil {
+		t.Fatal("Expected opening the connection to fail without retries")
+	}
+
+	conn, err := NewConnection(config, WithOpenRetries(1), WithOpenTimeout(5*time.Second), WithReadyWait(5*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = conn.Close()
+}
+
+func TestSafety(t *testing.T) {
+	mock := clock.NewMock(time.Now())
+
+	conn, err := NewConnection(&Config{
+		Transport: &simulatorTransport{
+			opened: make(chan *simulator.Simulator, 1),
+		},
+		Safety:        true,
+		SafetyTimeout: time.Minute,
+		Clock:         mock,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	loc := conn.Cab(3)
+
+	err = loc.Speed(ctx, 50, cab.DirectionForward)
+	if !errors.Is(err, ErrDisarmed) {
+		t.Errorf("Expected %v but got %v", ErrDisarmed, err)
+	}
+
+	// Stopping is always allowed.
+	err = loc.Speed(ctx, 0, cab.DirectionBackward)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = conn.Arm(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = loc.Speed(ctx, 50, cab.DirectionForward)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock.Advance(time.Minute)
+
+	// The auto-disarm is applied asynchronously.
+	for conn.Armed() {
+		select {
+		case <-ctx.Done():
+			t.Fatal("Timed out waiting for the connection to be disarmed")
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	err = conn.CommandStation().Power(ctx, station.PowerOn)
+	if !errors.Is(err, ErrDisarmed) {
+		t.Errorf("Expected %v but got %v", ErrDisarmed, err)
+	}
+}
+
+func TestOperate(t *testing.T) {
+	conn, err := NewConnection(&Config{
+		Transport: &simulatorTransport{
+			opened: make(chan *simulator.Simulator, 1),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = conn.Operate(ctx, "cab/3", "speed", "20", "forward")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := conn.Cab(3).Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	speed, direction := status.SpeedDirection()
+	if speed != 20 || direction != cab.DirectionForward {
+		t.Errorf("Expected speed 20 forward but got %d %d", speed, direction)
+	}
+
+	err = conn.Operate(ctx, "sensor/17", "throw")
+	if !errors.Is(err, ErrUnsupportedAction) {
+		t.Errorf("Expected %v but got %v", ErrUnsupportedAction, err)
+	}
+
+	for _, id := range []string{"sensor", "signal/1", "turnout/x", "cab/70000"} {
+		_, err = ParseEntityID(id)
+		if err == nil {
+			t.Errorf("Expected %q to be invalid", id)
+		}
+	}
+}
+
+func TestCommandStationShared(t *testing.T) {
+	conn, err := NewConnection(&Config{
+		Transport: &simulatorTransport{
+			opened: make(chan *simulator.Simulator, 1),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+
+	commandStation := conn.CommandStation()
+	goroutines := runtime.NumGoroutine()
+
+	for range 100 {
+		if conn.CommandStation() != commandStation {
+			t.Fatal("Expected the command station to be shared")
+		}
+	}
+
+	if runtime.NumGoroutine() > goroutines {
+		t.Errorf("Expected %d goroutines but got %d", goroutines, runtime.NumGoroutine())
+	}
+}
+
+// lockedBuffer is a buffer safe for concurrent writes from multiple connections.
+type lockedBuffer struct {
+	buf  bytes.Buffer
+	lock sync.Mutex
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.buf.String()
+}
+
+func TestLabel(t *testing.T) {
+	logs := &lockedBuffer{}
+	logger := slog.New(slog.NewJSONHandler(logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	// Expvar names are global, keep them unique across repeated runs.
+	metrics := protocol.NewExpvarMetrics(fmt.Sprintf("dcc_ex_test_label_%d", time.Now().UnixNano()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Two layouts share the logger and metrics.
+	for _, label := range []string{"north", "south"} {
+		conn := &Connection{
+			config: &Config{
+				Label:   label,
+				Logger:  logger,
+				Metrics: metrics,
+			},
+		}
+
+		conn.start(simulator.NewSimulator())
+		defer conn.Close()
+
+		_, err := conn.CommandStation().Status(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, label := range []string{"north", "south"} {
+		expected := fmt.Sprintf(`"msg":"wrote command","label":%q`, label)
+		if !strings.Contains(logs.String(), expected) {
+			t.Errorf("Expected %s in logs %s", expected, logs)
+		}
+
+		vars, ok := metrics.Vars().Get(label).(*expvar.Map)
+		if !ok {
+			t.Fatalf("Expected metrics of %q", label)
+		}
+
+		writes := vars.Get(string(protocol.CounterWrites))
+		if writes == nil || writes.String() != "1" {
+			t.Errorf("Expected a single write of %q but got %v", label, writes)
+		}
+	}
+}
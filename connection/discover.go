@@ -0,0 +1,108 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/station"
+	"go.bug.st/serial"
+	"golang.org/x/sync/errgroup"
+)
+
+// Candidate is a serial device which responded like a DCC-EX command station.
+type Candidate struct {
+	Device string
+	Status *station.Status
+}
+
+var (
+	// DiscoverTimeout is the maximum duration to wait for a single device to respond.
+	// Some boards reboot when the serial port gets opened which takes a few seconds.
+	DiscoverTimeout = 5 * time.Second
+	// discoverAttemptTimeout is the duration after which the status is requested again.
+	// Requests sent while the board is still rebooting don't get a response.
+	discoverAttemptTimeout = time.Second
+)
+
+// Discover probes all serial ports concurrently and returns the devices of the command stations found.
+// Every device gets probed by requesting its status within DiscoverTimeout.
+// Devices which cannot be opened or don't respond are skipped.
+func Discover(ctx context.Context) ([]*Candidate, error) {
+	devices, err := serial.GetPortsList()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list serial ports: %w", err)
+	}
+
+	candidates := []*Candidate{}
+	candidatesLock := sync.Mutex{}
+
+	group, groupCtx := errgroup.WithContext(ctx)
+	for _, device := range devices {
+		group.Go(func() error {
+			status, err := probe(groupCtx, device)
+			if err != nil {
+				// Not a command station.
+				return nil
+			}
+
+			candidatesLock.Lock()
+			candidates = append(candidates, &Candidate{
+				Device: device,
+				Status: status,
+			})
+
+			candidatesLock.Unlock()
+			return nil
+		})
+	}
+
+	_ = group.Wait()
+
+	// The context got cancelled before all of the devices could be probed.
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	slices.SortFunc(candidates, func(a *Candidate, b *Candidate) int {
+		return strings.Compare(a.Device, b.Device)
+	})
+
+	return candidates, nil
+}
+
+// probe opens the given device and requests the command station's status.
+func probe(ctx context.Context, device string) (*station.Status, error) {
+	config := NewDefaultConfig(device)
+	config.RequireSubscriber = false
+
+	conn, err := NewConnection(config)
+	if err != nil {
+		return nil, err
+	}
+
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, DiscoverTimeout)
+	defer cancel()
+
+	commandStation := conn.CommandStation()
+
+	for {
+		attemptCtx, attemptCancel := context.WithTimeout(ctx, discoverAttemptTimeout)
+		status, err := commandStation.Status(attemptCtx)
+		attemptCancel()
+		if err == nil {
+			return status, nil
+		}
+
+		// Only retry in case the attempt timed out.
+		if !errors.Is(err, context.DeadlineExceeded) || ctx.Err() != nil {
+			return nil, err
+		}
+	}
+}
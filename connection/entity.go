@@ -0,0 +1,177 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/roosterfish/dcc-ex-go/cab"
+	"github.com/roosterfish/dcc-ex-go/output"
+	"github.com/roosterfish/dcc-ex-go/sensor"
+	"github.com/roosterfish/dcc-ex-go/turnout"
+)
+
+// EntityKind is the kind of entity addressed by an EntityID.
+type EntityKind string
+
+const (
+	EntitySensor  EntityKind = "sensor"
+	EntityTurnout EntityKind = "turnout"
+	EntityOutput  EntityKind = "output"
+	EntityCab     EntityKind = "cab"
+)
+
+// ErrUnsupportedAction is returned by Operate for actions the addressed entity doesn't support.
+var ErrUnsupportedAction = errors.New("unsupported action")
+
+// EntityID is the canonical address of an entity like sensor/17, turnout/5 or cab/3.
+// Share it across the layers exposing the connection (e.g. REST, MQTT or CLI)
+// so all of them use the same addressing scheme.
+type EntityID struct {
+	Kind EntityKind
+	// ID is the ID of the sensor, turnout or output or the address of the cab.
+	ID uint16
+}
+
+// ParseEntityID parses the canonical address of an entity (e.g. sensor/17).
+func ParseEntityID(id string) (EntityID, error) {
+	kind, rawID, ok := strings.Cut(id, "/")
+	if !ok {
+		return EntityID{}, fmt.Errorf("invalid entity id %q: missing kind", id)
+	}
+
+	entityKind := EntityKind(kind)
+	switch entityKind {
+	case EntitySensor, EntityTurnout, EntityOutput, EntityCab:
+	default:
+		return EntityID{}, fmt.Errorf("invalid entity id %q: unknown kind %q", id, kind)
+	}
+
+	entityID, err := strconv.ParseUint(rawID, 10, 16)
+	if err != nil {
+		return EntityID{}, fmt.Errorf("invalid entity id %q: %w", id, err)
+	}
+
+	return EntityID{
+		Kind: entityKind,
+		ID:   uint16(entityID),
+	}, nil
+}
+
+func (e EntityID) String() string {
+	return fmt.Sprintf("%s/%d", e.Kind, e.ID)
+}
+
+// Resolve returns the entity addressed by the given canonical ID.
+// It's either a *sensor.Sensor, *turnout.TurnoutServo, *output.Output or *cab.Cab.
+func (c *Connection) Resolve(id string) (any, error) {
+	entityID, err := ParseEntityID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	switch entityID.Kind {
+	case EntitySensor:
+		return c.Sensor(sensor.ID(entityID.ID)), nil
+	case EntityTurnout:
+		return c.TurnoutServo(turnout.ID(entityID.ID)), nil
+	case EntityOutput:
+		return c.Output(output.ID(entityID.ID)), nil
+	default:
+		return c.Cab(cab.Address(entityID.ID)), nil
+	}
+}
+
+// Operate performs the action on the entity addressed by the given canonical ID.
+// The supported actions are:
+//
+//	turnout/<id> throw|close
+//	output/<id>  high|low
+//	cab/<addr>   speed <0-127> forward|backward
+//	cab/<addr>   function <0-68> on|off
+//	cab/<addr>   stop
+//
+// Sensors are read-only. ErrUnsupportedAction is returned for any other action.
+func (c *Connection) Operate(ctx context.Context, id string, action string, args ...string) error {
+	entity, err := c.Resolve(id)
+	if err != nil {
+		return err
+	}
+
+	switch e := entity.(type) {
+	case *turnout.TurnoutServo:
+		switch {
+		case action == "throw" && len(args) == 0:
+			err = e.Throw(ctx)
+		case action == "close" && len(args) == 0:
+			err = e.Close(ctx)
+		default:
+			return fmt.Errorf("%s %s: %w", id, action, ErrUnsupportedAction)
+		}
+	case *output.Output:
+		switch {
+		case action == "high" && len(args) == 0:
+			err = e.High(ctx)
+		case action == "low" && len(args) == 0:
+			err = e.Low(ctx)
+		default:
+			return fmt.Errorf("%s %s: %w", id, action, ErrUnsupportedAction)
+		}
+	case *cab.Cab:
+		err = operateCab(ctx, e, id, action, args)
+	default:
+		return fmt.Errorf("%s %s: %w", id, action, ErrUnsupportedAction)
+	}
+
+	if err != nil {
+		return c.labelError(fmt.Errorf("Failed to operate %s: %w", id, err))
+	}
+
+	return nil
+}
+
+func operateCab(ctx context.Context, c *cab.Cab, id string, action string, args []string) error {
+	switch {
+	case action == "stop" && len(args) == 0:
+		return c.Speed(ctx, 0, cab.DirectionForward)
+	case action == "speed" && len(args) == 2:
+		speed, err := strconv.ParseUint(args[0], 10, 7)
+		if err != nil {
+			return fmt.Errorf("invalid speed %q: %w", args[0], err)
+		}
+
+		direction, err := parseChoice(args[1], "backward", "forward")
+		if err != nil {
+			return fmt.Errorf("invalid direction: %w", err)
+		}
+
+		return c.Speed(ctx, cab.Speed(speed), cab.Direction(direction))
+	case action == "function" && len(args) == 2:
+		function, err := strconv.ParseUint(args[0], 10, 8)
+		if err != nil {
+			return fmt.Errorf("invalid function %q: %w", args[0], err)
+		}
+
+		state, err := parseChoice(args[1], "off", "on")
+		if err != nil {
+			return fmt.Errorf("invalid function state: %w", err)
+		}
+
+		return c.Function(ctx, cab.Function(function), cab.FunctionState(state))
+	default:
+		return fmt.Errorf("%s %s: %w", id, action, ErrUnsupportedAction)
+	}
+}
+
+// parseChoice returns the index of the given value within the choices.
+func parseChoice(value string, choices ...string) (uint8, error) {
+	for i, choice := range choices {
+		if value == choice {
+			return uint8(i), nil
+		}
+	}
+
+	return 0, fmt.Errorf("%q is none of %s", value, strings.Join(choices, ", "))
+}
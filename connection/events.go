@@ -0,0 +1,120 @@
+package connection
+
+import (
+	"sync"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/clock"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+)
+
+type EventType uint8
+
+const (
+	// EventConnected is emitted once the connection was reopened.
+	EventConnected EventType = iota
+	// EventDisconnected is emitted once the connection got lost.
+	EventDisconnected
+	// EventReadError is emitted if reading from the connection failed.
+	EventReadError
+	// EventReconnecting is emitted before every attempt to reopen the connection.
+	EventReconnecting
+	// EventClosed is emitted once the connection got closed.
+	EventClosed
+	// EventArmed is emitted once movement commands are allowed in safety mode.
+	EventArmed
+	// EventDisarmed is emitted once movement commands are rejected again in safety mode.
+	EventDisarmed
+)
+
+// eventBufferSize is the number of events buffered for every subscriber.
+const eventBufferSize = 32
+
+// Event describes a change in the connection's lifecycle.
+type Event struct {
+	// Label is the label of the connection which emitted the event.
+	Label string
+	Type  EventType
+	// Err is the cause of the event if any.
+	Err  error
+	Time time.Time
+}
+
+// EventC receives the connection's lifecycle events.
+type EventC chan Event
+
+type events struct {
+	label       string
+	clock       clock.Clock
+	subscribers map[EventC]struct{}
+	lock        sync.Mutex
+}
+
+func (t EventType) String() string {
+	switch t {
+	case EventConnected:
+		return "connected"
+	case EventDisconnected:
+		return "disconnected"
+	case EventReadError:
+		return "read error"
+	case EventReconnecting:
+		return "reconnecting"
+	case EventClosed:
+		return "closed"
+	case EventArmed:
+		return "armed"
+	case EventDisarmed:
+		return "disarmed"
+	default:
+		return "unknown"
+	}
+}
+
+func newEvents(label string, clock clock.Clock) *events {
+	return &events{
+		label:       label,
+		clock:       clock,
+		subscribers: make(map[EventC]struct{}),
+	}
+}
+
+// emit sends the event to all subscribers.
+// Events are dropped for subscribers which don't keep up consuming them.
+func (e *events) emit(eventType EventType, err error) {
+	event := Event{
+		Label: e.label,
+		Type:  eventType,
+		Err:   err,
+		Time:  e.clock.Now(),
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	for eventC := range e.subscribers {
+		select {
+		case eventC <- event:
+		default:
+		}
+	}
+}
+
+// Events returns a channel which receives the connection's lifecycle events.
+// Up to 32 events are buffered; further events are dropped until the channel is consumed.
+// Never close the channel manually but instead call the cleanup function.
+func (c *Connection) Events() (EventC, protocol.CleanupF) {
+	eventC := make(EventC, eventBufferSize)
+
+	c.events.lock.Lock()
+	c.events.subscribers[eventC] = struct{}{}
+	c.events.lock.Unlock()
+
+	return eventC, sync.OnceFunc(func() {
+		c.events.lock.Lock()
+		delete(c.events.subscribers, eventC)
+		c.events.lock.Unlock()
+
+		close(eventC)
+	})
+}
@@ -0,0 +1,147 @@
+package connection
+
+import (
+	"context"
+	"sync"
+
+	"github.com/roosterfish/dcc-ex-go/command"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+)
+
+// HealthEvent is sent every time the connection's health changes.
+type HealthEvent struct {
+	// Label is the label of the connection whose health changed.
+	Label   string
+	Healthy bool
+}
+
+// HealthC receives the connection's health every time it changes.
+type HealthC chan HealthEvent
+
+type health struct {
+	label       string
+	healthy     bool
+	subscribers map[HealthC]struct{}
+	lock        sync.Mutex
+}
+
+func newHealth(label string) *health {
+	return &health{
+		label: label,
+		// The connection was just opened successfully.
+		healthy:     true,
+		subscribers: make(map[HealthC]struct{}),
+	}
+}
+
+// set updates the health and notifies the subscribers in case it changed.
+func (h *health) set(healthy bool) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if h.healthy == healthy {
+		return
+	}
+
+	h.healthy = healthy
+
+	for healthC := range h.subscribers {
+		// Only the latest health is of interest.
+		// Drop the previous one in case the subscriber didn't consume it yet.
+		select {
+		case <-healthC:
+		default:
+		}
+
+		healthC <- HealthEvent{
+			Label:   h.label,
+			Healthy: healthy,
+		}
+	}
+}
+
+func (h *health) get() bool {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	return h.healthy
+}
+
+// heartbeat starts probing the command station every HeartbeatInterval.
+// The returned function stops the heartbeat.
+func (c *Connection) heartbeat() func() {
+	// Probing requires writing to the command station.
+	if c.config.HeartbeatInterval <= 0 || c.config.ReadOnly {
+		return func() {}
+	}
+
+	timeout := c.config.HeartbeatTimeout
+	if timeout <= 0 {
+		timeout = c.config.HeartbeatInterval
+	}
+
+	probeCommand := c.config.HeartbeatCommand
+	if probeCommand == nil {
+		probeCommand = command.NewCommand(command.OpCodeStatus, "")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := sync.WaitGroup{}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ticker := c.channel.Clock().NewTicker(c.config.HeartbeatInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C():
+			case <-ctx.Done():
+				return
+			}
+
+			// The timeout includes waiting for the session, a stuck session marks the connection unhealthy too.
+			probeCtx, probeCancel := context.WithTimeout(ctx, timeout)
+			err := c.channel.Write(probeCtx, probeCommand)
+			probeCancel()
+
+			// Don't consider the connection unhealthy because it got closed.
+			if ctx.Err() != nil {
+				return
+			}
+
+			c.health.set(err == nil)
+		}
+	}()
+
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+// Healthy returns whether or not the command station responded to the latest heartbeat.
+// Without heartbeat, the connection is considered unhealthy only while it is reconnecting.
+func (c *Connection) Healthy() bool {
+	return c.health.get()
+}
+
+// Health returns a channel which receives the connection's health every time it changes.
+// Never close the channel manually but instead call the cleanup function.
+func (c *Connection) Health() (HealthC, protocol.CleanupF) {
+	healthC := make(HealthC, 1)
+
+	c.health.lock.Lock()
+	c.health.subscribers[healthC] = struct{}{}
+	c.health.lock.Unlock()
+
+	return healthC, sync.OnceFunc(func() {
+		c.health.lock.Lock()
+		delete(c.health.subscribers, healthC)
+		c.health.lock.Unlock()
+
+		close(healthC)
+	})
+}
@@ -0,0 +1,92 @@
+package connection
+
+import (
+	"testing"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/protocol"
+	"github.com/roosterfish/dcc-ex-go/simulator"
+)
+
+func TestHeartbeat(t *testing.T) {
+	sim := simulator.NewSimulator()
+
+	conn := &Connection{
+		config: &Config{
+			Label:             "layout",
+			HeartbeatInterval: 10 * time.Millisecond,
+		},
+	}
+
+	conn.start(sim)
+	defer conn.Close()
+
+	healthC, cleanupF := conn.Health()
+	defer cleanupF()
+
+	if !conn.Healthy() {
+		t.Error("Expected connection to be healthy")
+	}
+
+	// Unplug the command station.
+	_ = sim.Close()
+
+	select {
+	case event := <-healthC:
+		if event.Label != "layout" {
+			t.Errorf("Expected label %q but got %q", "layout", event.Label)
+		}
+
+		if event.Healthy {
+			t.Error("Expected connection to become unhealthy")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for health change")
+	}
+
+	if conn.Healthy() {
+		t.Error("Expected connection to be unhealthy")
+	}
+
+	// Calling the cleanup function again is a no-op.
+	cleanupF()
+	cleanupF()
+}
+
+func TestHeartbeatStuckSession(t *testing.T) {
+	conn := &Connection{
+		config: &Config{
+			HeartbeatInterval: 10 * time.Millisecond,
+		},
+	}
+
+	conn.start(simulator.NewSimulator())
+	defer conn.Close()
+
+	healthC, cleanupF := conn.Health()
+	defer cleanupF()
+
+	// Hold the channel like a stuck session.
+	heldC := make(chan struct{})
+	releaseC := make(chan struct{})
+	defer close(releaseC)
+
+	go func() {
+		_ = conn.channel.Session(func(protocol protocol.ReadWriteCloser) error {
+			close(heldC)
+			<-releaseC
+			return nil
+		})
+	}()
+
+	<-heldC
+
+	select {
+	case event := <-healthC:
+		if event.Healthy {
+			t.Error("Expected connection to become unhealthy")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for health change")
+	}
+}
@@ -0,0 +1,100 @@
+package connection
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/clock"
+)
+
+// DefaultOpenRetryDelay is the duration to wait between retries of opening the connection.
+const DefaultOpenRetryDelay = 500 * time.Millisecond
+
+type options struct {
+	openTimeout    time.Duration
+	openRetries    int
+	openRetryDelay time.Duration
+	readyTimeout   time.Duration
+}
+
+// Option configures how NewConnection establishes the connection.
+type Option func(o *options)
+
+// WithOpenTimeout limits the overall duration of opening the connection including all retries.
+func WithOpenTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.openTimeout = timeout
+	}
+}
+
+// WithOpenRetries retries opening the connection the given number of times waiting DefaultOpenRetryDelay in between.
+// Use it for devices which aren't yet available (e.g. USB enumeration on boot or the Arduino still resetting).
+func WithOpenRetries(retries int) Option {
+	return func(o *options) {
+		o.openRetries = retries
+	}
+}
+
+// WithReadyWait waits up to the given timeout for the command station to be ready before returning the connection.
+func WithReadyWait(timeout time.Duration) Option {
+	return func(o *options) {
+		o.readyTimeout = timeout
+	}
+}
+
+// openWithOptions tries to open up a new connection honoring the given options.
+func (c *Connection) openWithOptions(o *options) (io.ReadWriteCloser, error) {
+	ctx := context.Background()
+	if o.openTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.openTimeout)
+		defer cancel()
+	}
+
+	type result struct {
+		port io.ReadWriteCloser
+		err  error
+	}
+
+	var err error
+	for attempt := 0; attempt <= o.openRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-clock.Or(c.config.Clock).After(o.openRetryDelay):
+			case <-ctx.Done():
+				// Report the last attempt's error.
+				return nil, err
+			}
+		}
+
+		// Opening a device might block, don't wait for it beyond the timeout.
+		resultC := make(chan result, 1)
+		go func() {
+			port, err := c.open()
+			resultC <- result{port: port, err: err}
+		}()
+
+		select {
+		case res := <-resultC:
+			if res.err == nil {
+				return res.port, nil
+			}
+
+			err = res.err
+		case <-ctx.Done():
+			// Close the connection in case it got opened after giving up.
+			go func() {
+				res := <-resultC
+				if res.err == nil {
+					_ = res.port.Close()
+				}
+			}()
+
+			return nil, c.labelError(fmt.Errorf("Timed out opening the connection: %w", ctx.Err()))
+		}
+	}
+
+	return nil, err
+}
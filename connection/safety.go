@@ -0,0 +1,166 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/clock"
+	"github.com/roosterfish/dcc-ex-go/command"
+)
+
+// DefaultSafetyTimeout is the duration of inactivity after which an armed connection gets disarmed.
+const DefaultSafetyTimeout = 5 * time.Minute
+
+// ErrDisarmed is returned for movement commands written while the connection isn't armed.
+var ErrDisarmed = errors.New("connection is not armed")
+
+type safety struct {
+	clock   clock.Clock
+	timeout time.Duration
+	armed   bool
+	timer   clock.Timer
+	// disarmedF is called once the connection got disarmed due to inactivity.
+	disarmedF func()
+	lock      sync.Mutex
+}
+
+func newSafety(clock clock.Clock, timeout time.Duration, disarmedF func()) *safety {
+	if timeout <= 0 {
+		timeout = DefaultSafetyTimeout
+	}
+
+	return &safety{
+		clock:     clock,
+		timeout:   timeout,
+		disarmedF: disarmedF,
+	}
+}
+
+// movement returns true for commands which can cause trains to move.
+// Stopping a cab, emergency stops and powering off are always allowed.
+func movement(cmd *command.Command) bool {
+	switch cmd.OpCode() {
+	case command.OpCodePowerOn:
+		// Powering on the tracks.
+		return true
+	case command.OpCodeCabSpeed:
+		// <t cab speed direction>, <t cab> only requests the cab's status.
+		params := cmd.Parameters()
+		if len(params) < 3 {
+			return false
+		}
+
+		// Treat unparsable speeds as movement to be on the safe side.
+		speed, err := strconv.Atoi(fmt.Sprint(params[1]))
+		return err != nil || speed > 0
+	}
+
+	return false
+}
+
+// intercept rejects movement commands while disarmed and keeps the connection armed while in use.
+func (s *safety) intercept(cmd *command.Command) (*command.Command, error) {
+	if !movement(cmd) {
+		return cmd, nil
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if !s.armed {
+		return nil, fmt.Errorf("%q: %w", cmd.String(), ErrDisarmed)
+	}
+
+	s.timer.Reset(s.timeout)
+	return cmd, nil
+}
+
+func (s *safety) arm() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.armed {
+		s.timer.Reset(s.timeout)
+		return
+	}
+
+	s.armed = true
+
+	var timer clock.Timer
+	timer = s.clock.AfterFunc(s.timeout, func() {
+		s.lock.Lock()
+		// Ignore the expiry if the connection got disarmed and armed again in the meantime.
+		disarmed := s.armed && s.timer == timer
+		if disarmed {
+			s.armed = false
+		}
+
+		s.lock.Unlock()
+
+		if disarmed && s.disarmedF != nil {
+			s.disarmedF()
+		}
+	})
+
+	s.timer = timer
+}
+
+func (s *safety) disarm() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if !s.armed {
+		return
+	}
+
+	s.armed = false
+	s.timer.Stop()
+}
+
+// Arm allows movement commands (cab speeds above zero and powering on the tracks) in safety mode.
+// The command station is probed first, so arming a dead connection fails.
+// The connection gets disarmed automatically after the configured SafetyTimeout without any movement command.
+// It's a no-op in case safety mode isn't enabled.
+func (c *Connection) Arm(ctx context.Context) error {
+	if c.safety == nil {
+		return nil
+	}
+
+	_, err := c.CommandStation().Status(ctx)
+	if err != nil {
+		return c.labelError(fmt.Errorf("Failed to arm connection: %w", err))
+	}
+
+	c.safety.arm()
+	c.events.emit(EventArmed, nil)
+
+	return nil
+}
+
+// Disarm rejects movement commands until the connection is armed again.
+// It's a no-op in case safety mode isn't enabled.
+func (c *Connection) Disarm() {
+	if c.safety == nil {
+		return
+	}
+
+	c.safety.disarm()
+	c.events.emit(EventDisarmed, nil)
+}
+
+// Armed returns true in case movement commands are allowed.
+// It always returns true in case safety mode isn't enabled.
+func (c *Connection) Armed() bool {
+	if c.safety == nil {
+		return true
+	}
+
+	c.safety.lock.Lock()
+	defer c.safety.lock.Unlock()
+
+	return c.safety.armed
+}
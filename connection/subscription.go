@@ -0,0 +1,117 @@
+package connection
+
+import (
+	"context"
+	"sync"
+
+	"github.com/roosterfish/dcc-ex-go/command"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+)
+
+// Message is either a command received from the command station or a gap marker.
+type Message struct {
+	// Label is the label of the connection which received the message.
+	Label string
+	// Command is the received command.
+	// It's nil for gap markers.
+	Command *command.Command
+	// Gap marks that commands might have been missed as the connection got lost in the meantime.
+	Gap bool
+}
+
+// MessageC receives the messages of a durable subscription.
+type MessageC chan Message
+
+type subscriptions struct {
+	gapCs map[chan struct{}]struct{}
+	lock  sync.Mutex
+}
+
+func newSubscriptions() *subscriptions {
+	return &subscriptions{
+		gapCs: make(map[chan struct{}]struct{}),
+	}
+}
+
+// gap notifies all durable subscriptions that commands might have been missed.
+func (s *subscriptions) gap() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for gapC := range s.gapCs {
+		select {
+		case gapC <- struct{}{}:
+		default:
+			// There is already a pending gap marker.
+		}
+	}
+}
+
+// Subscribe returns a durable subscription to all commands received from the command station.
+// Unlike protocol readers, the subscription survives reconnects of the connection and the channel is kept.
+// After reconnecting, a message with Gap set informs that commands might have been missed during the outage.
+// Consume the channel as fast as possible as it might otherwise block every other reader.
+// Never close the channel manually but instead call the cleanup function.
+func (c *Connection) Subscribe() (MessageC, protocol.CleanupF) {
+	messageC := make(MessageC)
+	gapC := make(chan struct{}, 1)
+
+	c.subscriptions.lock.Lock()
+	c.subscriptions.gapCs[gapC] = struct{}{}
+	c.subscriptions.lock.Unlock()
+
+	// Register the reader before returning to not miss any command.
+	var commandC protocol.CommandC
+	var readCleanupF protocol.CleanupF
+	_ = c.channel.RSession(func(sessionProtocol protocol.Reader) error {
+		commandC, readCleanupF = protocol.ReadWithOptions(sessionProtocol, &protocol.ReadOptions{
+			Ownership: protocol.OwnershipObserver,
+		})
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := sync.WaitGroup{}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer readCleanupF()
+
+		for {
+			message := Message{
+				Label: c.config.Label,
+			}
+
+			select {
+			case cmd, ok := <-commandC:
+				if !ok {
+					return
+				}
+
+				message.Command = cmd
+			case <-gapC:
+				message.Gap = true
+			case <-ctx.Done():
+				return
+			}
+
+			select {
+			case messageC <- message:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return messageC, sync.OnceFunc(func() {
+		cancel()
+		wg.Wait()
+
+		c.subscriptions.lock.Lock()
+		delete(c.subscriptions.gapCs, gapC)
+		c.subscriptions.lock.Unlock()
+
+		close(messageC)
+	})
+}
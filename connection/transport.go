@@ -0,0 +1,201 @@
+package connection
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/coder/websocket"
+	"go.bug.st/serial"
+)
+
+// Transport is the link to a command station (e.g. serial port, network connection, PTY or SSH tunnel).
+// Open is called before the transport is used and again on every reconnect after the transport got closed.
+// Read, Write and Close are only called on an opened transport.
+type Transport interface {
+	io.ReadWriteCloser
+	Open() error
+}
+
+// SerialTransport is a transport using a serial device.
+type SerialTransport struct {
+	device string
+	mode   Mode
+
+	// lock guards port which is replaced on every reconnect while being read from and written to.
+	lock sync.RWMutex
+	port serial.Port
+}
+
+// NewSerialTransport returns a new transport using the given serial device and mode.
+func NewSerialTransport(device string, mode Mode) *SerialTransport {
+	return &SerialTransport{
+		device: device,
+		mode:   mode,
+	}
+}
+
+// Open opens the serial device.
+func (s *SerialTransport) Open() error {
+	port, err := serial.Open(s.device, s.mode)
+	if err != nil {
+		return fmt.Errorf("Failed to open %q: %w", s.device, err)
+	}
+
+	s.lock.Lock()
+	s.port = port
+	s.lock.Unlock()
+
+	return nil
+}
+
+// current returns the currently opened serial port.
+func (s *SerialTransport) current() serial.Port {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.port
+}
+
+func (s *SerialTransport) Read(p []byte) (int, error) {
+	return s.current().Read(p)
+}
+
+func (s *SerialTransport) Write(p []byte) (int, error) {
+	return s.current().Write(p)
+}
+
+func (s *SerialTransport) Close() error {
+	return s.current().Close()
+}
+
+// NetworkTransport is a transport using a TCP connection.
+type NetworkTransport struct {
+	address string
+
+	// lock guards conn which is replaced on every reconnect while being read from and written to.
+	lock sync.RWMutex
+	conn net.Conn
+}
+
+// NewNetworkTransport returns a new transport using the given network address (host:port).
+func NewNetworkTransport(address string) *NetworkTransport {
+	return &NetworkTransport{
+		address: address,
+	}
+}
+
+// Open dials the network address.
+func (n *NetworkTransport) Open() error {
+	conn, err := net.Dial("tcp", n.address)
+	if err != nil {
+		return fmt.Errorf("Failed to dial %q: %w", n.address, err)
+	}
+
+	n.lock.Lock()
+	n.conn = conn
+	n.lock.Unlock()
+
+	return nil
+}
+
+// current returns the currently opened network connection.
+func (n *NetworkTransport) current() net.Conn {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+
+	return n.conn
+}
+
+func (n *NetworkTransport) Read(p []byte) (int, error) {
+	return n.current().Read(p)
+}
+
+func (n *NetworkTransport) Write(p []byte) (int, error) {
+	return n.current().Write(p)
+}
+
+func (n *NetworkTransport) Close() error {
+	return n.current().Close()
+}
+
+// WebSocketTransport is a transport using a WebSocket.
+// Every command is sent as a single text message.
+type WebSocketTransport struct {
+	url string
+
+	// lock guards conn which is replaced on every reconnect while being read from and written to.
+	lock sync.RWMutex
+	conn net.Conn
+}
+
+// NewWebSocketTransport returns a new transport using the given WebSocket URL (ws:// or wss://).
+func NewWebSocketTransport(url string) *WebSocketTransport {
+	return &WebSocketTransport{
+		url: url,
+	}
+}
+
+// Open dials the WebSocket URL.
+func (w *WebSocketTransport) Open() error {
+	ctx, cancel := context.WithTimeout(context.Background(), webSocketDialTimeout)
+	defer cancel()
+
+	conn, _, err := websocket.Dial(ctx, w.url, nil)
+	if err != nil {
+		return fmt.Errorf("Failed to dial %q: %w", w.url, err)
+	}
+
+	// Expose the WebSocket as stream so the protocol can be used unchanged.
+	netConn := websocket.NetConn(context.Background(), conn, websocket.MessageText)
+
+	w.lock.Lock()
+	w.conn = netConn
+	w.lock.Unlock()
+
+	return nil
+}
+
+// current returns the currently opened WebSocket.
+func (w *WebSocketTransport) current() net.Conn {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	return w.conn
+}
+
+func (w *WebSocketTransport) Read(p []byte) (int, error) {
+	return w.current().Read(p)
+}
+
+func (w *WebSocketTransport) Write(p []byte) (int, error) {
+	return w.current().Write(p)
+}
+
+func (w *WebSocketTransport) Close() error {
+	return w.current().Close()
+}
+
+// transportSession is a single opening of a transport.
+// As transports are reopened in place, closing a session which got superseded by a reconnect must not
+// close the transport again.
+type transportSession struct {
+	Transport
+
+	lock   sync.Mutex
+	closed bool
+}
+
+func (t *transportSession) Close() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.closed {
+		return nil
+	}
+
+	t.closed = true
+	return t.Transport.Close()
+}
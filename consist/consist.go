@@ -0,0 +1,113 @@
+package consist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/roosterfish/dcc-ex-go/cab"
+)
+
+// Member is a single cab of a consist.
+type Member struct {
+	Cab *cab.Cab
+	// Inverted is set for cabs facing the opposite way (e.g. the rear unit of a back-to-back lash-up).
+	// Their direction is reversed.
+	Inverted bool
+	// Trim adjusts the member's speed by the given percentage to match the other members (e.g. -10 for 90%).
+	Trim int
+}
+
+// Consist groups multiple cabs which run together (multiple unit lash-up) without reprogramming the decoders.
+// The first member is the lead unit.
+type Consist struct {
+	name    string
+	members []*Member
+}
+
+// NewConsist returns a new consist using the given members.
+// The first member is the lead unit.
+func NewConsist(name string, members ...*Member) *Consist {
+	return &Consist{
+		name:    name,
+		members: members,
+	}
+}
+
+// Name returns the consist's name.
+func (c *Consist) Name() string {
+	return c.name
+}
+
+// Members returns the consist's members starting with the lead unit.
+func (c *Consist) Members() []*Member {
+	return c.members
+}
+
+// Lead returns the consist's lead unit or nil if the consist doesn't have any members.
+func (c *Consist) Lead() *Member {
+	if len(c.members) == 0 {
+		return nil
+	}
+
+	return c.members[0]
+}
+
+// Speed returns the member's speed and direction for the given consist speed and direction.
+// Stopping and emergency stopping aren't trimmed. Moving members don't drop below speed 1.
+func (m *Member) Speed(speed cab.Speed, direction cab.Direction) (cab.Speed, cab.Direction) {
+	if m.Inverted {
+		direction = direction.Opposite()
+	}
+
+	if speed <= 0 || m.Trim == 0 {
+		return speed, direction
+	}
+
+	trimmed := int(speed) * (100 + m.Trim) / 100
+	return cab.Speed(min(max(trimmed, 1), 126)), direction
+}
+
+// Speed sets the speed and direction of all of the consist's members.
+// All members are set even if setting one of them fails. The errors are joined.
+func (c *Consist) Speed(ctx context.Context, speed cab.Speed, direction cab.Direction) error {
+	var errs []error
+	for _, member := range c.members {
+		memberSpeed, memberDirection := member.Speed(speed, direction)
+
+		err := member.Cab.Speed(ctx, memberSpeed, memberDirection)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to set speed of cab %d of consist %q: %w", member.Cab.Address(), c.name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// EStop stops all of the consist's members immediately (see cab.Cab.EStop).
+func (c *Consist) EStop(ctx context.Context) error {
+	var errs []error
+	for _, member := range c.members {
+		err := member.Cab.EStop(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to emergency stop cab %d of consist %q: %w", member.Cab.Address(), c.name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Function sets the function of the consist's lead unit (e.g. lights or sound).
+func (c *Consist) Function(ctx context.Context, funct cab.Function, state cab.FunctionState) error {
+	lead := c.Lead()
+	if lead == nil {
+		return fmt.Errorf("failed to set function of consist %q: no members", c.name)
+	}
+
+	err := lead.Cab.Function(ctx, funct, state)
+	if err != nil {
+		return fmt.Errorf("failed to set function of consist %q: %w", c.name, err)
+	}
+
+	return nil
+}
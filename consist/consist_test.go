@@ -0,0 +1,82 @@
+package consist_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/cab"
+	"github.com/roosterfish/dcc-ex-go/channel"
+	"github.com/roosterfish/dcc-ex-go/consist"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+	"github.com/roosterfish/dcc-ex-go/simulator"
+)
+
+func TestMemberSpeed(t *testing.T) {
+	tests := []struct {
+		name              string
+		member            consist.Member
+		speed             cab.Speed
+		expectedSpeed     cab.Speed
+		expectedDirection cab.Direction
+	}{
+		{name: "unchanged", member: consist.Member{}, speed: 50, expectedSpeed: 50, expectedDirection: cab.DirectionForward},
+		{name: "inverted", member: consist.Member{Inverted: true}, speed: 50, expectedSpeed: 50, expectedDirection: cab.DirectionBackward},
+		{name: "trimmed", member: consist.Member{Trim: -10}, speed: 50, expectedSpeed: 45, expectedDirection: cab.DirectionForward},
+		{name: "capped", member: consist.Member{Trim: 50}, speed: 120, expectedSpeed: 126, expectedDirection: cab.DirectionForward},
+		{name: "stopped", member: consist.Member{Trim: 10}, speed: 0, expectedSpeed: 0, expectedDirection: cab.DirectionForward},
+		{name: "emergency stop", member: consist.Member{Trim: 10}, speed: -1, expectedSpeed: -1, expectedDirection: cab.DirectionForward},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			speed, direction := test.member.Speed(test.speed, cab.DirectionForward)
+			if speed != test.expectedSpeed || direction != test.expectedDirection {
+				t.Errorf("Expected speed %d and direction %d but got %d and %d", test.expectedSpeed, test.expectedDirection, speed, direction)
+			}
+		})
+	}
+}
+
+func TestConsist(t *testing.T) {
+	p := protocol.NewProtocol(simulator.NewSimulator(), &protocol.Config{})
+	defer p.Close()
+
+	ch := channel.NewChannel(p)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	lead := cab.NewCab(3, ch)
+	rear := cab.NewCab(4, ch)
+
+	c := consist.NewConsist("freight", &consist.Member{Cab: lead}, &consist.Member{Cab: rear, Inverted: true, Trim: -10})
+
+	err := c.Speed(ctx, 40, cab.DirectionForward)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.Function(ctx, 0, cab.FunctionOn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leadState, err := lead.State(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if leadState.Speed != 40 || leadState.Direction != cab.DirectionForward || leadState.Function(0) != cab.FunctionOn {
+		t.Errorf("Unexpected lead state %+v", leadState)
+	}
+
+	rearState, err := rear.State(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rearState.Speed != 36 || rearState.Direction != cab.DirectionBackward || rearState.Function(0) != cab.FunctionOff {
+		t.Errorf("Unexpected rear state %+v", rearState)
+	}
+}
@@ -0,0 +1,227 @@
+package diagnostics
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/channel"
+	"github.com/roosterfish/dcc-ex-go/clock"
+	"github.com/roosterfish/dcc-ex-go/command"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+)
+
+// JitterConfig configures a run of the jitter analyzer.
+type JitterConfig struct {
+	// Samples is the number of probe commands written during the run.
+	Samples int
+	// Interval is the pause between two probe commands.
+	Interval time.Duration
+	// Command is the probe command written to the command station.
+	Command *command.Command
+	// ResponseOpCode is the op code of the broadcast caused by the probe command.
+	ResponseOpCode command.OpCode
+	// Clock is the source of time used to measure the arrivals and latencies.
+	// It defaults to the real time.
+	Clock clock.Clock
+}
+
+// Stats summarizes a series of durations.
+// StdDev is the jitter of the series.
+type Stats struct {
+	Count  int
+	Min    time.Duration
+	Max    time.Duration
+	Mean   time.Duration
+	StdDev time.Duration
+	P50    time.Duration
+	P95    time.Duration
+	P99    time.Duration
+}
+
+// JitterReport is the result of a run of the jitter analyzer.
+type JitterReport struct {
+	// Duration is the total duration of the run.
+	Duration time.Duration
+	// Frames is the number of frames observed during the run.
+	Frames int
+	// InterArrival summarizes the durations between the arrival of two consecutive frames.
+	InterArrival Stats
+	// Latency summarizes the durations between writing a probe command and observing its response.
+	Latency Stats
+}
+
+// NewDefaultJitterConfig returns a config probing the command station's status 100 times.
+func NewDefaultJitterConfig() *JitterConfig {
+	return &JitterConfig{
+		Samples:        100,
+		Interval:       50 * time.Millisecond,
+		Command:        command.NewCommand(command.OpCodeStatus, ""),
+		ResponseOpCode: command.OpCodeStatusResponse,
+	}
+}
+
+// NewStats returns the summary of the given durations.
+func NewStats(durations []time.Duration) Stats {
+	if len(durations) == 0 {
+		return Stats{}
+	}
+
+	sorted := slices.Clone(durations)
+	slices.Sort(sorted)
+
+	var sum time.Duration
+	for _, duration := range sorted {
+		sum += duration
+	}
+
+	mean := sum / time.Duration(len(sorted))
+
+	var variance float64
+	for _, duration := range sorted {
+		diff := float64(duration - mean)
+		variance += diff * diff
+	}
+
+	variance /= float64(len(sorted))
+
+	percentileF := func(p float64) time.Duration {
+		index := int(math.Ceil(p*float64(len(sorted)))) - 1
+		return sorted[max(index, 0)]
+	}
+
+	return Stats{
+		Count:  len(sorted),
+		Min:    sorted[0],
+		Max:    sorted[len(sorted)-1],
+		Mean:   mean,
+		StdDev: time.Duration(math.Sqrt(variance)),
+		P50:    percentileF(0.50),
+		P95:    percentileF(0.95),
+		P99:    percentileF(0.99),
+	}
+}
+
+func (s Stats) String() string {
+	return fmt.Sprintf("n=%d min=%s max=%s mean=%s jitter=%s p50=%s p95=%s p99=%s", s.Count, s.Min, s.Max, s.Mean, s.StdDev, s.P50, s.P95, s.P99)
+}
+
+func (r *JitterReport) String() string {
+	builder := strings.Builder{}
+	fmt.Fprintf(&builder, "Duration: %s\n", r.Duration)
+	fmt.Fprintf(&builder, "Frames: %d\n", r.Frames)
+	fmt.Fprintf(&builder, "Inter-arrival: %s\n", r.InterArrival)
+	fmt.Fprintf(&builder, "Latency: %s\n", r.Latency)
+
+	return builder.String()
+}
+
+// AnalyzeJitter measures the arrival jitter of all frames and the write-to-broadcast latency of the
+// configured probe command.
+// Run it using different transports (e.g. USB and WiFi) to compare them and tune timing margins.
+func AnalyzeJitter(ctx context.Context, channel channel.Interface, config *JitterConfig) (*JitterReport, error) {
+	source := clock.Or(config.Clock)
+
+	var arrivals []time.Time
+	arrivalsLock := sync.Mutex{}
+
+	readerCtx, cancel := context.WithCancel(ctx)
+	wg := sync.WaitGroup{}
+	readyC := make(chan struct{})
+
+	// Record the arrival of every frame independent of the probe commands.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		_ = channel.RSession(func(sessionProtocol protocol.Reader) error {
+			commandC, cleanupF := protocol.ReadWithOptions(sessionProtocol, &protocol.ReadOptions{
+				Ownership: protocol.OwnershipObserver,
+			})
+			defer cleanupF()
+
+			close(readyC)
+
+			for {
+				select {
+				case _, ok := <-commandC:
+					if !ok {
+						return nil
+					}
+
+					arrivalsLock.Lock()
+					arrivals = append(arrivals, source.Now())
+					arrivalsLock.Unlock()
+				case <-readerCtx.Done():
+					return readerCtx.Err()
+				}
+			}
+		})
+	}()
+
+	<-readyC
+
+	latencies := make([]time.Duration, 0, config.Samples)
+	start := source.Now()
+
+	var err error
+	for i := range config.Samples {
+		if i > 0 {
+			select {
+			case <-source.After(config.Interval):
+			case <-ctx.Done():
+				err = ctx.Err()
+			}
+		}
+
+		if err != nil {
+			break
+		}
+
+		var latency time.Duration
+		received := false
+		written := source.Now()
+		err = channel.WriteAndReadOpCode(ctx, config.Command, config.ResponseOpCode, func(cmd *command.Command) error {
+			// Only consider the first response.
+			if !received {
+				latency = source.Since(written)
+				received = true
+			}
+
+			return nil
+		})
+		if err != nil {
+			err = fmt.Errorf("failed to write probe command %q: %w", config.Command.String(), err)
+			break
+		}
+
+		if received {
+			latencies = append(latencies, latency)
+		}
+	}
+
+	duration := source.Since(start)
+
+	cancel()
+	wg.Wait()
+
+	if err != nil {
+		return nil, err
+	}
+
+	interArrivals := make([]time.Duration, 0, len(arrivals))
+	for i := 1; i < len(arrivals); i++ {
+		interArrivals = append(interArrivals, arrivals[i].Sub(arrivals[i-1]))
+	}
+
+	return &JitterReport{
+		Duration:     duration,
+		Frames:       len(arrivals),
+		InterArrival: NewStats(interArrivals),
+		Latency:      NewStats(latencies),
+	}, nil
+}
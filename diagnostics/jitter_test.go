@@ -0,0 +1,109 @@
+package diagnostics_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/channel"
+	"github.com/roosterfish/dcc-ex-go/clock"
+	"github.com/roosterfish/dcc-ex-go/diagnostics"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+	"github.com/roosterfish/dcc-ex-go/simulator"
+)
+
+func TestNewStats(t *testing.T) {
+	durations := []time.Duration{}
+	for i := 10; i >= 1; i-- {
+		durations = append(durations, time.Duration(i)*time.Millisecond)
+	}
+
+	stats := diagnostics.NewStats(durations)
+
+	expected := diagnostics.Stats{
+		Count:  10,
+		Min:    time.Millisecond,
+		Max:    10 * time.Millisecond,
+		Mean:   5500 * time.Microsecond,
+		StdDev: 2872281 * time.Nanosecond,
+		P50:    5 * time.Millisecond,
+		P95:    10 * time.Millisecond,
+		P99:    10 * time.Millisecond,
+	}
+
+	if stats != expected {
+		t.Errorf("Expected stats %+v but got %+v", expected, stats)
+	}
+
+	if diagnostics.NewStats(nil) != (diagnostics.Stats{}) {
+		t.Error("Expected empty stats")
+	}
+}
+
+func TestAnalyzeJitter(t *testing.T) {
+	p := protocol.NewProtocol(simulator.NewSimulator(), &protocol.Config{})
+	defer p.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	config := diagnostics.NewDefaultJitterConfig()
+	config.Samples = 10
+	config.Interval = time.Millisecond
+
+	report, err := diagnostics.AnalyzeJitter(ctx, channel.NewChannel(p), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Latency.Count != config.Samples {
+		t.Errorf("Expected %d latency samples but got %d", config.Samples, report.Latency.Count)
+	}
+
+	if report.Frames < config.Samples {
+		t.Errorf("Expected at least %d frames but got %d", config.Samples, report.Frames)
+	}
+}
+
+func TestAnalyzeJitterClock(t *testing.T) {
+	p := protocol.NewProtocol(simulator.NewSimulator(), &protocol.Config{})
+	defer p.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	mock := clock.NewMock(time.Unix(0, 0))
+
+	config := diagnostics.NewDefaultJitterConfig()
+	config.Samples = 2
+	config.Interval = time.Minute
+	config.Clock = mock
+
+	reportC := make(chan *diagnostics.JitterReport)
+	go func() {
+		report, err := diagnostics.AnalyzeJitter(ctx, channel.NewChannel(p), config)
+		if err != nil {
+			t.Error(err)
+		}
+
+		reportC <- report
+	}()
+
+	// Wait for the interval between the two probe commands.
+	mock.BlockUntil(1)
+	mock.Advance(time.Minute)
+
+	report := <-reportC
+	if report == nil {
+		t.FailNow()
+	}
+
+	if report.Duration != time.Minute {
+		t.Errorf("Expected a duration of %s but got %s", time.Minute, report.Duration)
+	}
+
+	// The responses arrive without the mock clock advancing which must still be sampled.
+	if report.Latency.Count != config.Samples || report.Latency.Max != 0 {
+		t.Errorf("Expected %d latency samples of zero but got %s", config.Samples, report.Latency)
+	}
+}
@@ -0,0 +1,307 @@
+package diagnostics
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/channel"
+	"github.com/roosterfish/dcc-ex-go/command"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+	"github.com/roosterfish/dcc-ex-go/station"
+	"github.com/roosterfish/dcc-ex-go/turnout"
+)
+
+// MotorFault is the kind of failure inferred for a turnout motor.
+type MotorFault uint8
+
+const (
+	// MotorStalled indicates the motor kept drawing current for longer than a throw takes (e.g. blocked points).
+	MotorStalled MotorFault = iota
+	// MotorShorted indicates the motor drew an excessive current.
+	MotorShorted
+)
+
+// maintenanceBufferSize is the number of maintenance events buffered for the consumer.
+const maintenanceBufferSize = 32
+
+// TurnoutMotorConfig configures the inference of turnout motor failures.
+type TurnoutMotorConfig struct {
+	// Track is the index of the track powering the turnout motors (0 is MAIN).
+	Track int
+	// Interval is the pause between sampling the track current.
+	Interval time.Duration
+	// Window is the duration after a turnout got thrown or closed in which spikes are attributed to it.
+	Window time.Duration
+	// SpikeThreshold is the current in milliamps above the baseline which is considered to be a spike.
+	SpikeThreshold int
+	// ShortThreshold is the current in milliamps above the baseline which indicates a shorted motor.
+	ShortThreshold int
+	// StallDuration is the duration after which a spike indicates a stalled motor.
+	StallDuration time.Duration
+}
+
+// MaintenanceEvent names a turnout whose motor is suspected to be faulty.
+type MaintenanceEvent struct {
+	Turnout turnout.ID
+	Fault   MotorFault
+	// Peak is the highest current in milliamps above the baseline observed during the spike.
+	Peak int
+	// Duration is the duration of the longest spike.
+	Duration time.Duration
+	Time     time.Time
+}
+
+// MaintenanceC receives the maintenance events.
+type MaintenanceC chan *MaintenanceEvent
+
+// TurnoutMotorMonitor infers stalled or shorted turnout motors by combining turnout state broadcasts
+// with spikes of the track current.
+type TurnoutMotorMonitor struct {
+	channel *channel.Channel
+	config  *TurnoutMotorConfig
+}
+
+// motorWindow tracks the current after a single turnout got thrown or closed.
+type motorWindow struct {
+	turnout    turnout.ID
+	start      time.Time
+	peak       int
+	spikeStart time.Time
+	longest    time.Duration
+}
+
+// motorDetector attributes current spikes to the most recently moved turnout.
+type motorDetector struct {
+	config   *TurnoutMotorConfig
+	baseline float64
+	sampled  bool
+	window   *motorWindow
+}
+
+func (f MotorFault) String() string {
+	switch f {
+	case MotorStalled:
+		return "stalled"
+	case MotorShorted:
+		return "shorted"
+	default:
+		return "unknown"
+	}
+}
+
+func (e *MaintenanceEvent) String() string {
+	return fmt.Sprintf("turnout %d motor %s (peak %dmA for %s)", e.Turnout, e.Fault, e.Peak, e.Duration)
+}
+
+// NewDefaultTurnoutMotorConfig returns a config suitable for solenoid and stall motors on the main track.
+func NewDefaultTurnoutMotorConfig() *TurnoutMotorConfig {
+	return &TurnoutMotorConfig{
+		Track:          0,
+		Interval:       50 * time.Millisecond,
+		Window:         2 * time.Second,
+		SpikeThreshold: 100,
+		ShortThreshold: 1500,
+		StallDuration:  time.Second,
+	}
+}
+
+// NewTurnoutMotorMonitor returns a new monitor using the given channel and config.
+func NewTurnoutMotorMonitor(channel *channel.Channel, config *TurnoutMotorConfig) *TurnoutMotorMonitor {
+	return &TurnoutMotorMonitor{
+		channel: channel,
+		config:  config,
+	}
+}
+
+// moved opens a new window for the given turnout and closes the previous one.
+func (d *motorDetector) moved(id turnout.ID, t time.Time) *MaintenanceEvent {
+	event := d.close(t)
+	d.window = &motorWindow{
+		turnout: id,
+		start:   t,
+	}
+
+	return event
+}
+
+// sample processes the track current sampled at the given time.
+func (d *motorDetector) sample(current int, t time.Time) *MaintenanceEvent {
+	if d.window != nil && t.Sub(d.window.start) > d.config.Window {
+		return d.close(t)
+	}
+
+	if d.window == nil {
+		// Outside of windows the current is used to learn the baseline.
+		if !d.sampled {
+			d.baseline = float64(current)
+			d.sampled = true
+		} else {
+			d.baseline = 0.9*d.baseline + 0.1*float64(current)
+		}
+
+		return nil
+	}
+
+	excess := current - int(d.baseline)
+	d.window.peak = max(d.window.peak, excess)
+
+	if excess >= d.config.SpikeThreshold {
+		if d.window.spikeStart.IsZero() {
+			d.window.spikeStart = t
+		}
+
+		d.window.longest = max(d.window.longest, t.Sub(d.window.spikeStart))
+	} else {
+		d.window.spikeStart = time.Time{}
+	}
+
+	return nil
+}
+
+// close closes the current window and returns the inferred fault if any.
+func (d *motorDetector) close(t time.Time) *MaintenanceEvent {
+	window := d.window
+	d.window = nil
+
+	if window == nil {
+		return nil
+	}
+
+	event := &MaintenanceEvent{
+		Turnout:  window.turnout,
+		Peak:     window.peak,
+		Duration: window.longest,
+		Time:     t,
+	}
+
+	if window.peak >= d.config.ShortThreshold {
+		event.Fault = MotorShorted
+		return event
+	}
+
+	if window.longest >= d.config.StallDuration {
+		event.Fault = MotorStalled
+		return event
+	}
+
+	return nil
+}
+
+// Start starts sampling the track current and observing the turnouts until cleanup is called.
+// Up to 32 maintenance events are buffered; further events are dropped until the channel is consumed.
+// Never close the channel manually but instead call the cleanup function.
+func (m *TurnoutMotorMonitor) Start() (MaintenanceC, protocol.CleanupF) {
+	maintenanceC := make(MaintenanceC, maintenanceBufferSize)
+	detector := &motorDetector{
+		config: m.config,
+	}
+
+	detectorLock := sync.Mutex{}
+	emitF := func(event *MaintenanceEvent) {
+		if event == nil {
+			return
+		}
+
+		select {
+		case maintenanceC <- event:
+		default:
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := sync.WaitGroup{}
+	readyC := make(chan struct{})
+
+	// Observe turnouts being thrown or closed.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		_ = m.channel.RSession(func(sessionProtocol protocol.Reader) error {
+			commandC, cleanupF := protocol.ReadFiltered(sessionProtocol, command.OpCodeTurnoutResponse)
+			defer cleanupF()
+
+			close(readyC)
+
+			for {
+				select {
+				case cmd, ok := <-commandC:
+					if !ok {
+						return nil
+					}
+
+					id, ok := parseTurnoutState(cmd)
+					if !ok {
+						continue
+					}
+
+					detectorLock.Lock()
+					emitF(detector.moved(id, m.channel.Clock().Now()))
+					detectorLock.Unlock()
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+		})
+	}()
+
+	<-readyC
+
+	// Sample the track current.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		commandStation := station.NewStation(m.channel)
+		defer commandStation.Close()
+
+		ticker := m.channel.Clock().NewTicker(m.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C():
+			case <-ctx.Done():
+				return
+			}
+
+			currents, err := commandStation.TrackCurrents(ctx)
+			if err != nil || m.config.Track >= len(currents) {
+				continue
+			}
+
+			detectorLock.Lock()
+			emitF(detector.sample(currents[m.config.Track], m.channel.Clock().Now()))
+			detectorLock.Unlock()
+		}
+	}()
+
+	return maintenanceC, func() {
+		cancel()
+		wg.Wait()
+
+		close(maintenanceC)
+	}
+}
+
+// parseTurnoutState returns the ID of the turnout in a <H id state> broadcast.
+func parseTurnoutState(cmd *command.Command) (turnout.ID, bool) {
+	if cmd.OpCode() != command.OpCodeTurnoutResponse {
+		return 0, false
+	}
+
+	params, err := cmd.ParametersStrings()
+	if err != nil || len(params) != 2 {
+		return 0, false
+	}
+
+	id, err := strconv.ParseUint(params[0], 10, 16)
+	if err != nil {
+		return 0, false
+	}
+
+	return turnout.ID(id), true
+}
@@ -0,0 +1,69 @@
+package diagnostics_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/channel"
+	"github.com/roosterfish/dcc-ex-go/command"
+	"github.com/roosterfish/dcc-ex-go/diagnostics"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+	"github.com/roosterfish/dcc-ex-go/simulator"
+	"github.com/roosterfish/dcc-ex-go/turnout"
+)
+
+func TestTurnoutMotorMonitor(t *testing.T) {
+	sim := simulator.NewSimulator()
+	p := protocol.NewProtocol(sim, &protocol.Config{})
+	defer p.Close()
+
+	ch := channel.NewChannel(p)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := ch.Write(ctx, command.NewCommand(command.OpCodeTurnout, "%d SERVO %d %d %d %d", 7, 100, 400, 100, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	monitor := diagnostics.NewTurnoutMotorMonitor(ch, &diagnostics.TurnoutMotorConfig{
+		Interval:       5 * time.Millisecond,
+		Window:         200 * time.Millisecond,
+		SpikeThreshold: 100,
+		ShortThreshold: 1500,
+		StallDuration:  50 * time.Millisecond,
+	})
+
+	err = sim.SetTrackCurrent(0, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	maintenanceC, cleanupF := monitor.Start()
+	defer cleanupF()
+
+	// Learn the baseline before throwing the turnout.
+	time.Sleep(50 * time.Millisecond)
+
+	// The motor keeps drawing current as it's blocked.
+	err = sim.SetTrackCurrent(0, 500)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = turnout.NewTurnoutServo(7, ch).Throw(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case event := <-maintenanceC:
+		if event.Turnout != 7 || event.Fault != diagnostics.MotorStalled {
+			t.Errorf("Expected turnout 7 to be stalled but got %q", event)
+		}
+	case <-ctx.Done():
+		t.Fatal("Timed out waiting for the maintenance event")
+	}
+}
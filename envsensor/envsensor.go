@@ -0,0 +1,176 @@
+package envsensor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/channel"
+	"github.com/roosterfish/dcc-ex-go/command"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+)
+
+type VPin uint16
+type Unit string
+
+// ConvertF converts the raw analog value of an input into its unit.
+type ConvertF func(raw int) float64
+
+type ReadingC chan *Reading
+
+const (
+	UnitRaw     Unit = ""
+	UnitCelsius Unit = "°C"
+	UnitVolt    Unit = "V"
+)
+
+// Input is an analog vpin exposing an environment value like temperature or voltage.
+type Input struct {
+	Name     string
+	VPin     VPin
+	Unit     Unit
+	ConvertF ConvertF
+}
+
+// Reading is a single reading of an input.
+// In case the input couldn't be read, Err is set.
+type Reading struct {
+	Input *Input
+	Raw   int
+	Value float64
+	Time  time.Time
+	Err   error
+}
+
+type Monitor struct {
+	interval time.Duration
+	inputs   []*Input
+	channel  channel.Interface
+}
+
+// LinearF returns a conversion function which scales the raw value and adds the offset.
+// As an example a voltage divider of 1:3 on a 5V board using a 10 bit ADC can be
+// converted using LinearF(5.0/1023*3, 0).
+func LinearF(scale float64, offset float64) ConvertF {
+	return func(raw int) float64 {
+		return float64(raw)*scale + offset
+	}
+}
+
+// NewMonitor returns a monitor periodically reading the given inputs.
+func NewMonitor(interval time.Duration, channel channel.Interface, inputs ...*Input) *Monitor {
+	return &Monitor{
+		interval: interval,
+		inputs:   inputs,
+		channel:  channel,
+	}
+}
+
+// Read reads the analog value of the given input once.
+// The command station responds to <D ANIN vpin> with <* VPIN=vpin value=raw *>.
+func (m *Monitor) Read(ctx context.Context, input *Input) (*Reading, error) {
+	var raw *int
+
+	readCommand := command.NewCommand(command.OpCodeDiagnostic, "ANIN %d", input.VPin)
+	err := m.channel.WriteAndReadOpCode(ctx, readCommand, command.OpCodeDescribe, func(cmd *command.Command) error {
+		params, err := cmd.ParametersStrings()
+		if err != nil {
+			return fmt.Errorf("failed getting analog input command parameters: %w", err)
+		}
+
+		vpinParam := fmt.Sprintf("VPIN=%d", input.VPin)
+		if !slices.ContainsFunc(params, func(param string) bool { return strings.EqualFold(param, vpinParam) }) {
+			// Not the right vpin, skip it.
+			return nil
+		}
+
+		for _, param := range params {
+			value, ok := strings.CutPrefix(param, "value=")
+			if !ok {
+				continue
+			}
+
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid analog value %q: %w", value, err)
+			}
+
+			raw = &parsed
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read input %q on vpin %d: %w", input.Name, input.VPin, err)
+	}
+
+	if raw == nil {
+		return nil, errors.New("analog value response is missing")
+	}
+
+	reading := &Reading{
+		Input: input,
+		Raw:   *raw,
+		Value: float64(*raw),
+		Time:  m.channel.Clock().Now(),
+	}
+
+	if input.ConvertF != nil {
+		reading.Value = input.ConvertF(*raw)
+	}
+
+	return reading, nil
+}
+
+// Start starts reading all of the inputs every interval.
+// The readings are sent to the returned channel.
+// Never close the channel manually but instead call the cleanup function.
+func (m *Monitor) Start() (ReadingC, protocol.CleanupF) {
+	readingC := make(ReadingC)
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := sync.WaitGroup{}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ticker := m.channel.Clock().NewTicker(m.interval)
+		defer ticker.Stop()
+
+		for {
+			for _, input := range m.inputs {
+				reading, err := m.Read(ctx, input)
+				if err != nil {
+					reading = &Reading{
+						Input: input,
+						Time:  m.channel.Clock().Now(),
+						Err:   err,
+					}
+				}
+
+				select {
+				case readingC <- reading:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			select {
+			case <-ticker.C():
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return readingC, func() {
+		cancel()
+		wg.Wait()
+		close(readingC)
+	}
+}
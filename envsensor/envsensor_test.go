@@ -0,0 +1,79 @@
+package envsensor_test
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/channel"
+	"github.com/roosterfish/dcc-ex-go/envsensor"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+	"github.com/roosterfish/dcc-ex-go/simulator"
+)
+
+func TestMonitor(t *testing.T) {
+	sim := simulator.NewSimulator()
+	sim.SetAnalogValue(57, 512)
+	sim.SetAnalogValue(58, 100)
+
+	p := protocol.NewProtocol(sim, &protocol.Config{})
+	defer p.Close()
+
+	temperature := &envsensor.Input{
+		Name:     "temperature",
+		VPin:     57,
+		Unit:     envsensor.UnitCelsius,
+		ConvertF: envsensor.LinearF(0.1, -20),
+	}
+
+	voltage := &envsensor.Input{
+		Name: "voltage",
+		VPin: 58,
+		Unit: envsensor.UnitRaw,
+	}
+
+	monitor := envsensor.NewMonitor(time.Millisecond, channel.NewChannel(p), temperature, voltage)
+
+	readingC, cleanupF := monitor.Start()
+	defer cleanupF()
+
+	expected := map[*envsensor.Input]float64{
+		temperature: 31.2,
+		voltage:     100,
+	}
+
+	timeout := time.After(5 * time.Second)
+	for range 2 * len(expected) {
+		select {
+		case reading := <-readingC:
+			if reading.Err != nil {
+				t.Fatal(reading.Err)
+			}
+
+			if math.Abs(reading.Value-expected[reading.Input]) > 1e-9 {
+				t.Errorf("Expected %s value %f but got %f", reading.Input.Name, expected[reading.Input], reading.Value)
+			}
+		case <-timeout:
+			t.Fatal("Timed out waiting for readings")
+		}
+	}
+}
+
+func TestMonitorRead(t *testing.T) {
+	p := protocol.NewProtocol(simulator.NewSimulator(), &protocol.Config{})
+	defer p.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	monitor := envsensor.NewMonitor(time.Second, channel.NewChannel(p))
+	reading, err := monitor.Read(ctx, &envsensor.Input{VPin: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reading.Raw != 0 {
+		t.Errorf("Expected raw value 0 but got %d", reading.Raw)
+	}
+}
@@ -0,0 +1,60 @@
+package errs
+
+import (
+	"context"
+	"errors"
+)
+
+// Sentinel errors returned by the packages of this module.
+// Use errors.Is to check for them instead of matching the error messages.
+var (
+	// ErrClosed is returned once the underlying protocol, serial port or network connection got closed.
+	ErrClosed = errors.New("closed")
+	// ErrTimeout is returned if an operation's deadline exceeded (e.g. while waiting for a response).
+	ErrTimeout = errors.New("timeout")
+	// ErrStationFailure is returned if the command station refused or failed to run a command.
+	ErrStationFailure = errors.New("command station failure")
+	// ErrMalformedCommand is returned for commands and frames which cannot be parsed or aren't accepted by DCC-EX.
+	ErrMalformedCommand = errors.New("malformed command")
+)
+
+// markedError is an error matching an additional sentinel error while keeping its own message.
+type markedError struct {
+	err      error
+	sentinel error
+}
+
+func (e *markedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *markedError) Unwrap() []error {
+	return []error{e.err, e.sentinel}
+}
+
+// Mark returns an error with the same message as err which matches both err and the sentinel error using errors.Is.
+func Mark(err error, sentinel error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &markedError{
+		err:      err,
+		sentinel: sentinel,
+	}
+}
+
+// New returns a new error with the given message which matches the sentinel error using errors.Is.
+func New(message string, sentinel error) error {
+	return Mark(errors.New(message), sentinel)
+}
+
+// Context marks context errors caused by an exceeded deadline with ErrTimeout.
+// Any other error is returned as is.
+func Context(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return Mark(err, ErrTimeout)
+	}
+
+	return err
+}
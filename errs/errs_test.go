@@ -0,0 +1,34 @@
+package errs
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestMark(t *testing.T) {
+	original := errors.New("serial port is gone")
+	err := Mark(original, ErrClosed)
+
+	if err.Error() != "serial port is gone" {
+		t.Errorf("Expected the original message but got %q", err.Error())
+	}
+
+	if !errors.Is(err, original) || !errors.Is(err, ErrClosed) {
+		t.Error("Expected the error to match both the original and the sentinel error")
+	}
+
+	if Mark(nil, ErrClosed) != nil {
+		t.Error("Expected marking nil to return nil")
+	}
+}
+
+func TestContext(t *testing.T) {
+	if !errors.Is(Context(context.DeadlineExceeded), ErrTimeout) {
+		t.Error("Expected an exceeded deadline to match ErrTimeout")
+	}
+
+	if errors.Is(Context(context.Canceled), ErrTimeout) {
+		t.Error("Expected a cancelled context not to match ErrTimeout")
+	}
+}
@@ -3,6 +3,7 @@ module github.com/roosterfish/dcc-ex-go
 go 1.23.4
 
 require (
+	github.com/coder/websocket v1.8.15
 	github.com/google/uuid v1.6.0
 	go.bug.st/serial v1.6.2
 	golang.org/x/sync v0.12.0
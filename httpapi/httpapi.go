@@ -0,0 +1,274 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/roosterfish/dcc-ex-go/channel"
+	"github.com/roosterfish/dcc-ex-go/command"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+)
+
+// EntityType is the type of entity a broadcast belongs to.
+type EntityType string
+
+const (
+	EntitySensor  EntityType = "sensor"
+	EntityTurnout EntityType = "turnout"
+	EntityOutput  EntityType = "output"
+	EntityCab     EntityType = "cab"
+	EntityPower   EntityType = "power"
+)
+
+// eventBufferSize is the number of events buffered for every client.
+// Further events are dropped until the client caught up.
+const eventBufferSize = 64
+
+// Event is a broadcast of the command station sent to the clients of the event stream.
+type Event struct {
+	Type EntityType `json:"type"`
+	// ID is the ID of the sensor, turnout or output or the address of the cab.
+	// It's omitted for power broadcasts.
+	ID      *uint16 `json:"id,omitempty"`
+	Command string  `json:"command"`
+}
+
+// Filter selects the events sent to a client.
+type Filter struct {
+	// Types are the entity types to send. All of the types are sent if empty.
+	Types []EntityType
+	// IDs are the entity IDs to send. All of the IDs are sent if empty.
+	// Events without ID (e.g. power) aren't affected.
+	IDs []uint16
+}
+
+// Server exposes the command station's broadcasts over HTTP.
+type Server struct {
+	channel *channel.Channel
+	mux     *http.ServeMux
+}
+
+// NewServer returns a new server streaming the broadcasts of the given channel.
+// The event stream is served as server-sent events on GET /events.
+// It can be filtered using the query parameters type and id (e.g. /events?type=sensor,turnout&id=1,2).
+func NewServer(channel *channel.Channel) *Server {
+	s := &Server{
+		channel: channel,
+		mux:     http.NewServeMux(),
+	}
+
+	s.mux.HandleFunc("GET /events", s.handleEvents)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// ParseFilter parses the filter from the comma separated query parameters type and id.
+func ParseFilter(r *http.Request) (*Filter, error) {
+	filter := &Filter{}
+
+	for _, param := range splitQuery(r, "type") {
+		entityType := EntityType(param)
+		switch entityType {
+		case EntitySensor, EntityTurnout, EntityOutput, EntityCab, EntityPower:
+			filter.Types = append(filter.Types, entityType)
+		default:
+			return nil, fmt.Errorf("unknown entity type %q", param)
+		}
+	}
+
+	for _, param := range splitQuery(r, "id") {
+		id, err := strconv.ParseUint(param, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid id %q: %w", param, err)
+		}
+
+		filter.IDs = append(filter.IDs, uint16(id))
+	}
+
+	return filter, nil
+}
+
+// splitQuery returns the comma separated values of the given query parameter.
+func splitQuery(r *http.Request, key string) []string {
+	var values []string
+	for _, value := range r.URL.Query()[key] {
+		for _, v := range strings.Split(value, ",") {
+			v = strings.TrimSpace(v)
+			if v != "" {
+				values = append(values, v)
+			}
+		}
+	}
+
+	return values
+}
+
+// Match returns true if the event passes the filter.
+func (f *Filter) Match(event *Event) bool {
+	if len(f.Types) > 0 && !slices.Contains(f.Types, event.Type) {
+		return false
+	}
+
+	if len(f.IDs) > 0 && event.ID != nil && !slices.Contains(f.IDs, *event.ID) {
+		return false
+	}
+
+	return true
+}
+
+// opCodes returns the op codes of the broadcasts of the filter's entity types.
+func (f *Filter) opCodes() []command.OpCode {
+	opCodes := map[EntityType][]command.OpCode{
+		EntitySensor:  {'Q', 'q'},
+		EntityTurnout: {command.OpCodeTurnoutResponse},
+		EntityOutput:  {command.OpCodeOutputResponse},
+		EntityCab:     {command.OpCodeCabResponse},
+		EntityPower:   {command.OpCodePower},
+	}
+
+	types := f.Types
+	if len(types) == 0 {
+		types = []EntityType{EntitySensor, EntityTurnout, EntityOutput, EntityCab, EntityPower}
+	}
+
+	var filtered []command.OpCode
+	for _, entityType := range types {
+		filtered = append(filtered, opCodes[entityType]...)
+	}
+
+	return filtered
+}
+
+// NewEvent returns the event of the given broadcast.
+// It returns nil for commands which aren't broadcasts (e.g. listings).
+func NewEvent(cmd *command.Command) *Event {
+	params, err := cmd.ParametersStrings()
+	if err != nil {
+		return nil
+	}
+
+	event := &Event{
+		Command: cmd.String(),
+	}
+
+	// The broadcasts are <Q id>, <q id>, <H id state>, <Y id state>, <l cab reg speed functions> and <p state [track]>.
+	switch {
+	case (cmd.OpCode() == 'Q' || cmd.OpCode() == 'q') && len(params) == 1:
+		event.Type = EntitySensor
+	case cmd.OpCode() == command.OpCodeTurnoutResponse && len(params) == 2:
+		event.Type = EntityTurnout
+	case cmd.OpCode() == command.OpCodeOutputResponse && len(params) == 2:
+		event.Type = EntityOutput
+	case cmd.OpCode() == command.OpCodeCabResponse && len(params) == 4:
+		event.Type = EntityCab
+	case cmd.OpCode() == command.OpCodePower:
+		event.Type = EntityPower
+		return event
+	default:
+		return nil
+	}
+
+	id, err := strconv.ParseUint(params[0], 10, 16)
+	if err != nil {
+		return nil
+	}
+
+	event.ID = new(uint16)
+	*event.ID = uint16(id)
+
+	return event
+}
+
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	filter, err := ParseFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	_ = s.channel.RSession(func(sessionProtocol protocol.Reader) error {
+		commandC, cleanupF := protocol.ReadFiltered(sessionProtocol, filter.opCodes()...)
+		defer cleanupF()
+
+		// Respond only once subscribed so the client cannot miss any broadcast.
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		// Decouple the client from the protocol's listener.
+		// A slow client only drops its own events but doesn't stall the other readers.
+		ctx, cancel := context.WithCancel(r.Context())
+		eventC := make(chan *Event, eventBufferSize)
+
+		// Ensure the routine has returned before the reader gets cleaned up.
+		defer func() {
+			cancel()
+			for range eventC {
+			}
+		}()
+
+		go func() {
+			defer close(eventC)
+
+			for {
+				select {
+				case cmd, ok := <-commandC:
+					if !ok {
+						return
+					}
+
+					event := NewEvent(cmd)
+					if event == nil || !filter.Match(event) {
+						continue
+					}
+
+					select {
+					case eventC <- event:
+					default:
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+
+		data := &bytes.Buffer{}
+		encoder := json.NewEncoder(data)
+		// Keep the commands readable (<Q 1> instead of \u003cQ 1\u003e).
+		encoder.SetEscapeHTML(false)
+
+		for event := range eventC {
+			data.Reset()
+			err := encoder.Encode(event)
+			if err != nil {
+				return err
+			}
+
+			// The encoder already terminates the data with a newline.
+			_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n", event.Type, data)
+			if err != nil {
+				return err
+			}
+
+			flusher.Flush()
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,81 @@
+package httpapi_test
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/channel"
+	"github.com/roosterfish/dcc-ex-go/httpapi"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+	"github.com/roosterfish/dcc-ex-go/sensor"
+	"github.com/roosterfish/dcc-ex-go/simulator"
+)
+
+func TestEventsFilter(t *testing.T) {
+	sim := simulator.NewSimulator()
+	p := protocol.NewProtocol(sim, &protocol.Config{})
+	defer p.Close()
+
+	server := httptest.NewServer(httpapi.NewServer(channel.NewChannel(p)))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/events?type=sensor&id=2", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	// Only the last state change passes the filter.
+	sim.SetSensorState(1, sensor.StateActive)
+	sim.SetSensorState(2, sensor.StateActive)
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+
+		expected := `data: {"type":"sensor","id":2,"command":"<Q 2>"}`
+		if line != expected {
+			t.Errorf("Expected %s but got %s", expected, line)
+		}
+
+		return
+	}
+
+	t.Fatalf("Stream ended without event: %v", scanner.Err())
+}
+
+func TestEventsInvalidFilter(t *testing.T) {
+	p := protocol.NewProtocol(simulator.NewSimulator(), &protocol.Config{})
+	defer p.Close()
+
+	server := httptest.NewServer(httpapi.NewServer(channel.NewChannel(p)))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/events?type=signal")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected status %d but got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+}
@@ -0,0 +1,96 @@
+package layoutconfig
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+
+	"github.com/roosterfish/dcc-ex-go/station"
+)
+
+// Config describes the electrical configuration of a layout.
+type Config struct {
+	// Tracks maps the track outputs (A-H) to their mode, e.g. "MAIN", "PROG" or "DC 10".
+	Tracks map[string]string `json:"tracks"`
+}
+
+// Parse reads the config in JSON format from r.
+func Parse(r io.Reader) (*Config, error) {
+	config := &Config{}
+
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode layout config: %w", err)
+	}
+
+	_, err = config.TrackAssignments()
+	if err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// Load reads the config from the file at the given path.
+func Load(path string) (*Config, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open layout config: %w", err)
+	}
+
+	defer file.Close()
+
+	return Parse(file)
+}
+
+// TrackAssignments returns the config's track assignments ordered by track.
+func (c *Config) TrackAssignments() ([]*station.TrackAssignment, error) {
+	assignments := make([]*station.TrackAssignment, 0, len(c.Tracks))
+	for _, track := range slices.Sorted(maps.Keys(c.Tracks)) {
+		assignment, err := station.ParseTrackAssignment(track, c.Tracks[track])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse layout config: %w", err)
+		}
+
+		assignments = append(assignments, assignment)
+	}
+
+	return assignments, nil
+}
+
+// Apply assigns the configured modes to the command station's track outputs.
+// Afterwards the assignments are read back from the command station to verify they got applied.
+func (c *Config) Apply(ctx context.Context, commandStation *station.CommandStation) error {
+	assignments, err := c.TrackAssignments()
+	if err != nil {
+		return err
+	}
+
+	for _, assignment := range assignments {
+		err := commandStation.AssignTrack(ctx, assignment)
+		if err != nil {
+			return fmt.Errorf("failed to apply layout config: %w", err)
+		}
+	}
+
+	actual, err := commandStation.TrackAssignments(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to verify layout config: %w", err)
+	}
+
+	for _, assignment := range assignments {
+		actualAssignment, ok := actual[assignment.Track]
+		if !ok || *actualAssignment != *assignment {
+			return fmt.Errorf("failed to verify layout config: track %s is not assigned %q", assignment.Track, assignment)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package layoutconfig_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/channel"
+	"github.com/roosterfish/dcc-ex-go/layoutconfig"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+	"github.com/roosterfish/dcc-ex-go/simulator"
+	"github.com/roosterfish/dcc-ex-go/station"
+)
+
+func TestApply(t *testing.T) {
+	config, err := layoutconfig.Parse(strings.NewReader(`{"tracks": {"A": "MAIN", "B": "PROG", "C": "DC 10"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := protocol.NewProtocol(simulator.NewSimulator(), &protocol.Config{})
+	defer p.Close()
+
+	commandStation := station.NewStation(channel.NewChannel(p))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = config.Apply(ctx, commandStation)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assignments, err := commandStation.TrackAssignments(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(assignments) != 3 || assignments["C"].Mode != station.TrackModeDC || assignments["C"].Cab != 10 {
+		t.Errorf("Unexpected track assignments %v", assignments)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	_, err := layoutconfig.Parse(strings.NewReader(`{"tracks": {"C": "DC"}}`))
+	if err == nil {
+		t.Error("Expected missing cab address to fail")
+	}
+}
@@ -7,6 +7,8 @@ import (
 
 	"github.com/roosterfish/dcc-ex-go/channel"
 	"github.com/roosterfish/dcc-ex-go/command"
+	"github.com/roosterfish/dcc-ex-go/station"
+	dccvpin "github.com/roosterfish/dcc-ex-go/vpin"
 )
 
 type ID uint16
@@ -20,10 +22,10 @@ type Status struct {
 
 type Output struct {
 	id      ID
-	channel *channel.Channel
+	channel channel.Interface
 }
 
-func NewOutput(id ID, channel *channel.Channel) *Output {
+func NewOutput(id ID, channel channel.Interface) *Output {
 	return &Output{
 		id:      id,
 		channel: channel,
@@ -31,24 +33,26 @@ func NewOutput(id ID, channel *channel.Channel) *Output {
 }
 
 // Persist creates the output and persists its definition in the EEPROM.
+// In case a vpin.Checker is passed using vpin.WithChecker and the vpin is already assigned to another entity,
+// a *vpin.ConflictError is returned.
+// In case the EEPROM is estimated to be exhausted, station.ErrEEPROMFull is returned.
 func (o *Output) Persist(ctx context.Context, vpin VPin, iFlag IFlag) error {
-	outputCommand := command.NewCommand(command.OpCodeOutput, "%d %d %d", o.id, vpin, iFlag)
-	persistCommand := command.NewCommand(command.OpCodeEEPROM, "")
+	return o.channel.SessionContext(ctx, func(ctx context.Context) error {
+		owner := dccvpin.Owner{Kind: dccvpin.KindOutput, ID: uint16(o.id)}
+		err := dccvpin.CheckContext(ctx, uint16(vpin), owner)
+		if err != nil {
+			return fmt.Errorf("failed to persist output %d: %w", o.id, err)
+		}
 
-	persisted := false
-	err := o.channel.WriteAndReadOpCode(ctx, outputCommand.Append(persistCommand), command.OpCodeSuccess, func(cmd *command.Command) error {
-		persisted = true
+		outputCommand := command.NewCommand(command.OpCodeOutput, "%d %d %d", o.id, vpin, iFlag)
+		_, err = station.PersistDefinition(ctx, o.channel, outputCommand)
+		if err != nil {
+			return fmt.Errorf("failed to persist output %d: %w", o.id, err)
+		}
+
+		dccvpin.AssignContext(ctx, uint16(vpin), owner)
 		return nil
 	})
-	if err != nil {
-		return err
-	}
-
-	if !persisted {
-		return fmt.Errorf("failed to persist output %d: %w", o.id, err)
-	}
-
-	return nil
 }
 
 func (o *Output) setCommand(value DigitalValue) *command.Command {
@@ -56,25 +60,44 @@ func (o *Output) setCommand(value DigitalValue) *command.Command {
 }
 
 func (o *Output) equalsCommandParams(cmd *command.Command) error {
-	params, err := cmd.ParametersStrings()
+	// <Y id state>
+	if !command.NewMatcher(command.OpCodeOutputResponse, o.id, command.Wildcard).Match(cmd) {
+		return fmt.Errorf("invalid response for output %d: %q", o.id, cmd.String())
+	}
+
+	return nil
+}
+
+// set sets the output's value and waits for the command station to broadcast it.
+// The command station responds with <X> instead in case the output isn't defined.
+func (o *Output) set(ctx context.Context, value DigitalValue) error {
+	broadcasted := false
+	err := o.channel.WriteAndReadOpCode(ctx, o.setCommand(value), command.OpCodeOutputResponse, func(cmd *command.Command) error {
+		err := o.equalsCommandParams(cmd)
+		if err != nil {
+			return err
+		}
+
+		broadcasted = true
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed getting output command parameters: %w", err)
+		return err
 	}
 
-	outputMatch := len(params) == 2 && params[0] == strconv.FormatUint(uint64(o.id), 10)
-	if !outputMatch {
-		return fmt.Errorf("invalid response for output %d: %q", o.id, cmd.String())
+	if !broadcasted {
+		return fmt.Errorf("failed to set output %d: %w", o.id, channel.ErrCommandFailed)
 	}
 
 	return nil
 }
 
 func (o *Output) High(ctx context.Context) error {
-	return o.channel.WriteAndReadOpCode(ctx, o.setCommand(High), command.OpCodeOutputResponse, o.equalsCommandParams)
+	return o.set(ctx, High)
 }
 
 func (o *Output) Low(ctx context.Context) error {
-	return o.channel.WriteAndReadOpCode(ctx, o.setCommand(Low), command.OpCodeOutputResponse, o.equalsCommandParams)
+	return o.set(ctx, Low)
 }
 
 func (o *Output) Status(ctx context.Context) (*Status, error) {
@@ -124,7 +147,7 @@ func (o *Output) Status(ctx context.Context) (*Status, error) {
 	}
 
 	if outputStatus == nil {
-		return nil, fmt.Errorf("failed to find status for output %d", o.id)
+		return nil, fmt.Errorf("failed to find status for output %d: %w", o.id, channel.ErrCommandFailed)
 	}
 
 	return outputStatus, nil
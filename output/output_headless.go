@@ -26,12 +26,12 @@ const (
 )
 
 type OutputHeadless struct {
-	channel *channel.Channel
+	channel channel.Interface
 }
 
 // NewOutputHeadless returns an output without ID.
 // It allows directly interacting with vPINs.
-func NewOutputHeadless(channel *channel.Channel) *OutputHeadless {
+func NewOutputHeadless(channel channel.Interface) *OutputHeadless {
 	return &OutputHeadless{
 		channel: channel,
 	}
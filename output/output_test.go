@@ -0,0 +1,40 @@
+package output_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/channeltest"
+	"github.com/roosterfish/dcc-ex-go/errs"
+	"github.com/roosterfish/dcc-ex-go/output"
+)
+
+func TestSetFailed(t *testing.T) {
+	ch := channeltest.NewChannel()
+	defer ch.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// The output isn't defined, so the command station responds with <X> instead of <Y>.
+	o := output.NewOutput(5, ch)
+
+	err := o.High(ctx)
+	if !errors.Is(err, errs.ErrStationFailure) {
+		t.Errorf("Expected %v but got %v", errs.ErrStationFailure, err)
+	}
+
+	err = o.Low(ctx)
+	if !errors.Is(err, errs.ErrStationFailure) {
+		t.Errorf("Expected %v but got %v", errs.ErrStationFailure, err)
+	}
+
+	ch.Respond("<Z 5 1>", "<Y 5 1>")
+
+	err = o.High(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,85 @@
+package protocol
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/roosterfish/dcc-ex-go/command"
+	"github.com/roosterfish/dcc-ex-go/record"
+)
+
+// WriteBatch writes all of the batch's commands using a single write onto the underlying connection.
+// Unlike multiple calls to Write, the scheduler and write lock are only acquired once which ensures
+// no other writes are interleaved (e.g. when provisioning dozens of turnouts).
+// The batch is written with the highest priority of its commands (see PriorityOf).
+// Outbound interceptors and op code aliases are applied to every command.
+func (p *Protocol) WriteBatch(batch *command.Batch) error {
+	if p.config.ReadOnly {
+		return ErrReadOnly
+	}
+
+	start := p.clock().Now()
+
+	commands := make([]*command.Command, 0, batch.Len())
+	priority := PriorityLow
+
+	for _, cmd := range batch.Commands() {
+		// Reject the whole batch in case any of its commands is invalid.
+		err := cmd.Validate()
+		if err != nil {
+			return err
+		}
+
+		cmd, err = intercept(cmd, p.config.OutboundInterceptors)
+		if err != nil {
+			return fmt.Errorf("outbound interceptor failed: %w", err)
+		}
+
+		if cmd == nil {
+			continue
+		}
+
+		opCode, ok := p.egressAliases[cmd.OpCode()]
+		if ok {
+			cmd = remap(cmd, opCode)
+		}
+
+		priority = max(priority, PriorityOf(cmd))
+		commands = append(commands, cmd)
+	}
+
+	if len(commands) == 0 {
+		return nil
+	}
+
+	data := command.NewBatch(commands...).Bytes()
+
+	p.scheduler.acquire(priority)
+	defer p.scheduler.release()
+
+	// Emergency stops are never delayed but still account for the rate.
+	wait := p.rateLimiter.reserve(len(data))
+	if wait > 0 && priority != PriorityEmergency {
+		p.clock().Sleep(wait)
+	}
+
+	p.writeLock.Lock()
+	defer p.writeLock.Unlock()
+
+	_, err := p.port.Write(data)
+	if err != nil {
+		return p.writeError(err, fmt.Sprintf("batch of %d commands", len(commands)))
+	}
+
+	// The duration includes waiting for the scheduler and rate limiter.
+	duration := p.clock().Since(start)
+
+	for _, cmd := range commands {
+		p.tap(record.DirectionWrite, cmd.Bytes())
+		p.inc(CounterWrites)
+		p.observe(HistogramWriteLatency, duration)
+		p.logCommand("wrote command", cmd, slog.Duration("duration", duration), slog.Int("batch", len(commands)))
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package protocol
+
+import (
+	"sync"
+
+	"github.com/roosterfish/dcc-ex-go/command"
+)
+
+// DiagnosticC receives the diagnostic messages of the command station.
+type DiagnosticC chan *command.Diagnostic
+
+// ReadDiagnostics returns a channel which receives the <* ... *> diagnostic messages of the command station.
+// The messages caused by control commands are filtered out.
+// Never close the channel manually but instead call the cleanup function.
+func (p *Protocol) ReadDiagnostics() (DiagnosticC, CleanupF) {
+	commandC, cleanupF := p.ReadFiltered(command.OpCodeDescribe)
+	diagnosticC := make(DiagnosticC)
+	doneC := make(chan struct{})
+	wg := sync.WaitGroup{}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		for {
+			select {
+			case cmd, ok := <-commandC:
+				if !ok {
+					return
+				}
+
+				diagnostic, err := command.NewDiagnostic(cmd)
+				if err != nil || diagnostic.Control() {
+					continue
+				}
+
+				select {
+				case diagnosticC <- diagnostic:
+				case <-doneC:
+					return
+				}
+			case <-doneC:
+				return
+			}
+		}
+	}()
+
+	return diagnosticC, sync.OnceFunc(func() {
+		close(doneC)
+		wg.Wait()
+		cleanupF()
+		close(diagnosticC)
+	})
+}
@@ -0,0 +1,59 @@
+package protocol
+
+import (
+	"fmt"
+)
+
+// frameErrorBufferSize is the number of malformed frames buffered for every subscriber.
+const frameErrorBufferSize = 32
+
+// FrameError describes a malformed frame which got discarded.
+type FrameError struct {
+	// Frame is the raw payload of the frame without the delimiters.
+	Frame string
+	Err   error
+}
+
+// FrameErrorC receives the malformed frames.
+type FrameErrorC chan *FrameError
+
+func (e *FrameError) Error() string {
+	return fmt.Sprintf("malformed frame %q: %v", e.Frame, e.Err)
+}
+
+func (e *FrameError) Unwrap() error {
+	return e.Err
+}
+
+// FrameErrors returns a channel which receives every malformed frame which got discarded.
+// Frequent errors usually indicate a baud rate mismatch or noise on the line.
+// Up to 32 errors are buffered; further errors are dropped until the channel is consumed.
+// Never close the channel manually but instead call the cleanup function.
+func (p *Protocol) FrameErrors() (FrameErrorC, CleanupF) {
+	frameErrorC := make(FrameErrorC, frameErrorBufferSize)
+
+	p.frameErrorLock.Lock()
+	p.frameErrorSubscribers[frameErrorC] = struct{}{}
+	p.frameErrorLock.Unlock()
+
+	return frameErrorC, func() {
+		p.frameErrorLock.Lock()
+		delete(p.frameErrorSubscribers, frameErrorC)
+		p.frameErrorLock.Unlock()
+
+		close(frameErrorC)
+	}
+}
+
+// emitFrameError sends the malformed frame to all subscribers without blocking the listener.
+func (p *Protocol) emitFrameError(frameError *FrameError) {
+	p.frameErrorLock.Lock()
+	defer p.frameErrorLock.Unlock()
+
+	for frameErrorC := range p.frameErrorSubscribers {
+		select {
+		case frameErrorC <- frameError:
+		default:
+		}
+	}
+}
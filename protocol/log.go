@@ -0,0 +1,30 @@
+package protocol
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/roosterfish/dcc-ex-go/command"
+)
+
+// logCommand logs the given command at debug level in case a logger is configured.
+func (p *Protocol) logCommand(msg string, cmd *command.Command, attrs ...slog.Attr) {
+	logger := p.config.Logger
+	if logger == nil || !logger.Enabled(context.Background(), slog.LevelDebug) {
+		return
+	}
+
+	// Written commands carry typed parameters (e.g. ints) whereas received ones carry strings.
+	params := make([]string, 0, len(cmd.Parameters()))
+	for _, param := range cmd.Parameters() {
+		params = append(params, fmt.Sprint(param))
+	}
+
+	attrs = append(attrs,
+		slog.String("opcode", string(cmd.OpCode())),
+		slog.Any("params", params),
+	)
+
+	logger.LogAttrs(context.Background(), slog.LevelDebug, msg, attrs...)
+}
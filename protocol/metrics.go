@@ -0,0 +1,225 @@
+package protocol
+
+import (
+	"expvar"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Counter is the name of a metric which only increases.
+type Counter string
+
+// Gauge is the name of a metric which can go up and down.
+type Gauge string
+
+// Histogram is the name of a metric recording the distribution of latencies.
+type Histogram string
+
+const (
+	// CounterFramesReceived counts the frames read from the underlying connection.
+	CounterFramesReceived Counter = "frames_received"
+	// CounterFramesDropped counts the commands dropped for readers which didn't keep up.
+	CounterFramesDropped Counter = "frames_dropped"
+	// CounterWrites counts the commands written to the underlying connection.
+	CounterWrites Counter = "writes"
+	// CounterWriteErrors counts the commands which failed to be written.
+	CounterWriteErrors Counter = "write_errors"
+	// CounterParseErrors counts the malformed frames which got discarded.
+	CounterParseErrors Counter = "parse_errors"
+	// CounterVerifyErrors counts the written commands whose response wasn't observed (see Config.VerifyTimeout).
+	CounterVerifyErrors Counter = "verify_errors"
+)
+
+const (
+	// GaugeSubscriptions is the number of active readers.
+	GaugeSubscriptions Gauge = "subscriptions"
+	// GaugeSessionQueueDepth is the number of sessions waiting for a channel (see channel.Channel.SetMetrics).
+	GaugeSessionQueueDepth Gauge = "session_queue_depth"
+)
+
+const (
+	// HistogramWriteLatency is the duration of writes including waiting for the scheduler and rate limiter.
+	HistogramWriteLatency Histogram = "write_latency"
+	// HistogramResponseLatency is the duration between writing a command and observing its response
+	// using WriteAndReadOpCode.
+	HistogramResponseLatency Histogram = "response_latency"
+	// HistogramSessionWait is the time spent waiting for a channel's session (see channel.Channel.SetMetrics).
+	HistogramSessionWait Histogram = "session_wait"
+)
+
+// Metrics receives the protocol's metrics.
+// Implement it to export the metrics (e.g. to Prometheus).
+// Observe receives every single latency so the exporter can record its distribution (e.g. using buckets).
+// The methods are called from within the listener and must not block.
+type Metrics interface {
+	Inc(counter Counter)
+	Set(gauge Gauge, value float64)
+	Observe(histogram Histogram, d time.Duration)
+}
+
+// LabeledMetrics is implemented by metrics which can attribute their series to a label
+// (e.g. the label of a connection when controlling multiple layouts).
+type LabeledMetrics interface {
+	Metrics
+	// WithLabel returns the metrics whose series are attributed to the given label.
+	WithLabel(label string) Metrics
+}
+
+// WithLabel returns metrics attributing every series to the given label.
+// In case the metrics implement LabeledMetrics, they decide how to attach the label.
+// Otherwise the label is prefixed to the name of every metric (<label>_<name>).
+func WithLabel(metrics Metrics, label string) Metrics {
+	labeled, ok := metrics.(LabeledMetrics)
+	if ok {
+		return labeled.WithLabel(label)
+	}
+
+	return &prefixedMetrics{
+		metrics: metrics,
+		prefix:  label + "_",
+	}
+}
+
+// prefixedMetrics prefixes the name of every metric before passing it on.
+type prefixedMetrics struct {
+	metrics Metrics
+	prefix  string
+}
+
+func (m *prefixedMetrics) Inc(counter Counter) {
+	m.metrics.Inc(Counter(m.prefix + string(counter)))
+}
+
+func (m *prefixedMetrics) Set(gauge Gauge, value float64) {
+	m.metrics.Set(Gauge(m.prefix+string(gauge)), value)
+}
+
+func (m *prefixedMetrics) Observe(histogram Histogram, d time.Duration) {
+	m.metrics.Observe(Histogram(m.prefix+string(histogram)), d)
+}
+
+// DefaultLatencyBuckets are the upper bounds of the latency buckets used by ExpvarMetrics.
+var DefaultLatencyBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// ExpvarMetrics publishes the protocol's metrics using expvar.
+// Histograms are published as the number of observations (<name>_count), their sum in seconds (<name>_sum)
+// and the cumulative number of observations per bucket (<name>_bucket) keyed by the bucket's upper bound in seconds.
+// Like Prometheus histograms, the buckets allow estimating the latency percentiles.
+type ExpvarMetrics struct {
+	vars    *expvar.Map
+	bounds  []time.Duration
+	buckets map[Histogram]*expvar.Map
+	labels  map[string]*ExpvarMetrics
+	lock    sync.Mutex
+}
+
+// NewExpvarMetrics publishes a new map of metrics with the given name.
+// The histograms use the DefaultLatencyBuckets.
+// Like expvar.Publish it panics in case the name is already in use.
+func NewExpvarMetrics(name string) *ExpvarMetrics {
+	return newExpvarMetrics(expvar.NewMap(name), DefaultLatencyBuckets)
+}
+
+func newExpvarMetrics(vars *expvar.Map, bounds []time.Duration) *ExpvarMetrics {
+	return &ExpvarMetrics{
+		vars:    vars,
+		bounds:  bounds,
+		buckets: make(map[Histogram]*expvar.Map),
+		labels:  make(map[string]*ExpvarMetrics),
+	}
+}
+
+func (m *ExpvarMetrics) Inc(counter Counter) {
+	m.vars.Add(string(counter), 1)
+}
+
+func (m *ExpvarMetrics) Set(gauge Gauge, value float64) {
+	v := &expvar.Float{}
+	v.Set(value)
+	m.vars.Set(string(gauge), v)
+}
+
+func (m *ExpvarMetrics) Observe(histogram Histogram, d time.Duration) {
+	m.vars.Add(string(histogram)+"_count", 1)
+	m.vars.AddFloat(string(histogram)+"_sum", d.Seconds())
+
+	buckets := m.histogramBuckets(histogram)
+	for _, bound := range m.bounds {
+		if d <= bound {
+			buckets.Add(strconv.FormatFloat(bound.Seconds(), 'g', -1, 64), 1)
+		}
+	}
+
+	buckets.Add("+Inf", 1)
+}
+
+// histogramBuckets returns the published buckets of the given histogram.
+func (m *ExpvarMetrics) histogramBuckets(histogram Histogram) *expvar.Map {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	buckets, ok := m.buckets[histogram]
+	if !ok {
+		buckets = &expvar.Map{}
+		m.vars.Set(string(histogram)+"_bucket", buckets)
+		m.buckets[histogram] = buckets
+	}
+
+	return buckets
+}
+
+// WithLabel returns metrics published as a nested map named after the label.
+func (m *ExpvarMetrics) WithLabel(label string) Metrics {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	labeled, ok := m.labels[label]
+	if !ok {
+		labeled = newExpvarMetrics(&expvar.Map{}, m.bounds)
+		m.vars.Set(label, labeled.vars)
+		m.labels[label] = labeled
+	}
+
+	return labeled
+}
+
+// Vars returns the published map of metrics.
+func (m *ExpvarMetrics) Vars() *expvar.Map {
+	return m.vars
+}
+
+// inc increments the counter in case metrics are configured.
+func (p *Protocol) inc(counter Counter) {
+	if p.config.Metrics != nil {
+		p.config.Metrics.Inc(counter)
+	}
+}
+
+// observe records the latency in case metrics are configured.
+func (p *Protocol) observe(histogram Histogram, d time.Duration) {
+	if p.config.Metrics != nil {
+		p.config.Metrics.Observe(histogram, d)
+	}
+}
+
+// setSubscriptions updates the gauge of active readers in case metrics are configured.
+// The caller must hold the subscription lock.
+func (p *Protocol) setSubscriptions() {
+	if p.config.Metrics != nil {
+		p.config.Metrics.Set(GaugeSubscriptions, float64(len(p.subscriptions)))
+	}
+}
@@ -0,0 +1,152 @@
+package protocol
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/roosterfish/dcc-ex-go/command"
+	"github.com/roosterfish/dcc-ex-go/errs"
+)
+
+// OptionsReader is implemented by readers which can filter and buffer the commands sent to them (e.g. Protocol).
+// It's not part of Reader to keep existing implementations of the v1 interfaces working.
+type OptionsReader interface {
+	ReadWithOptions(options *ReadOptions) (CommandC, CleanupF)
+}
+
+// BatchWriter is implemented by writers which can write multiple commands at once (e.g. Protocol).
+type BatchWriter interface {
+	WriteBatch(batch *command.Batch) error
+}
+
+// ResponseWriter is implemented by writers which can write a command and wait for its response (e.g. Protocol).
+type ResponseWriter interface {
+	WriteAndReadOpCode(ctx context.Context, command *command.Command, opCode command.OpCode) (*command.Command, error)
+}
+
+// ReadWithOptions reads from the given reader using the options if it implements OptionsReader.
+// Other readers fall back to Read and only the op codes of the options are applied.
+func ReadWithOptions(reader Reader, options *ReadOptions) (CommandC, CleanupF) {
+	optionsReader, ok := reader.(OptionsReader)
+	if ok {
+		return optionsReader.ReadWithOptions(options)
+	}
+
+	commandC, cleanupF := reader.Read()
+	if len(options.OpCodes) == 0 {
+		return commandC, cleanupF
+	}
+
+	filteredC := make(CommandC)
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := sync.WaitGroup{}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		// Closing the channel signals the caller that the underlying reader is gone.
+		defer close(filteredC)
+
+		for {
+			select {
+			case cmd, ok := <-commandC:
+				if !ok {
+					return
+				}
+
+				if !slices.Contains(options.OpCodes, cmd.OpCode()) {
+					continue
+				}
+
+				select {
+				case filteredC <- cmd:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return filteredC, sync.OnceFunc(func() {
+		cancel()
+		wg.Wait()
+		cleanupF()
+	})
+}
+
+// ReadFiltered works like ReadWithOptions but only filters the commands by the given op codes.
+func ReadFiltered(reader Reader, opCodes ...command.OpCode) (CommandC, CleanupF) {
+	return ReadWithOptions(reader, &ReadOptions{
+		OpCodes: opCodes,
+	})
+}
+
+// ReadMatch waits until a command accepted by the matcher was observed on the given reader.
+func ReadMatch(ctx context.Context, reader Reader, matcher *command.Matcher) error {
+	commandC, cleanupF := ReadFiltered(reader, matcher.OpCode)
+	defer cleanupF()
+
+	for {
+		select {
+		case cmd, ok := <-commandC:
+			if !ok {
+				return ErrClosed
+			}
+
+			if matcher.Match(cmd) {
+				return nil
+			}
+		case <-ctx.Done():
+			return errs.Context(ctx.Err())
+		}
+	}
+}
+
+// WriteBatch writes the batch using the given writer if it implements BatchWriter.
+// Other writers fall back to writing the commands one after another.
+func WriteBatch(writer Writer, batch *command.Batch) error {
+	batchWriter, ok := writer.(BatchWriter)
+	if ok {
+		return batchWriter.WriteBatch(batch)
+	}
+
+	for _, cmd := range batch.Commands() {
+		err := writer.Write(cmd)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WriteAndReadOpCode writes the command using the given writer if it implements ResponseWriter.
+// Other writers fall back to waiting for the op code using ReadOpCode before writing the command.
+func WriteAndReadOpCode(ctx context.Context, readWriter ReadWriteCloser, cmd *command.Command, opCode command.OpCode) (*command.Command, error) {
+	responseWriter, ok := readWriter.(ResponseWriter)
+	if ok {
+		return responseWriter.WriteAndReadOpCode(ctx, cmd, opCode)
+	}
+
+	waiter := readWriter.ReadOpCode(ctx, opCode)
+
+	err := readWriter.Write(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	<-waiter.WaitC
+	if waiter.Command() == nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("failed waiting for response to %q: %w", cmd.String(), errs.Context(ctx.Err()))
+		}
+
+		return nil, ErrClosed
+	}
+
+	return waiter.Command(), nil
+}
@@ -0,0 +1,29 @@
+package protocol
+
+import (
+	"github.com/roosterfish/dcc-ex-go/command"
+)
+
+// Ownership defines which of the command station's responses a reader receives.
+// Responses are the commands whose op code is a known response but not a broadcast (see command.OpCode.IsResponse and
+// command.OpCode.IsBroadcast), e.g. <O>, <X> or <r cv value>.
+// As they are correlated with the command causing them, they belong to the writer awaiting them.
+type Ownership uint8
+
+const (
+	// OwnershipShared readers receive the responses only if there isn't an owner awaiting them.
+	// Broadcasts are always received.
+	OwnershipShared Ownership = iota
+	// OwnershipOwner readers own the responses while they are active. Write sessions use it to await the
+	// responses to their commands without concurrent readers observing them.
+	OwnershipOwner
+	// OwnershipObserver readers receive all of the commands including the owned responses (e.g. to monitor the traffic).
+	OwnershipObserver
+)
+
+// owned returns true if the command is a response owned by an active owner subscription.
+// Unknown op codes (e.g. of firmware forks) might be broadcasts, so they are never owned.
+// The caller must hold the subscription lock.
+func (p *Protocol) owned(cmd *command.Command) bool {
+	return p.owners > 0 && cmd.OpCode().IsResponse() && !cmd.OpCode().IsBroadcast()
+}
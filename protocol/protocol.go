@@ -1,14 +1,25 @@
 package protocol
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"maps"
+	"net"
+	"slices"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/roosterfish/dcc-ex-go/clock"
 	"github.com/roosterfish/dcc-ex-go/command"
+	"github.com/roosterfish/dcc-ex-go/errs"
+	"github.com/roosterfish/dcc-ex-go/record"
 	"golang.org/x/sys/unix"
 )
 
@@ -17,6 +28,10 @@ type ObservationsC chan Observation
 type CommandC chan *command.Command
 type CleanupF func()
 
+// InterceptorF intercepts a command and returns the command to continue with.
+// Returning a nil command drops the command.
+type InterceptorF func(cmd *command.Command) (*command.Command, error)
+
 type Waiter struct {
 	command *command.Command
 
@@ -25,21 +40,171 @@ type Waiter struct {
 
 type Config struct {
 	RequireSubscriber bool
+	// ReadBufferSize is the initial size of the buffer used to read from the underlying connection.
+	ReadBufferSize int
+	// MaxFrameSize is the maximum size of a single frame (<...>).
+	// Larger frames are discarded and reported using ParseErrorF.
+	MaxFrameSize int
+	// ParseErrorF is called for every malformed frame which gets discarded.
+	// It's called from within the listener and must not block.
+	// Use FrameErrors to receive the malformed frames on a channel instead.
+	ParseErrorF func(frame string, err error)
+	// ReadOnly refuses all writes with ErrReadOnly.
+	// Ingress commands are still distributed to all readers.
+	ReadOnly bool
+	// ReopenF is used to reopen the underlying connection in case reading from it fails.
+	// Existing subscriptions stay attached while the connection is reopened.
+	// If not set, the protocol stops listening once reading fails.
+	ReopenF func() (io.ReadWriteCloser, error)
+	// ReopenBackoff is the initial duration to wait before trying to reopen the connection.
+	// The duration is doubled after every failed attempt up to ReopenMaxBackoff.
+	ReopenBackoff    time.Duration
+	ReopenMaxBackoff time.Duration
+	// DisconnectF is called once reading from the underlying connection failed.
+	DisconnectF func(err error)
+	// ReopeningF is called before every attempt to reopen the underlying connection.
+	ReopeningF func(attempt int)
+	// ReconnectF is called once the underlying connection was reopened.
+	// The functions are called from within the listener and must not block.
+	ReconnectF func()
+	// OpCodeAliases maps op codes used by firmware forks to the op codes of DCC-EX.
+	// Ingress commands using an alias are parsed as the DCC-EX op code and egress commands using the
+	// DCC-EX op code are written using the alias.
+	// Only the leading op code of a command is remapped.
+	OpCodeAliases map[command.OpCode]command.OpCode
+	// WriteRate limits the number of commands written per second to protect the command station's input buffer.
+	// WriteBurst is the number of commands which can be written at once and defaults to 1.
+	// Rate limiting is disabled if not set.
+	WriteRate  float64
+	WriteBurst int
+	// WriteByteRate limits the number of bytes written per second.
+	// WriteByteBurst is the number of bytes which can be written at once and defaults to DefaultWriteByteBurst.
+	// Rate limiting is disabled if not set.
+	WriteByteRate  float64
+	WriteByteBurst int
+	// InboundInterceptors are called in order for every ingress command before it gets distributed to the readers.
+	// They are called from within the listener and must not block.
+	// Commands for which an interceptor fails are dropped and reported using ParseErrorF.
+	InboundInterceptors []InterceptorF
+	// OutboundInterceptors are called in order for every egress command before it gets written.
+	// Writing fails in case an interceptor fails.
+	// Dropping a command written by the channel abstractions makes them wait until their context is cancelled.
+	OutboundInterceptors []InterceptorF
+	// SubscriptionBuffer is the default number of commands buffered for every reader.
+	// Without buffer, a slow reader stalls the delivery to all of the other readers.
+	SubscriptionBuffer int
+	// Overflow is the default policy applied once the buffer of a reader is full.
+	Overflow OverflowPolicy
+	// Clock is the source of time used for backoffs and rate limiting.
+	// It defaults to the real time.
+	Clock clock.Clock
+	// Logger logs every written and received command at debug level and every malformed frame at warn level.
+	// Logging is disabled if not set.
+	Logger *slog.Logger
+	// Metrics receives counters, gauges and latencies of the protocol if set.
+	Metrics Metrics
+	// VerifyTimeout enables verifying every written command against the command station's response
+	// (see command.ResponseMatcher). Writes aren't delayed as the responses are awaited in the background.
+	// Commands whose response isn't observed within the timeout are reported using VerifyErrorF.
+	// Use WriteVerified to wait for the response instead.
+	// Verification is disabled if not set.
+	VerifyTimeout time.Duration
+	// VerifyErrorF is called for every written command which couldn't be verified.
+	// It's called from a separate goroutine per command.
+	VerifyErrorF func(err *VerifyError)
+}
+
+var (
+	// ErrReadOnly is returned when writing to a read-only protocol.
+	ErrReadOnly = errors.New("protocol is read-only")
+	// ErrClosed is returned by readers once the protocol got closed and their channel was closed.
+	// It matches errs.ErrClosed.
+	ErrClosed = errs.New("protocol is closed", errs.ErrClosed)
+	// ErrFrameTooLarge is reported for frames exceeding the maximum frame size.
+	// It matches errs.ErrMalformedCommand.
+	ErrFrameTooLarge = errs.New("frame exceeds maximum size", errs.ErrMalformedCommand)
+	// ErrFrameIncomplete is reported for frames which weren't terminated before the next frame started.
+	// It matches errs.ErrMalformedCommand.
+	ErrFrameIncomplete = errs.New("frame is incomplete", errs.ErrMalformedCommand)
+)
+
+const (
+	DefaultReadBufferSize   = 256
+	DefaultMaxFrameSize     = 1024
+	DefaultReopenBackoff    = 500 * time.Millisecond
+	DefaultReopenMaxBackoff = 30 * time.Second
+)
+
+// OverflowPolicy defines what happens with new commands once the buffer of a reader is full.
+type OverflowPolicy uint8
+
+const (
+	// OverflowBlock waits until the reader consumed a command.
+	// This stalls the delivery to all of the other readers.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest drops the oldest buffered command to make room for the new one.
+	OverflowDropOldest
+	// OverflowDropNewest drops the new command.
+	OverflowDropNewest
+)
+
+// ReadOptions configures a single reader.
+type ReadOptions struct {
+	// OpCodes filters the commands sent to the reader.
+	// All of the commands are sent if empty.
+	OpCodes []command.OpCode
+	// Buffer is the number of commands buffered for the reader.
+	// It defaults to Config.SubscriptionBuffer and is at least 1 if commands are dropped on overflow.
+	Buffer int
+	// Overflow is the policy applied once the buffer is full.
+	// It defaults to Config.Overflow.
+	Overflow *OverflowPolicy
+	// Ownership defines whether the reader receives the responses awaited by other readers.
+	// It defaults to OwnershipShared.
+	Ownership Ownership
 }
 
 type Subscription struct {
 	ingressC, egressC CommandC
 	cancelledC        chan bool
+	// opCodes filters the commands sent to the subscription.
+	// All of the commands are sent if empty.
+	opCodes   []command.OpCode
+	overflow  OverflowPolicy
+	ownership Ownership
+	// cleanup cancels the subscription.
+	// It's safe to be called multiple times.
+	cleanup CleanupF
 }
 
 type Protocol struct {
 	config           *Config
 	port             io.ReadWriteCloser
 	subscriptions    map[string]*Subscription
+	egressAliases    map[command.OpCode]command.OpCode
 	firstSubscriberF func()
 	listenerExitC    chan bool
+	closedC          chan bool
+	closed           bool
 	subscriptionLock sync.Mutex
 	writeLock        sync.Mutex
+	scheduler        writeScheduler
+	rateLimiter      *rateLimiter
+	// sequence tags every verified write.
+	sequence atomic.Uint64
+	// owners is the number of active owner subscriptions.
+	// It's guarded by the subscription lock.
+	owners int
+
+	frameErrorSubscribers map[FrameErrorC]struct{}
+	frameErrorLock        sync.Mutex
+
+	taps    map[*io.Writer]io.Writer
+	tapLock sync.Mutex
+
+	// subscriptionsClosed is set once the protocol got closed and all of the subscriptions were cancelled.
+	// It's guarded by the subscription lock.
+	subscriptionsClosed bool
 }
 
 type Reader interface {
@@ -78,6 +243,16 @@ func NewProtocol(port io.ReadWriteCloser, config *Config) *Protocol {
 			close(firstSubscriber)
 		}),
 		listenerExitC: make(chan bool),
+		closedC:       make(chan bool),
+		egressAliases: make(map[command.OpCode]command.OpCode),
+		rateLimiter:   newRateLimiter(config),
+
+		frameErrorSubscribers: make(map[FrameErrorC]struct{}),
+		taps:                  make(map[*io.Writer]io.Writer),
+	}
+
+	for alias, opCode := range config.OpCodeAliases {
+		protocol.egressAliases[opCode] = alias
 	}
 
 	go protocol.listen(firstSubscriber)
@@ -89,20 +264,48 @@ func (p *Protocol) listen(firstSubscriber chan bool) {
 	// The protocol's Close is waiting for the channel to be closed.
 	defer close(p.listenerExitC)
 
-	notifyF := func(stringCommand string) {
-		command, err := command.NewCommandFromString(stringCommand)
+	// The listener is the only user of the parser which allows reusing its buffers for every frame.
+	parser := command.NewParser()
+
+	notifyF := func(frame []byte) {
+		p.inc(CounterFramesReceived)
+
+		command, err := parser.Parse(frame)
+		if err != nil {
+			p.parseError(bytes.Trim(frame, "<>"), err)
+			return
+		}
+
+		opCode, ok := p.config.OpCodeAliases[command.OpCode()]
+		if ok {
+			command = remap(command, opCode)
+		}
+
+		command, err = intercept(command, p.config.InboundInterceptors)
 		if err != nil {
-			// TODO: Log as it means we are dropping ingress commands
+			p.parseError(bytes.Trim(frame, "<>"), fmt.Errorf("inbound interceptor failed: %w", err))
+			return
+		}
+
+		if command == nil {
 			return
 		}
 
+		p.logCommand("received command", command)
+
 		p.subscriptionLock.Lock()
+		owned := p.owned(command)
 		for _, subscription := range p.subscriptions {
-			select {
-			case subscription.ingressC <- command:
-				// Try writing the command to the subscriptions ingress channel.
-			case <-subscription.cancelledC:
-				// In case the subscription was cancelled, don't block trying to write.
+			if len(subscription.opCodes) > 0 && !slices.Contains(subscription.opCodes, command.OpCode()) {
+				continue
+			}
+
+			if owned && subscription.ownership == OwnershipShared {
+				continue
+			}
+
+			if !subscription.deliver(command) {
+				p.inc(CounterFramesDropped)
 			}
 		}
 
@@ -116,46 +319,272 @@ func (p *Protocol) listen(firstSubscriber chan bool) {
 		<-firstSubscriber
 	}
 
-	commandRunes := []rune{}
-	commandReading := false
-
 	for {
-		// Always create a new buffer for every read.
-		// This ensures there aren't any leftover traces from the previous read.
-		buf := make([]byte, 100)
+		// The scanner is bound to the current connection.
+		// In case the connection gets reopened, a new scanner is created and any partially read frame is dropped.
+		scanner := bufio.NewScanner(&tapReader{protocol: p, port: p.port})
+		scanner.Buffer(make([]byte, 0, p.readBufferSize()), 2*p.maxFrameSize()+2)
+		scanner.Split(p.splitFrames)
 
-		n, err := p.port.Read(buf)
-		if err != nil {
+		for scanner.Scan() {
+			notifyF(scanner.Bytes())
+		}
+
+		// The scanner doesn't return io.EOF.
+		err := scanner.Err()
+		if err == nil {
+			err = io.EOF
+		}
+
+		if !p.reopen(err) {
 			return
 		}
+	}
+}
 
-		for _, receivedByte := range buf[:n] {
-			// The parsing of the commands is implemented according to
-			// https://dcc-ex.com/reference/developers/api.html#appendix-b-suggested-parameter-parsing-sequence.
-			receivedRune := rune(receivedByte)
-			if receivedRune == '<' {
-				commandReading = true
-				continue
-			}
+func (p *Protocol) readBufferSize() int {
+	if p.config.ReadBufferSize <= 0 {
+		return DefaultReadBufferSize
+	}
 
-			if receivedRune == '>' {
-				notifyF(string(commandRunes))
+	return p.config.ReadBufferSize
+}
 
-				commandReading = false
-				commandRunes = []rune{}
-				continue
+func (p *Protocol) maxFrameSize() int {
+	if p.config.MaxFrameSize <= 0 {
+		return DefaultMaxFrameSize
+	}
+
+	return p.config.MaxFrameSize
+}
+
+// parseError reports the given malformed frame.
+func (p *Protocol) parseError(frame []byte, err error) {
+	p.inc(CounterParseErrors)
+
+	if p.config.Logger != nil {
+		p.config.Logger.Warn("discarded malformed frame", slog.String("frame", string(frame)), slog.Any("error", err))
+	}
+
+	if p.config.ParseErrorF != nil {
+		p.config.ParseErrorF(string(frame), err)
+	}
+
+	p.emitFrameError(&FrameError{
+		Frame: string(frame),
+		Err:   err,
+	})
+}
+
+// frameEnd returns the index of the > terminating the frame at the beginning of data.
+// Quoted text (e.g. of LCD messages) can contain both < and > as well as escaped quotes.
+// In case another frame is started before the frame got terminated, its index is returned as restart.
+// Both indexes are -1 if not found.
+func frameEnd(data []byte) (end int, restart int) {
+	quoted := false
+
+	for i := 1; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			// Skip the escaped character.
+			if quoted {
+				i++
+			}
+		case '"':
+			quoted = !quoted
+		case '<':
+			if !quoted {
+				return -1, i
+			}
+		case '>':
+			if !quoted {
+				return i, -1
 			}
+		}
+	}
 
-			// Filter out newlines.
-			if receivedRune == '\n' {
-				continue
+	return -1, -1
+}
+
+// splitFrames is a bufio.SplitFunc returning every frame delimited by < and > including its delimiters.
+// The parsing of the frames is implemented according to
+// https://dcc-ex.com/reference/developers/api.html#appendix-b-suggested-parameter-parsing-sequence.
+// Anything outside of a frame gets discarded.
+func (p *Protocol) splitFrames(data []byte, atEOF bool) (int, []byte, error) {
+	start := bytes.IndexByte(data, '<')
+	if start == -1 {
+		// Discard anything outside of a frame.
+		return len(data), nil, nil
+	}
+
+	end, restart := frameEnd(data[start:])
+	if restart != -1 {
+		// A new frame was started before the previous one got terminated.
+		p.parseError(data[start+1:start+restart], ErrFrameIncomplete)
+		return start + restart, nil, nil
+	}
+
+	if end == -1 {
+		if len(data)-start > p.maxFrameSize() {
+			p.parseError(data[start:], ErrFrameTooLarge)
+			return len(data), nil, nil
+		}
+
+		if atEOF {
+			return len(data), nil, nil
+		}
+
+		// Discard anything before the frame and request more data.
+		return start, nil, nil
+	}
+
+	frame := data[start+1 : start+end]
+	if len(frame) > p.maxFrameSize() {
+		p.parseError(frame, ErrFrameTooLarge)
+		return start + end + 1, nil, nil
+	}
+
+	// Keep the delimiters which preserves the frame as it was received.
+	frame = data[start : start+end+1]
+
+	// Filter out newlines.
+	if bytes.ContainsAny(frame, "\r\n") {
+		frame = bytes.ReplaceAll(frame, []byte{'\n'}, nil)
+		frame = bytes.ReplaceAll(frame, []byte{'\r'}, nil)
+	}
+
+	return start + end + 1, frame, nil
+}
+
+// reopen tries to reopen the underlying connection with exponential backoff after reading from it failed.
+// It returns false in case the connection cannot be reopened or the protocol got closed in the meantime.
+func (p *Protocol) reopen(readErr error) bool {
+	// The protocol got closed, don't try to reopen.
+	select {
+	case <-p.closedC:
+		return false
+	default:
+	}
+
+	if p.config.DisconnectF != nil {
+		p.config.DisconnectF(readErr)
+	}
+
+	if p.config.ReopenF == nil {
+		return false
+	}
+
+	backoff := p.config.ReopenBackoff
+	if backoff <= 0 {
+		backoff = DefaultReopenBackoff
+	}
+
+	maxBackoff := p.config.ReopenMaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultReopenMaxBackoff
+	}
+
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-p.clock().After(backoff):
+		case <-p.closedC:
+			return false
+		}
+
+		if p.config.ReopeningF != nil {
+			p.config.ReopeningF(attempt)
+		}
+
+		port, err := p.config.ReopenF()
+		if err != nil {
+			backoff = min(backoff*2, maxBackoff)
+			continue
+		}
+
+		// Swap the connection while holding the write lock.
+		// This ensures there isn't any concurrent write on the old connection.
+		p.writeLock.Lock()
+		if p.closed {
+			p.writeLock.Unlock()
+			_ = port.Close()
+			return false
+		}
+
+		_ = p.port.Close()
+		p.port = port
+		p.writeLock.Unlock()
+
+		if p.config.ReconnectF != nil {
+			p.config.ReconnectF()
+		}
+
+		return true
+	}
+}
+
+// clock returns the configured source of time.
+func (p *Protocol) clock() clock.Clock {
+	return clock.Or(p.config.Clock)
+}
+
+// intercept runs the command through the given interceptors.
+// It returns a nil command in case one of the interceptors dropped it.
+func intercept(cmd *command.Command, interceptors []InterceptorF) (*command.Command, error) {
+	var err error
+	for _, interceptor := range interceptors {
+		cmd, err = interceptor(cmd)
+		if err != nil || cmd == nil {
+			return nil, err
+		}
+	}
+
+	return cmd, nil
+}
+
+// remap returns a copy of the given command using the given op code.
+func remap(cmd *command.Command, opCode command.OpCode) *command.Command {
+	return cmd.WithOpCode(opCode)
+}
+
+// deliver writes the command to the subscription's ingress channel honoring its overflow policy.
+// It returns false in case a command got dropped.
+// The caller must hold the subscription lock.
+func (s *Subscription) deliver(cmd *command.Command) bool {
+	switch s.overflow {
+	case OverflowDropNewest:
+		select {
+		case s.ingressC <- cmd:
+			return true
+		default:
+			return false
+		}
+	case OverflowDropOldest:
+		delivered := true
+		for {
+			select {
+			case s.ingressC <- cmd:
+				return delivered
+			default:
 			}
 
-			if commandReading {
-				commandRunes = append(commandRunes, receivedRune)
+			// Make room by dropping the oldest command.
+			// The reader might have consumed it in the meantime which is fine too.
+			select {
+			case <-s.ingressC:
+				delivered = false
+			default:
 			}
 		}
 	}
+
+	select {
+	case s.ingressC <- cmd:
+		// Try writing the command to the subscriptions ingress channel.
+	case <-s.cancelledC:
+		// In case the subscription was cancelled, don't block trying to write.
+	}
+
+	return true
 }
 
 // Read returns a channel on which every ingress command from the underlying connections gets send to.
@@ -164,24 +593,50 @@ func (p *Protocol) listen(firstSubscriber chan bool) {
 // command and calling cleanup as this might block every other caller of Read.
 // New commands are sent to all readers one after another.
 func (p *Protocol) Read() (CommandC, CleanupF) {
+	return p.ReadFiltered()
+}
+
+// ReadFiltered works like Read but only sends commands with one of the given op codes to the returned channel.
+// Filtering happens within the listener, so readers only interested in a few op codes (e.g. sensor watchers)
+// don't have to consume every single command.
+// All of the commands are sent if no op code is given.
+func (p *Protocol) ReadFiltered(opCodes ...command.OpCode) (CommandC, CleanupF) {
+	return p.ReadWithOptions(&ReadOptions{
+		OpCodes: opCodes,
+	})
+}
+
+// ReadWithOptions works like Read but allows filtering, buffering and setting the overflow policy
+// of the returned channel.
+func (p *Protocol) ReadWithOptions(options *ReadOptions) (CommandC, CleanupF) {
+	buffer := options.Buffer
+	if buffer <= 0 {
+		buffer = p.config.SubscriptionBuffer
+	}
+
+	overflow := p.config.Overflow
+	if options.Overflow != nil {
+		overflow = *options.Overflow
+	}
+
+	// Dropping commands requires a buffer to drop them from.
+	if overflow != OverflowBlock {
+		buffer = max(buffer, 1)
+	}
+
 	// In order to easily identify the caller in the subscription map create an UUID.
 	uuid := uuid.NewString()
 
-	p.subscriptionLock.Lock()
-
-	// Create the caller's subscription channel and insert it into the map.
+	// Create the caller's subscription channel.
 	subscription := &Subscription{
 		egressC:    make(CommandC),
-		ingressC:   make(CommandC),
+		ingressC:   make(CommandC, buffer),
 		cancelledC: make(chan bool),
+		opCodes:    options.OpCodes,
+		overflow:   overflow,
+		ownership:  options.Ownership,
 	}
 
-	p.subscriptions[uuid] = subscription
-	p.subscriptionLock.Unlock()
-
-	// Unlock the listener as at least one subscriber is active.
-	p.firstSubscriberF()
-
 	// Create a new context to allow cancellation of the routine.
 	ctx, cancel := context.WithCancel(context.Background())
 	wg := sync.WaitGroup{}
@@ -208,7 +663,7 @@ func (p *Protocol) Read() (CommandC, CleanupF) {
 
 	// The cleanup function is returned to the caller and ensures the
 	// routine has returned, the channels are closed and the subscription is removed.
-	cleanup := func() {
+	subscription.cleanup = sync.OnceFunc(func() {
 		// Cancels the routine.
 		cancel()
 		wg.Wait()
@@ -225,28 +680,82 @@ func (p *Protocol) Read() (CommandC, CleanupF) {
 		// Obtain the lock and cleanup the subscription.
 		p.subscriptionLock.Lock()
 		close(subscription.ingressC)
+		_, subscribed := p.subscriptions[uuid]
+		if subscribed && subscription.ownership == OwnershipOwner {
+			p.owners--
+		}
+
 		delete(p.subscriptions, uuid)
+		p.setSubscriptions()
 		p.subscriptionLock.Unlock()
+	})
+
+	// Insert the subscription into the map.
+	p.subscriptionLock.Lock()
+	if p.subscriptionsClosed {
+		// The protocol is already closed, hand out a closed channel.
+		p.subscriptionLock.Unlock()
+		subscription.cleanup()
+
+		return subscription.egressC, subscription.cleanup
+	}
+
+	p.subscriptions[uuid] = subscription
+	if subscription.ownership == OwnershipOwner {
+		p.owners++
 	}
 
-	return subscription.egressC, cleanup
+	p.setSubscriptions()
+	p.subscriptionLock.Unlock()
+
+	// Unlock the listener as at least one subscriber is active.
+	p.firstSubscriberF()
+
+	return subscription.egressC, subscription.cleanup
 }
 
 // ReadCommand waits until the given command was observed on the underlying connection and returns afterwards.
 func (p *Protocol) ReadCommand(ctx context.Context, command *command.Command) error {
-	commandC, cleanupF := p.Read()
+	commandC, cleanupF := p.ReadFiltered(command.OpCode())
 	defer cleanupF()
 
 	commandStr := command.String()
 
 	for {
 		select {
-		case cmd := <-commandC:
-			if cmd.String() == commandStr {
+		case cmd, ok := <-commandC:
+			if !ok {
+				return ErrClosed
+			}
+
+			// Compare the raw frame as well as its representation might differ (e.g. <jT> and <j T>).
+			if cmd.String() == commandStr || cmd.Raw() == commandStr {
+				return nil
+			}
+		case <-ctx.Done():
+			return errs.Context(ctx.Err())
+		}
+	}
+}
+
+// ReadMatch works like ReadCommand but waits until a command accepted by the matcher was observed.
+// Unlike ReadCommand it's not affected by additional parameters appended by DCC-EX.
+func (p *Protocol) ReadMatch(ctx context.Context, matcher *command.Matcher) error {
+	commandC, cleanupF := p.ReadFiltered(matcher.OpCode)
+	defer cleanupF()
+
+	for {
+		select {
+		case cmd, ok := <-commandC:
+			if !ok {
+				return ErrClosed
+			}
+
+			if matcher.Match(cmd) {
 				return nil
 			}
 		case <-ctx.Done():
-			return ctx.Err()
+			return errs.Context(ctx.Err())
 		}
 	}
 }
@@ -254,7 +763,25 @@ func (p *Protocol) ReadCommand(ctx context.Context, command *command.Command) er
 // ReadOpCode returns a channel which gets closed once the provided op code was observed.
 // Once the channel is returned, it is ensured there is an activer reader.
 func (p *Protocol) ReadOpCode(ctx context.Context, opCode command.OpCode) *Waiter {
-	commandC, cleanupF := p.Read()
+	return p.readOpCode(ctx, opCode, func(cmd *command.Command) bool {
+		return cmd.OpCode() == opCode
+	})
+}
+
+// ReadOpCodeMatch works like ReadOpCode but waits for a command accepted by the matcher (e.g. any <H 5 *> response).
+func (p *Protocol) ReadOpCodeMatch(ctx context.Context, matcher *command.Matcher) *Waiter {
+	return p.readOpCode(ctx, matcher.OpCode, matcher.Match)
+}
+
+// ReadMultiOpCode works like ReadOpCode but waits for a multi rune op code (e.g. the <jT> response).
+func (p *Protocol) ReadMultiOpCode(ctx context.Context, opCode command.MultiOpCode) *Waiter {
+	return p.readOpCode(ctx, opCode.OpCode(), func(cmd *command.Command) bool {
+		return cmd.MultiOpCode() == opCode
+	})
+}
+
+func (p *Protocol) readOpCode(ctx context.Context, opCode command.OpCode, matchF func(cmd *command.Command) bool) *Waiter {
+	commandC, cleanupF := p.ReadFiltered(opCode)
 
 	// Once the op code is observed, the channel gets closed.
 	waiter := &Waiter{
@@ -269,8 +796,12 @@ func (p *Protocol) ReadOpCode(ctx context.Context, opCode command.OpCode) *Waite
 
 		for {
 			select {
-			case cmd := <-commandC:
-				if cmd.OpCode() == opCode {
+			case cmd, ok := <-commandC:
+				if !ok {
+					return
+				}
+
+				if matchF(cmd) {
 					// Make the actual command available in the waiter.
 					waiter.command = cmd
 					return
@@ -284,31 +815,211 @@ func (p *Protocol) ReadOpCode(ctx context.Context, opCode command.OpCode) *Waite
 	return waiter
 }
 
+// WriteAndReadOpCode writes the given command and returns the first response with the given op code.
+// The reader is subscribed before writing, so the response cannot be missed even if it arrives immediately.
+// Unlike the channel abstractions it doesn't gate the end of the response, so use it within a session for
+// commands which are answered by a single response.
+func (p *Protocol) WriteAndReadOpCode(ctx context.Context, command *command.Command, opCode command.OpCode) (*command.Command, error) {
+	return p.writeAndReadOpCode(ctx, command, opCode, nil)
+}
+
+// WriteAndReadMultiOpCode works like WriteAndReadOpCode but returns the first response with the given
+// multi rune op code (e.g. <jT> for <JT>).
+func (p *Protocol) WriteAndReadMultiOpCode(ctx context.Context, cmd *command.Command, opCode command.MultiOpCode) (*command.Command, error) {
+	return p.writeAndReadOpCode(ctx, cmd, opCode.OpCode(), func(response *command.Command) bool {
+		return response.MultiOpCode() == opCode
+	})
+}
+
+// writeAndReadOpCode returns the first response with the given op code accepted by matchF.
+// Every response with the op code is accepted if matchF is nil.
+func (p *Protocol) writeAndReadOpCode(ctx context.Context, cmd *command.Command, opCode command.OpCode, matchF func(cmd *command.Command) bool) (*command.Command, error) {
+	commandC, cleanupF := p.ReadWithOptions(&ReadOptions{
+		OpCodes:   []command.OpCode{opCode},
+		Ownership: OwnershipOwner,
+	})
+	defer cleanupF()
+
+	start := p.clock().Now()
+
+	err := p.Write(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		select {
+		case response, ok := <-commandC:
+			if !ok {
+				return nil, ErrClosed
+			}
+
+			if matchF != nil && !matchF(response) {
+				continue
+			}
+
+			p.observe(HistogramResponseLatency, p.clock().Since(start))
+			return response, nil
+		case <-ctx.Done():
+			return nil, fmt.Errorf("failed waiting for response to %q: %w", cmd.String(), errs.Context(ctx.Err()))
+		}
+	}
+}
+
+// writeError converts the error of a failed write of the given subject.
+func (p *Protocol) writeError(err error, subject string) error {
+	p.inc(CounterWriteErrors)
+
+	if errors.Is(err, unix.EBADF) {
+		return fmt.Errorf("serial port is %w", errs.ErrClosed)
+	} else if errors.Is(err, net.ErrClosed) {
+		return fmt.Errorf("network connection is %w", errs.ErrClosed)
+	}
+
+	return fmt.Errorf("failed to write %s: %w", subject, err)
+}
+
 // Write writes a new command onto the protocol's underlying connection.
 // Writes aquire a lock as the method might be exposed to the user when using the console without channel sessions.
+// Concurrent writes are ordered by the command's priority (see PriorityOf), so emergency stops jump ahead
+// of queued commands.
 func (p *Protocol) Write(command *command.Command) error {
+	return p.WritePriority(command, PriorityOf(command))
+}
+
+// WritePriority writes a new command onto the protocol's underlying connection using the given priority.
+// Use PriorityLow for commands which are sent frequently (e.g. throttle updates from UIs).
+func (p *Protocol) WritePriority(command *command.Command, priority Priority) error {
+	if p.config.VerifyTimeout <= 0 {
+		return p.write(command, priority)
+	}
+
+	expectation := p.expect(command)
+	if expectation == nil {
+		return p.write(command, priority)
+	}
+
+	err := p.write(command, priority)
+	if err != nil {
+		expectation.cleanupF()
+		return err
+	}
+
+	go func() {
+		err := expectation.wait(context.Background(), p.config.VerifyTimeout)
+		if err != nil {
+			p.verifyFailed(err)
+		}
+	}()
+
+	return nil
+}
+
+func (p *Protocol) write(command *command.Command, priority Priority) error {
+	if p.config.ReadOnly {
+		return ErrReadOnly
+	}
+
+	// Reject invalid commands before the bytes hit the wire instead of waiting for the <X> response.
+	err := command.Validate()
+	if err != nil {
+		return err
+	}
+
+	start := p.clock().Now()
+
+	command, err = intercept(command, p.config.OutboundInterceptors)
+	if err != nil {
+		return fmt.Errorf("outbound interceptor failed: %w", err)
+	}
+
+	if command == nil {
+		return nil
+	}
+
+	p.scheduler.acquire(priority)
+	defer p.scheduler.release()
+
+	opCode, ok := p.egressAliases[command.OpCode()]
+	if ok {
+		command = remap(command, opCode)
+	}
+
+	// Emergency stops are never delayed but still account for the rate.
+	wait := p.rateLimiter.reserve(len(command.Bytes()))
+	if wait > 0 && priority != PriorityEmergency {
+		p.clock().Sleep(wait)
+	}
+
 	p.writeLock.Lock()
 	defer p.writeLock.Unlock()
 
-	_, err := p.port.Write(command.Bytes())
+	_, err = p.port.Write(command.Bytes())
 	if err != nil {
-		if errors.Is(err, unix.EBADF) {
-			return fmt.Errorf("serial port is closed")
-		} else {
-			return fmt.Errorf("failed to write command %q: %w", command.String(), err)
-		}
+		return p.writeError(err, fmt.Sprintf("command %q", command.String()))
 	}
 
+	p.tap(record.DirectionWrite, command.Bytes())
+
+	// The duration includes waiting for the scheduler and rate limiter.
+	duration := p.clock().Since(start)
+	p.inc(CounterWrites)
+	p.observe(HistogramWriteLatency, duration)
+	p.logCommand("wrote command", command, slog.Duration("duration", duration))
+
 	return nil
 }
 
 // Close closes the underlying connection.
+// See CloseContext for details.
 func (p *Protocol) Close() error {
+	return p.CloseContext(context.Background())
+}
+
+// CloseContext closes the underlying connection and cancels all of the subscriptions.
+// The channels of all readers are closed, so readers waiting for commands are released.
+// It returns once the listener and all of the routines forwarding commands to the readers have exited
+// or the context is cancelled.
+func (p *Protocol) CloseContext(ctx context.Context) error {
+	p.writeLock.Lock()
+	if p.closed {
+		p.writeLock.Unlock()
+		return errors.New("protocol is already closed")
+	}
+
+	p.closed = true
+	close(p.closedC)
 	err := p.port.Close()
+	p.writeLock.Unlock()
 	if err != nil {
-		return fmt.Errorf("failed to close serial port: %w", err)
+		err = fmt.Errorf("failed to close serial port: %w", err)
 	}
 
-	<-p.listenerExitC
-	return nil
+	doneC := make(chan struct{})
+	go func() {
+		defer close(doneC)
+
+		// Prevent new subscriptions and cancel the existing ones.
+		// This also unblocks the listener in case it's waiting for a reader.
+		p.subscriptionLock.Lock()
+		p.subscriptionsClosed = true
+		subscriptions := slices.Collect(maps.Values(p.subscriptions))
+		p.subscriptionLock.Unlock()
+
+		for _, subscription := range subscriptions {
+			subscription.cleanup()
+		}
+
+		// Unblock the listener in case it's still waiting for the first subscriber.
+		p.firstSubscriberF()
+
+		<-p.listenerExitC
+	}()
+
+	select {
+	case <-doneC:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("failed waiting for the protocol to shut down: %w", errs.Context(ctx.Err()))
+	}
 }
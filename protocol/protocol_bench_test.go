@@ -0,0 +1,77 @@
+package protocol_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/command"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+	"github.com/roosterfish/dcc-ex-go/sensor"
+	"github.com/roosterfish/dcc-ex-go/simulator"
+)
+
+// BenchmarkBroadcast measures the fan-out of broadcasts to a varying number of readers.
+func BenchmarkBroadcast(b *testing.B) {
+	for _, subscribers := range []int{1, 8, 64} {
+		b.Run(fmt.Sprintf("subscribers=%d", subscribers), func(b *testing.B) {
+			sim := simulator.NewSimulator()
+			p := protocol.NewProtocol(sim, &protocol.Config{SubscriptionBuffer: 64})
+			defer p.Close()
+
+			wg := sync.WaitGroup{}
+			cleanups := make([]protocol.CleanupF, 0, subscribers)
+
+			for range subscribers {
+				commandC, cleanupF := p.ReadFiltered(sensor.StateActive.OpCode())
+				cleanups = append(cleanups, cleanupF)
+
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+
+					for range b.N {
+						<-commandC
+					}
+				}()
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := range b.N {
+				sim.SetSensorState(sensor.ID(i%1024), sensor.StateActive)
+			}
+
+			wg.Wait()
+			b.StopTimer()
+
+			for _, cleanupF := range cleanups {
+				cleanupF()
+			}
+		})
+	}
+}
+
+// BenchmarkWriteAndReadOpCode measures the round trip of a command and its response.
+func BenchmarkWriteAndReadOpCode(b *testing.B) {
+	p := protocol.NewProtocol(simulator.NewSimulator(), &protocol.Config{})
+	defer p.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	statusCommand := command.NewCommand(command.OpCodeStatus, "")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for range b.N {
+		_, err := p.WriteAndReadOpCode(ctx, statusCommand, command.OpCodeStatusResponse)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,873 @@
+package protocol_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/command"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+	"github.com/roosterfish/dcc-ex-go/sensor"
+	"github.com/roosterfish/dcc-ex-go/simulator"
+)
+
+func TestReopen(t *testing.T) {
+	sim := simulator.NewSimulator()
+	reconnectedC := make(chan struct{})
+
+	p := protocol.NewProtocol(sim, &protocol.Config{
+		RequireSubscriber: true,
+		ReopenF: func() (io.ReadWriteCloser, error) {
+			return simulator.NewSimulator(), nil
+		},
+		ReopenBackoff: time.Millisecond,
+		ReconnectF: func() {
+			close(reconnectedC)
+		},
+	})
+	defer p.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	commandC, cleanupF := p.Read()
+	defer cleanupF()
+
+	readyCommand := command.NewCommand(command.OpCodeInfo, "%d %d %q", 0, 3, "Ready").String()
+	waitReadyF := func() {
+		for {
+			select {
+			case cmd := <-commandC:
+				if cmd.String() == readyCommand {
+					return
+				}
+			case <-ctx.Done():
+				t.Fatal(ctx.Err())
+			}
+		}
+	}
+
+	waitReadyF()
+
+	// Unplug the command station.
+	_ = sim.Close()
+
+	select {
+	case <-reconnectedC:
+	case <-ctx.Done():
+		t.Fatal(ctx.Err())
+	}
+
+	// The existing subscription observes the new station becoming ready.
+	waitReadyF()
+
+	err := p.Write(command.NewCommand(command.OpCodeStatus, ""))
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	p := protocol.NewProtocol(simulator.NewSimulator(), &protocol.Config{
+		RequireSubscriber: true,
+		ReadOnly:          true,
+	})
+	defer p.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	readyWaiter := p.ReadOpCode(ctx, command.OpCodeInfo)
+
+	err := p.Write(command.NewCommand(command.OpCodeStatus, ""))
+	if !errors.Is(err, protocol.ErrReadOnly) {
+		t.Errorf("Expected %v but got %v", protocol.ErrReadOnly, err)
+	}
+
+	<-readyWaiter.WaitC
+	if readyWaiter.Command() == nil {
+		t.Error("Expected the ready broadcast to be distributed")
+	}
+}
+
+func TestOpCodeAliases(t *testing.T) {
+	station, port := net.Pipe()
+	defer station.Close()
+
+	// The firmware fork uses <y> and <u> instead of <Z> and <Y>.
+	p := protocol.NewProtocol(port, &protocol.Config{
+		OpCodeAliases: map[command.OpCode]command.OpCode{
+			'y': command.OpCodeOutput,
+			'u': command.OpCodeOutputResponse,
+		},
+	})
+	defer p.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	go func() {
+		_ = p.Write(command.NewCommand(command.OpCodeOutput, "%d %d", 1, 1))
+	}()
+
+	buffer := make([]byte, 16)
+	n, err := station.Read(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(buffer[:n]) != "<y 1 1>\n" {
+		t.Errorf("Expected the alias to be written but got %q", buffer[:n])
+	}
+
+	waiter := p.ReadOpCode(ctx, command.OpCodeOutputResponse)
+
+	_, err = station.Write([]byte("<u 1 1>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	<-waiter.WaitC
+	if waiter.Command() == nil || waiter.Command().String() != "<Y 1 1>" {
+		t.Errorf("Expected the alias to be parsed as %q but got %v", command.OpCodeOutputResponse, waiter.Command())
+	}
+}
+
+func TestWriteRate(t *testing.T) {
+	p := protocol.NewProtocol(simulator.NewSimulator(), &protocol.Config{
+		WriteRate: 20,
+	})
+	defer p.Close()
+
+	start := time.Now()
+	for range 3 {
+		err := p.Write(command.NewCommand(command.OpCodeStatus, ""))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The first command is written immediately.
+	elapsed := time.Since(start)
+	if elapsed < 90*time.Millisecond {
+		t.Errorf("Expected writes to be rate limited but they took %v", elapsed)
+	}
+
+	// Emergency stops aren't delayed.
+	start = time.Now()
+	err := p.Write(command.NewCommand(command.OpCodeEmergencyStop, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if time.Since(start) > 25*time.Millisecond {
+		t.Errorf("Expected emergency stop not to be delayed but it took %v", time.Since(start))
+	}
+}
+
+func TestInterceptors(t *testing.T) {
+	station, port := net.Pipe()
+	defer station.Close()
+
+	p := protocol.NewProtocol(port, &protocol.Config{
+		InboundInterceptors: []protocol.InterceptorF{
+			func(cmd *command.Command) (*command.Command, error) {
+				// Filter the power broadcasts.
+				if cmd.OpCode() == command.OpCodePower {
+					return nil, nil
+				}
+
+				return cmd, nil
+			},
+		},
+		OutboundInterceptors: []protocol.InterceptorF{
+			func(cmd *command.Command) (*command.Command, error) {
+				if cmd.OpCode() == command.OpCodeEmergencyStop {
+					return nil, errors.New("emergency stop is not allowed")
+				}
+
+				return cmd, nil
+			},
+			func(cmd *command.Command) (*command.Command, error) {
+				// Always request the status instead.
+				return command.NewCommand(command.OpCodeStatus, ""), nil
+			},
+		},
+	})
+	defer p.Close()
+
+	err := p.Write(command.NewCommand(command.OpCodeEmergencyStop, ""))
+	if err == nil {
+		t.Error("Expected the outbound interceptor to fail the write")
+	}
+
+	go func() {
+		_ = p.Write(command.NewCommand(command.OpCodeCabSpeed, "%d", 3))
+	}()
+
+	buffer := make([]byte, 16)
+	n, err := station.Read(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(buffer[:n]) != "<s>\n" {
+		t.Errorf("Expected the rewritten command to be written but got %q", buffer[:n])
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	commandC, cleanupF := p.Read()
+	defer cleanupF()
+
+	_, err = station.Write([]byte("<p1><O>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case cmd := <-commandC:
+		if cmd.OpCode() != command.OpCodeSuccess {
+			t.Errorf("Expected the power broadcast to be filtered but got %q", cmd)
+		}
+	case <-ctx.Done():
+		t.Fatal(ctx.Err())
+	}
+}
+
+func TestReadFiltered(t *testing.T) {
+	station, port := net.Pipe()
+	defer station.Close()
+
+	p := protocol.NewProtocol(port, &protocol.Config{})
+	defer p.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	commandC, cleanupF := p.ReadFiltered(command.OpCode('Q'), command.OpCode('q'))
+	defer cleanupF()
+
+	go func() {
+		_, _ = station.Write([]byte("<p1><H 1 1><q 5><l 3 0 128 0><Q 5>"))
+	}()
+
+	for _, expected := range []string{"<q 5>", "<Q 5>"} {
+		select {
+		case cmd := <-commandC:
+			if cmd.String() != expected {
+				t.Errorf("Expected %q but got %q", expected, cmd)
+			}
+		case <-ctx.Done():
+			t.Fatal(ctx.Err())
+		}
+	}
+}
+
+func TestReadOwnership(t *testing.T) {
+	station, port := net.Pipe()
+	defer station.Close()
+
+	p := protocol.NewProtocol(port, &protocol.Config{
+		SubscriptionBuffer: 8,
+	})
+	defer p.Close()
+
+	sharedC, sharedCleanupF := p.Read()
+	defer sharedCleanupF()
+
+	observerC, observerCleanupF := p.ReadWithOptions(&protocol.ReadOptions{
+		Ownership: protocol.OwnershipObserver,
+	})
+	defer observerCleanupF()
+
+	ownerC, ownerCleanupF := p.ReadWithOptions(&protocol.ReadOptions{
+		Ownership: protocol.OwnershipOwner,
+	})
+
+	// Responses are owned whereas broadcasts are sent to all of the readers.
+	_, err := station.Write([]byte("<O><H 1 1><r 29 6>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	receivedF := func(commandC protocol.CommandC, n int) []string {
+		commands := []string{}
+		for len(commands) < n {
+			select {
+			case cmd := <-commandC:
+				commands = append(commands, cmd.String())
+			case <-time.After(time.Second):
+				return commands
+			}
+		}
+
+		return commands
+	}
+
+	all := []string{"<O>", "<H 1 1>", "<r 29 6>"}
+	for name, commandC := range map[string]protocol.CommandC{"owner": ownerC, "observer": observerC} {
+		received := receivedF(commandC, len(all))
+		if !slices.Equal(received, all) {
+			t.Errorf("Expected the %s to receive %v but got %v", name, all, received)
+		}
+	}
+
+	// Once the owner is gone, responses are shared again.
+	ownerCleanupF()
+
+	_, err = station.Write([]byte("<X>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"<H 1 1>", "<X>"}
+	received := receivedF(sharedC, len(expected))
+	if !slices.Equal(received, expected) {
+		t.Errorf("Expected the shared reader to receive %v but got %v", expected, received)
+	}
+}
+
+func TestReadOwnershipUnknownOpCode(t *testing.T) {
+	station, port := net.Pipe()
+	defer station.Close()
+
+	p := protocol.NewProtocol(port, &protocol.Config{
+		SubscriptionBuffer: 8,
+	})
+	defer p.Close()
+
+	sharedC, sharedCleanupF := p.Read()
+	defer sharedCleanupF()
+
+	// The owner resembles a write session awaiting its responses.
+	ownerC, ownerCleanupF := p.ReadWithOptions(&protocol.ReadOptions{
+		Ownership: protocol.OwnershipOwner,
+	})
+	defer ownerCleanupF()
+
+	// The unknown op code might be a broadcast of a firmware fork, so it's sent to every reader.
+	_, err := station.Write([]byte("<O><~ 1 2>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string][]string{
+		"owner":  {"<O>", "<~ 1 2>"},
+		"shared": {"<~ 1 2>"},
+	}
+
+	for name, commandC := range map[string]protocol.CommandC{"owner": ownerC, "shared": sharedC} {
+		for _, expectedCmd := range expected[name] {
+			select {
+			case cmd := <-commandC:
+				if cmd.String() != expectedCmd {
+					t.Errorf("Expected the %s reader to receive %q but got %q", name, expectedCmd, cmd)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("Timed out waiting for the %s reader", name)
+			}
+		}
+	}
+}
+
+func TestReadOverflow(t *testing.T) {
+	station, port := net.Pipe()
+	defer station.Close()
+
+	p := protocol.NewProtocol(port, &protocol.Config{
+		SubscriptionBuffer: 2,
+	})
+	defer p.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	dropOldest := protocol.OverflowDropOldest
+	oldestC, oldestCleanupF := p.ReadWithOptions(&protocol.ReadOptions{
+		OpCodes:  []command.OpCode{'q'},
+		Overflow: &dropOldest,
+	})
+	defer oldestCleanupF()
+
+	dropNewest := protocol.OverflowDropNewest
+	newestC, newestCleanupF := p.ReadWithOptions(&protocol.ReadOptions{
+		OpCodes:  []command.OpCode{'q'},
+		Overflow: &dropNewest,
+	})
+	defer newestCleanupF()
+
+	waiter := p.ReadOpCode(ctx, command.OpCodeSuccess)
+
+	// Neither of the slow readers stalls the delivery to the other ones.
+	_, err := station.Write([]byte("<q 1><q 2><q 3><q 4><q 5><O>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	<-waiter.WaitC
+	if waiter.Command() == nil {
+		t.Fatal("Expected <O> to be delivered")
+	}
+
+	receivedF := func(commandC protocol.CommandC) []string {
+		commands := []string{}
+		for {
+			select {
+			case cmd := <-commandC:
+				commands = append(commands, cmd.String())
+			case <-time.After(50 * time.Millisecond):
+				return commands
+			}
+		}
+	}
+
+	// Besides the buffered commands, the reader might hold another one.
+	oldest := receivedF(oldestC)
+	if len(oldest) < 2 || len(oldest) > 3 || oldest[len(oldest)-1] != "<q 5>" {
+		t.Errorf("Expected the newest commands to be kept but got %v", oldest)
+	}
+
+	newest := receivedF(newestC)
+	if len(newest) < 2 || len(newest) > 3 || newest[0] != "<q 1>" || slices.Contains(newest, "<q 5>") {
+		t.Errorf("Expected the oldest commands to be kept but got %v", newest)
+	}
+}
+
+func TestWriteAndReadOpCode(t *testing.T) {
+	p := protocol.NewProtocol(simulator.NewSimulator(), &protocol.Config{})
+	defer p.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	response, err := p.WriteAndReadOpCode(ctx, command.NewCommand(command.OpCodeStatus, ""), command.OpCodeStatusResponse)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.OpCode() != command.OpCodeStatusResponse {
+		t.Errorf("Expected response %q but got %q", command.OpCodeStatusResponse, response)
+	}
+}
+
+// lockedBuffer is a buffer safe for concurrent writes from the listener and writers.
+type lockedBuffer struct {
+	buf  bytes.Buffer
+	lock sync.Mutex
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.buf.String()
+}
+
+func TestLogger(t *testing.T) {
+	logs := &lockedBuffer{}
+	logger := slog.New(slog.NewJSONHandler(logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	p := protocol.NewProtocol(simulator.NewSimulator(), &protocol.Config{Logger: logger})
+	defer p.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := p.WriteAndReadOpCode(ctx, command.NewCommand(command.OpCodeStatus, ""), command.OpCodeStatusResponse)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Written commands carry typed parameters which are logged too.
+	err = p.Write(command.NewCommand(command.OpCodeCabSpeed, "%d %d %d", 3, 50, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{
+		`"msg":"wrote command","duration":`,
+		`"opcode":"s"`,
+		`"msg":"received command","opcode":"i"`,
+		`"opcode":"t","params":["3","50","1"]`,
+	} {
+		if !strings.Contains(logs.String(), expected) {
+			t.Errorf("Expected %s in logs %s", expected, logs)
+		}
+	}
+}
+
+func TestExpvarMetrics(t *testing.T) {
+	// Expvar names are global, keep them unique across repeated runs.
+	metrics := protocol.NewExpvarMetrics(fmt.Sprintf("dcc_ex_test_%d", time.Now().UnixNano()))
+
+	p := protocol.NewProtocol(simulator.NewSimulator(), &protocol.Config{Metrics: metrics})
+	defer p.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := p.WriteAndReadOpCode(ctx, command.NewCommand(command.OpCodeStatus, ""), command.OpCodeStatusResponse)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, expected := range map[string]string{
+		string(protocol.CounterWrites):                       "1",
+		string(protocol.HistogramWriteLatency) + "_count":    "1",
+		string(protocol.HistogramResponseLatency) + "_count": "1",
+		string(protocol.GaugeSubscriptions):                  "0",
+	} {
+		v := metrics.Vars().Get(name)
+		if v == nil || v.String() != expected {
+			t.Errorf("Expected %s to be %s but got %v", name, expected, v)
+		}
+	}
+
+	// The ready message and the status response were received.
+	v := metrics.Vars().Get(string(protocol.CounterFramesReceived))
+	if v == nil || v.String() == "0" {
+		t.Errorf("Expected received frames but got %v", v)
+	}
+}
+
+func TestExpvarMetricsBuckets(t *testing.T) {
+	metrics := protocol.NewExpvarMetrics(fmt.Sprintf("dcc_ex_test_buckets_%d", time.Now().UnixNano()))
+
+	for _, d := range []time.Duration{3 * time.Millisecond, 20 * time.Millisecond, 30 * time.Second} {
+		metrics.Observe(protocol.HistogramWriteLatency, d)
+	}
+
+	buckets, ok := metrics.Vars().Get(string(protocol.HistogramWriteLatency) + "_bucket").(*expvar.Map)
+	if !ok {
+		t.Fatal("Expected the buckets to be published")
+	}
+
+	// The buckets are cumulative.
+	for bound, expected := range map[string]string{
+		"0.001": "",
+		"0.005": "1",
+		"0.025": "2",
+		"10":    "2",
+		"+Inf":  "3",
+	} {
+		v := buckets.Get(bound)
+		if (v == nil && expected != "") || (v != nil && v.String() != expected) {
+			t.Errorf("Expected bucket %s to be %q but got %v", bound, expected, v)
+		}
+	}
+}
+
+func TestFrameErrors(t *testing.T) {
+	station, port := net.Pipe()
+	defer station.Close()
+
+	p := protocol.NewProtocol(port, &protocol.Config{})
+	defer p.Close()
+
+	frameErrorC, cleanupF := p.FrameErrors()
+	defer cleanupF()
+
+	_, err := station.Write([]byte("<Q 1<q 2>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case frameError := <-frameErrorC:
+		if frameError.Frame != "Q 1" || !errors.Is(frameError, protocol.ErrFrameIncomplete) {
+			t.Errorf("Unexpected frame error %v", frameError)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the frame error")
+	}
+}
+
+func TestWriteBatch(t *testing.T) {
+	station, port := net.Pipe()
+	defer station.Close()
+
+	p := protocol.NewProtocol(port, &protocol.Config{})
+	defer p.Close()
+
+	batch := command.NewBatch(
+		command.NewCommand(command.OpCodeTurnout, "%d %s %d %d", 1, "DCC", 10, 0),
+		command.NewCommand(command.OpCodeTurnout, "%d %s %d %d", 2, "DCC", 10, 1),
+	)
+	batch.Add(command.NewCommand(command.OpCodeEEPROM, ""))
+
+	errC := make(chan error)
+	go func() {
+		errC <- p.WriteBatch(batch)
+	}()
+
+	// A single read receives the whole batch as it's written at once.
+	buffer := make([]byte, 1024)
+	n, err := station.Read(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "<T 1 DCC 10 0>\n<T 2 DCC 10 1>\n<E>\n"
+	if string(buffer[:n]) != expected {
+		t.Errorf("Expected %q but got %q", expected, buffer[:n])
+	}
+
+	err = <-errC
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// v1Protocol only exposes the methods of the v1 interfaces.
+type v1Protocol struct {
+	protocol.ReadWriteCloser
+}
+
+func TestOptionalFallback(t *testing.T) {
+	sim := simulator.NewSimulator()
+	p := protocol.NewProtocol(sim, &protocol.Config{})
+	defer p.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	readWriter := v1Protocol{p}
+	_, ok := any(readWriter).(protocol.OptionsReader)
+	if ok {
+		t.Fatal("Expected the v1 protocol to not implement the optional interfaces")
+	}
+
+	commandC, cleanupF := protocol.ReadFiltered(readWriter, command.OpCodeStatusResponse)
+	defer cleanupF()
+
+	response, err := protocol.WriteAndReadOpCode(ctx, readWriter, command.NewCommand(command.OpCodeStatus, ""), command.OpCodeStatusResponse)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The status also reports the power state which must have been filtered.
+	select {
+	case cmd := <-commandC:
+		if cmd.String() != response.String() {
+			t.Errorf("Expected %q but got %q", response, cmd)
+		}
+	case <-ctx.Done():
+		t.Fatal("Timed out waiting for the filtered command")
+	}
+
+	powerC, powerCleanupF := protocol.ReadFiltered(readWriter, command.OpCodePower)
+	defer powerCleanupF()
+
+	err = protocol.WriteBatch(readWriter, command.NewBatch(command.NewCommand(command.OpCodePowerOn, "")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	matcher := command.NewMatcher(command.OpCodePower, 1)
+	select {
+	case cmd := <-powerC:
+		if !matcher.Match(cmd) {
+			t.Errorf("Expected %q but got %q", matcher, cmd)
+		}
+	case <-ctx.Done():
+		t.Fatal("Timed out waiting for the power state")
+	}
+}
+
+func TestWriteValidates(t *testing.T) {
+	station, port := net.Pipe()
+	defer station.Close()
+
+	p := protocol.NewProtocol(port, &protocol.Config{})
+	defer p.Close()
+
+	// The invalid commands are rejected before they are written, so nothing needs to read from the pipe.
+	invalid := command.NewCommand(command.OpCodeCabSpeed, "%d %d %d", 3, 128, 1)
+
+	err := p.Write(invalid)
+	if !errors.Is(err, command.ErrInvalidCommand) {
+		t.Errorf("Expected %v but got %v", command.ErrInvalidCommand, err)
+	}
+
+	err = p.WriteBatch(command.NewBatch(command.NewCommand(command.OpCodeEEPROM, ""), invalid))
+	if !errors.Is(err, command.ErrInvalidCommand) {
+		t.Errorf("Expected %v but got %v", command.ErrInvalidCommand, err)
+	}
+}
+
+func TestWriteVerified(t *testing.T) {
+	sim := simulator.NewSimulator()
+	p := protocol.NewProtocol(sim, &protocol.Config{})
+	defer p.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := p.WriteVerified(ctx, command.NewCommand(command.OpCodeStatus, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = p.WriteVerified(ctx, command.NewCommand(command.OpCodeAccessory, "%d %d %d", 1, 0, 1))
+	if !errors.Is(err, protocol.ErrNotVerifiable) {
+		t.Errorf("Expected %v but got %v", protocol.ErrNotVerifiable, err)
+	}
+}
+
+func TestVerifyTimeout(t *testing.T) {
+	station, port := net.Pipe()
+	defer station.Close()
+
+	verifyErrC := make(chan *protocol.VerifyError, 1)
+	p := protocol.NewProtocol(port, &protocol.Config{
+		VerifyTimeout: 50 * time.Millisecond,
+		VerifyErrorF: func(err *protocol.VerifyError) {
+			verifyErrC <- err
+		},
+	})
+	defer p.Close()
+
+	// The station reads the command but never responds.
+	go func() {
+		_, _ = io.Copy(io.Discard, station)
+	}()
+
+	err := p.Write(command.NewCommand(command.OpCodeStatus, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case verifyErr := <-verifyErrC:
+		if verifyErr.Sequence != 1 || !errors.Is(verifyErr, protocol.ErrNoResponse) {
+			t.Errorf("Unexpected verify error %v", verifyErr)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the verify error")
+	}
+}
+
+func TestReadDiagnostics(t *testing.T) {
+	station, port := net.Pipe()
+	defer station.Close()
+
+	p := protocol.NewProtocol(port, &protocol.Config{})
+	defer p.Close()
+
+	diagnosticC, cleanupF := p.ReadDiagnostics()
+	defer cleanupF()
+
+	go func() {
+		_, _ = station.Write([]byte("<* Opcode=X params=0 *><X><Q 5><*  Track B  sensOffset=0 *>"))
+	}()
+
+	select {
+	case diagnostic := <-diagnosticC:
+		if diagnostic.Message != "Track B  sensOffset=0" {
+			t.Errorf("Unexpected diagnostic message %q", diagnostic.Message)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the diagnostic")
+	}
+}
+
+func TestCloseDrainsSubscribers(t *testing.T) {
+	sim := simulator.NewSimulator()
+	p := protocol.NewProtocol(sim, &protocol.Config{})
+
+	// The reader never consumes, so the listener is blocked delivering to it.
+	_, blockedCleanupF := p.Read()
+	defer blockedCleanupF()
+
+	commandC, cleanupF := p.ReadFiltered(command.OpCodeSuccess)
+	defer cleanupF()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	errC := make(chan error)
+	go func() {
+		errC <- p.ReadCommand(ctx, command.NewCommand(command.OpCodeSuccess, ""))
+	}()
+
+	sim.SetSensorState(1, sensor.StateActive)
+
+	err := p.CloseContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, ok := <-commandC
+	if ok {
+		t.Error("Expected the reader's channel to be closed")
+	}
+
+	err = <-errC
+	if !errors.Is(err, protocol.ErrClosed) {
+		t.Errorf("Expected %v but got %v", protocol.ErrClosed, err)
+	}
+
+	// Readers subscribing after the protocol got closed receive a closed channel.
+	lateC, lateCleanupF := p.Read()
+	defer lateCleanupF()
+
+	_, ok = <-lateC
+	if ok {
+		t.Error("Expected the late reader's channel to be closed")
+	}
+}
+
+func TestTap(t *testing.T) {
+	p := protocol.NewProtocol(simulator.NewSimulator(), &protocol.Config{})
+	defer p.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tapped := &lockedBuffer{}
+	cleanupF := p.Tap(tapped)
+
+	_, err := p.WriteAndReadOpCode(ctx, command.NewCommand(command.OpCodeStatus, ""), command.OpCodeStatusResponse)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cleanupF()
+
+	lines := strings.Split(strings.TrimSpace(tapped.String()), "\n")
+	if len(lines) < 2 {
+		t.Fatalf("Expected the write and the response to be tapped but got %q", lines)
+	}
+
+	if !strings.HasSuffix(lines[0], ` W "<s>\n"`) {
+		t.Errorf("Expected the tapped write but got %q", lines[0])
+	}
+
+	if !strings.Contains(lines[1], " R ") || !strings.Contains(lines[1], "<i DCC-EX") {
+		t.Errorf("Expected the tapped response but got %q", lines[1])
+	}
+
+	_, err = time.Parse(time.RFC3339Nano, strings.Fields(lines[0])[0])
+	if err != nil {
+		t.Errorf("Expected a timestamp: %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package protocol
+
+import (
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/clock"
+)
+
+// DefaultWriteByteBurst is the size of the serial input buffer of an Arduino.
+const DefaultWriteByteBurst = 64
+
+// tokenBucket limits the rate of writes.
+// It's not safe for concurrent use and relies on the write scheduler to serialize writes.
+type tokenBucket struct {
+	clock  clock.Clock
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(clock clock.Clock, rate float64, burst int) *tokenBucket {
+	return &tokenBucket{
+		clock:  clock,
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   clock.Now(),
+	}
+}
+
+// reserve takes n tokens from the bucket and returns the duration to wait until they are available.
+func (b *tokenBucket) reserve(n int) time.Duration {
+	now := b.clock.Now()
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	b.tokens -= float64(n)
+
+	if b.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// rateLimiter limits the rate of written commands and bytes.
+type rateLimiter struct {
+	commands *tokenBucket
+	bytes    *tokenBucket
+}
+
+func newRateLimiter(config *Config) *rateLimiter {
+	limiter := &rateLimiter{}
+	source := clock.Or(config.Clock)
+
+	if config.WriteRate > 0 {
+		limiter.commands = newTokenBucket(source, config.WriteRate, max(config.WriteBurst, 1))
+	}
+
+	if config.WriteByteRate > 0 {
+		burst := config.WriteByteBurst
+		if burst <= 0 {
+			burst = DefaultWriteByteBurst
+		}
+
+		limiter.bytes = newTokenBucket(source, config.WriteByteRate, burst)
+	}
+
+	return limiter
+}
+
+// reserve takes the tokens required to write a command of the given size and returns the duration to wait.
+func (l *rateLimiter) reserve(size int) time.Duration {
+	wait := time.Duration(0)
+	if l.commands != nil {
+		wait = max(wait, l.commands.reserve(1))
+	}
+
+	if l.bytes != nil {
+		wait = max(wait, l.bytes.reserve(size))
+	}
+
+	return wait
+}
@@ -0,0 +1,93 @@
+package protocol
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/roosterfish/dcc-ex-go/command"
+)
+
+// Priority defines the order in which concurrent writes are performed.
+// Writes with a higher priority jump ahead of queued writes with a lower priority.
+// Writes with the same priority are performed in the order they got queued.
+type Priority uint8
+
+const (
+	PriorityLow Priority = iota
+	PriorityNormal
+	PriorityHigh
+	// PriorityEmergency is used for emergency stops (<!> or speed -1) and powering off (<0>).
+	PriorityEmergency
+)
+
+// PriorityOf returns the priority a command is written with by default.
+// Emergency stops (<!> or speed -1) and powering off (<0>) get PriorityEmergency, every other command PriorityNormal.
+func PriorityOf(cmd *command.Command) Priority {
+	switch cmd.OpCode() {
+	case command.OpCodeEmergencyStop, command.OpCodePowerOff:
+		return PriorityEmergency
+	case command.OpCodeCabSpeed:
+		// <t cab speed direction>
+		params := cmd.Parameters()
+		if len(params) >= 3 && fmt.Sprint(params[1]) == "-1" {
+			return PriorityEmergency
+		}
+	}
+
+	return PriorityNormal
+}
+
+type writeTicket struct {
+	priority Priority
+	readyC   chan struct{}
+}
+
+// writeScheduler hands out the right to write to the underlying connection ordered by priority.
+type writeScheduler struct {
+	lock    sync.Mutex
+	busy    bool
+	waiting []*writeTicket
+}
+
+// acquire blocks until it's the caller's turn to write.
+func (s *writeScheduler) acquire(priority Priority) {
+	s.lock.Lock()
+	if !s.busy {
+		s.busy = true
+		s.lock.Unlock()
+		return
+	}
+
+	ticket := &writeTicket{
+		priority: priority,
+		readyC:   make(chan struct{}),
+	}
+
+	s.waiting = append(s.waiting, ticket)
+	s.lock.Unlock()
+
+	<-ticket.readyC
+}
+
+// release hands the right to write over to the queued writer with the highest priority.
+func (s *writeScheduler) release() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if len(s.waiting) == 0 {
+		s.busy = false
+		return
+	}
+
+	next := 0
+	for i, ticket := range s.waiting {
+		if ticket.priority > s.waiting[next].priority {
+			next = i
+		}
+	}
+
+	// The queue is ordered by arrival, so the first ticket with the highest priority is the oldest one.
+	ticket := s.waiting[next]
+	s.waiting = append(s.waiting[:next], s.waiting[next+1:]...)
+	close(ticket.readyC)
+}
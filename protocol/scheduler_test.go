@@ -0,0 +1,79 @@
+package protocol
+
+import (
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/command"
+)
+
+func TestPriorityOf(t *testing.T) {
+	tests := []struct {
+		command  *command.Command
+		priority Priority
+	}{
+		{command.NewCommand(command.OpCodeEmergencyStop, ""), PriorityEmergency},
+		{command.NewCommand(command.OpCodeCabSpeed, "%d %d %d", 3, -1, 1), PriorityEmergency},
+		{command.NewControlCommand(command.OpCodeCabSpeed, "%d %d %d", 3, -1, 1), PriorityEmergency},
+		{command.NewCommand(command.OpCodeCabSpeed, "%d %d %d", 3, 50, 1), PriorityNormal},
+		{command.NewCommand(command.OpCodeCabSpeed, "%d", 3), PriorityNormal},
+		{command.NewCommand(command.OpCodePowerOff, "%s", "MAIN"), PriorityEmergency},
+		{command.NewCommand(command.OpCodePowerOn, ""), PriorityNormal},
+	}
+
+	for _, test := range tests {
+		priority := PriorityOf(test.command)
+		if priority != test.priority {
+			t.Errorf("%s: Expected priority %d but got %d", test.command, test.priority, priority)
+		}
+	}
+}
+
+func TestWriteScheduler(t *testing.T) {
+	scheduler := &writeScheduler{}
+	scheduler.acquire(PriorityNormal)
+
+	order := []Priority{}
+	orderLock := sync.Mutex{}
+	wg := sync.WaitGroup{}
+
+	queueF := func(priority Priority) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			scheduler.acquire(priority)
+			orderLock.Lock()
+			order = append(order, priority)
+			orderLock.Unlock()
+			scheduler.release()
+		}()
+
+		// Wait for the writer to be queued.
+		for {
+			scheduler.lock.Lock()
+			queued := len(scheduler.waiting) > 0 && scheduler.waiting[len(scheduler.waiting)-1].priority == priority
+			scheduler.lock.Unlock()
+
+			if queued {
+				return
+			}
+
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	queueF(PriorityLow)
+	queueF(PriorityNormal)
+	queueF(PriorityEmergency)
+
+	scheduler.release()
+	wg.Wait()
+
+	expected := []Priority{PriorityEmergency, PriorityNormal, PriorityLow}
+	if !slices.Equal(expected, order) {
+		t.Errorf("Expected writes in order %v but got %v", expected, order)
+	}
+}
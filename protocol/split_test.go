@@ -0,0 +1,108 @@
+package protocol
+
+import (
+	"bufio"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSplitFrames(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		frames []string
+		errors []error
+	}{
+		{
+			name:   "single frame",
+			input:  "<iDCC-EX V-5.4.0>\n",
+			frames: []string{"<iDCC-EX V-5.4.0>"},
+		},
+		{
+			name:   "multiple frames with noise",
+			input:  "noise<p1>\r\n<H 1 0>garbage<O>",
+			frames: []string{"<p1>", "<H 1 0>", "<O>"},
+		},
+		{
+			name:   "newline within frame",
+			input:  "<Q\n 1>",
+			frames: []string{"<Q 1>"},
+		},
+		{
+			name:   "unterminated frame",
+			input:  "<Q 1<q 2>",
+			frames: []string{"<q 2>"},
+			errors: []error{ErrFrameIncomplete},
+		},
+		{
+			name:   "frame too large",
+			input:  "<" + strings.Repeat("a", 20) + "><O>",
+			frames: []string{"<O>"},
+			errors: []error{ErrFrameTooLarge},
+		},
+		{
+			name:   "unterminated frame too large",
+			input:  "<" + strings.Repeat("a", 40),
+			frames: []string{},
+			errors: []error{ErrFrameTooLarge},
+		},
+		{
+			name:   "delimiters within quotes",
+			input:  `<@ 0 2 "<a \">">`,
+			frames: []string{`<@ 0 2 "<a \">">`},
+		},
+		{
+			name:   "unterminated frame at end",
+			input:  "<O><X",
+			frames: []string{"<O>"},
+		},
+	}
+
+	for _, test := range tests {
+		parseErrors := []error{}
+
+		p := &Protocol{
+			config: &Config{
+				ReadBufferSize: 4,
+				MaxFrameSize:   16,
+				ParseErrorF: func(frame string, err error) {
+					parseErrors = append(parseErrors, err)
+				},
+			},
+		}
+
+		scanner := bufio.NewScanner(strings.NewReader(test.input))
+		scanner.Buffer(make([]byte, 0, p.readBufferSize()), 2*p.maxFrameSize()+2)
+		scanner.Split(p.splitFrames)
+
+		frames := []string{}
+		for scanner.Scan() {
+			frames = append(frames, scanner.Text())
+		}
+
+		if scanner.Err() != nil {
+			t.Errorf("%s: Unexpected error %v", test.name, scanner.Err())
+		}
+
+		if test.frames == nil {
+			test.frames = []string{}
+		}
+
+		if !slices.Equal(test.frames, frames) {
+			t.Errorf("%s: Expected frames %q but got %q", test.name, test.frames, frames)
+		}
+
+		if len(test.errors) != len(parseErrors) {
+			t.Errorf("%s: Expected parse errors %v but got %v", test.name, test.errors, parseErrors)
+			continue
+		}
+
+		for i, err := range test.errors {
+			if !errors.Is(parseErrors[i], err) {
+				t.Errorf("%s: Expected parse error %v but got %v", test.name, err, parseErrors[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package protocol
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/record"
+)
+
+// tapReader mirrors all bytes read from the underlying connection to the taps.
+type tapReader struct {
+	protocol *Protocol
+	port     io.Reader
+}
+
+func (r *tapReader) Read(p []byte) (int, error) {
+	n, err := r.port.Read(p)
+	if n > 0 {
+		r.protocol.tap(record.DirectionRead, p[:n])
+	}
+
+	return n, err
+}
+
+// Tap mirrors all of the incoming and outgoing bytes to w until the returned cleanup function is called.
+// Every chunk is written as a single line "<RFC 3339 timestamp> <R|W> <quoted data>".
+// Writing to w happens from within the listener, so use a fast writer (e.g. a file or buffer).
+// Tapping is best effort, errors returned by w are ignored.
+func (p *Protocol) Tap(w io.Writer) CleanupF {
+	p.tapLock.Lock()
+	p.taps[&w] = w
+	p.tapLock.Unlock()
+
+	return func() {
+		p.tapLock.Lock()
+		delete(p.taps, &w)
+		p.tapLock.Unlock()
+	}
+}
+
+// tap writes the given data to all of the taps.
+func (p *Protocol) tap(direction record.Direction, data []byte) {
+	p.tapLock.Lock()
+	defer p.tapLock.Unlock()
+
+	if len(p.taps) == 0 {
+		return
+	}
+
+	line := fmt.Sprintf("%s %c %s\n", p.clock().Now().Format(time.RFC3339Nano), direction, strconv.Quote(string(data)))
+	for _, w := range p.taps {
+		_, _ = io.WriteString(w, line)
+	}
+}
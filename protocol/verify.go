@@ -0,0 +1,163 @@
+package protocol
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/command"
+	"github.com/roosterfish/dcc-ex-go/errs"
+)
+
+// verifyBuffer is the number of responses buffered for every verified write.
+// Older responses are dropped so verification never stalls the listener.
+const verifyBuffer = 16
+
+var (
+	// ErrNoResponse is reported for written commands whose response wasn't observed in time.
+	// It matches errs.ErrTimeout.
+	ErrNoResponse = errs.New("no response observed", errs.ErrTimeout)
+	// ErrNotVerifiable is returned by WriteVerified for commands the command station doesn't respond to.
+	ErrNotVerifiable = errors.New("command has no response")
+)
+
+// VerifyError describes a written command which couldn't be verified against the command station's response.
+type VerifyError struct {
+	// Sequence is the number of the verified write. It increases with every verified write.
+	Sequence uint64
+	Command  *command.Command
+	Err      error
+}
+
+func (e *VerifyError) Error() string {
+	return fmt.Sprintf("failed to verify command #%d %q: %v", e.Sequence, e.Command.String(), e.Err)
+}
+
+func (e *VerifyError) Unwrap() error {
+	return e.Err
+}
+
+// expectation awaits the response of a single written command.
+type expectation struct {
+	protocol *Protocol
+	sequence uint64
+	command  *command.Command
+	matcher  *command.Matcher
+	commandC CommandC
+	cleanupF CleanupF
+}
+
+// expect subscribes to the response of the given command before it gets written.
+// It returns nil if the command station doesn't respond to the command.
+func (p *Protocol) expect(cmd *command.Command) *expectation {
+	matcher, ok := cmd.ResponseMatcher()
+	if !ok {
+		return nil
+	}
+
+	overflow := OverflowDropOldest
+	commandC, cleanupF := p.ReadWithOptions(&ReadOptions{
+		OpCodes:  []command.OpCode{matcher.OpCode},
+		Buffer:   verifyBuffer,
+		Overflow: &overflow,
+		// Verification might outlast the session of the write, so observe the response instead of owning it.
+		Ownership: OwnershipObserver,
+	})
+
+	return &expectation{
+		protocol: p,
+		sequence: p.sequence.Add(1),
+		command:  cmd,
+		matcher:  matcher,
+		commandC: commandC,
+		cleanupF: cleanupF,
+	}
+}
+
+// wait blocks until the response was observed.
+// It fails with a VerifyError if the context is done or the timeout (if set) elapsed before.
+// The subscription is cleaned up in any case.
+func (e *expectation) wait(ctx context.Context, timeout time.Duration) error {
+	defer e.cleanupF()
+
+	var timeoutC <-chan time.Time
+	if timeout > 0 {
+		timer := e.protocol.clock().NewTimer(timeout)
+		defer timer.Stop()
+
+		timeoutC = timer.C()
+	}
+
+	for {
+		select {
+		case cmd, ok := <-e.commandC:
+			if !ok {
+				return e.error(ErrClosed)
+			}
+
+			if e.matcher.Match(cmd) {
+				return nil
+			}
+		case <-timeoutC:
+			return e.error(ErrNoResponse)
+		case <-ctx.Done():
+			return e.error(errs.Context(ctx.Err()))
+		}
+	}
+}
+
+func (e *expectation) error(err error) *VerifyError {
+	return &VerifyError{
+		Sequence: e.sequence,
+		Command:  e.command,
+		Err:      err,
+	}
+}
+
+// verifyFailed reports a command which couldn't be verified in the background.
+// Commands are not reported once the protocol got closed.
+func (p *Protocol) verifyFailed(err error) {
+	verifyErr := &VerifyError{}
+	if !errors.As(err, &verifyErr) || errors.Is(err, ErrClosed) {
+		return
+	}
+
+	p.inc(CounterVerifyErrors)
+
+	if p.config.Logger != nil {
+		p.config.Logger.Warn("failed to verify command", slog.Uint64("sequence", verifyErr.Sequence), slog.String("command", verifyErr.Command.String()), slog.Any("error", verifyErr.Err))
+	}
+
+	if p.config.VerifyErrorF != nil {
+		p.config.VerifyErrorF(verifyErr)
+	}
+}
+
+// WriteVerified writes the command and waits until the command station's response was observed
+// (see command.ResponseMatcher).
+// It fails with a VerifyError wrapping ErrNoResponse if the response isn't observed within
+// VerifyTimeout (if set) or the context's error once it's done.
+// Commands the command station doesn't respond to are refused with ErrNotVerifiable.
+// Make sure none of the caller's other readers block the listener while waiting.
+func (p *Protocol) WriteVerified(ctx context.Context, cmd *command.Command) error {
+	expectation := p.expect(cmd)
+	if expectation == nil {
+		return fmt.Errorf("failed to verify command %q: %w", cmd.String(), ErrNotVerifiable)
+	}
+
+	err := p.write(cmd, PriorityOf(cmd))
+	if err != nil {
+		expectation.cleanupF()
+		return err
+	}
+
+	err = expectation.wait(ctx, p.config.VerifyTimeout)
+	if err != nil {
+		p.inc(CounterVerifyErrors)
+		return err
+	}
+
+	return nil
+}
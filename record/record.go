@@ -0,0 +1,262 @@
+package record
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/clock"
+)
+
+type Direction rune
+
+const (
+	// DirectionRead marks bytes read from the command station.
+	DirectionRead Direction = 'R'
+	// DirectionWrite marks bytes written to the command station.
+	DirectionWrite Direction = 'W'
+)
+
+// Entry is a single chunk of recorded traffic.
+// Offset is the duration since the recording started.
+type Entry struct {
+	Offset    time.Duration
+	Direction Direction
+	Data      []byte
+}
+
+// Recorder wraps a connection and records all of the traffic in both directions.
+// Every read and write is recorded as a single line "<offset in ns> <R|W> <quoted data>".
+type Recorder struct {
+	port      io.ReadWriteCloser
+	w         io.Writer
+	clock     clock.Clock
+	start     time.Time
+	writeLock sync.Mutex
+}
+
+// Replayer feeds recorded traffic back as if it was sent by the command station.
+// Writes are discarded.
+type Replayer struct {
+	entries  []*Entry
+	realtime bool
+	clock    clock.Clock
+	start    time.Time
+	pending  []byte
+	closedC  chan struct{}
+	closeF   func()
+	readLock sync.Mutex
+}
+
+func (e *Entry) String() string {
+	return fmt.Sprintf("%d %c %s", e.Offset.Nanoseconds(), e.Direction, strconv.Quote(string(e.Data)))
+}
+
+// ParseEntry parses a single line of a recording.
+func ParseEntry(line string) (*Entry, error) {
+	offsetStr, rest, ok := strings.Cut(line, " ")
+	if !ok {
+		return nil, fmt.Errorf("invalid entry %q", line)
+	}
+
+	directionStr, dataStr, ok := strings.Cut(rest, " ")
+	if !ok || len(directionStr) != 1 {
+		return nil, fmt.Errorf("invalid entry %q", line)
+	}
+
+	offset, err := strconv.ParseInt(offsetStr, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid offset %q: %w", offsetStr, err)
+	}
+
+	direction := Direction(directionStr[0])
+	if direction != DirectionRead && direction != DirectionWrite {
+		return nil, fmt.Errorf("invalid direction %q", directionStr)
+	}
+
+	data, err := strconv.Unquote(dataStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid data %q: %w", dataStr, err)
+	}
+
+	return &Entry{
+		Offset:    time.Duration(offset),
+		Direction: direction,
+		Data:      []byte(data),
+	}, nil
+}
+
+// NewRecorder returns a new recorder wrapping the given connection (port).
+// The recording is written to w.
+func NewRecorder(port io.ReadWriteCloser, w io.Writer) *Recorder {
+	return &Recorder{
+		port:  port,
+		w:     w,
+		start: clock.Real.Now(),
+	}
+}
+
+// SetClock sets the source of time used for the offsets of the recorded entries.
+// The recording restarts at the clock's current time.
+// Set it before using the recorder.
+func (r *Recorder) SetClock(clock clock.Clock) {
+	r.clock = clock
+	r.start = r.Clock().Now()
+}
+
+// Clock returns the source of time used by the recorder.
+// It defaults to the real time.
+func (r *Recorder) Clock() clock.Clock {
+	return clock.Or(r.clock)
+}
+
+func (r *Recorder) record(direction Direction, data []byte) {
+	entry := &Entry{
+		Offset:    r.Clock().Since(r.start),
+		Direction: direction,
+		Data:      data,
+	}
+
+	r.writeLock.Lock()
+	defer r.writeLock.Unlock()
+
+	// Recording is best effort and must not interfere with the connection.
+	_, _ = fmt.Fprintln(r.w, entry.String())
+}
+
+func (r *Recorder) Read(p []byte) (int, error) {
+	n, err := r.port.Read(p)
+	if n > 0 {
+		r.record(DirectionRead, p[:n])
+	}
+
+	return n, err
+}
+
+func (r *Recorder) Write(p []byte) (int, error) {
+	n, err := r.port.Write(p)
+	if n > 0 {
+		r.record(DirectionWrite, p[:n])
+	}
+
+	return n, err
+}
+
+// Close closes the underlying connection.
+// The writer of the recording needs to be closed by the caller.
+func (r *Recorder) Close() error {
+	return r.port.Close()
+}
+
+// NewReplayer returns a new replayer reading the recording from r.
+// If realtime is set, the recorded reads are replayed using their original timing.
+// Otherwise they are replayed as fast as possible.
+func NewReplayer(r io.Reader, realtime bool) (*Replayer, error) {
+	entries := []*Entry{}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+
+		entry, err := ParseEntry(line)
+		if err != nil {
+			return nil, err
+		}
+
+		if entry.Direction == DirectionRead {
+			entries = append(entries, entry)
+		}
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+
+	closedC := make(chan struct{})
+
+	return &Replayer{
+		entries:  entries,
+		realtime: realtime,
+		start:    clock.Real.Now(),
+		closedC:  closedC,
+		closeF: sync.OnceFunc(func() {
+			close(closedC)
+		}),
+	}, nil
+}
+
+// SetClock sets the source of time used to replay the recorded reads using their original timing.
+// The replay restarts at the clock's current time.
+// Set it before using the replayer.
+func (r *Replayer) SetClock(clock clock.Clock) {
+	r.clock = clock
+	r.start = r.Clock().Now()
+}
+
+// Clock returns the source of time used by the replayer.
+// It defaults to the real time.
+func (r *Replayer) Clock() clock.Clock {
+	return clock.Or(r.clock)
+}
+
+// Read returns the next recorded read.
+// It returns io.EOF once the whole recording was replayed or the replayer got closed.
+func (r *Replayer) Read(p []byte) (int, error) {
+	r.readLock.Lock()
+	defer r.readLock.Unlock()
+
+	if len(r.pending) == 0 {
+		if len(r.entries) == 0 {
+			return 0, io.EOF
+		}
+
+		entry := r.entries[0]
+		r.entries = r.entries[1:]
+
+		if r.realtime {
+			select {
+			case <-r.Clock().After(r.Clock().Until(r.start.Add(entry.Offset))):
+			case <-r.closedC:
+				return 0, io.EOF
+			}
+		}
+
+		r.pending = entry.Data
+	}
+
+	select {
+	case <-r.closedC:
+		return 0, io.EOF
+	default:
+	}
+
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+
+	return n, nil
+}
+
+// Write discards the given bytes.
+func (r *Replayer) Write(p []byte) (int, error) {
+	select {
+	case <-r.closedC:
+		return 0, io.ErrClosedPipe
+	default:
+	}
+
+	return len(p), nil
+}
+
+// Close stops replaying.
+func (r *Replayer) Close() error {
+	r.closeF()
+	return nil
+}
@@ -0,0 +1,127 @@
+package record_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/channel"
+	"github.com/roosterfish/dcc-ex-go/clock"
+	"github.com/roosterfish/dcc-ex-go/command"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+	"github.com/roosterfish/dcc-ex-go/record"
+	"github.com/roosterfish/dcc-ex-go/simulator"
+	"github.com/roosterfish/dcc-ex-go/station"
+)
+
+func TestParseEntry(t *testing.T) {
+	entry, err := record.ParseEntry(`42 R "<iDCC-EX V-5.4.0>\n"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if entry.Offset != 42 || entry.Direction != record.DirectionRead || string(entry.Data) != "<iDCC-EX V-5.4.0>\n" {
+		t.Errorf("Unexpected entry %+v", entry)
+	}
+
+	for _, line := range []string{"", "42", "42 R", "a R \"\"", "42 Q \"\"", "42 R unquoted"} {
+		_, err := record.ParseEntry(line)
+		if err == nil {
+			t.Errorf("Expected error parsing %q", line)
+		}
+	}
+}
+
+func TestRecordReplay(t *testing.T) {
+	recording := &bytes.Buffer{}
+	recorder := record.NewRecorder(simulator.NewSimulator(), recording)
+	p := protocol.NewProtocol(recorder, &protocol.Config{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := station.NewStation(channel.NewChannel(p)).Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = p.Close()
+
+	if !strings.Contains(recording.String(), ` W "<s ><X>\n"`) {
+		t.Errorf("Expected the status command to be recorded: %s", recording.String())
+	}
+
+	replayer, err := record.NewReplayer(recording, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p = protocol.NewProtocol(replayer, &protocol.Config{RequireSubscriber: true})
+	defer p.Close()
+
+	commandC, cleanupF := p.Read()
+	defer cleanupF()
+
+	for {
+		select {
+		case cmd := <-commandC:
+			if cmd.OpCode() == command.OpCodeStatusResponse {
+				return
+			}
+		case <-ctx.Done():
+			t.Fatal("Status response wasn't replayed")
+		}
+	}
+}
+
+// discard is a connection discarding all writes.
+type discard struct{}
+
+func (discard) Read(p []byte) (int, error)  { return 0, io.EOF }
+func (discard) Write(p []byte) (int, error) { return len(p), nil }
+func (discard) Close() error                { return nil }
+
+func TestRecordReplayClock(t *testing.T) {
+	mock := clock.NewMock(time.Unix(0, 0))
+
+	recording := &bytes.Buffer{}
+	recorder := record.NewRecorder(discard{}, recording)
+	recorder.SetClock(mock)
+
+	mock.Advance(42 * time.Millisecond)
+
+	_, err := recorder.Write([]byte("<s>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if recording.String() != "42000000 W \"<s>\"\n" {
+		t.Fatalf("Unexpected recording %q", recording.String())
+	}
+
+	replayer, err := record.NewReplayer(strings.NewReader("42000000 R \"<p1>\"\n"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replayer.SetClock(mock)
+
+	readC := make(chan string)
+	go func() {
+		p := make([]byte, 16)
+		n, _ := replayer.Read(p)
+		readC <- string(p[:n])
+	}()
+
+	// The read is replayed once its offset is reached.
+	mock.BlockUntil(1)
+	mock.Advance(42 * time.Millisecond)
+
+	data := <-readC
+	if data != "<p1>" {
+		t.Errorf("Expected %q but got %q", "<p1>", data)
+	}
+}
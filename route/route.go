@@ -0,0 +1,99 @@
+package route
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/roosterfish/dcc-ex-go/sensor"
+	"github.com/roosterfish/dcc-ex-go/turnout"
+)
+
+// Setting is the state of a single turnout along a route.
+type Setting struct {
+	Turnout *turnout.TurnoutServo
+	// State is either turnout.StateThrown or turnout.StateClosed.
+	State turnout.State
+	// Block is the block detection sensor of the block the turnout lies in.
+	// If set, the route cannot be activated while the block is occupied.
+	Block *sensor.Sensor
+}
+
+type Route struct {
+	name     string
+	settings []*Setting
+}
+
+// OccupiedError is returned if a route cannot be activated as one of its turnouts lies in an occupied block.
+type OccupiedError struct {
+	Route   string
+	Turnout turnout.ID
+	Block   sensor.ID
+}
+
+func (e *OccupiedError) Error() string {
+	return fmt.Sprintf("route %q cannot be activated: turnout %d lies in occupied block %d", e.Route, e.Turnout, e.Block)
+}
+
+// NewRoute returns a new route setting the given turnouts.
+func NewRoute(name string, settings ...*Setting) *Route {
+	return &Route{
+		name:     name,
+		settings: settings,
+	}
+}
+
+// Name returns the route's name.
+func (r *Route) Name() string {
+	return r.name
+}
+
+// Check returns an *OccupiedError in case any of the route's turnouts lies in an occupied block.
+func (r *Route) Check(ctx context.Context) error {
+	for _, setting := range r.settings {
+		if setting.Block == nil {
+			continue
+		}
+
+		state, err := setting.Block.State(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to check block %d of route %q: %w", setting.Block.ID(), r.name, err)
+		}
+
+		if state == sensor.StateActive {
+			return &OccupiedError{
+				Route:   r.name,
+				Turnout: setting.Turnout.ID(),
+				Block:   setting.Block.ID(),
+			}
+		}
+	}
+
+	return nil
+}
+
+// Activate sets all of the route's turnouts.
+// Before any turnout is set, the blocks are checked for occupancy.
+// This prevents throwing points under a moving train.
+func (r *Route) Activate(ctx context.Context) error {
+	err := r.Check(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, setting := range r.settings {
+		switch setting.State {
+		case turnout.StateThrown:
+			err = setting.Turnout.Throw(ctx)
+		case turnout.StateClosed:
+			err = setting.Turnout.Close(ctx)
+		default:
+			err = fmt.Errorf("invalid state %q", setting.State)
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed to set turnout %d of route %q: %w", setting.Turnout.ID(), r.name, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package route_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/channel"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+	"github.com/roosterfish/dcc-ex-go/route"
+	"github.com/roosterfish/dcc-ex-go/sensor"
+	"github.com/roosterfish/dcc-ex-go/simulator"
+	"github.com/roosterfish/dcc-ex-go/turnout"
+)
+
+func TestActivate(t *testing.T) {
+	sim := simulator.NewSimulator()
+	p := protocol.NewProtocol(sim, &protocol.Config{})
+	defer p.Close()
+
+	ch := channel.NewChannel(p)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	points := turnout.NewTurnoutServo(1, ch)
+	err := points.Persist(ctx, 100, 400, 200, turnout.ProfileFast)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block := sensor.NewSensor(10, ch)
+	err = block.Persist(ctx, 110, sensor.PullUpOn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := route.NewRoute("siding", &route.Setting{
+		Turnout: points,
+		State:   turnout.StateThrown,
+		Block:   block,
+	})
+
+	sim.SetSensorState(10, sensor.StateActive)
+
+	err = r.Activate(ctx)
+
+	var occupiedErr *route.OccupiedError
+	if !errors.As(err, &occupiedErr) {
+		t.Fatalf("Expected occupied error but got %v", err)
+	}
+
+	if occupiedErr.Turnout != 1 || occupiedErr.Block != 10 {
+		t.Errorf("Unexpected occupied error %+v", occupiedErr)
+	}
+
+	status, err := points.Examine(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status.State != turnout.StateClosed {
+		t.Error("Expected turnout to stay closed")
+	}
+
+	sim.SetSensorState(10, sensor.StateInactive)
+
+	err = r.Activate(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status, err = points.Examine(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status.State != turnout.StateThrown {
+		t.Error("Expected turnout to be thrown")
+	}
+}
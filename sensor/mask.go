@@ -0,0 +1,97 @@
+package sensor
+
+import (
+	"sync"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/channel"
+	"github.com/roosterfish/dcc-ex-go/clock"
+)
+
+// Status is the state of a sensor together with its mask.
+type Status struct {
+	// State is always StateInactive while the sensor is masked.
+	State  State
+	Masked bool
+}
+
+type mask struct {
+	masked bool
+	timer  clock.Timer
+	lock   sync.Mutex
+}
+
+type maskKey struct {
+	channel channel.Interface
+	id      ID
+}
+
+// masks contains the mask of every sensor per channel.
+// It's shared as sensors are derived from the connection on demand.
+var masks sync.Map
+
+// mask returns the sensor's mask.
+func (s *Sensor) mask() *mask {
+	m, _ := masks.LoadOrStore(maskKey{channel: s.channel, id: s.id}, &mask{})
+	return m.(*mask)
+}
+
+// Mask suppresses the sensor's events and freezes its state as StateInactive.
+// This prevents automations from being triggered e.g. while cleaning the track section.
+// The sensor gets unmasked automatically after the given timeout.
+// In case the timeout is zero, the sensor stays masked until Unmask is called.
+// Masking an already masked sensor restarts the timeout.
+func (s *Sensor) Mask(timeout time.Duration) {
+	m := s.mask()
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.timer != nil {
+		_ = m.timer.Stop()
+		m.timer = nil
+	}
+
+	m.masked = true
+
+	if timeout > 0 {
+		var timer clock.Timer
+		timer = s.channel.Clock().AfterFunc(timeout, func() {
+			m.lock.Lock()
+			defer m.lock.Unlock()
+
+			// Ignore the expiry if the sensor got masked again in the meantime.
+			if m.timer == timer {
+				m.masked = false
+				m.timer = nil
+			}
+		})
+
+		m.timer = timer
+	}
+}
+
+// Unmask resumes the sensor's events.
+func (s *Sensor) Unmask() {
+	m := s.mask()
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.timer != nil {
+		_ = m.timer.Stop()
+		m.timer = nil
+	}
+
+	m.masked = false
+}
+
+// Masked returns true if the sensor is masked.
+func (s *Sensor) Masked() bool {
+	m := s.mask()
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return m.masked
+}
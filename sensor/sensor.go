@@ -9,7 +9,10 @@ import (
 
 	"github.com/roosterfish/dcc-ex-go/channel"
 	"github.com/roosterfish/dcc-ex-go/command"
+	"github.com/roosterfish/dcc-ex-go/errs"
 	"github.com/roosterfish/dcc-ex-go/protocol"
+	"github.com/roosterfish/dcc-ex-go/station"
+	dccvpin "github.com/roosterfish/dcc-ex-go/vpin"
 )
 
 type ID uint16
@@ -29,7 +32,7 @@ const (
 
 type Sensor struct {
 	id      ID
-	channel *channel.Channel
+	channel channel.Interface
 }
 
 func (s State) OpCode() command.OpCode {
@@ -44,16 +47,41 @@ func (s State) Opposite() State {
 	return StateActive
 }
 
-func NewSensor(id ID, channel *channel.Channel) *Sensor {
+func NewSensor(id ID, channel channel.Interface) *Sensor {
 	return &Sensor{
 		id:      id,
 		channel: channel,
 	}
 }
 
+// ID returns the sensor's ID.
+func (s *Sensor) ID() ID {
+	return s.id
+}
+
+// Wait waits until the given state is observed.
+// Events observed while the sensor is masked are ignored.
 func (s *Sensor) Wait(ctx context.Context, state State) error {
-	return s.channel.RSession(func(protocol protocol.Reader) error {
-		return protocol.ReadCommand(ctx, command.NewCommand(state.OpCode(), "%d", s.id))
+	return s.channel.RSession(func(sessionProtocol protocol.Reader) error {
+		commandC, cleanupF := protocol.ReadFiltered(sessionProtocol, state.OpCode())
+		defer cleanupF()
+
+		stateCommand := command.NewCommand(state.OpCode(), "%d", s.id).String()
+
+		for {
+			select {
+			case cmd, ok := <-commandC:
+				if !ok {
+					return channel.ErrClosed
+				}
+
+				if cmd.String() == stateCommand && !s.Masked() {
+					return nil
+				}
+			case <-ctx.Done():
+				return errs.Context(ctx.Err())
+			}
+		}
 	})
 }
 
@@ -61,6 +89,7 @@ func (s *Sensor) Wait(ctx context.Context, state State) error {
 // This helps waiting for sensors (e.g. block detection) whose values flicker during the transition period.
 // In case the sensor already has the given state, it will start waiting immediately.
 // In case the sensor has a different state, it will wait until the expected state is observed for the first time.
+// Events observed while the sensor is masked are ignored.
 func (s *Sensor) WaitConsistent(ctx context.Context, state State, duration time.Duration) error {
 	// First read the sensors current state.
 	// It might be that the sensor doesn't receive any state change during the wait duration.
@@ -77,17 +106,17 @@ func (s *Sensor) WaitConsistent(ctx context.Context, state State, duration time.
 	}
 
 	// Create a new timer without any duration.
-	timer := time.NewTimer(startDuration)
+	timer := s.channel.Clock().NewTimer(startDuration)
 	defer timer.Stop()
 
 	// As the timer could be created without duration, in this case it will expire right away.
 	// Read the expiry time from the channel so it's clean.
 	if startDuration == 0 {
-		<-timer.C
+		<-timer.C()
 	}
 
-	return s.channel.RSession(func(protocol protocol.Reader) error {
-		commandC, cleanupF := protocol.Read()
+	return s.channel.RSession(func(sessionProtocol protocol.Reader) error {
+		commandC, cleanupF := protocol.ReadFiltered(sessionProtocol, state.OpCode(), state.Opposite().OpCode())
 		defer cleanupF()
 
 		stateCommand := command.NewCommand(state.OpCode(), "%d", s.id).String()
@@ -95,7 +124,15 @@ func (s *Sensor) WaitConsistent(ctx context.Context, state State, duration time.
 
 		for {
 			select {
-			case cmd := <-commandC:
+			case cmd, ok := <-commandC:
+				if !ok {
+					return channel.ErrClosed
+				}
+
+				if s.Masked() {
+					continue
+				}
+
 				cmdStr := cmd.String()
 				if cmdStr == stateCommand {
 					// In case the requested state was observed reset the expired timer.
@@ -104,17 +141,19 @@ func (s *Sensor) WaitConsistent(ctx context.Context, state State, duration time.
 					// In case the opposite state was observed stop the timer.
 					_ = timer.Stop()
 				}
-			case <-timer.C:
+			case <-timer.C():
 				// In case the timer expired return.
 				return nil
 			case <-ctx.Done():
 				// If the outer context expires return the error.
-				return ctx.Err()
+				return errs.Context(ctx.Err())
 			}
 		}
 	})
 }
 
+// SetCallback calls f every time the given state is observed.
+// The callback isn't called while the sensor is masked.
 func (s *Sensor) SetCallback(state State, f func(id ID, state State)) protocol.CleanupF {
 	wg := sync.WaitGroup{}
 
@@ -125,16 +164,20 @@ func (s *Sensor) SetCallback(state State, f func(id ID, state State)) protocol.C
 
 		wgInner := sync.WaitGroup{}
 
-		_ = s.channel.RSession(func(protocol protocol.Reader) error {
-			commandC, cleanupF := protocol.Read()
+		_ = s.channel.RSession(func(sessionProtocol protocol.Reader) error {
+			commandC, cleanupF := protocol.ReadFiltered(sessionProtocol, state.OpCode())
 			defer cleanupF()
 
 			stateCommand := command.NewCommand(state.OpCode(), "%d", s.id)
 
 			for {
 				select {
-				case cmd := <-commandC:
-					if cmd.String() == stateCommand.String() {
+				case cmd, ok := <-commandC:
+					if !ok {
+						return nil
+					}
+
+					if cmd.String() == stateCommand.String() && !s.Masked() {
 						// Ensure the callback is always executed in its own routine.
 						// This is essential to detach from the protocols read loop.
 						wgInner.Add(1)
@@ -145,7 +188,7 @@ func (s *Sensor) SetCallback(state State, f func(id ID, state State)) protocol.C
 						}()
 					}
 				case <-ctx.Done():
-					return ctx.Err()
+					return errs.Context(ctx.Err())
 				}
 			}
 		})
@@ -163,24 +206,26 @@ func (s *Sensor) SetCallback(state State, f func(id ID, state State)) protocol.C
 }
 
 // Persist creates the sensor and persists its definition in the EEPROM.
+// In case a vpin.Checker is passed using vpin.WithChecker and the vpin is already assigned to another entity,
+// a *vpin.ConflictError is returned.
+// In case the EEPROM is estimated to be exhausted, station.ErrEEPROMFull is returned.
 func (s *Sensor) Persist(ctx context.Context, vpin VPin, pullUp PullUp) error {
-	sensorCommand := command.NewCommand(command.OpCodeSensorCreate, "%d %d %d", s.id, vpin, pullUp)
-	persistCommand := command.NewCommand(command.OpCodeEEPROM, "")
+	return s.channel.SessionContext(ctx, func(ctx context.Context) error {
+		owner := dccvpin.Owner{Kind: dccvpin.KindSensor, ID: uint16(s.id)}
+		err := dccvpin.CheckContext(ctx, uint16(vpin), owner)
+		if err != nil {
+			return fmt.Errorf("failed to persist sensor %d: %w", s.id, err)
+		}
+
+		sensorCommand := command.NewCommand(command.OpCodeSensorCreate, "%d %d %d", s.id, vpin, pullUp)
+		_, err = station.PersistDefinition(ctx, s.channel, sensorCommand)
+		if err != nil {
+			return fmt.Errorf("failed to persist sensor %d: %w", s.id, err)
+		}
 
-	persisted := false
-	err := s.channel.WriteAndReadOpCode(ctx, sensorCommand.Append(persistCommand), command.OpCodeSuccess, func(cmd *command.Command) error {
-		persisted = true
+		dccvpin.AssignContext(ctx, uint16(vpin), owner)
 		return nil
 	})
-	if err != nil {
-		return err
-	}
-
-	if !persisted {
-		return fmt.Errorf("failed to persist sensor %d: %w", s.id, err)
-	}
-
-	return nil
 }
 
 func (s *Sensor) Active(ctx context.Context) bool {
@@ -192,7 +237,26 @@ func (s *Sensor) Active(ctx context.Context) bool {
 	return sensorState == StateActive
 }
 
+// State returns the sensor's state.
+// While the sensor is masked its state is always StateInactive.
 func (s *Sensor) State(ctx context.Context) (State, error) {
+	status, err := s.Status(ctx)
+	if err != nil {
+		return StateInactive, err
+	}
+
+	return status.State, nil
+}
+
+// Status returns the sensor's state and whether or not it's masked.
+func (s *Sensor) Status(ctx context.Context) (*Status, error) {
+	if s.Masked() {
+		return &Status{
+			State:  StateInactive,
+			Masked: true,
+		}, nil
+	}
+
 	sensorState := StateInactive
 
 	stateCommand := command.NewCommand(StateActive.OpCode(), "")
@@ -210,8 +274,10 @@ func (s *Sensor) State(ctx context.Context) (State, error) {
 		return nil
 	})
 	if err != nil {
-		return sensorState, fmt.Errorf("failed to get sensor %d status: %w", s.id, err)
+		return nil, fmt.Errorf("failed to get sensor %d status: %w", s.id, err)
 	}
 
-	return sensorState, nil
+	return &Status{
+		State: sensorState,
+	}, nil
 }
@@ -0,0 +1,100 @@
+package sensor_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/channel"
+	"github.com/roosterfish/dcc-ex-go/clock"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+	"github.com/roosterfish/dcc-ex-go/sensor"
+	"github.com/roosterfish/dcc-ex-go/simulator"
+)
+
+func TestWaitConsistent(t *testing.T) {
+	sim := simulator.NewSimulator()
+	p := protocol.NewProtocol(sim, &protocol.Config{})
+	defer p.Close()
+
+	mock := clock.NewMock(time.Now())
+	ch := channel.NewChannel(p)
+	ch.SetClock(mock)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sim.SetSensorState(5, sensor.StateActive)
+
+	errC := make(chan error)
+	go func() {
+		errC <- sensor.NewSensor(5, ch).WaitConsistent(ctx, sensor.StateActive, time.Second)
+	}()
+
+	// Wait until the sensor's timer is armed.
+	mock.BlockUntil(1)
+	mock.Advance(999 * time.Millisecond)
+
+	select {
+	case err := <-errC:
+		t.Fatalf("Expected to still wait but got %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	mock.Advance(time.Millisecond)
+
+	select {
+	case err := <-errC:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-ctx.Done():
+		t.Fatal("Timed out waiting for the sensor")
+	}
+}
+
+func TestMask(t *testing.T) {
+	sim := simulator.NewSimulator()
+	p := protocol.NewProtocol(sim, &protocol.Config{})
+	defer p.Close()
+
+	mock := clock.NewMock(time.Now())
+	ch := channel.NewChannel(p)
+	ch.SetClock(mock)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sim.SetSensorState(7, sensor.StateActive)
+	sensor.NewSensor(7, ch).Mask(time.Minute)
+
+	// Sensors derived later share the mask.
+	status, err := sensor.NewSensor(7, ch).Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status.State != sensor.StateInactive || !status.Masked {
+		t.Errorf("Expected masked inactive sensor but got %+v", status)
+	}
+
+	mock.Advance(time.Minute)
+
+	// The unmask is applied asynchronously.
+	for sensor.NewSensor(7, ch).Masked() {
+		select {
+		case <-ctx.Done():
+			t.Fatal("Timed out waiting for the sensor to get unmasked")
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	state, err := sensor.NewSensor(7, ch).State(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if state != sensor.StateActive {
+		t.Errorf("Expected active sensor after unmask but got %c", state)
+	}
+}
@@ -0,0 +1,245 @@
+package simulator
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/clock"
+	"github.com/roosterfish/dcc-ex-go/command"
+	"github.com/roosterfish/dcc-ex-go/sensor"
+)
+
+// Fault is a failure which can be injected into the simulator.
+type Fault uint8
+
+const (
+	// FaultOverload shorts the MAIN track once. Like DCC-EX the track's power is turned off.
+	FaultOverload Fault = iota
+	// FaultMalformedFrame sends a single malformed frame.
+	FaultMalformedFrame
+	// FaultUnresponsive ignores all of the commands until the fault gets cleared.
+	FaultUnresponsive
+	// FaultDisconnect fails all of the reads with ErrDisconnected until the fault gets cleared.
+	FaultDisconnect
+)
+
+// overloadCurrent is the current in milliamps drawn from a shorted track.
+const overloadCurrent = 5000
+
+// trainTick is the interval in which virtual trains advance.
+const trainTick = 20 * time.Millisecond
+
+// ErrDisconnected is returned by Read while FaultDisconnect is injected.
+var ErrDisconnected = errors.New("simulated disconnect")
+
+// TrainConfig describes a virtual train moving along a sequence of blocks.
+type TrainConfig struct {
+	// Cab is the address of the cab driving the train.
+	// The train moves at the speed set for the cab using regular cab commands.
+	Cab uint16
+	// Blocks are the block detection sensors the train passes in order.
+	// The train starts in the first block.
+	Blocks []sensor.ID
+	// BlockDuration is the time it takes to pass a single block at full speed (126).
+	// Slower speeds take proportionally longer.
+	BlockDuration time.Duration
+	// Loop starts over at the first block after passing the last one.
+	// Otherwise the train stays in the last block.
+	Loop bool
+}
+
+// Train is a virtual train moving along blocks.
+type Train struct {
+	simulator *Simulator
+	config    *TrainConfig
+	// block is the index of the occupied block.
+	block int
+	// progress is the fraction of the occupied block the train already passed.
+	progress float64
+	stopC    chan struct{}
+	doneC    chan struct{}
+}
+
+func (f Fault) String() string {
+	switch f {
+	case FaultOverload:
+		return "overload"
+	case FaultMalformedFrame:
+		return "malformed frame"
+	case FaultUnresponsive:
+		return "unresponsive"
+	case FaultDisconnect:
+		return "disconnect"
+	default:
+		return "unknown"
+	}
+}
+
+// SetClock sets the source of time used for sensor trips and virtual trains.
+// Set it before using the simulator.
+func (s *Simulator) SetClock(clock clock.Clock) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.clock = clock
+}
+
+// Clock returns the source of time used by the simulator.
+// It defaults to the real time.
+func (s *Simulator) Clock() clock.Clock {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return clock.Or(s.clock)
+}
+
+// TripSensor activates the given sensor and deactivates it again after the given duration.
+func (s *Simulator) TripSensor(id sensor.ID, duration time.Duration) {
+	s.SetSensorState(id, sensor.StateActive)
+	s.Clock().AfterFunc(duration, func() {
+		s.SetSensorState(id, sensor.StateInactive)
+	})
+}
+
+// InjectFault injects the given fault.
+// FaultOverload and FaultMalformedFrame happen right away, the other faults last until they get cleared.
+func (s *Simulator) InjectFault(fault Fault) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	switch fault {
+	case FaultOverload:
+		s.currents[0] = overloadCurrent
+		s.power["MAIN"] = false
+		s.send(command.NewCommand(command.OpCodeDescribe, "TRACK A POWER OVERLOAD current=%d *", overloadCurrent))
+		s.send(command.NewCommand(command.OpCodePower, "%c %s", command.OpCodePowerOff, "MAIN"))
+	case FaultMalformedFrame:
+		s.egress.WriteString("<>\n")
+		s.readCond.Broadcast()
+	default:
+		s.faults[fault] = true
+		// Wake up blocked readers so they observe the disconnect.
+		s.readCond.Broadcast()
+	}
+}
+
+// ClearFault clears the given fault.
+// Clearing FaultOverload resets the MAIN track's current.
+func (s *Simulator) ClearFault(fault Fault) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if fault == FaultOverload {
+		s.currents[0] = 0
+	}
+
+	delete(s.faults, fault)
+}
+
+// AddTrain places a new virtual train in the first of the given blocks.
+// While the cab drives, the train passes the blocks by activating and deactivating their sensors.
+// Call Stop to remove the train.
+func (s *Simulator) AddTrain(config *TrainConfig) (*Train, error) {
+	if len(config.Blocks) == 0 {
+		return nil, errors.New("failed to add train: no blocks")
+	}
+
+	if config.BlockDuration <= 0 {
+		return nil, fmt.Errorf("failed to add train: invalid block duration %s", config.BlockDuration)
+	}
+
+	t := &Train{
+		simulator: s,
+		config:    config,
+		stopC:     make(chan struct{}),
+		doneC:     make(chan struct{}),
+	}
+
+	s.lock.Lock()
+	s.cab(config.Cab)
+	s.lock.Unlock()
+
+	s.SetSensorState(config.Blocks[0], sensor.StateActive)
+
+	clock := s.Clock()
+	ticker := clock.NewTicker(trainTick)
+
+	go func() {
+		defer close(t.doneC)
+		defer ticker.Stop()
+
+		last := clock.Now()
+		for {
+			select {
+			case <-ticker.C():
+				// Use the actual elapsed time as ticks might be dropped.
+				now := clock.Now()
+				t.advance(now.Sub(last))
+				last = now
+			case <-t.stopC:
+				return
+			}
+		}
+	}()
+
+	return t, nil
+}
+
+// advance moves the train according to its cab's speed.
+func (t *Train) advance(elapsed time.Duration) {
+	s := t.simulator
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.closed {
+		return
+	}
+
+	// The speed byte uses 0 for stop and 1 for emergency stop. Any other speed is offset by one.
+	speedByte := s.cab(t.config.Cab).speedByte & 0x7f
+	if speedByte < 2 {
+		return
+	}
+
+	speed := float64(speedByte - 1)
+	t.progress += float64(elapsed) * speed / 126 / float64(t.config.BlockDuration)
+
+	for t.progress >= 1 {
+		next := t.block + 1
+		if next == len(t.config.Blocks) {
+			if !t.config.Loop {
+				// The end of the line is reached.
+				t.progress = 0
+				return
+			}
+
+			next = 0
+		}
+
+		t.progress--
+		s.setSensorState(t.config.Blocks[t.block], sensor.StateInactive)
+		t.block = next
+		s.setSensorState(t.config.Blocks[t.block], sensor.StateActive)
+	}
+}
+
+// Block returns the block detection sensor of the block the train occupies.
+func (t *Train) Block() sensor.ID {
+	t.simulator.lock.Lock()
+	defer t.simulator.lock.Unlock()
+
+	return t.config.Blocks[t.block]
+}
+
+// Stop removes the train. The block it occupied gets cleared.
+func (t *Train) Stop() {
+	close(t.stopC)
+	<-t.doneC
+
+	t.simulator.lock.Lock()
+	defer t.simulator.lock.Unlock()
+
+	t.simulator.setSensorState(t.config.Blocks[t.block], sensor.StateInactive)
+}
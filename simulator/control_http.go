@@ -0,0 +1,183 @@
+package simulator
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/sensor"
+)
+
+// ControlServer exposes the simulator's control API over HTTP.
+// It allows driving the simulator from automation stacks written in any language:
+//
+//	POST   /sensors/{id}/active
+//	POST   /sensors/{id}/inactive
+//	POST   /sensors/{id}/trip?duration=500ms
+//	POST   /trains {"cab": 3, "blocks": [1, 2, 3], "blockDuration": "2s", "loop": true}
+//	DELETE /trains/{id}
+//	POST   /faults/{fault}
+//	DELETE /faults/{fault}
+//
+// The faults are named overload, malformed, unresponsive and disconnect.
+type ControlServer struct {
+	simulator *Simulator
+	mux       *http.ServeMux
+	trains    map[int]*Train
+	nextTrain int
+	lock      sync.Mutex
+}
+
+type trainRequest struct {
+	Cab           uint16      `json:"cab"`
+	Blocks        []sensor.ID `json:"blocks"`
+	BlockDuration string      `json:"blockDuration"`
+	Loop          bool        `json:"loop"`
+}
+
+type trainResponse struct {
+	ID int `json:"id"`
+}
+
+var faultNames = map[string]Fault{
+	"overload":     FaultOverload,
+	"malformed":    FaultMalformedFrame,
+	"unresponsive": FaultUnresponsive,
+	"disconnect":   FaultDisconnect,
+}
+
+// NewControlServer returns a new server controlling the given simulator.
+func NewControlServer(simulator *Simulator) *ControlServer {
+	c := &ControlServer{
+		simulator: simulator,
+		mux:       http.NewServeMux(),
+		trains:    make(map[int]*Train),
+	}
+
+	c.mux.HandleFunc("POST /sensors/{id}/{action}", c.handleSensor)
+	c.mux.HandleFunc("POST /trains", c.handleAddTrain)
+	c.mux.HandleFunc("DELETE /trains/{id}", c.handleRemoveTrain)
+	c.mux.HandleFunc("POST /faults/{fault}", c.handleFault)
+	c.mux.HandleFunc("DELETE /faults/{fault}", c.handleFault)
+
+	return c
+}
+
+func (c *ControlServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mux.ServeHTTP(w, r)
+}
+
+// Close removes all of the trains added using the server.
+func (c *ControlServer) Close() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for id, train := range c.trains {
+		train.Stop()
+		delete(c.trains, id)
+	}
+}
+
+func (c *ControlServer) handleSensor(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 16)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid sensor id: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	switch r.PathValue("action") {
+	case "active":
+		c.simulator.SetSensorState(sensor.ID(id), sensor.StateActive)
+	case "inactive":
+		c.simulator.SetSensorState(sensor.ID(id), sensor.StateInactive)
+	case "trip":
+		duration, err := time.ParseDuration(r.URL.Query().Get("duration"))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid duration: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		c.simulator.TripSensor(sensor.ID(id), duration)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *ControlServer) handleAddTrain(w http.ResponseWriter, r *http.Request) {
+	request := &trainRequest{}
+	err := json.NewDecoder(r.Body).Decode(request)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid train: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	blockDuration, err := time.ParseDuration(request.BlockDuration)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid block duration: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	train, err := c.simulator.AddTrain(&TrainConfig{
+		Cab:           request.Cab,
+		Blocks:        request.Blocks,
+		BlockDuration: blockDuration,
+		Loop:          request.Loop,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c.lock.Lock()
+	c.nextTrain++
+	id := c.nextTrain
+	c.trains[id] = train
+	c.lock.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(&trainResponse{ID: id})
+}
+
+func (c *ControlServer) handleRemoveTrain(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid train id: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	c.lock.Lock()
+	train, ok := c.trains[id]
+	delete(c.trains, id)
+	c.lock.Unlock()
+
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	train.Stop()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *ControlServer) handleFault(w http.ResponseWriter, r *http.Request) {
+	fault, ok := faultNames[r.PathValue("fault")]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		c.simulator.ClearFault(fault)
+	} else {
+		c.simulator.InjectFault(fault)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
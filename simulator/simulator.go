@@ -0,0 +1,1183 @@
+package simulator
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/roosterfish/dcc-ex-go/clock"
+	"github.com/roosterfish/dcc-ex-go/command"
+	"github.com/roosterfish/dcc-ex-go/sensor"
+)
+
+// Version is the DCC-EX version reported by the simulator.
+const Version = "V-5.4.0"
+
+// supportedCabs is the size of the simulated reminder table of the cabs.
+const supportedCabs = 50
+
+// Current limits of the simulated main track's motor driver in milliamps.
+const (
+	maxCurrent  = 2000
+	tripCurrent = 1500
+)
+
+type sensorDefinition struct {
+	vpin   uint16
+	pullUp uint8
+	state  sensor.State
+}
+
+type turnoutDefinition struct {
+	// kind is either SERVO, DCC or VPIN.
+	kind           string
+	vpin           uint16
+	thrownPosition uint16
+	closedPosition uint16
+	profile        uint8
+	// address and subAddress are the accessory address of DCC turnouts.
+	address    uint16
+	subAddress uint16
+	thrown     bool
+}
+
+type outputDefinition struct {
+	vpin  uint16
+	iFlag uint8
+	high  bool
+}
+
+type rosterEntry struct {
+	name      string
+	functions string
+}
+
+type automation struct {
+	automationType rune
+	description    string
+}
+
+// TurntablePosition is a position of a simulated turntable.
+type TurntablePosition struct {
+	// Angle is given in tenths of a degree.
+	Angle       uint16
+	Description string
+}
+
+type turntable struct {
+	exTurntable bool
+	description string
+	position    uint8
+	positions   []TurntablePosition
+}
+
+type cabState struct {
+	speedByte uint8
+	functMap  uint32
+}
+
+// Simulator emulates a DCC-EX command station.
+// It implements io.ReadWriteCloser and can be used in place of a serial connection
+// to test code built on top of the protocol and channel without any hardware.
+type Simulator struct {
+	sensors     map[uint16]*sensorDefinition
+	turnouts    map[uint16]*turnoutDefinition
+	outputs     map[uint16]*outputDefinition
+	cabs        map[uint16]*cabState
+	analog      map[uint16]int
+	roster      map[uint16]*rosterEntry
+	automations map[uint16]*automation
+	turntables  map[uint16]*turntable
+	power       map[string]bool
+	currents    []int
+	tracks      map[string]string
+
+	freeMemory int
+	faults     map[Fault]bool
+	clock      clock.Clock
+
+	egress   bytes.Buffer
+	ingress  []byte
+	closed   bool
+	lock     sync.Mutex
+	readCond *sync.Cond
+}
+
+// NewSimulator returns a new simulated command station.
+// Like a real command station it starts by broadcasting <@ 0 3 "Ready">.
+func NewSimulator() *Simulator {
+	s := &Simulator{
+		sensors:     make(map[uint16]*sensorDefinition),
+		turnouts:    make(map[uint16]*turnoutDefinition),
+		outputs:     make(map[uint16]*outputDefinition),
+		cabs:        make(map[uint16]*cabState),
+		analog:      make(map[uint16]int),
+		roster:      make(map[uint16]*rosterEntry),
+		automations: make(map[uint16]*automation),
+		turntables:  make(map[uint16]*turntable),
+		faults:      make(map[Fault]bool),
+		freeMemory:  2048,
+		power: map[string]bool{
+			"MAIN": false,
+			"PROG": false,
+		},
+		currents: []int{0, 0},
+		tracks: map[string]string{
+			"A": "MAIN",
+			"B": "PROG",
+		},
+	}
+
+	s.readCond = sync.NewCond(&s.lock)
+	s.send(command.NewCommand(command.OpCodeInfo, "%d %d %q", 0, 3, "Ready"))
+
+	return s
+}
+
+// Read reads the simulator's responses and broadcasts.
+// It blocks until there is data available or the simulator is closed.
+func (s *Simulator) Read(p []byte) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for s.egress.Len() == 0 && !s.closed && !s.faults[FaultDisconnect] {
+		s.readCond.Wait()
+	}
+
+	if s.closed {
+		return 0, io.EOF
+	}
+
+	if s.faults[FaultDisconnect] {
+		return 0, ErrDisconnected
+	}
+
+	return s.egress.Read(p)
+}
+
+// Write passes the given commands to the simulator.
+// Commands can be split across multiple writes.
+func (s *Simulator) Write(p []byte) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.closed {
+		return 0, io.ErrClosedPipe
+	}
+
+	s.ingress = append(s.ingress, p...)
+
+	for {
+		start := bytes.IndexByte(s.ingress, '<')
+		if start == -1 {
+			s.ingress = s.ingress[:0]
+			break
+		}
+
+		end := frameEnd(s.ingress[start:])
+		if end == -1 {
+			// Wait for the rest of the command.
+			s.ingress = s.ingress[start:]
+			break
+		}
+
+		frame := string(s.ingress[start : start+end+1])
+		s.ingress = s.ingress[start+end+1:]
+
+		// An unresponsive command station swallows the commands.
+		if s.faults[FaultUnresponsive] {
+			continue
+		}
+
+		cmd, err := command.NewCommandFromString(frame)
+		if err != nil {
+			s.send(command.NewCommand(command.OpCodeFail, ""))
+			continue
+		}
+
+		s.handle(cmd)
+	}
+
+	return len(p), nil
+}
+
+// frameEnd returns the index of the > ending the frame or -1 if the frame is incomplete.
+// A > within quoted text (e.g. a roster entry's function labels) doesn't end the frame.
+func frameEnd(frame []byte) int {
+	quoted := false
+	escaped := false
+
+	for i, b := range frame {
+		switch {
+		case escaped:
+			escaped = false
+		case b == '\\' && quoted:
+			escaped = true
+		case b == '"':
+			quoted = !quoted
+		case b == '>' && !quoted:
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Close closes the simulator.
+// Any blocked reader returns with io.EOF.
+func (s *Simulator) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.closed {
+		return io.ErrClosedPipe
+	}
+
+	s.closed = true
+	s.readCond.Broadcast()
+
+	return nil
+}
+
+// SetSensorState sets the state of the given sensor and broadcasts the change.
+// The sensor doesn't have to be defined beforehand.
+func (s *Simulator) SetSensorState(id sensor.ID, state sensor.State) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.setSensorState(id, state)
+}
+
+// setSensorState sets the state of the given sensor and broadcasts the change.
+// The caller must hold the lock.
+func (s *Simulator) setSensorState(id sensor.ID, state sensor.State) {
+	definition, ok := s.sensors[uint16(id)]
+	if !ok {
+		definition = &sensorDefinition{
+			vpin: uint16(id),
+		}
+
+		s.sensors[uint16(id)] = definition
+	}
+
+	definition.state = state
+	s.send(command.NewCommand(state.OpCode(), "%d", id))
+}
+
+// SetAnalogValue sets the raw value read from the given analog vpin.
+func (s *Simulator) SetAnalogValue(vpin uint16, value int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.analog[vpin] = value
+}
+
+// SetTrackCurrent sets the current in milliamps drawn from the given track (0 is MAIN, 1 is PROG).
+// It returns an error in case the simulator doesn't have the track.
+func (s *Simulator) SetTrackCurrent(track int, current int) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if track < 0 || track >= len(s.currents) {
+		return fmt.Errorf("failed to set track current: invalid track %d", track)
+	}
+
+	s.currents[track] = current
+	return nil
+}
+
+// AddRosterEntry adds a loco to the roster.
+// The functions are the labels of the functions separated by slashes, e.g. "Lights/*Horn".
+func (s *Simulator) AddRosterEntry(address uint16, name string, functions string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.roster[address] = &rosterEntry{
+		name:      name,
+		functions: functions,
+	}
+}
+
+// AddAutomation adds an EX-RAIL sequence with the given type (R for ROUTE, A for AUTOMATION).
+func (s *Simulator) AddAutomation(id uint16, automationType rune, description string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.automations[id] = &automation{
+		automationType: automationType,
+		description:    description,
+	}
+}
+
+// AddTurntable adds a turntable at position 0 (home) with the given positions.
+// EX-Turntables broadcast the movement before its completion whereas DCC turntables only broadcast the completion.
+func (s *Simulator) AddTurntable(id uint16, exTurntable bool, description string, positions ...TurntablePosition) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.turntables[id] = &turntable{
+		exTurntable: exTurntable,
+		description: description,
+		positions:   positions,
+	}
+}
+
+// SetFreeMemory sets the free memory in bytes reported by <D RAM>.
+func (s *Simulator) SetFreeMemory(bytes int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.freeMemory = bytes
+}
+
+// send queues the given command to be read by the reader.
+// The caller must hold the lock.
+func (s *Simulator) send(cmd *command.Command) {
+	s.egress.Write(cmd.Bytes())
+	s.readCond.Broadcast()
+}
+
+func (s *Simulator) sendSuccess() {
+	s.send(command.NewCommand(command.OpCodeSuccess, ""))
+}
+
+func (s *Simulator) sendFail() {
+	s.send(command.NewCommand(command.OpCodeFail, ""))
+}
+
+// handle dispatches the given command and sends the respective response.
+// The caller must hold the lock.
+func (s *Simulator) handle(cmd *command.Command) {
+	params, err := cmd.ParametersStrings()
+	if err != nil {
+		s.sendFail()
+		return
+	}
+
+	switch cmd.OpCode() {
+	case command.OpCodeStatus:
+		s.handleStatus()
+	case command.OpCodeStationSupportedCabs:
+		s.send(command.NewCommand(command.OpCodeStationSupportedCabs, "%d", supportedCabs))
+	case command.OpCodeEEPROM:
+		s.handleEEPROM()
+	case command.OpCodeEEPROMErase:
+		// Like DCC-EX the definitions are kept until the command station restarts.
+		s.sendSuccess()
+	case command.OpCodePowerOn, command.OpCodePowerOff:
+		s.handlePower(cmd.OpCode(), params)
+	case command.OpCodeSensorCreate:
+		s.handleSensor(params)
+	case sensor.StateActive.OpCode():
+		s.handleSensorStates()
+	case command.OpCodeTurnout:
+		s.handleTurnout(params)
+	case command.OpCodeOutput:
+		s.handleOutput(params)
+	case command.OpCodeCabSpeed:
+		s.handleCabSpeed(params)
+	case command.OpCodeCabFunction:
+		s.handleCabFunction(params)
+	case command.OpCodeEmergencyStop:
+		s.handleEmergencyStop()
+	case command.OpCodeCabForget:
+		s.handleCabForget(params)
+	case command.OpCodeDiagnostic:
+		s.handleDiagnostic(params)
+	case command.OpCodeTrackInfo:
+		s.handleTrackInfo(params)
+	case command.OpCodeTrackManager:
+		s.handleTrackManager(params)
+	case command.OpCodeTurntable:
+		s.handleTurntable(params)
+	case command.OpCodeInfo:
+		s.handleDisplay(params)
+	case command.OpCodeCurrent:
+		// <c "CurrentMAIN" current C "Milli" "0" max "1" trip>
+		s.send(command.NewCommand(command.OpCodeCurrent, "%q %d C %q %q %d %q %d", "CurrentMAIN", s.currents[0], "Milli", "0", maxCurrent, "1", tripCurrent))
+	default:
+		// Like DCC-EX describe the unknown op code before failing.
+		s.send(command.NewCommand(command.OpCodeDescribe, "Opcode=%c params=%d *", cmd.OpCode(), len(params)))
+		s.sendFail()
+	}
+}
+
+func (s *Simulator) handleStatus() {
+	s.send(command.NewCommand(command.OpCodeStatusResponse, "DCC-EX %s / MEGA / SIMULATOR G-simulator", Version))
+
+	powerState := '0'
+	if s.power["MAIN"] || s.power["PROG"] {
+		powerState = '1'
+	}
+
+	s.send(command.NewCommand(command.OpCodePower, "%c", powerState))
+}
+
+// handleEEPROM stores the definitions like DCC-EX, which only reports the number of stored definitions.
+func (s *Simulator) handleEEPROM() {
+	s.send(command.NewCommand(command.OpCodeEEPROMResponse, "%d %d %d", len(s.turnouts), len(s.sensors), len(s.outputs)))
+}
+
+func (s *Simulator) handlePower(state command.OpCode, params []string) {
+	on := state == '1'
+
+	if len(params) == 0 {
+		for track := range s.power {
+			s.power[track] = on
+		}
+
+		s.send(command.NewCommand(command.OpCodePower, "%c", state))
+		return
+	}
+
+	track := params[0]
+	switch track {
+	case "JOIN":
+		s.power["MAIN"] = on
+		s.power["PROG"] = on
+	case "MAIN", "PROG":
+		s.power[track] = on
+	default:
+		s.sendFail()
+		return
+	}
+
+	s.send(command.NewCommand(command.OpCodePower, "%c %s", state, track))
+	s.send(command.NewCommand(command.OpCodeInfo, "%d %d %q", 0, 2, "PWR "+s.powerDescription()))
+}
+
+// powerDescription mimics the LCD power line of DCC-EX.
+func (s *Simulator) powerDescription() string {
+	main, prog := s.power["MAIN"], s.power["PROG"]
+
+	switch {
+	case main && prog:
+		return "On"
+	case main:
+		return "Ab"
+	case prog:
+		return "aB"
+	default:
+		return "Off"
+	}
+}
+
+func (s *Simulator) handleSensor(params []string) {
+	ids := slices.Sorted(maps.Keys(s.sensors))
+
+	switch len(params) {
+	case 0:
+		// List all of the sensor definitions.
+		if len(ids) == 0 {
+			s.sendFail()
+			return
+		}
+
+		for _, id := range ids {
+			definition := s.sensors[id]
+			s.send(command.NewCommand(sensor.StateActive.OpCode(), "%d %d %d", id, definition.vpin, definition.pullUp))
+		}
+	case 1:
+		// Delete the sensor.
+		id, err := parseUint16(params[0])
+		if err != nil || s.sensors[id] == nil {
+			s.sendFail()
+			return
+		}
+
+		delete(s.sensors, id)
+		s.sendSuccess()
+	case 3:
+		// Create the sensor.
+		values, err := parseUint16s(params)
+		if err != nil || values[2] > 1 {
+			s.sendFail()
+			return
+		}
+
+		s.sensors[values[0]] = &sensorDefinition{
+			vpin:   values[1],
+			pullUp: uint8(values[2]),
+			state:  sensor.StateInactive,
+		}
+
+		s.sendSuccess()
+	default:
+		s.sendFail()
+	}
+}
+
+func (s *Simulator) handleSensorStates() {
+	for _, id := range slices.Sorted(maps.Keys(s.sensors)) {
+		s.send(command.NewCommand(s.sensors[id].state.OpCode(), "%d", id))
+	}
+}
+
+func (s *Simulator) sendTurnout(id uint16) {
+	definition := s.turnouts[id]
+
+	switch definition.kind {
+	case "DCC":
+		s.send(command.NewCommand(command.OpCodeTurnoutResponse, "%d DCC %d %d %d", id, definition.address, definition.subAddress, boolToInt(definition.thrown)))
+	case "VPIN":
+		s.send(command.NewCommand(command.OpCodeTurnoutResponse, "%d VPIN %d %d", id, definition.vpin, boolToInt(definition.thrown)))
+	default:
+		s.send(command.NewCommand(command.OpCodeTurnoutResponse, "%d SERVO %d %d %d %d %d", id, definition.vpin, definition.thrownPosition, definition.closedPosition, definition.profile, boolToInt(definition.thrown)))
+	}
+}
+
+func (s *Simulator) handleTurnout(params []string) {
+	switch len(params) {
+	case 0:
+		// List all of the turnouts.
+		ids := slices.Sorted(maps.Keys(s.turnouts))
+		if len(ids) == 0 {
+			s.sendFail()
+			return
+		}
+
+		for _, id := range ids {
+			s.sendTurnout(id)
+		}
+	case 1:
+		// Delete the turnout.
+		id, err := parseUint16(params[0])
+		if err != nil || s.turnouts[id] == nil {
+			s.sendFail()
+			return
+		}
+
+		delete(s.turnouts, id)
+		s.sendSuccess()
+	case 2:
+		// Change or examine the turnout's state.
+		id, err := parseUint16(params[0])
+		if err != nil || s.turnouts[id] == nil {
+			s.sendFail()
+			return
+		}
+
+		definition := s.turnouts[id]
+
+		var thrown bool
+		switch params[1] {
+		case "T", "1":
+			thrown = true
+		case "C", "0":
+			thrown = false
+		case "X":
+			s.sendTurnout(id)
+			return
+		default:
+			s.sendFail()
+			return
+		}
+
+		// There isn't a broadcast sent if the turnout already has the requested state.
+		if definition.thrown == thrown {
+			return
+		}
+
+		definition.thrown = thrown
+		s.send(command.NewCommand(command.OpCodeTurnoutResponse, "%d %d", id, boolToInt(thrown)))
+	case 3, 4:
+		// Create the DCC turnout (<T id DCC address subaddress> or <T id DCC linearaddress>) or the VPIN turnout.
+		values, err := parseUint16s(append([]string{params[0]}, params[2:]...))
+		if err != nil {
+			s.sendFail()
+			return
+		}
+
+		definition := &turnoutDefinition{
+			kind: params[1],
+		}
+
+		switch {
+		case params[1] == "VPIN" && len(values) == 2:
+			definition.vpin = values[1]
+		case params[1] == "DCC" && len(values) == 2 && values[1] >= 1 && values[1] <= 2044:
+			definition.address = (values[1]-1)/4 + 1
+			definition.subAddress = (values[1] - 1) % 4
+		case params[1] == "DCC" && len(values) == 3 && values[1] <= 511 && values[2] <= 3:
+			definition.address = values[1]
+			definition.subAddress = values[2]
+		default:
+			s.sendFail()
+			return
+		}
+
+		s.turnouts[values[0]] = definition
+		s.sendSuccess()
+	case 6:
+		// Create the servo turnout.
+		if params[1] != "SERVO" {
+			s.sendFail()
+			return
+		}
+
+		values, err := parseUint16s(append([]string{params[0]}, params[2:]...))
+		if err != nil {
+			s.sendFail()
+			return
+		}
+
+		s.turnouts[values[0]] = &turnoutDefinition{
+			kind:           "SERVO",
+			vpin:           values[1],
+			thrownPosition: values[2],
+			closedPosition: values[3],
+			profile:        uint8(values[4]),
+		}
+
+		s.sendSuccess()
+	default:
+		s.sendFail()
+	}
+}
+
+func (s *Simulator) handleOutput(params []string) {
+	switch len(params) {
+	case 0:
+		// List all of the outputs.
+		ids := slices.Sorted(maps.Keys(s.outputs))
+		if len(ids) == 0 {
+			s.sendFail()
+			return
+		}
+
+		for _, id := range ids {
+			definition := s.outputs[id]
+			s.send(command.NewCommand(command.OpCodeOutputResponse, "%d %d %d %d", id, definition.vpin, definition.iFlag, boolToInt(definition.high)))
+		}
+	case 1:
+		// Delete the output.
+		id, err := parseUint16(params[0])
+		if err != nil || s.outputs[id] == nil {
+			s.sendFail()
+			return
+		}
+
+		delete(s.outputs, id)
+		s.sendSuccess()
+	case 2:
+		// Set the output's state.
+		id, err := parseUint16(params[0])
+		if err != nil || s.outputs[id] == nil || (params[1] != "0" && params[1] != "1") {
+			s.sendFail()
+			return
+		}
+
+		s.outputs[id].high = params[1] == "1"
+		s.send(command.NewCommand(command.OpCodeOutputResponse, "%d %s", id, params[1]))
+	case 3:
+		// Create the output.
+		values, err := parseUint16s(params)
+		if err != nil {
+			s.sendFail()
+			return
+		}
+
+		s.outputs[values[0]] = &outputDefinition{
+			vpin:  values[1],
+			iFlag: uint8(values[2]),
+		}
+
+		s.sendSuccess()
+	default:
+		s.sendFail()
+	}
+}
+
+// cab returns the state of the cab with the given address.
+// The cab gets registered in case it wasn't known yet.
+func (s *Simulator) cab(address uint16) *cabState {
+	state, ok := s.cabs[address]
+	if !ok {
+		// A cab is stopped and facing forward by default.
+		state = &cabState{
+			speedByte: 128,
+		}
+
+		s.cabs[address] = state
+	}
+
+	return state
+}
+
+func (s *Simulator) sendCab(address uint16) {
+	state := s.cabs[address]
+	s.send(command.NewCommand(command.OpCodeCabResponse, "%d %d %d %d", address, 0, state.speedByte, state.functMap))
+}
+
+func (s *Simulator) handleCabSpeed(params []string) {
+	switch len(params) {
+	case 1:
+		// Report the cab's state.
+		address, err := parseUint16(params[0])
+		if err != nil {
+			s.sendFail()
+			return
+		}
+
+		s.cab(address)
+		s.sendCab(address)
+	case 3:
+		address, err := parseUint16(params[0])
+		if err != nil {
+			s.sendFail()
+			return
+		}
+
+		speed, err := strconv.ParseInt(params[1], 10, 8)
+		if err != nil || speed < -1 {
+			s.sendFail()
+			return
+		}
+
+		if params[2] != "0" && params[2] != "1" {
+			s.sendFail()
+			return
+		}
+
+		// Like DCC-EX the speed 127 is treated as full speed 126 as the speed byte only has room for 0-127.
+		speed = min(speed, 126)
+
+		// The speed byte uses 0 for stop and 1 for emergency stop.
+		// Any other speed is offset by one. Forward movement sets the MSB.
+		var speedByte uint8
+		switch speed {
+		case -1:
+			speedByte = 1
+		case 0:
+			speedByte = 0
+		default:
+			speedByte = uint8(speed) + 1
+		}
+
+		if params[2] == "1" {
+			speedByte |= 0x80
+		}
+
+		state := s.cab(address)
+
+		// There isn't a broadcast sent if the cab already has the requested speed and direction.
+		if state.speedByte == speedByte {
+			return
+		}
+
+		state.speedByte = speedByte
+		s.sendCab(address)
+	default:
+		s.sendFail()
+	}
+}
+
+func (s *Simulator) handleCabFunction(params []string) {
+	if len(params) != 3 {
+		s.sendFail()
+		return
+	}
+
+	address, err := parseUint16(params[0])
+	if err != nil {
+		s.sendFail()
+		return
+	}
+
+	funct, err := strconv.ParseUint(params[1], 10, 8)
+	if err != nil || funct > command.MaxCabFunction || (params[2] != "0" && params[2] != "1") {
+		s.sendFail()
+		return
+	}
+
+	state := s.cab(address)
+
+	// Like DCC-EX only F0-F28 are remembered and reported.
+	if funct > 28 {
+		return
+	}
+	functMap := state.functMap &^ (1 << funct)
+	if params[2] == "1" {
+		functMap |= 1 << funct
+	}
+
+	// There isn't a broadcast sent if the function already has the requested state.
+	if state.functMap == functMap {
+		return
+	}
+
+	state.functMap = functMap
+	s.sendCab(address)
+}
+
+func (s *Simulator) handleDiagnostic(params []string) {
+	if len(params) == 1 && params[0] == "RAM" {
+		s.send(command.NewCommand(command.OpCodeDescribe, "Free memory=%d *", s.freeMemory))
+		return
+	}
+
+	if len(params) == 1 && params[0] == "RESET" {
+		s.handleReset()
+		return
+	}
+
+	if len(params) == 1 && params[0] == "CABS" {
+		s.handleDiagnosticCabs()
+		return
+	}
+
+	// Moving a servo isn't answered.
+	if len(params) >= 3 && params[0] == "SERVO" {
+		return
+	}
+
+	// Enabling the diagnostic messages isn't answered.
+	if len(params) == 2 && (params[1] == "ON" || params[1] == "OFF") && slices.Contains([]string{"ACK", "CMD", "WIFI", "ETHERNET", "WIT", "LCN"}, params[0]) {
+		return
+	}
+
+	if len(params) == 2 && params[0] == "ANIN" {
+		vpin, err := parseUint16(params[1])
+		if err != nil {
+			s.sendFail()
+			return
+		}
+
+		s.send(command.NewCommand(command.OpCodeDescribe, "VPIN=%d value=%d *", vpin, s.analog[vpin]))
+		return
+	}
+
+	s.sendFail()
+}
+
+// handleCabForget removes the given cab or all of the cabs from the reminders. It isn't answered.
+func (s *Simulator) handleCabForget(params []string) {
+	switch len(params) {
+	case 0:
+		clear(s.cabs)
+	case 1:
+		address, err := parseUint16(params[0])
+		if err != nil {
+			s.sendFail()
+			return
+		}
+
+		delete(s.cabs, address)
+	default:
+		s.sendFail()
+	}
+}
+
+// handleEmergencyStop stops all of the cabs using the emergency stop speed while preserving their direction.
+func (s *Simulator) handleEmergencyStop() {
+	for _, address := range slices.Sorted(maps.Keys(s.cabs)) {
+		state := s.cabs[address]
+
+		speedByte := 1 | state.speedByte&0x80
+		if state.speedByte == speedByte {
+			continue
+		}
+
+		state.speedByte = speedByte
+		s.sendCab(address)
+	}
+}
+
+// handleReset restarts the simulated command station which powers off the tracks and forgets the cabs.
+// The definitions are kept as if they were stored in the EEPROM.
+func (s *Simulator) handleReset() {
+	for track := range s.power {
+		s.power[track] = false
+	}
+
+	clear(s.cabs)
+	s.send(command.NewCommand(command.OpCodeInfo, "%d %d %q", 0, 3, "Ready"))
+}
+
+// handleDiagnosticCabs lists the cabs like DCC-EX using a single multiline diagnostic message.
+func (s *Simulator) handleDiagnosticCabs() {
+	message := ""
+	for _, address := range slices.Sorted(maps.Keys(s.cabs)) {
+		direction := 'R'
+		if s.cabs[address].speedByte&0x80 != 0 {
+			direction = 'F'
+		}
+
+		message += fmt.Sprintf("cab=%d, speed=%d, dir=%c \n", address, s.cabs[address].speedByte&0x7f, direction)
+	}
+
+	message += fmt.Sprintf("Used=%d, max=%d\n", len(s.cabs), supportedCabs)
+	s.send(command.NewCommand(command.OpCodeDescribe, "\n%s*", message))
+}
+
+func (s *Simulator) handleTrackInfo(params []string) {
+	if len(params) == 1 && params[0] == "I" {
+		format := strings.TrimPrefix(strings.Repeat(" %d", len(s.currents)), " ")
+		args := []any{}
+		for _, current := range s.currents {
+			args = append(args, current)
+		}
+
+		s.send(command.NewMultiCommand(command.OpCodeTrackCurrentsResponse, format, args...))
+		return
+	}
+
+	if len(params) >= 1 && params[0] == "R" {
+		s.handleRoster(params[1:])
+		return
+	}
+
+	if len(params) >= 1 && params[0] == "A" {
+		s.handleAutomations(params[1:])
+		return
+	}
+
+	if len(params) >= 1 && params[0] == "O" {
+		s.handleTurntables(params[1:])
+		return
+	}
+
+	if len(params) == 2 && params[0] == "P" {
+		s.handleTurntablePositions(params[1])
+		return
+	}
+
+	s.sendFail()
+}
+
+// handleDisplay shows the text on the simulated display which broadcasts it like EX-RAIL's SCREEN.
+func (s *Simulator) handleDisplay(params []string) {
+	if len(params) != 3 {
+		s.sendFail()
+		return
+	}
+
+	display, err := strconv.ParseUint(params[0], 10, 8)
+	if err != nil {
+		s.sendFail()
+		return
+	}
+
+	line, err := strconv.ParseUint(params[1], 10, 8)
+	if err != nil {
+		s.sendFail()
+		return
+	}
+
+	s.send(command.NewCommand(command.OpCodeInfo, "%d %d %q", display, line, params[2]))
+}
+
+func (s *Simulator) handleTurntables(params []string) {
+	if len(params) == 0 {
+		ids := slices.Sorted(maps.Keys(s.turntables))
+		format := strings.TrimPrefix(strings.Repeat(" %d", len(ids)), " ")
+		args := []any{}
+		for _, id := range ids {
+			args = append(args, id)
+		}
+
+		s.send(command.NewMultiCommand(command.OpCodeTurntablesResponse, format, args...))
+		return
+	}
+
+	id, err := parseUint16(params[0])
+	if err != nil {
+		s.sendFail()
+		return
+	}
+
+	// Like DCC-EX unknown turntables are answered with the type X.
+	t, ok := s.turntables[id]
+	if !ok {
+		s.send(command.NewMultiCommand(command.OpCodeTurntablesResponse, "%d X", id))
+		return
+	}
+
+	turntableType := 0
+	if t.exTurntable {
+		turntableType = 1
+	}
+
+	s.send(command.NewMultiCommand(command.OpCodeTurntablesResponse, "%d %d %d %d %q", id, turntableType, t.position, len(t.positions), t.description))
+}
+
+func (s *Simulator) handleTurntablePositions(param string) {
+	id, err := parseUint16(param)
+	if err != nil {
+		s.sendFail()
+		return
+	}
+
+	t, ok := s.turntables[id]
+	if !ok {
+		s.send(command.NewMultiCommand(command.OpCodeTurntablePositionsResponse, "%d X", id))
+		return
+	}
+
+	for index, position := range t.positions {
+		s.send(command.NewMultiCommand(command.OpCodeTurntablePositionsResponse, "%d %d %d %q", id, index, position.Angle, position.Description))
+	}
+}
+
+// handleTurntable rotates the turntable to the given position.
+// Like DCC-EX the movement is broadcasted as <I id position 1> and its completion as <I id position 0>.
+func (s *Simulator) handleTurntable(params []string) {
+	if len(params) < 2 {
+		s.sendFail()
+		return
+	}
+
+	id, err := parseUint16(params[0])
+	if err != nil {
+		s.sendFail()
+		return
+	}
+
+	position, err := strconv.ParseUint(params[1], 10, 8)
+	t, ok := s.turntables[id]
+	if err != nil || !ok || int(position) >= len(t.positions) {
+		s.sendFail()
+		return
+	}
+
+	t.position = uint8(position)
+	if t.exTurntable {
+		s.send(command.NewCommand(command.OpCodeTurntable, "%d %d %d", id, position, 1))
+	}
+
+	s.send(command.NewCommand(command.OpCodeTurntable, "%d %d %d", id, position, 0))
+}
+
+func (s *Simulator) handleAutomations(params []string) {
+	if len(params) == 0 {
+		ids := slices.Sorted(maps.Keys(s.automations))
+		format := strings.TrimPrefix(strings.Repeat(" %d", len(ids)), " ")
+		args := []any{}
+		for _, id := range ids {
+			args = append(args, id)
+		}
+
+		s.send(command.NewMultiCommand(command.OpCodeAutomationsResponse, format, args...))
+		return
+	}
+
+	id, err := parseUint16(params[0])
+	if err != nil {
+		s.sendFail()
+		return
+	}
+
+	// Like DCC-EX unknown sequences are answered with the type X.
+	a, ok := s.automations[id]
+	if !ok {
+		s.send(command.NewMultiCommand(command.OpCodeAutomationsResponse, "%d X", id))
+		return
+	}
+
+	s.send(command.NewMultiCommand(command.OpCodeAutomationsResponse, "%d %c %q", id, a.automationType, a.description))
+}
+
+func (s *Simulator) handleRoster(params []string) {
+	if len(params) == 0 {
+		addresses := slices.Sorted(maps.Keys(s.roster))
+		format := strings.TrimPrefix(strings.Repeat(" %d", len(addresses)), " ")
+		args := []any{}
+		for _, address := range addresses {
+			args = append(args, address)
+		}
+
+		s.send(command.NewMultiCommand(command.OpCodeRosterResponse, format, args...))
+		return
+	}
+
+	address, err := parseUint16(params[0])
+	if err != nil {
+		s.sendFail()
+		return
+	}
+
+	// Like DCC-EX unknown entries are answered with blank strings.
+	entry, ok := s.roster[address]
+	if !ok {
+		entry = &rosterEntry{name: " ", functions: " "}
+	}
+
+	s.send(command.NewMultiCommand(command.OpCodeRosterResponse, "%d %q %q", address, entry.name, entry.functions))
+}
+
+func (s *Simulator) sendTrack(track string) {
+	s.send(command.NewCommand(command.OpCodeTrackManager, "%s %s", track, s.tracks[track]))
+}
+
+func (s *Simulator) handleTrackManager(params []string) {
+	switch len(params) {
+	case 0:
+		for _, track := range slices.Sorted(maps.Keys(s.tracks)) {
+			s.sendTrack(track)
+		}
+	case 2, 3:
+		track := strings.ToUpper(params[0])
+		if len(track) != 1 || track[0] < 'A' || track[0] > 'H' {
+			s.sendFail()
+			return
+		}
+
+		mode := strings.ToUpper(params[1])
+		switch {
+		case len(params) == 2 && (mode == "MAIN" || mode == "PROG" || mode == "NONE"):
+			s.tracks[track] = mode
+		case len(params) == 3 && (mode == "DC" || mode == "DCX"):
+			cab, err := parseUint16(params[2])
+			if err != nil {
+				s.sendFail()
+				return
+			}
+
+			s.tracks[track] = fmt.Sprintf("%s %d", mode, cab)
+		default:
+			s.sendFail()
+			return
+		}
+
+		// Like DCC-EX broadcast the changed assignment.
+		s.sendTrack(track)
+	default:
+		s.sendFail()
+	}
+}
+
+func parseUint16(value string) (uint16, error) {
+	parsed, err := strconv.ParseUint(value, 10, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q: %w", value, err)
+	}
+
+	return uint16(parsed), nil
+}
+
+func parseUint16s(values []string) ([]uint16, error) {
+	parsed := make([]uint16, 0, len(values))
+	for _, value := range values {
+		v, err := parseUint16(value)
+		if err != nil {
+			return nil, err
+		}
+
+		parsed = append(parsed, v)
+	}
+
+	return parsed, nil
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+
+	return 0
+}
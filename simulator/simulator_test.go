@@ -0,0 +1,303 @@
+package simulator
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/cab"
+	"github.com/roosterfish/dcc-ex-go/channel"
+	"github.com/roosterfish/dcc-ex-go/clock"
+	"github.com/roosterfish/dcc-ex-go/output"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+	"github.com/roosterfish/dcc-ex-go/sensor"
+	"github.com/roosterfish/dcc-ex-go/station"
+	"github.com/roosterfish/dcc-ex-go/turnout"
+)
+
+func newTestChannel(t *testing.T) (*Simulator, *channel.Channel) {
+	t.Helper()
+
+	sim := NewSimulator()
+	p := protocol.NewProtocol(sim, &protocol.Config{})
+	t.Cleanup(func() {
+		_ = p.Close()
+	})
+
+	return sim, channel.NewChannel(p)
+}
+
+func TestSimulatorStation(t *testing.T) {
+	_, ch := newTestChannel(t)
+	commandStation := station.NewStation(ch)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	status, err := commandStation.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status.Version != Version {
+		t.Errorf("Expected version %q but got %q", Version, status.Version)
+	}
+
+	err = commandStation.Power(ctx, station.PowerOn)
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = commandStation.PowerTrack(ctx, station.PowerOff, station.TrackProg)
+	if err != nil {
+		t.Error(err)
+	}
+
+	supportedCabs, err := commandStation.SupportedCabs(ctx)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if supportedCabs != 50 {
+		t.Errorf("Expected 50 supported cabs but got %d", supportedCabs)
+	}
+}
+
+func TestSimulatorTurnout(t *testing.T) {
+	_, ch := newTestChannel(t)
+	servo := turnout.NewTurnoutServo(1, ch)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := servo.Persist(ctx, 100, 400, 200, turnout.ProfileSlow)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = servo.Throw(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statuses, err := turnout.ExamineAll(ctx, ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status, ok := statuses[1]
+	if !ok {
+		t.Fatal("Turnout 1 is missing")
+	}
+
+	if status.State != turnout.StateThrown {
+		t.Errorf("Expected state %c but got %c", turnout.StateThrown, status.State)
+	}
+
+	if status.VPin != 100 || status.ThrownPosition != 400 || status.ClosedPosition != 200 || status.Profile != turnout.ProfileSlow {
+		t.Errorf("Unexpected turnout status %+v", status)
+	}
+}
+
+func TestSimulatorOutput(t *testing.T) {
+	_, ch := newTestChannel(t)
+	out := output.NewOutput(2, ch)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := out.Persist(ctx, 30, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = out.High(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := out.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status.VPin != 30 || status.State != output.High {
+		t.Errorf("Unexpected output status %+v", status)
+	}
+}
+
+func TestSimulatorCab(t *testing.T) {
+	_, ch := newTestChannel(t)
+	loc := cab.NewCab(3, ch)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := loc.Speed(ctx, 70, cab.DirectionForward)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := loc.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status.SpeedByte != 128+71 {
+		t.Errorf("Expected speed byte %d but got %d", 128+71, status.SpeedByte)
+	}
+
+	// Setting the same speed again must not wait for a broadcast.
+	err = loc.Speed(ctx, 70, cab.DirectionForward)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSimulatorSensor(t *testing.T) {
+	sim, ch := newTestChannel(t)
+	block := sensor.NewSensor(31, ch)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := block.Persist(ctx, 31, sensor.PullUpOn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if block.Active(ctx) {
+		t.Error("Expected sensor to be inactive")
+	}
+
+	sim.SetSensorState(31, sensor.StateActive)
+
+	if !block.Active(ctx) {
+		t.Error("Expected sensor to be active")
+	}
+}
+
+func TestSimulatorTrain(t *testing.T) {
+	sim, ch := newTestChannel(t)
+
+	mock := clock.NewMock(time.Now())
+	sim.SetClock(mock)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	train, err := sim.AddTrain(&TrainConfig{
+		Cab:           3,
+		Blocks:        []sensor.ID{1, 2, 3},
+		BlockDuration: time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer train.Stop()
+
+	err = cab.NewCab(3, ch).Speed(ctx, 63, cab.DirectionForward)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// At half speed passing a block takes two seconds.
+	mock.BlockUntil(1)
+	mock.Advance(2500 * time.Millisecond)
+
+	for train.Block() != 2 {
+		select {
+		case <-ctx.Done():
+			t.Fatal("Timed out waiting for the train to enter block 2")
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	if sensor.NewSensor(1, ch).Active(ctx) || !sensor.NewSensor(2, ch).Active(ctx) {
+		t.Error("Expected only block 2 to be occupied")
+	}
+}
+
+func TestControlServer(t *testing.T) {
+	sim, ch := newTestChannel(t)
+
+	server := httptest.NewServer(NewControlServer(sim))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	resp, err := http.Post(server.URL+"/sensors/7/active", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = resp.Body.Close()
+
+	if !sensor.NewSensor(7, ch).Active(ctx) {
+		t.Error("Expected sensor to be active")
+	}
+
+	resp, err = http.Post(server.URL+"/faults/overload", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = resp.Body.Close()
+
+	currents, err := station.NewStation(ch).TrackCurrents(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if currents[0] != overloadCurrent {
+		t.Errorf("Expected current %d but got %d", overloadCurrent, currents[0])
+	}
+
+	resp, err = http.Post(server.URL+"/faults/meltdown", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status %d but got %d", http.StatusNotFound, resp.StatusCode)
+	}
+}
+
+func TestSetTrackCurrent(t *testing.T) {
+	sim := NewSimulator()
+
+	err := sim.SetTrackCurrent(1, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, track := range []int{-1, 2} {
+		err = sim.SetTrackCurrent(track, 30)
+		if err == nil {
+			t.Errorf("Expected setting the current of track %d to fail", track)
+		}
+	}
+}
+
+func TestFrameEnd(t *testing.T) {
+	for frame, expected := range map[string]int{
+		"<s>":                     2,
+		`<J R 1 "A>B">`:           12,
+		`<J R 1 "A\">B">`:         14,
+		`<J R 1 "A>B`:             -1,
+		"<s":                      -1,
+		`<J R 1 "A>B"><s>`:        12,
+		`<J R 1 "A" "B>C" "D>E">`: 22,
+	} {
+		end := frameEnd([]byte(frame))
+		if end != expected {
+			t.Errorf("Expected the end of %q at %d but got %d", frame, expected, end)
+		}
+	}
+}
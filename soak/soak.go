@@ -0,0 +1,434 @@
+package soak
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/cab"
+	"github.com/roosterfish/dcc-ex-go/channel"
+	"github.com/roosterfish/dcc-ex-go/clock"
+	"github.com/roosterfish/dcc-ex-go/output"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+	"github.com/roosterfish/dcc-ex-go/sensor"
+	"github.com/roosterfish/dcc-ex-go/simulator"
+	"github.com/roosterfish/dcc-ex-go/turnout"
+)
+
+// Config configures a soak run.
+type Config struct {
+	// Duration is the duration of the run.
+	Duration time.Duration
+	// Seed seeds the randomized operations. Runs using the same seed perform the same operations per worker.
+	Seed int64
+	// Workers is the number of workers performing operations concurrently.
+	// Every worker owns its own cab, turnout, output and sensor.
+	Workers int
+	// OpTimeout is the duration after which an operation is considered to be deadlocked.
+	OpTimeout time.Duration
+	// CheckInterval is the interval in which the number of goroutines is sampled.
+	CheckInterval time.Duration
+	// MaxGoroutineGrowth is the number of goroutines the run may grow beyond the number observed after setup.
+	MaxGoroutineGrowth int
+	// Clock is the source of time used for the run's duration, the goroutine samples and the report.
+	// It defaults to the real time.
+	Clock clock.Clock
+}
+
+// ViolationType is the invariant which got violated.
+type ViolationType uint8
+
+const (
+	// ViolationDeadlock means an operation didn't return within the OpTimeout.
+	ViolationDeadlock ViolationType = iota
+	// ViolationError means an operation failed.
+	ViolationError
+	// ViolationMirror means the state read back from the simulator differs from the expected state.
+	ViolationMirror
+	// ViolationGoroutines means the number of goroutines grew beyond MaxGoroutineGrowth
+	// or goroutines were left behind after the run.
+	ViolationGoroutines
+)
+
+// Violation is a single violated invariant.
+type Violation struct {
+	Type ViolationType
+	// Worker is the worker which observed the violation. It's -1 for goroutine violations.
+	Worker    int
+	Operation string
+	Err       error
+	Time      time.Time
+}
+
+// Report summarizes a soak run.
+type Report struct {
+	Operations int
+	Violations []*Violation
+	// Goroutines is the number of goroutines observed after setup.
+	Goroutines int
+	// MaxGoroutines is the highest number of goroutines sampled during the run.
+	MaxGoroutines int
+	Duration      time.Duration
+}
+
+// errMismatch is returned by verify in case the state differs from the mirror.
+var errMismatch = errors.New("state mismatch")
+
+// mirror is the state a worker expects the simulator to have.
+type mirror struct {
+	speed     cab.Speed
+	direction cab.Direction
+	thrown    bool
+	high      bool
+	active    bool
+}
+
+type worker struct {
+	id      int
+	rand    *rand.Rand
+	sim     *simulator.Simulator
+	cab     *cab.Cab
+	turnout *turnout.TurnoutServo
+	output  *output.Output
+	sensor  *sensor.Sensor
+	mirror  mirror
+}
+
+// operation performs a random change and updates the mirror accordingly.
+type operation struct {
+	name string
+	f    func(ctx context.Context, w *worker) error
+}
+
+var operations = []operation{
+	{"cab speed", func(ctx context.Context, w *worker) error {
+		speed := cab.Speed(w.rand.Intn(127))
+		direction := cab.Direction(w.rand.Intn(2))
+
+		err := w.cab.Speed(ctx, speed, direction)
+		if err != nil {
+			return err
+		}
+
+		w.mirror.speed, w.mirror.direction = speed, direction
+		return nil
+	}},
+	{"turnout", func(ctx context.Context, w *worker) error {
+		thrown := w.rand.Intn(2) == 1
+
+		var err error
+		if thrown {
+			err = w.turnout.Throw(ctx)
+		} else {
+			err = w.turnout.Close(ctx)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		w.mirror.thrown = thrown
+		return nil
+	}},
+	{"output", func(ctx context.Context, w *worker) error {
+		high := w.rand.Intn(2) == 1
+
+		var err error
+		if high {
+			err = w.output.High(ctx)
+		} else {
+			err = w.output.Low(ctx)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		w.mirror.high = high
+		return nil
+	}},
+	{"sensor", func(ctx context.Context, w *worker) error {
+		active := w.rand.Intn(2) == 1
+
+		state := sensor.StateInactive
+		if active {
+			state = sensor.StateActive
+		}
+
+		w.sim.SetSensorState(w.sensor.ID(), state)
+		w.mirror.active = active
+		return nil
+	}},
+}
+
+func (t ViolationType) String() string {
+	switch t {
+	case ViolationDeadlock:
+		return "deadlock"
+	case ViolationError:
+		return "error"
+	case ViolationMirror:
+		return "mirror mismatch"
+	case ViolationGoroutines:
+		return "goroutine growth"
+	default:
+		return "unknown"
+	}
+}
+
+func (v *Violation) String() string {
+	return fmt.Sprintf("%s: worker %d %s: %v", v.Type, v.Worker, v.Operation, v.Err)
+}
+
+// Failed returns true if any invariant got violated.
+func (r *Report) Failed() bool {
+	return len(r.Violations) > 0
+}
+
+// NewDefaultConfig returns a config running for an hour.
+func NewDefaultConfig() *Config {
+	return &Config{
+		Duration:           time.Hour,
+		Seed:               time.Now().UnixNano(),
+		Workers:            8,
+		OpTimeout:          10 * time.Second,
+		CheckInterval:      time.Second,
+		MaxGoroutineGrowth: 64,
+	}
+}
+
+// verify reads the state back from the simulator and compares it with the mirror.
+func (w *worker) verify(ctx context.Context) error {
+	status, err := w.cab.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	speed, direction := status.SpeedDirection()
+	if speed != w.mirror.speed || direction != w.mirror.direction {
+		return fmt.Errorf("%w: cab: expected speed %d direction %d but got %d %d", errMismatch, w.mirror.speed, w.mirror.direction, speed, direction)
+	}
+
+	turnoutStatus, err := w.turnout.Examine(ctx)
+	if err != nil {
+		return err
+	}
+
+	if (turnoutStatus.State == turnout.StateThrown) != w.mirror.thrown {
+		return fmt.Errorf("%w: turnout: expected thrown %t but got state %c", errMismatch, w.mirror.thrown, turnoutStatus.State)
+	}
+
+	outputStatus, err := w.output.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	if (outputStatus.State == output.High) != w.mirror.high {
+		return fmt.Errorf("%w: output: expected high %t but got state %c", errMismatch, w.mirror.high, outputStatus.State)
+	}
+
+	if w.sensor.Active(ctx) != w.mirror.active {
+		return fmt.Errorf("%w: sensor: expected active %t", errMismatch, w.mirror.active)
+	}
+
+	return nil
+}
+
+// Run performs randomized operations against a simulator until the configured duration passed or
+// the context is cancelled. After every operation the state is read back and compared with the expected state.
+// Violated invariants are collected in the report, the returned error is only set if the setup failed.
+func Run(ctx context.Context, config *Config) (*Report, error) {
+	source := clock.Or(config.Clock)
+	goroutinesBefore := runtime.NumGoroutine()
+	start := source.Now()
+
+	sim := simulator.NewSimulator()
+	p := protocol.NewProtocol(sim, &protocol.Config{})
+	ch := channel.NewChannel(p)
+
+	workers := make([]*worker, config.Workers)
+	for i := range workers {
+		w := &worker{
+			id:      i,
+			rand:    rand.New(rand.NewSource(config.Seed + int64(i))),
+			sim:     sim,
+			cab:     cab.NewCab(cab.Address(10+i), ch),
+			turnout: turnout.NewTurnoutServo(turnout.ID(100+i), ch),
+			output:  output.NewOutput(output.ID(200+i), ch),
+			sensor:  sensor.NewSensor(sensor.ID(300+i), ch),
+		}
+
+		err := setup(ctx, w, config.OpTimeout)
+		if err != nil {
+			_ = p.Close()
+			return nil, fmt.Errorf("failed to set up worker %d: %w", i, err)
+		}
+
+		workers[i] = w
+	}
+
+	report := &Report{
+		Goroutines: runtime.NumGoroutine(),
+	}
+
+	report.MaxGoroutines = report.Goroutines
+
+	lock := sync.Mutex{}
+	violateF := func(violation *Violation) {
+		violation.Time = source.Now()
+
+		lock.Lock()
+		report.Violations = append(report.Violations, violation)
+		lock.Unlock()
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	durationTimer := source.AfterFunc(config.Duration, cancel)
+	defer durationTimer.Stop()
+
+	wg := sync.WaitGroup{}
+	for _, w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			operations := w.run(runCtx, config.OpTimeout, violateF)
+
+			lock.Lock()
+			report.Operations += operations
+			lock.Unlock()
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ticker := source.NewTicker(config.CheckInterval)
+		defer ticker.Stop()
+
+		reported := false
+		for {
+			select {
+			case <-ticker.C():
+				goroutines := runtime.NumGoroutine()
+
+				lock.Lock()
+				report.MaxGoroutines = max(report.MaxGoroutines, goroutines)
+				lock.Unlock()
+
+				if !reported && goroutines > report.Goroutines+config.MaxGoroutineGrowth {
+					reported = true
+					violateF(&Violation{
+						Type:      ViolationGoroutines,
+						Worker:    -1,
+						Operation: "sample",
+						Err:       fmt.Errorf("%d goroutines exceed the %d observed after setup", goroutines, report.Goroutines),
+					})
+				}
+			case <-runCtx.Done():
+				return
+			}
+		}
+	}()
+
+	wg.Wait()
+
+	err := p.Close()
+	if err != nil {
+		violateF(&Violation{Type: ViolationError, Worker: -1, Operation: "close", Err: err})
+	}
+
+	// Give the goroutines a moment to return before checking for leaks.
+	leaked := 0
+	for range 50 {
+		leaked = runtime.NumGoroutine() - goroutinesBefore
+		if leaked <= 0 {
+			break
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if leaked > 0 {
+		violateF(&Violation{
+			Type:      ViolationGoroutines,
+			Worker:    -1,
+			Operation: "close",
+			Err:       fmt.Errorf("%d goroutines left behind", leaked),
+		})
+	}
+
+	report.Duration = source.Since(start)
+	return report, nil
+}
+
+// setup persists the worker's entities and initializes the mirror.
+func setup(ctx context.Context, w *worker, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	err := w.turnout.Persist(ctx, turnout.VPin(100+w.id), 400, 200, turnout.ProfileInstant)
+	if err != nil {
+		return err
+	}
+
+	err = w.output.Persist(ctx, output.VPin(200+w.id), 0)
+	if err != nil {
+		return err
+	}
+
+	w.sim.SetSensorState(w.sensor.ID(), sensor.StateInactive)
+
+	// New cabs are stopped and facing forward.
+	w.mirror.direction = cab.DirectionForward
+	return nil
+}
+
+// run performs random operations until the context is done and returns the number of operations.
+func (w *worker) run(ctx context.Context, timeout time.Duration, violateF func(violation *Violation)) int {
+	count := 0
+
+	for ctx.Err() == nil {
+		op := operations[w.rand.Intn(len(operations))]
+
+		// Detach from the run's context so operations aren't reported as deadlocked once the run ends.
+		opCtx, cancel := context.WithTimeout(context.Background(), timeout)
+		err := op.f(opCtx, w)
+		if err == nil {
+			err = w.verify(opCtx)
+		}
+
+		cancel()
+		count++
+
+		if err == nil {
+			continue
+		}
+
+		violationType := ViolationError
+		if errors.Is(err, context.DeadlineExceeded) {
+			violationType = ViolationDeadlock
+		} else if errors.Is(err, errMismatch) {
+			violationType = ViolationMirror
+		}
+
+		violateF(&Violation{
+			Type:      violationType,
+			Worker:    w.id,
+			Operation: op.name,
+			Err:       err,
+		})
+
+		// The mirror cannot be trusted anymore after a failed operation.
+		return count
+	}
+
+	return count
+}
@@ -0,0 +1,27 @@
+package soak
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	config := NewDefaultConfig()
+	config.Duration = time.Second
+	config.Workers = 4
+	config.CheckInterval = 100 * time.Millisecond
+
+	report, err := Run(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, violation := range report.Violations {
+		t.Errorf("Violation with seed %d: %s", config.Seed, violation)
+	}
+
+	if report.Operations == 0 {
+		t.Error("Expected operations to be performed")
+	}
+}
@@ -0,0 +1,113 @@
+package station
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/roosterfish/dcc-ex-go/command"
+)
+
+// AutomationType is the kind of an EX-RAIL sequence.
+type AutomationType string
+
+const (
+	// AutomationTypeRoute is a ROUTE which can be started without a loco.
+	AutomationTypeRoute AutomationType = "R"
+	// AutomationTypeAutomation is an AUTOMATION which is started with a loco.
+	AutomationTypeAutomation AutomationType = "A"
+)
+
+// Automation is an EX-RAIL sequence (ROUTE or AUTOMATION) defined on the command station.
+type Automation struct {
+	ID          uint16
+	Type        AutomationType
+	Description string
+}
+
+// Automations returns the ROUTEs and AUTOMATIONs defined using EX-RAIL.
+// The command station responds to <JA> with <jA id1 id2 ...>
+// and to <JA id> with <jA id type "description">.
+func (c *CommandStation) Automations(ctx context.Context) ([]*Automation, error) {
+	var automations []*Automation
+
+	err := c.channel.SessionContext(ctx, func(ctx context.Context) error {
+		var ids []uint16
+
+		listCommand := command.NewMultiCommand(command.OpCodeAutomations, "")
+		err := c.channel.WriteAndReadMultiOpCode(ctx, listCommand, command.OpCodeAutomationsResponse, func(cmd *command.Command) error {
+			params, err := cmd.ParametersStrings()
+			if err != nil {
+				return fmt.Errorf("failed getting automations command parameters: %w", err)
+			}
+
+			for _, param := range params[1:] {
+				id, err := strconv.ParseUint(param, 10, 16)
+				if err != nil {
+					return fmt.Errorf("invalid automation ID %q: %w", param, err)
+				}
+
+				ids = append(ids, uint16(id))
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, id := range ids {
+			automation, err := c.automation(ctx, id)
+			if err != nil {
+				return err
+			}
+
+			automations = append(automations, automation)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get automations: %w", err)
+	}
+
+	return automations, nil
+}
+
+// automation returns the automation with the given ID.
+func (c *CommandStation) automation(ctx context.Context, id uint16) (*Automation, error) {
+	var automation *Automation
+
+	automationCommand := command.NewMultiCommand(command.OpCodeAutomations, "%d", id)
+	err := c.channel.WriteAndReadMultiOpCode(ctx, automationCommand, command.OpCodeAutomationsResponse, func(cmd *command.Command) error {
+		params, err := cmd.ParametersStrings()
+		if err != nil {
+			return fmt.Errorf("failed getting automation command parameters: %w", err)
+		}
+
+		if len(params) < 3 || params[1] != strconv.FormatUint(uint64(id), 10) {
+			// Not the right automation, skip it.
+			return nil
+		}
+
+		automation = &Automation{
+			ID:   id,
+			Type: AutomationType(params[2]),
+		}
+
+		if len(params) > 3 {
+			automation.Description = params[3]
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if automation == nil {
+		return nil, fmt.Errorf("failed to find automation %d", id)
+	}
+
+	return automation, nil
+}
@@ -0,0 +1,41 @@
+package station_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/channel"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+	"github.com/roosterfish/dcc-ex-go/simulator"
+	"github.com/roosterfish/dcc-ex-go/station"
+)
+
+func TestAutomations(t *testing.T) {
+	sim := simulator.NewSimulator()
+	sim.AddAutomation(100, 'R', "Yard exit")
+	sim.AddAutomation(200, 'A', "Shuttle")
+
+	p := protocol.NewProtocol(sim, &protocol.Config{})
+	defer p.Close()
+
+	commandStation := station.NewStation(channel.NewChannel(p))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	automations, err := commandStation.Automations(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []*station.Automation{
+		{ID: 100, Type: station.AutomationTypeRoute, Description: "Yard exit"},
+		{ID: 200, Type: station.AutomationTypeAutomation, Description: "Shuttle"},
+	}
+
+	if !reflect.DeepEqual(automations, expected) {
+		t.Errorf("Expected automations %+v, got %+v", expected, automations)
+	}
+}
@@ -0,0 +1,53 @@
+package station
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/roosterfish/dcc-ex-go/cab"
+)
+
+// ActiveCab is a loco reminded by the command station, i.e. one which got a speed command since the command station started.
+type ActiveCab struct {
+	Address   cab.Address
+	Speed     cab.Speed
+	Direction cab.Direction
+	FunctMap  uint32
+}
+
+// ActiveCabs returns the locos currently reminded by the command station including their speeds and directions.
+// The reminded locos are listed using <D CABS> before reading each of their states using <t cab>.
+func (c *CommandStation) ActiveCabs(ctx context.Context) ([]*ActiveCab, error) {
+	var activeCabs []*ActiveCab
+
+	err := c.channel.SessionContext(ctx, func(ctx context.Context) error {
+		cabs, err := c.Diagnostics().Cabs(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, diagnosticCab := range cabs.Cabs {
+			address := cab.Address(diagnosticCab.Address)
+
+			status, err := cab.NewCab(address, c.channel).Status(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to get status of cab %d: %w", address, err)
+			}
+
+			speed, direction := status.SpeedDirection()
+			activeCabs = append(activeCabs, &ActiveCab{
+				Address:   address,
+				Speed:     speed,
+				Direction: direction,
+				FunctMap:  status.FunctMap,
+			})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get active cabs: %w", err)
+	}
+
+	return activeCabs, nil
+}
@@ -0,0 +1,97 @@
+package station_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/cab"
+	"github.com/roosterfish/dcc-ex-go/channel"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+	"github.com/roosterfish/dcc-ex-go/simulator"
+	"github.com/roosterfish/dcc-ex-go/station"
+)
+
+func TestActiveCabs(t *testing.T) {
+	sim := simulator.NewSimulator()
+
+	p := protocol.NewProtocol(sim, &protocol.Config{})
+	defer p.Close()
+
+	ch := channel.NewChannel(p)
+	commandStation := station.NewStation(ch)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := cab.NewCab(3, ch).Speed(ctx, 40, cab.DirectionForward)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cab.NewCab(7, ch).Speed(ctx, 10, cab.DirectionBackward)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	activeCabs, err := commandStation.ActiveCabs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []*station.ActiveCab{
+		{Address: 3, Speed: 40, Direction: cab.DirectionForward},
+		{Address: 7, Speed: 10, Direction: cab.DirectionBackward},
+	}
+
+	if !reflect.DeepEqual(activeCabs, expected) {
+		t.Errorf("Expected active cabs %+v, got %+v", expected, activeCabs)
+	}
+}
+
+func TestForgetCabs(t *testing.T) {
+	p := protocol.NewProtocol(simulator.NewSimulator(), &protocol.Config{})
+	defer p.Close()
+
+	ch := channel.NewChannel(p)
+	commandStation := station.NewStation(ch)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for _, address := range []cab.Address{3, 7, 9} {
+		err := cab.NewCab(address, ch).Speed(ctx, 40, cab.DirectionForward)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := cab.NewCab(7, ch).Forget(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	activeCabs, err := commandStation.ActiveCabs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(activeCabs) != 2 || activeCabs[0].Address != 3 || activeCabs[1].Address != 9 {
+		t.Errorf("Expected cabs 3 and 9 to remain, got %+v", activeCabs)
+	}
+
+	err = commandStation.ForgetCabs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	activeCabs, err = commandStation.ActiveCabs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(activeCabs) != 0 {
+		t.Errorf("Expected all cabs to be forgotten, got %+v", activeCabs)
+	}
+}
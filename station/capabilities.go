@@ -0,0 +1,107 @@
+package station
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/roosterfish/dcc-ex-go/command"
+)
+
+// Capabilities are the capabilities of the command station discovered by probing it.
+type Capabilities struct {
+	Status  *Status
+	Version Version
+	// TrackManager is set if the command station answers <=> with the track assignments.
+	TrackManager bool
+	// Tracks are the assignments of the track outputs keyed by track.
+	Tracks map[string]*TrackAssignment
+	// Roster is set if the command station answers <JR> with its roster.
+	Roster bool
+	// RosterEntries is the number of locos in the roster.
+	RosterEntries int
+	// Turntables is set if the command station answers <JO> with its turntables.
+	Turntables bool
+	// TurntableCount is the number of defined turntables.
+	TurntableCount int
+}
+
+// Supports returns whether or not the discovered firmware version supports the given feature.
+func (c *Capabilities) Supports(feature Feature) bool {
+	minimum, ok := feature.MinimumVersion()
+	if !ok {
+		return false
+	}
+
+	return c.Version.Compare(minimum) >= 0
+}
+
+// Discover probes the command station using the status, track manager, roster and turntable queries.
+// Queries which aren't answered by the command station (e.g. due to an older firmware) are reported as unsupported.
+func (c *CommandStation) Discover(ctx context.Context) (*Capabilities, error) {
+	capabilities := &Capabilities{}
+
+	err := c.channel.SessionContext(ctx, func(ctx context.Context) error {
+		status, err := c.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		capabilities.Status = status
+		capabilities.Version, err = status.SemanticVersion()
+		if err != nil {
+			return err
+		}
+
+		capabilities.Tracks, err = c.TrackAssignments(ctx)
+		if err != nil {
+			return err
+		}
+
+		capabilities.TrackManager = len(capabilities.Tracks) > 0
+
+		capabilities.RosterEntries, capabilities.Roster, err = c.probeList(ctx, command.OpCodeRoster, command.OpCodeRosterResponse)
+		if err != nil {
+			return err
+		}
+
+		capabilities.TurntableCount, capabilities.Turntables, err = c.probeList(ctx, command.OpCodeTurntables, command.OpCodeTurntablesResponse)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover capabilities: %w", err)
+	}
+
+	// Reuse the discovered version for SupportsFeature.
+	c.versionLock.Lock()
+	c.firmwareVersion = &capabilities.Version
+	c.versionLock.Unlock()
+
+	return capabilities, nil
+}
+
+// probeList writes the J-series list command and returns the number of listed entries
+// and whether or not the command station answered at all.
+func (c *CommandStation) probeList(ctx context.Context, o command.MultiOpCode, response command.MultiOpCode) (int, bool, error) {
+	count := 0
+	answered := false
+
+	listCommand := command.NewMultiCommand(o, "")
+	err := c.channel.WriteAndReadMultiOpCode(ctx, listCommand, response, func(cmd *command.Command) error {
+		params, err := cmd.ParametersStrings()
+		if err != nil {
+			return fmt.Errorf("failed getting %s command parameters: %w", response, err)
+		}
+
+		answered = true
+		if len(params) > 1 {
+			count = len(params) - 1
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, false, err
+	}
+
+	return count, answered, nil
+}
@@ -0,0 +1,52 @@
+package station_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/channel"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+	"github.com/roosterfish/dcc-ex-go/simulator"
+	"github.com/roosterfish/dcc-ex-go/station"
+)
+
+func TestDiscover(t *testing.T) {
+	sim := simulator.NewSimulator()
+	sim.AddRosterEntry(3, "Thomas", "")
+	sim.AddRosterEntry(200, "Percy", "")
+	sim.AddTurntable(1, true, "Engine shed")
+
+	p := protocol.NewProtocol(sim, &protocol.Config{})
+	defer p.Close()
+
+	commandStation := station.NewStation(channel.NewChannel(p))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	capabilities, err := commandStation.Discover(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if capabilities.Version.String() != simulator.Version {
+		t.Errorf("Expected version %q, got %q", simulator.Version, capabilities.Version)
+	}
+
+	if !capabilities.TrackManager || capabilities.Tracks["A"] == nil {
+		t.Error("Expected the track manager to be supported")
+	}
+
+	if !capabilities.Roster || capabilities.RosterEntries != 2 {
+		t.Errorf("Expected a roster with 2 entries, got %d", capabilities.RosterEntries)
+	}
+
+	if !capabilities.Turntables || capabilities.TurntableCount != 1 {
+		t.Errorf("Expected 1 turntable, got %d", capabilities.TurntableCount)
+	}
+
+	if !capabilities.Supports(station.FeatureTurntables) {
+		t.Error("Expected turntables to be supported")
+	}
+}
@@ -0,0 +1,143 @@
+package station
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/command"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+)
+
+// Current is the current telemetry of the command station.
+type Current struct {
+	// Tracks are the currents in milliamps drawn from each of the tracks (see TrackCurrents).
+	Tracks []int
+	// Max is the maximum current in milliamps the main track's motor driver can supply.
+	Max int `dccex:"5"`
+	// Trip is the current in milliamps at which the main track gets switched off due to an overload.
+	Trip int `dccex:"7"`
+}
+
+// CurrentReading is a single reading of the current monitor.
+// In case the current couldn't be read, Err is set.
+type CurrentReading struct {
+	Current *Current
+	Time    time.Time
+	Err     error
+}
+
+type CurrentReadingC chan *CurrentReading
+
+// TrackCurrents returns the current in milliamps drawn from each of the tracks.
+// The first entry is track A (usually MAIN), the second track B (usually PROG) and so on.
+func (c *CommandStation) TrackCurrents(ctx context.Context) ([]int, error) {
+	var currents []int
+
+	// <JI> is answered with <jI current_A current_B ...>.
+	currentsCommand := command.NewMultiCommand(command.OpCodeTrackCurrents, "")
+	err := c.channel.WriteAndReadMultiOpCode(ctx, currentsCommand, command.OpCodeTrackCurrentsResponse, func(cmd *command.Command) error {
+		params, err := cmd.ParametersStrings()
+		if err != nil {
+			return fmt.Errorf("failed getting track currents command parameters: %w", err)
+		}
+
+		if len(params) < 2 {
+			return nil
+		}
+
+		currents = make([]int, 0, len(params)-1)
+		for _, param := range params[1:] {
+			current, err := strconv.Atoi(param)
+			if err != nil {
+				return fmt.Errorf("failed to convert track current to int: %w", err)
+			}
+
+			currents = append(currents, current)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get track currents: %w", err)
+	}
+
+	if currents == nil {
+		return nil, errors.New("failed to find track currents")
+	}
+
+	return currents, nil
+}
+
+// Current returns the currents drawn from each of the tracks together with the main track's limits.
+func (c *CommandStation) Current(ctx context.Context) (*Current, error) {
+	var current *Current
+
+	err := c.channel.SessionContext(ctx, func(ctx context.Context) error {
+		tracks, err := c.TrackCurrents(ctx)
+		if err != nil {
+			return err
+		}
+
+		// <c> is answered with <c "CurrentMAIN" current C "Milli" "0" max "1" trip>.
+		currentCommand := command.NewCommand(command.OpCodeCurrent, "")
+		return c.channel.WriteAndReadOpCode(ctx, currentCommand, command.OpCodeCurrent, func(cmd *command.Command) error {
+			current = &Current{
+				Tracks: tracks,
+			}
+
+			return command.Decode(cmd, current)
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current: %w", err)
+	}
+
+	if current == nil {
+		return nil, errors.New("failed to find current")
+	}
+
+	return current, nil
+}
+
+// MonitorCurrent starts reading the current every interval.
+// The readings are sent to the returned channel.
+// Never close the channel manually but instead call the cleanup function.
+func (c *CommandStation) MonitorCurrent(interval time.Duration) (CurrentReadingC, protocol.CleanupF) {
+	readingC := make(CurrentReadingC)
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := sync.WaitGroup{}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ticker := c.channel.Clock().NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			current, err := c.Current(ctx)
+
+			select {
+			case readingC <- &CurrentReading{Current: current, Time: c.channel.Clock().Now(), Err: err}:
+			case <-ctx.Done():
+				return
+			}
+
+			select {
+			case <-ticker.C():
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return readingC, func() {
+		cancel()
+		wg.Wait()
+		close(readingC)
+	}
+}
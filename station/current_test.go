@@ -0,0 +1,60 @@
+package station_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/channel"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+	"github.com/roosterfish/dcc-ex-go/simulator"
+	"github.com/roosterfish/dcc-ex-go/station"
+)
+
+func TestCurrent(t *testing.T) {
+	sim := simulator.NewSimulator()
+	for track, current := range []int{420, 30} {
+		err := sim.SetTrackCurrent(track, current)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p := protocol.NewProtocol(sim, &protocol.Config{})
+	defer p.Close()
+
+	commandStation := station.NewStation(channel.NewChannel(p))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	current, err := commandStation.Current(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(current.Tracks, []int{420, 30}) {
+		t.Errorf("Expected track currents [420 30], got %v", current.Tracks)
+	}
+
+	if current.Max != 2000 || current.Trip != 1500 {
+		t.Errorf("Expected max 2000 and trip 1500, got %d and %d", current.Max, current.Trip)
+	}
+
+	readingC, cleanupF := commandStation.MonitorCurrent(time.Millisecond)
+	defer cleanupF()
+
+	select {
+	case reading := <-readingC:
+		if reading.Err != nil {
+			t.Fatal(reading.Err)
+		}
+
+		if reading.Current.Tracks[0] != 420 {
+			t.Errorf("Expected main track current 420, got %d", reading.Current.Tracks[0])
+		}
+	case <-ctx.Done():
+		t.Fatal("Expected current reading")
+	}
+}
@@ -0,0 +1,177 @@
+package station
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/roosterfish/dcc-ex-go/channel"
+	"github.com/roosterfish/dcc-ex-go/command"
+)
+
+// DiagnosticCategory is a category of the command station's diagnostic messages which can be enabled.
+type DiagnosticCategory string
+
+const (
+	DiagnosticAck        DiagnosticCategory = "ACK"
+	DiagnosticCommands   DiagnosticCategory = "CMD"
+	DiagnosticWiFi       DiagnosticCategory = "WIFI"
+	DiagnosticEthernet   DiagnosticCategory = "ETHERNET"
+	DiagnosticWiThrottle DiagnosticCategory = "WIT"
+	DiagnosticLCN        DiagnosticCategory = "LCN"
+)
+
+var (
+	// <* cab=3, speed=50, dir=F ... Used=1, max=50 *>
+	diagnosticCabRegexp  = regexp.MustCompile(`cab=(\d+), speed=(\d+), dir=([FR])`)
+	diagnosticUsedRegexp = regexp.MustCompile(`Used=(\d+), max=(\d+)`)
+)
+
+// DiagnosticCab is a cab in the command station's reminder table.
+type DiagnosticCab struct {
+	Address uint16
+	Speed   uint8
+	Forward bool
+}
+
+// DiagnosticCabs is the command station's reminder table of the cabs.
+type DiagnosticCabs struct {
+	Cabs []DiagnosticCab
+	// Used and Max are the number of used and available reminder slots.
+	Used int
+	Max  int
+}
+
+// Diagnostics runs the command station's diagnostic commands (<D ...>).
+type Diagnostics struct {
+	channel channel.Interface
+}
+
+// Diagnostics returns the command station's diagnostics.
+func (c *CommandStation) Diagnostics() *Diagnostics {
+	return &Diagnostics{
+		channel: c.channel,
+	}
+}
+
+// readDiagnostic writes the given diagnostic command and calls f with the message of every diagnostic response.
+func (d *Diagnostics) readDiagnostic(ctx context.Context, diagnosticCommand *command.Command, f func(message string) error) error {
+	return d.channel.WriteAndReadOpCode(ctx, diagnosticCommand, command.OpCodeDescribe, func(cmd *command.Command) error {
+		diagnostic, err := command.NewDiagnostic(cmd)
+		if err != nil {
+			return err
+		}
+
+		return f(diagnostic.Message)
+	})
+}
+
+// Set enables or disables the diagnostic messages of the given category using <D category ON|OFF>.
+// The messages are broadcasted as <* ... *> (see protocol.Protocol.ReadDiagnostics).
+func (d *Diagnostics) Set(ctx context.Context, category DiagnosticCategory, enabled bool) error {
+	state := "OFF"
+	if enabled {
+		state = "ON"
+	}
+
+	err := d.channel.Write(ctx, command.NewCommand(command.OpCodeDiagnostic, "%s %s", category, state))
+	if err != nil {
+		return fmt.Errorf("failed to set %q diagnostics %s: %w", category, state, err)
+	}
+
+	return nil
+}
+
+// FreeMemory returns the command station's free RAM in bytes.
+// A command station running out of memory might crash, monitor it in long-running automations.
+// The command station responds to <D RAM> with <* Free memory=bytes *>.
+func (d *Diagnostics) FreeMemory(ctx context.Context) (int, error) {
+	var freeMemory *int
+
+	err := d.readDiagnostic(ctx, command.NewCommand(command.OpCodeDiagnostic, "RAM"), func(message string) error {
+		value, ok := strings.CutPrefix(message, "Free memory=")
+		if !ok {
+			// Not the free memory message, skip it.
+			return nil
+		}
+
+		bytes, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid free memory %q: %w", value, err)
+		}
+
+		freeMemory = &bytes
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to get free memory: %w", err)
+	}
+
+	if freeMemory == nil {
+		return 0, errors.New("failed to find free memory")
+	}
+
+	return *freeMemory, nil
+}
+
+// Cabs returns the command station's reminder table of the cabs.
+// The command station responds to <D CABS> with a single multiline diagnostic message:
+// <* cab=3, speed=50, dir=F Used=1, max=50 *>.
+func (d *Diagnostics) Cabs(ctx context.Context) (*DiagnosticCabs, error) {
+	var cabs *DiagnosticCabs
+
+	err := d.readDiagnostic(ctx, command.NewCommand(command.OpCodeDiagnostic, "CABS"), func(message string) error {
+		used := diagnosticUsedRegexp.FindStringSubmatch(message)
+		if used == nil {
+			// Not the cab list, skip it.
+			return nil
+		}
+
+		cabs = &DiagnosticCabs{}
+		cabs.Used, _ = strconv.Atoi(used[1])
+		cabs.Max, _ = strconv.Atoi(used[2])
+
+		for _, match := range diagnosticCabRegexp.FindAllStringSubmatch(message, -1) {
+			address, err := strconv.ParseUint(match[1], 10, 16)
+			if err != nil {
+				return fmt.Errorf("invalid cab address %q: %w", match[1], err)
+			}
+
+			speed, err := strconv.ParseUint(match[2], 10, 8)
+			if err != nil {
+				return fmt.Errorf("invalid cab speed %q: %w", match[2], err)
+			}
+
+			cabs.Cabs = append(cabs.Cabs, DiagnosticCab{
+				Address: uint16(address),
+				Speed:   uint8(speed),
+				Forward: match[3] == "F",
+			})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cabs: %w", err)
+	}
+
+	if cabs == nil {
+		return nil, errors.New("failed to find cabs")
+	}
+
+	return cabs, nil
+}
+
+// Servo moves the servo on the given vpin to the position using <D SERVO vpin position profile>.
+// It's meant for finding the thrown and closed positions of a servo turnout before defining it.
+func (d *Diagnostics) Servo(ctx context.Context, vpin uint16, position uint16, profile uint8) error {
+	err := d.channel.Write(ctx, command.NewCommand(command.OpCodeDiagnostic, "SERVO %d %d %d", vpin, position, profile))
+	if err != nil {
+		return fmt.Errorf("failed to move servo on vpin %d to %d: %w", vpin, position, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package station_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/cab"
+	"github.com/roosterfish/dcc-ex-go/channel"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+	"github.com/roosterfish/dcc-ex-go/simulator"
+	"github.com/roosterfish/dcc-ex-go/station"
+)
+
+func TestDiagnostics(t *testing.T) {
+	sim := simulator.NewSimulator()
+	sim.SetFreeMemory(1234)
+
+	p := protocol.NewProtocol(sim, &protocol.Config{})
+	defer p.Close()
+
+	ch := channel.NewChannel(p)
+	diagnostics := station.NewStation(ch).Diagnostics()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	freeMemory, err := diagnostics.FreeMemory(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if freeMemory != 1234 {
+		t.Errorf("Expected 1234 bytes of free memory, got %d", freeMemory)
+	}
+
+	err = diagnostics.Set(ctx, station.DiagnosticAck, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cab.NewCab(3, ch).Speed(ctx, 50, cab.DirectionForward)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cabs, err := diagnostics.Cabs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cabs.Used != 1 || cabs.Max != 50 || len(cabs.Cabs) != 1 {
+		t.Fatalf("Unexpected cabs %+v", cabs)
+	}
+
+	if cabs.Cabs[0] != (station.DiagnosticCab{Address: 3, Speed: 51, Forward: true}) {
+		t.Errorf("Unexpected cab %+v", cabs.Cabs[0])
+	}
+}
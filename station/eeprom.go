@@ -0,0 +1,178 @@
+package station
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/roosterfish/dcc-ex-go/channel"
+	"github.com/roosterfish/dcc-ex-go/command"
+	"github.com/roosterfish/dcc-ex-go/errs"
+)
+
+// ErrEEPROMFull is returned if the definitions exceed the command station's EEPROM.
+// DCC-EX neither refuses to store definitions exceeding the EEPROM nor reports its usage, so the error is
+// based on the usage estimated from the stored definition counts (see EEPROMUsage) and DefaultEEPROMSize.
+// The definitions might still fit in case the actual entries are smaller than estimated.
+// It matches errs.ErrStationFailure.
+var ErrEEPROMFull = errs.New("EEPROM is full", errs.ErrStationFailure)
+
+// DefaultEEPROMSize is the size of the EEPROM of an Arduino Mega in bytes.
+const DefaultEEPROMSize = 4096
+
+// Estimated sizes of the EEPROM entries in bytes.
+// The actual sizes depend on the firmware version and the turnout type.
+// The largest turnout (servo) is used to stay on the safe side.
+const (
+	eepromHeaderSize  = 14
+	eepromTurnoutSize = 10
+	eepromSensorSize  = 5
+	eepromOutputSize  = 6
+)
+
+// EEPROMCounts is the number of definitions stored in the EEPROM.
+type EEPROMCounts struct {
+	Turnouts int
+	Sensors  int
+	Outputs  int
+}
+
+// EEPROMUsage reports how much of the EEPROM is used by definitions.
+type EEPROMUsage struct {
+	Counts EEPROMCounts
+	// UsedBytes and TotalBytes are estimated using the counts as DCC-EX doesn't report the EEPROM usage.
+	UsedBytes  int
+	TotalBytes int
+	Estimated  bool
+}
+
+// Remaining returns the number of bytes still available in the EEPROM.
+func (u *EEPROMUsage) Remaining() int {
+	return max(u.TotalBytes-u.UsedBytes, 0)
+}
+
+// estimateEEPROMUsage estimates the used bytes using the given counts.
+func estimateEEPROMUsage(counts EEPROMCounts, size int) *EEPROMUsage {
+	return &EEPROMUsage{
+		Counts:     counts,
+		UsedBytes:  eepromHeaderSize + counts.Turnouts*eepromTurnoutSize + counts.Sensors*eepromSensorSize + counts.Outputs*eepromOutputSize,
+		TotalBytes: size,
+		Estimated:  true,
+	}
+}
+
+// storeEEPROM stores all definitions in the EEPROM and returns the estimated usage.
+// The command station responds to <E> with <e nTurnouts nSensors nOutputs>.
+// An estimated usage exceeding the EEPROM is reported as ErrEEPROMFull.
+func storeEEPROM(ctx context.Context, channel channel.Interface) (*EEPROMUsage, error) {
+	var counts *EEPROMCounts
+
+	storeCommand := command.NewCommand(command.OpCodeEEPROM, "")
+	err := channel.WriteAndReadOpCode(ctx, storeCommand, command.OpCodeEEPROMResponse, func(cmd *command.Command) error {
+		params, err := cmd.ParametersStrings()
+		if err != nil {
+			return fmt.Errorf("failed getting EEPROM command parameters: %w", err)
+		}
+
+		if len(params) != 3 {
+			return fmt.Errorf("invalid EEPROM command parameter length %q", len(params))
+		}
+
+		values := make([]int, 0, len(params))
+		for _, param := range params {
+			value, err := strconv.Atoi(param)
+			if err != nil {
+				return fmt.Errorf("invalid EEPROM count %q: %w", param, err)
+			}
+
+			values = append(values, value)
+		}
+
+		counts = &EEPROMCounts{
+			Turnouts: values[0],
+			Sensors:  values[1],
+			Outputs:  values[2],
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to store EEPROM: %w", err)
+	}
+
+	if counts == nil {
+		return nil, errors.New("EEPROM store response is missing")
+	}
+
+	usage := estimateEEPROMUsage(*counts, DefaultEEPROMSize)
+	if usage.UsedBytes > usage.TotalBytes {
+		return nil, fmt.Errorf("%w: an estimated %d/%d bytes are used", ErrEEPROMFull, usage.UsedBytes, usage.TotalBytes)
+	}
+
+	return usage, nil
+}
+
+// PersistDefinition runs the given definition command (e.g. <S id vpin pullup>) and stores it in the EEPROM.
+// It returns ErrEEPROMFull in case the definitions are estimated to exceed the EEPROM.
+func PersistDefinition(ctx context.Context, channel channel.Interface, definition *command.Command) (*EEPROMUsage, error) {
+	var usage *EEPROMUsage
+
+	err := channel.SessionContext(ctx, func(ctx context.Context) error {
+		err := channel.WriteWithAck(ctx, definition)
+		if err != nil {
+			return fmt.Errorf("failed to define %q: %w", definition.String(), err)
+		}
+
+		usage, err = storeEEPROM(ctx, channel)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return usage, nil
+}
+
+// EEPROMUsage stores all definitions in the EEPROM and reports its usage.
+// It's advisory only as the usage is estimated.
+// DCC-EX only writes the bytes which have changed so calling it doesn't wear the EEPROM.
+func (c *CommandStation) EEPROMUsage(ctx context.Context) (*EEPROMUsage, error) {
+	return storeEEPROM(ctx, c.channel)
+}
+
+// EEPROM manages the definitions stored in the command station's EEPROM.
+type EEPROM struct {
+	channel channel.Interface
+}
+
+// EEPROM returns the command station's EEPROM.
+func (c *CommandStation) EEPROM() *EEPROM {
+	return &EEPROM{
+		channel: c.channel,
+	}
+}
+
+// Store stores all definitions in the EEPROM and returns the number of stored definitions.
+// It returns ErrEEPROMFull in case the definitions are estimated to exceed the EEPROM.
+// The definitions are stored by DCC-EX anyway, so the error is a warning that some of them might be lost.
+func (e *EEPROM) Store(ctx context.Context) (*EEPROMCounts, error) {
+	usage, err := storeEEPROM(ctx, e.channel)
+	if err != nil {
+		return nil, err
+	}
+
+	return &usage.Counts, nil
+}
+
+// Erase erases all definitions from the EEPROM.
+// The command station responds to <e> with <O>.
+// The definitions are kept by the command station until it restarts or they are stored again.
+func (e *EEPROM) Erase(ctx context.Context) error {
+	err := e.channel.WriteWithAck(ctx, command.NewCommand(command.OpCodeEEPROMErase, ""))
+	if err != nil {
+		return fmt.Errorf("failed to erase EEPROM: %w", err)
+	}
+
+	return nil
+}
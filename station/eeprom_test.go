@@ -0,0 +1,107 @@
+package station_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/channel"
+	"github.com/roosterfish/dcc-ex-go/channeltest"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+	"github.com/roosterfish/dcc-ex-go/sensor"
+	"github.com/roosterfish/dcc-ex-go/simulator"
+	"github.com/roosterfish/dcc-ex-go/station"
+)
+
+func TestEEPROMUsage(t *testing.T) {
+	sim := simulator.NewSimulator()
+	p := protocol.NewProtocol(sim, &protocol.Config{})
+	defer p.Close()
+
+	ch := channel.NewChannel(p)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for id := range sensor.ID(2) {
+		err := sensor.NewSensor(id, ch).Persist(ctx, sensor.VPin(id), sensor.PullUpOn)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	usage, err := station.NewStation(ch).EEPROMUsage(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Header and two sensors.
+	if usage.Counts.Sensors != 2 || usage.UsedBytes != 24 || usage.TotalBytes != station.DefaultEEPROMSize || !usage.Estimated {
+		t.Errorf("Unexpected EEPROM usage %+v", usage)
+	}
+}
+
+func TestEEPROMFull(t *testing.T) {
+	ch := channeltest.NewChannel()
+	defer ch.Close()
+
+	// DCC-EX stores the definitions anyway and only reports their counts.
+	ch.Respond("<E>", "<e 0 817 0>")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := station.NewStation(ch).EEPROMUsage(ctx)
+	if !errors.Is(err, station.ErrEEPROMFull) {
+		t.Fatalf("Expected %v but got %v", station.ErrEEPROMFull, err)
+	}
+
+	if !strings.Contains(err.Error(), "4099/4096 bytes") {
+		t.Errorf("Expected the usage in %q", err)
+	}
+
+	ch.Respond("<E>", "<e 0 816 0>")
+
+	usage, err := station.NewStation(ch).EEPROMUsage(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if usage.UsedBytes != 4094 || usage.Remaining() != 2 {
+		t.Errorf("Unexpected EEPROM usage %+v", usage)
+	}
+}
+
+func TestEEPROMStoreAndErase(t *testing.T) {
+	sim := simulator.NewSimulator()
+
+	p := protocol.NewProtocol(sim, &protocol.Config{})
+	defer p.Close()
+
+	ch := channel.NewChannel(p)
+	eeprom := station.NewStation(ch).EEPROM()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := sensor.NewSensor(1, ch).Persist(ctx, 1, sensor.PullUpOn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counts, err := eeprom.Store(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *counts != (station.EEPROMCounts{Sensors: 1}) {
+		t.Errorf("Unexpected EEPROM counts %+v", counts)
+	}
+
+	err = eeprom.Erase(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,36 @@
+package station
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/roosterfish/dcc-ex-go/command"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+)
+
+// InfoC receives the info broadcasts of the command station.
+type InfoC chan *command.InfoMessage
+
+// Info returns a channel which receives all of the <@ display line "text"> info broadcasts of the command station.
+// Broadcasts which cannot be parsed are skipped.
+// Never close the channel manually but instead call the cleanup function.
+func (c *CommandStation) Info() (InfoC, protocol.CleanupF) {
+	infoC, cleanupF := subscribe(c.channel, command.OpCodeInfo, command.NewInfoMessage)
+	return InfoC(infoC), cleanupF
+}
+
+// WriteDisplay shows the text on the given line of the command station's display (e.g. an attached LCD or OLED)
+// using <@ display line "text">. Display 0 is the command station's main display.
+// Like EX-RAIL's SCREEN, the text is broadcasted as an info message afterwards.
+func (c *CommandStation) WriteDisplay(ctx context.Context, display int, line int, text string) error {
+	if display < 0 || line < 0 {
+		return fmt.Errorf("invalid display %d line %d", display, line)
+	}
+
+	err := c.channel.Write(ctx, command.NewCommand(command.OpCodeInfo, "%d %d %q", display, line, text))
+	if err != nil {
+		return fmt.Errorf("failed to write display %d line %d: %w", display, line, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package station_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/channel"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+	"github.com/roosterfish/dcc-ex-go/simulator"
+	"github.com/roosterfish/dcc-ex-go/station"
+)
+
+func TestInfo(t *testing.T) {
+	sim := simulator.NewSimulator()
+
+	p := protocol.NewProtocol(sim, &protocol.Config{})
+	defer p.Close()
+
+	ch := channel.NewChannel(p)
+	commandStation := station.NewStation(ch)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	infoC, cleanupF := commandStation.Info()
+	defer cleanupF()
+
+	errC := make(chan error, 1)
+	go func() {
+		errC <- commandStation.PowerTrack(ctx, station.PowerOn, station.TrackMain)
+	}()
+
+	for {
+		select {
+		case info := <-infoC:
+			if info.Display != 0 || info.Line != 2 || !strings.HasPrefix(info.Text, "PWR") {
+				continue
+			}
+
+			err := <-errC
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			return
+		case <-ctx.Done():
+			t.Fatal("Expected power info broadcast")
+		}
+	}
+}
+
+func TestWriteDisplay(t *testing.T) {
+	sim := simulator.NewSimulator()
+
+	p := protocol.NewProtocol(sim, &protocol.Config{})
+	defer p.Close()
+
+	commandStation := station.NewStation(channel.NewChannel(p))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	infoC, cleanupF := commandStation.Info()
+	defer cleanupF()
+
+	errC := make(chan error, 1)
+	go func() {
+		errC <- commandStation.WriteDisplay(ctx, 0, 4, "Route 3 active")
+	}()
+
+	for {
+		select {
+		case info := <-infoC:
+			if info.Line != 4 {
+				continue
+			}
+
+			if info.Text != "Route 3 active" {
+				t.Errorf("Expected text %q, got %q", "Route 3 active", info.Text)
+			}
+
+			cleanupF()
+
+			err := <-errC
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			return
+		case <-ctx.Done():
+			t.Fatal("Expected display broadcast")
+		}
+	}
+}
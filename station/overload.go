@@ -0,0 +1,179 @@
+package station
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/command"
+	"github.com/roosterfish/dcc-ex-go/errs"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+)
+
+// ErrOverloadRetriesExhausted is reported once a track got overloaded more often than the retry policy allows.
+var ErrOverloadRetriesExhausted = errs.New("overload retries exhausted", errs.ErrStationFailure)
+
+// <* TRACK A POWER OVERLOAD current=5000 *>
+var overloadRegexp = regexp.MustCompile(`TRACK ([A-H]) .*OVERLOAD(?: current=(\d+))?`)
+
+// OverloadEvent is an overcurrent (e.g. a short circuit) which caused the command station to power off a track.
+type OverloadEvent struct {
+	// Track is the track output (A-H) which got powered off.
+	Track string
+	// Current is the measured current in milliamps. It's 0 if not reported.
+	Current int
+}
+
+type OverloadC chan *OverloadEvent
+
+// OverloadRetryPolicy defines how the power of overloaded tracks is restored.
+type OverloadRetryPolicy struct {
+	// Wait is the duration to wait before powering on the track again.
+	Wait time.Duration
+	// Attempts is the number of consecutive overloads of a track after which the power is no longer restored.
+	Attempts int
+	// ResetAfter resets the consecutive overloads of a track once it didn't get overloaded for the duration.
+	// The overloads are never reset if not set.
+	ResetAfter time.Duration
+}
+
+// OverloadRetry is the outcome of restoring the power of an overloaded track.
+type OverloadRetry struct {
+	Overload *OverloadEvent
+	// Attempt is the number of consecutive overloads of the track.
+	Attempt int
+	// Err is set in case the power couldn't be restored.
+	// Once the attempts are exhausted, it's ErrOverloadRetriesExhausted.
+	Err error
+}
+
+type OverloadRetryC chan *OverloadRetry
+
+// parseOverloadEvent returns the overload event of the given diagnostic message.
+func parseOverloadEvent(cmd *command.Command) (*OverloadEvent, error) {
+	diagnostic, err := command.NewDiagnostic(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	match := overloadRegexp.FindStringSubmatch(diagnostic.Message)
+	if match == nil {
+		return nil, fmt.Errorf("failed to get overload from %q: not an overload", cmd.String())
+	}
+
+	event := &OverloadEvent{
+		Track: match[1],
+	}
+
+	if match[2] != "" {
+		event.Current, err = strconv.Atoi(match[2])
+		if err != nil {
+			return nil, fmt.Errorf("invalid overload current %q: %w", match[2], err)
+		}
+	}
+
+	return event, nil
+}
+
+// Overloads returns a channel which receives every overload reported by the command station.
+// Never close the channel manually but instead call the cleanup function.
+func (c *CommandStation) Overloads() (OverloadC, protocol.CleanupF) {
+	return subscribe(c.channel, command.OpCodeDescribe, parseOverloadEvent)
+}
+
+// restorePower powers on the given track output again.
+// Only tracks in the modes MAIN and PROG can be powered on.
+func (c *CommandStation) restorePower(ctx context.Context, track string) error {
+	assignments, err := c.TrackAssignments(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to restore power of track %q: %w", track, err)
+	}
+
+	assignment, ok := assignments[track]
+	if !ok || (assignment.Mode != TrackModeMain && assignment.Mode != TrackModeProg) {
+		return fmt.Errorf("failed to restore power of track %q: unsupported assignment", track)
+	}
+
+	return c.PowerTrack(ctx, PowerOn, Track(assignment.Mode))
+}
+
+// RetryOverloads restores the power of overloaded tracks according to the given policy.
+// The outcome of every overload is sent to the returned channel.
+// Never close the channel manually but instead call the cleanup function.
+func (c *CommandStation) RetryOverloads(policy OverloadRetryPolicy) (OverloadRetryC, protocol.CleanupF) {
+	overloadC, overloadCleanupF := c.Overloads()
+
+	retryC := make(OverloadRetryC)
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := sync.WaitGroup{}
+
+	sendF := func(retry *OverloadRetry) {
+		select {
+		case retryC <- retry:
+		case <-ctx.Done():
+		}
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		attempts := make(map[string]int)
+		overloaded := make(map[string]time.Time)
+
+		for {
+			select {
+			case event, ok := <-overloadC:
+				if !ok {
+					return
+				}
+
+				now := c.channel.Clock().Now()
+				if policy.ResetAfter > 0 && now.Sub(overloaded[event.Track]) >= policy.ResetAfter {
+					attempts[event.Track] = 0
+				}
+
+				overloaded[event.Track] = now
+				attempts[event.Track]++
+
+				retry := &OverloadRetry{
+					Overload: event,
+					Attempt:  attempts[event.Track],
+				}
+
+				// Restore the power in the background to keep consuming the overloads.
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+
+					if retry.Attempt > policy.Attempts {
+						retry.Err = ErrOverloadRetriesExhausted
+						sendF(retry)
+						return
+					}
+
+					select {
+					case <-c.channel.Clock().After(policy.Wait):
+					case <-ctx.Done():
+						return
+					}
+
+					retry.Err = c.restorePower(ctx, event.Track)
+					sendF(retry)
+				}()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return retryC, sync.OnceFunc(func() {
+		cancel()
+		overloadCleanupF()
+		wg.Wait()
+		close(retryC)
+	})
+}
@@ -0,0 +1,57 @@
+package station_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/channel"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+	"github.com/roosterfish/dcc-ex-go/simulator"
+	"github.com/roosterfish/dcc-ex-go/station"
+)
+
+func TestRetryOverloads(t *testing.T) {
+	sim := simulator.NewSimulator()
+
+	p := protocol.NewProtocol(sim, &protocol.Config{})
+	defer p.Close()
+
+	commandStation := station.NewStation(channel.NewChannel(p))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	retryC, cleanupF := commandStation.RetryOverloads(station.OverloadRetryPolicy{
+		Wait:     time.Millisecond,
+		Attempts: 1,
+	})
+	defer cleanupF()
+
+	sim.InjectFault(simulator.FaultOverload)
+
+	select {
+	case retry := <-retryC:
+		if retry.Err != nil {
+			t.Fatal(retry.Err)
+		}
+
+		if retry.Overload.Track != "A" || retry.Overload.Current != 5000 || retry.Attempt != 1 {
+			t.Errorf("Unexpected retry %+v of overload %+v", retry, retry.Overload)
+		}
+	case <-ctx.Done():
+		t.Fatal("Expected power to be restored")
+	}
+
+	sim.InjectFault(simulator.FaultOverload)
+
+	select {
+	case retry := <-retryC:
+		if !errors.Is(retry.Err, station.ErrOverloadRetriesExhausted) {
+			t.Errorf("Expected %v, got %v", station.ErrOverloadRetriesExhausted, retry.Err)
+		}
+	case <-ctx.Done():
+		t.Fatal("Expected retries to be exhausted")
+	}
+}
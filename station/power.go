@@ -0,0 +1,180 @@
+package station
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/roosterfish/dcc-ex-go/command"
+	"github.com/roosterfish/dcc-ex-go/errs"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+)
+
+// PowerEvent is a power change broadcasted by the command station using <p state> or <p state track>.
+// The track is empty in case the power of all tracks changed.
+type PowerEvent struct {
+	State PowerState
+	Track Track
+}
+
+type PowerEventC chan *PowerEvent
+
+// powerStates caches the latest power state of each track.
+type powerStates struct {
+	states map[Track]PowerState
+	lock   sync.Mutex
+}
+
+// parsePowerEvent returns the power event of the given <p ...> command.
+func parsePowerEvent(cmd *command.Command) (*PowerEvent, error) {
+	params, err := cmd.ParametersStrings()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get power event from %q: %w", cmd.String(), err)
+	}
+
+	if len(params) == 0 || len(params) > 2 {
+		return nil, fmt.Errorf("failed to get power event from %q: invalid parameter length %d", cmd.String(), len(params))
+	}
+
+	event := &PowerEvent{}
+	switch params[0] {
+	case string(PowerOn):
+		event.State = PowerOn
+	case string(PowerOff):
+		event.State = PowerOff
+	default:
+		return nil, fmt.Errorf("failed to get power event from %q: invalid state %q", cmd.String(), params[0])
+	}
+
+	if len(params) == 2 {
+		event.Track = Track(params[1])
+	}
+
+	return event, nil
+}
+
+// apply updates the cached power states using the given event.
+// Events without track as well as joining the tracks apply to both MAIN and PROG.
+func (p *powerStates) apply(event *PowerEvent) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	switch event.Track {
+	case "", TrackJoin:
+		p.states[TrackMain] = event.State
+		p.states[TrackProg] = event.State
+	default:
+		p.states[event.Track] = event.State
+	}
+}
+
+func (p *powerStates) get(track Track) (PowerState, bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	state, ok := p.states[track]
+	return state, ok
+}
+
+// trackPower keeps the cached power states up to date until the returned cleanup function is called
+// or the channel's protocol gets closed.
+func (c *CommandStation) trackPower() protocol.CleanupF {
+	var commandC protocol.CommandC
+	var cleanupF protocol.CleanupF
+
+	_ = c.channel.RSession(func(sessionProtocol protocol.Reader) error {
+		commandC, cleanupF = protocol.ReadFiltered(sessionProtocol, command.OpCodePower)
+		return nil
+	})
+
+	doneC := make(chan struct{})
+	wg := sync.WaitGroup{}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		for {
+			select {
+			case cmd, ok := <-commandC:
+				if !ok {
+					return
+				}
+
+				event, err := parsePowerEvent(cmd)
+				if err != nil {
+					continue
+				}
+
+				c.power.apply(event)
+			case <-doneC:
+				return
+			}
+		}
+	}()
+
+	return sync.OnceFunc(func() {
+		close(doneC)
+		wg.Wait()
+		cleanupF()
+	})
+}
+
+// CurrentPower returns the latest power state of the given track as broadcasted by the command station.
+// It returns false in case the power state of the track isn't known yet (e.g. before calling Status).
+func (c *CommandStation) CurrentPower(track Track) (PowerState, bool) {
+	return c.power.get(track)
+}
+
+// PowerEvents returns a channel which receives the power changes broadcasted by the command station.
+// CurrentPower already returns the changed state once the event is received.
+// Never close the channel manually but instead call the cleanup function.
+func (c *CommandStation) PowerEvents() (PowerEventC, protocol.CleanupF) {
+	return subscribe(c.channel, command.OpCodePower, func(cmd *command.Command) (*PowerEvent, error) {
+		event, err := parsePowerEvent(cmd)
+		if err != nil {
+			return nil, err
+		}
+
+		// The tracking routine might not have seen the broadcast yet.
+		c.power.apply(event)
+		return event, nil
+	})
+}
+
+// Join joins the programming track to the main track by powering both of them using <1 JOIN>.
+// This allows driving a loco from the main track onto the programming track.
+// It returns once the command station confirmed the joined tracks with <p1 JOIN>.
+func (c *CommandStation) Join(ctx context.Context) error {
+	return c.powerTrackConfirmed(ctx, PowerOn, TrackJoin)
+}
+
+// Unjoin restores the separate programming track after Join by powering it off using <0 PROG>.
+// The main track stays powered.
+// It returns once the command station confirmed the programming track to be powered off with <p0 PROG>.
+func (c *CommandStation) Unjoin(ctx context.Context) error {
+	return c.powerTrackConfirmed(ctx, PowerOff, TrackProg)
+}
+
+// powerTrackConfirmed sets the tracks power and waits for the <p state track> confirmation.
+func (c *CommandStation) powerTrackConfirmed(ctx context.Context, state PowerState, track Track) error {
+	confirmed := false
+	confirmationMatcher := command.NewMatcher(command.OpCodePower, string(state), string(track))
+
+	err := c.channel.WriteAndReadOpCode(ctx, command.NewCommand(state.OpCode(), "%s", track), command.OpCodePower, func(cmd *command.Command) error {
+		if confirmationMatcher.Match(cmd) {
+			confirmed = true
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set power %q on track %q: %w", state, track, err)
+	}
+
+	if !confirmed {
+		return fmt.Errorf("failed to set power %q on track %q: %w", state, track, errs.ErrStationFailure)
+	}
+
+	return nil
+}
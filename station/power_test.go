@@ -0,0 +1,80 @@
+package station_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/channel"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+	"github.com/roosterfish/dcc-ex-go/simulator"
+	"github.com/roosterfish/dcc-ex-go/station"
+)
+
+func TestPowerEvents(t *testing.T) {
+	sim := simulator.NewSimulator()
+
+	p := protocol.NewProtocol(sim, &protocol.Config{})
+	defer p.Close()
+
+	commandStation := station.NewStation(channel.NewChannel(p))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, ok := commandStation.CurrentPower(station.TrackMain)
+	if ok {
+		t.Fatal("Expected unknown power state")
+	}
+
+	powerEventC, cleanupF := commandStation.PowerEvents()
+	defer cleanupF()
+
+	errC := make(chan error, 1)
+	go func() {
+		errC <- commandStation.PowerTrack(ctx, station.PowerOn, station.TrackMain)
+	}()
+
+	select {
+	case event := <-powerEventC:
+		if event.State != station.PowerOn || event.Track != station.TrackMain {
+			t.Fatalf("Expected MAIN to be powered on, got %+v", event)
+		}
+	case <-ctx.Done():
+		t.Fatal("Expected power event")
+	}
+
+	cleanupF()
+
+	err := <-errC
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state, ok := commandStation.CurrentPower(station.TrackMain)
+	if !ok || state != station.PowerOn {
+		t.Errorf("Expected MAIN to be powered on, got %q", state)
+	}
+}
+
+func TestJoin(t *testing.T) {
+	sim := simulator.NewSimulator()
+
+	p := protocol.NewProtocol(sim, &protocol.Config{})
+	defer p.Close()
+
+	commandStation := station.NewStation(channel.NewChannel(p))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := commandStation.Join(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = commandStation.Unjoin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,131 @@
+package station
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/roosterfish/dcc-ex-go/command"
+)
+
+// RosterFunction is the label of a loco's function.
+type RosterFunction struct {
+	// Label is empty for unlabeled functions.
+	Label string
+	// Momentary is set for functions which are only active while pressed (e.g. a horn).
+	Momentary bool
+}
+
+// RosterEntry is a loco of the command station's roster.
+type RosterEntry struct {
+	Address uint16
+	Name    string
+	// Functions are the functions F0, F1, ... of the loco.
+	Functions []RosterFunction
+}
+
+// parseRosterFunctions decodes the function map of a roster entry, e.g. "Lights/*Horn//Bell".
+// Momentary functions are prefixed with an asterisk.
+func parseRosterFunctions(functionMap string) []RosterFunction {
+	functionMap = strings.TrimSpace(functionMap)
+	if functionMap == "" {
+		return nil
+	}
+
+	labels := strings.Split(functionMap, "/")
+	functions := make([]RosterFunction, 0, len(labels))
+	for _, label := range labels {
+		momentaryLabel, momentary := strings.CutPrefix(label, "*")
+		functions = append(functions, RosterFunction{
+			Label:     momentaryLabel,
+			Momentary: momentary,
+		})
+	}
+
+	return functions
+}
+
+// Roster returns the locos of the command station's roster.
+// The command station responds to <JR> with <jR address1 address2 ...>
+// and to <JR address> with <jR address "name" "functions">.
+func (c *CommandStation) Roster(ctx context.Context) ([]*RosterEntry, error) {
+	var entries []*RosterEntry
+
+	err := c.channel.SessionContext(ctx, func(ctx context.Context) error {
+		var addresses []uint16
+
+		listCommand := command.NewMultiCommand(command.OpCodeRoster, "")
+		err := c.channel.WriteAndReadMultiOpCode(ctx, listCommand, command.OpCodeRosterResponse, func(cmd *command.Command) error {
+			params, err := cmd.ParametersStrings()
+			if err != nil {
+				return fmt.Errorf("failed getting roster command parameters: %w", err)
+			}
+
+			for _, param := range params[1:] {
+				address, err := strconv.ParseUint(param, 10, 16)
+				if err != nil {
+					return fmt.Errorf("invalid roster address %q: %w", param, err)
+				}
+
+				addresses = append(addresses, uint16(address))
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, address := range addresses {
+			entry, err := c.rosterEntry(ctx, address)
+			if err != nil {
+				return err
+			}
+
+			entries = append(entries, entry)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get roster: %w", err)
+	}
+
+	return entries, nil
+}
+
+// rosterEntry returns the roster entry of the given address.
+func (c *CommandStation) rosterEntry(ctx context.Context, address uint16) (*RosterEntry, error) {
+	var entry *RosterEntry
+
+	entryCommand := command.NewMultiCommand(command.OpCodeRoster, "%d", address)
+	err := c.channel.WriteAndReadMultiOpCode(ctx, entryCommand, command.OpCodeRosterResponse, func(cmd *command.Command) error {
+		params, err := cmd.ParametersStrings()
+		if err != nil {
+			return fmt.Errorf("failed getting roster entry command parameters: %w", err)
+		}
+
+		if len(params) != 4 || params[1] != strconv.FormatUint(uint64(address), 10) {
+			// Not the right entry, skip it.
+			return nil
+		}
+
+		entry = &RosterEntry{
+			Address:   address,
+			Name:      strings.TrimSpace(params[2]),
+			Functions: parseRosterFunctions(params[3]),
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if entry == nil {
+		return nil, fmt.Errorf("failed to find roster entry %d", address)
+	}
+
+	return entry, nil
+}
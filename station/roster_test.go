@@ -0,0 +1,53 @@
+package station_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/channel"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+	"github.com/roosterfish/dcc-ex-go/simulator"
+	"github.com/roosterfish/dcc-ex-go/station"
+)
+
+func TestRoster(t *testing.T) {
+	sim := simulator.NewSimulator()
+	sim.AddRosterEntry(3, "Thomas", "Lights/*Horn//Bell")
+	sim.AddRosterEntry(200, "Percy", "")
+
+	p := protocol.NewProtocol(sim, &protocol.Config{})
+	defer p.Close()
+
+	commandStation := station.NewStation(channel.NewChannel(p))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	roster, err := commandStation.Roster(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []*station.RosterEntry{
+		{
+			Address: 3,
+			Name:    "Thomas",
+			Functions: []station.RosterFunction{
+				{Label: "Lights"},
+				{Label: "Horn", Momentary: true},
+				{},
+				{Label: "Bell"},
+			},
+		},
+		{
+			Address: 200,
+			Name:    "Percy",
+		},
+	}
+
+	if !reflect.DeepEqual(roster, expected) {
+		t.Errorf("Expected roster %+v, got %+v", expected, roster)
+	}
+}
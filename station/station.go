@@ -5,9 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/roosterfish/dcc-ex-go/channel"
 	"github.com/roosterfish/dcc-ex-go/command"
+	"github.com/roosterfish/dcc-ex-go/errs"
 	"github.com/roosterfish/dcc-ex-go/protocol"
 )
 
@@ -15,8 +18,8 @@ type PowerState command.OpCode
 type Track string
 
 const (
-	PowerOff PowerState = '0'
-	PowerOn  PowerState = '1'
+	PowerOff PowerState = PowerState(command.OpCodePowerOff)
+	PowerOn  PowerState = PowerState(command.OpCodePowerOn)
 )
 
 const (
@@ -25,21 +28,46 @@ const (
 	TrackJoin Track = "JOIN"
 )
 
+// Status is the command station's response to <s>, e.g. <iDCC-EX V-5.4.0 / MEGA / STANDARD_MOTOR_SHIELD G-devel>.
+// Fields which cannot be found in the response are left empty.
 type Status struct {
 	Version             string
 	MicroprocessorType  string
 	MotorcontrollerType string
 	BuildNumber         string
+	// Raw is the full status text as sent by the command station.
+	Raw string
 }
 
 type CommandStation struct {
-	channel *channel.Channel
+	channel channel.Interface
+	power   *powerStates
+	// stopTrackingF stops tracking the power states.
+	stopTrackingF protocol.CleanupF
+
+	// firmwareVersion caches the version read by SupportsFeature.
+	firmwareVersion *Version
+	versionLock     sync.Mutex
 }
 
-func NewStation(channel *channel.Channel) *CommandStation {
-	return &CommandStation{
+// NewStation returns a command station using the given channel.
+// It starts tracking the power state of the tracks which is stopped by calling Close or once the channel's protocol is closed.
+// Create a single command station per channel and reuse it.
+func NewStation(channel channel.Interface) *CommandStation {
+	c := &CommandStation{
 		channel: channel,
+		power: &powerStates{
+			states: make(map[Track]PowerState),
+		},
 	}
+
+	c.stopTrackingF = c.trackPower()
+	return c
+}
+
+// Close stops tracking the power state of the tracks. The channel stays open.
+func (c *CommandStation) Close() {
+	c.stopTrackingF()
 }
 
 func (s PowerState) OpCode() command.OpCode {
@@ -56,8 +84,10 @@ func (c *CommandStation) Console() (protocol.CommandC, channel.WriteF, protocol.
 	var cleanupF protocol.CleanupF
 	var writeF channel.WriteF
 
-	_ = c.channel.Session(func(protocol protocol.ReadWriteCloser) error {
-		commandC, cleanupF = protocol.Read()
+	_ = c.channel.Session(func(sessionProtocol protocol.ReadWriteCloser) error {
+		commandC, cleanupF = protocol.ReadWithOptions(sessionProtocol, &protocol.ReadOptions{
+			Ownership: protocol.OwnershipObserver,
+		})
 		writeF = c.channel.Write
 		return nil
 	})
@@ -82,6 +112,27 @@ func (c *CommandStation) Power(ctx context.Context, state PowerState) error {
 	})
 }
 
+// EStopAll stops all of the cabs immediately using <!>. The tracks stay powered.
+// It's written as a safety command (see channel.Interface.WriteSafety) so it doesn't queue behind other sessions.
+func (c *CommandStation) EStopAll() error {
+	err := c.channel.WriteSafety(command.NewCommand(command.OpCodeEmergencyStop, ""))
+	if err != nil {
+		return fmt.Errorf("failed to emergency stop all cabs: %w", err)
+	}
+
+	return nil
+}
+
+// ForgetCabs removes all of the cabs from the command station's reminders using <-> which frees their slots.
+func (c *CommandStation) ForgetCabs(ctx context.Context) error {
+	err := c.channel.Write(ctx, command.NewCommand(command.OpCodeCabForget, ""))
+	if err != nil {
+		return fmt.Errorf("failed to forget cabs: %w", err)
+	}
+
+	return nil
+}
+
 // PowerTrack sets the tracks power to the given state.
 func (c *CommandStation) PowerTrack(ctx context.Context, state PowerState, track Track) error {
 	powerChanged := false
@@ -112,7 +163,7 @@ func (c *CommandStation) PowerTrack(ctx context.Context, state PowerState, track
 	}
 
 	if !powerChanged {
-		return fmt.Errorf("failed to set power %q on track %q", state, track)
+		return fmt.Errorf("failed to set power %q on track %q: %w", state, track, errs.ErrStationFailure)
 	}
 
 	return nil
@@ -120,35 +171,111 @@ func (c *CommandStation) PowerTrack(ctx context.Context, state PowerState, track
 
 // Ready waits for the <@ 0 3 "Ready"> broadcast message which indicates the station is ready the receive commands.
 func (c *CommandStation) Ready(ctx context.Context) error {
-	return c.channel.RSession(func(protocol protocol.Reader) error {
-		readyCommand := command.NewCommand(command.OpCodeInfo, "%d %d %q", 0, 3, "Ready")
-		return protocol.ReadCommand(ctx, readyCommand)
+	return c.channel.RSession(func(sessionProtocol protocol.Reader) error {
+		return protocol.ReadMatch(ctx, sessionProtocol, command.NewMatcher(command.OpCodeInfo, 0, 3, "Ready"))
 	})
 }
 
-// Status returns DCC-EX version and hardware info, along with defined turnouts.
-func (c *CommandStation) Status(ctx context.Context) (*Status, error) {
-	var status *Status
+// ParseStatus parses the command station's <i...> status response.
+// The layout of the response differs between firmware versions and boards, e.g.
+// <iDCC-EX V-5.4.0 / MEGA / STANDARD_MOTOR_SHIELD G-devel> or <iDCC-EX V-3.0.4 / UNO / STANDARD_MOTOR_SHIELD / 7>.
+// Its sections are separated by slashes. The version and build number are identified using their V- and G- prefixes.
+func ParseStatus(cmd *command.Command) (*Status, error) {
+	if cmd.OpCode() != command.OpCodeStatusResponse {
+		return nil, fmt.Errorf("failed to get status from %q: invalid op code %q", cmd.String(), cmd.OpCode())
+	}
 
-	statusCommand := command.NewCommand(command.OpCodeStatus, "")
-	err := c.channel.WriteAndReadOpCode(ctx, statusCommand, command.OpCodeStatusResponse, func(cmd *command.Command) error {
-		params, err := cmd.ParametersStrings()
-		if err != nil {
-			return fmt.Errorf("failed getting command station command parameters: %w", err)
+	raw := strings.TrimSpace(strings.TrimPrefix(strings.Trim(cmd.Raw(), "<>"), string(command.OpCodeStatusResponse)))
+	if raw == "" {
+		return nil, fmt.Errorf("failed to get status from %q: empty response", cmd.String())
+	}
+
+	status := &Status{
+		Raw: raw,
+	}
+
+	sections := strings.Split(raw, "/")
+	for i, section := range sections {
+		var remaining []string
+		for _, field := range strings.Fields(section) {
+			switch {
+			case status.Version == "" && strings.HasPrefix(field, "V-"):
+				status.Version = field
+			case status.BuildNumber == "" && strings.HasPrefix(field, "G-"):
+				status.BuildNumber = field
+			default:
+				remaining = append(remaining, field)
+			}
 		}
 
-		if len(params) != 7 {
-			return fmt.Errorf("invalid command station command parameter length %q", len(params))
+		text := strings.Join(remaining, " ")
+		switch i {
+		case 1:
+			status.MicroprocessorType = text
+		case 2:
+			status.MotorcontrollerType = text
+		case 3:
+			// Older firmwares append the build number as a separate section.
+			if status.BuildNumber == "" {
+				status.BuildNumber = text
+			}
 		}
+	}
+
+	return status, nil
+}
+
+// Restart restarts the command station using <D RESET> and waits for the <@ 0 3 "Ready"> broadcast message.
+// The channel is held exclusively until the command station is ready again.
+// In case the command station's port drops while restarting (e.g. USB), the connection must be configured to reconnect.
+func (c *CommandStation) Restart(ctx context.Context) error {
+	err := c.channel.Session(func(sessionProtocol protocol.ReadWriteCloser) error {
+		// Subscribe before restarting to not miss the broadcast.
+		commandC, cleanupF := protocol.ReadFiltered(sessionProtocol, command.OpCodeInfo)
+		defer cleanupF()
 
-		status = &Status{
-			Version:             params[1],
-			MicroprocessorType:  params[3],
-			MotorcontrollerType: params[5],
-			BuildNumber:         params[6],
+		err := sessionProtocol.Write(command.NewCommand(command.OpCodeDiagnostic, "RESET"))
+		if err != nil {
+			return err
 		}
 
-		return nil
+		readyMatcher := command.NewMatcher(command.OpCodeInfo, 0, 3, "Ready")
+		for {
+			select {
+			case cmd, ok := <-commandC:
+				if !ok {
+					return protocol.ErrClosed
+				}
+
+				if readyMatcher.Match(cmd) {
+					return nil
+				}
+			case <-ctx.Done():
+				return errs.Context(context.Cause(ctx))
+			}
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to restart command station: %w", err)
+	}
+
+	// The firmware might have been updated in the meantime.
+	c.versionLock.Lock()
+	c.firmwareVersion = nil
+	c.versionLock.Unlock()
+
+	return nil
+}
+
+// Status returns DCC-EX version and hardware info, along with defined turnouts.
+func (c *CommandStation) Status(ctx context.Context) (*Status, error) {
+	var status *Status
+
+	statusCommand := command.NewCommand(command.OpCodeStatus, "")
+	err := c.channel.WriteAndReadOpCode(ctx, statusCommand, command.OpCodeStatusResponse, func(cmd *command.Command) error {
+		var err error
+		status, err = ParseStatus(cmd)
+		return err
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get command station status: %w", err)
@@ -0,0 +1,131 @@
+package station_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/cab"
+	"github.com/roosterfish/dcc-ex-go/channel"
+	"github.com/roosterfish/dcc-ex-go/command"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+	"github.com/roosterfish/dcc-ex-go/simulator"
+	"github.com/roosterfish/dcc-ex-go/station"
+)
+
+func TestRestart(t *testing.T) {
+	sim := simulator.NewSimulator()
+
+	p := protocol.NewProtocol(sim, &protocol.Config{})
+	defer p.Close()
+
+	commandStation := station.NewStation(channel.NewChannel(p))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := commandStation.Restart(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The channel is usable after restarting.
+	_, err = commandStation.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEStopAll(t *testing.T) {
+	p := protocol.NewProtocol(simulator.NewSimulator(), &protocol.Config{})
+	defer p.Close()
+
+	ch := channel.NewChannel(p)
+	commandStation := station.NewStation(ch)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	directions := map[cab.Address]cab.Direction{
+		3: cab.DirectionForward,
+		4: cab.DirectionBackward,
+	}
+
+	for address, direction := range directions {
+		err := cab.NewCab(address, ch).Speed(ctx, 50, direction)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := commandStation.EStopAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for address, direction := range directions {
+		status, err := cab.NewCab(address, ch).Status(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		speed, gotDirection := status.SpeedDirection()
+		if speed != -1 || gotDirection != direction {
+			t.Errorf("Expected cab %d to be emergency stopped in direction %d but got speed %d and direction %d", address, direction, speed, gotDirection)
+		}
+	}
+}
+
+func TestParseStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		expected station.Status
+	}{
+		{
+			name:     "mega with standard motor shield",
+			response: "<iDCC-EX V-5.4.0 / MEGA / STANDARD_MOTOR_SHIELD G-devel>",
+			expected: station.Status{Version: "V-5.4.0", MicroprocessorType: "MEGA", MotorcontrollerType: "STANDARD_MOTOR_SHIELD", BuildNumber: "G-devel"},
+		},
+		{
+			name:     "esp32 with EX8874",
+			response: "<iDCC-EX V-5.0.7 / ESP32 / EX8874 G-3bddf4d>",
+			expected: station.Status{Version: "V-5.0.7", MicroprocessorType: "ESP32", MotorcontrollerType: "EX8874", BuildNumber: "G-3bddf4d"},
+		},
+		{
+			name:     "build number as separate section",
+			response: "<iDCC-EX V-3.0.4 / UNO / STANDARD_MOTOR_SHIELD / 7>",
+			expected: station.Status{Version: "V-3.0.4", MicroprocessorType: "UNO", MotorcontrollerType: "STANDARD_MOTOR_SHIELD", BuildNumber: "7"},
+		},
+		{
+			name:     "without build number",
+			response: "<iDCC-EX V-4.2.28 / NANOEVERY / POLOLU_MOTOR_SHIELD>",
+			expected: station.Status{Version: "V-4.2.28", MicroprocessorType: "NANOEVERY", MotorcontrollerType: "POLOLU_MOTOR_SHIELD"},
+		},
+		{
+			name:     "motor shield name with spaces",
+			response: "<iDCC-EX V-5.2.76 / ESP32 / EX CSB1 G-9fd2b06>",
+			expected: station.Status{Version: "V-5.2.76", MicroprocessorType: "ESP32", MotorcontrollerType: "EX CSB1", BuildNumber: "G-9fd2b06"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cmd, err := command.NewCommandFromString(test.response)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			status, err := station.ParseStatus(cmd)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			test.expected.Raw = strings.Trim(test.response, "<>i")
+			if *status != test.expected {
+				t.Errorf("Expected %+v, got %+v", test.expected, *status)
+			}
+		})
+	}
+}
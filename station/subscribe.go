@@ -0,0 +1,59 @@
+package station
+
+import (
+	"sync"
+
+	"github.com/roosterfish/dcc-ex-go/channel"
+	"github.com/roosterfish/dcc-ex-go/command"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+)
+
+// subscribe returns a channel which receives the broadcasts with the given op code decoded using decodeF.
+// Broadcasts which cannot be decoded are skipped.
+func subscribe[T any](ch channel.Interface, opCode command.OpCode, decodeF func(cmd *command.Command) (T, error)) (chan T, protocol.CleanupF) {
+	var commandC protocol.CommandC
+	var cleanupF protocol.CleanupF
+
+	_ = ch.RSession(func(sessionProtocol protocol.Reader) error {
+		commandC, cleanupF = protocol.ReadFiltered(sessionProtocol, opCode)
+		return nil
+	})
+
+	decodedC := make(chan T)
+	doneC := make(chan struct{})
+	wg := sync.WaitGroup{}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		for {
+			select {
+			case cmd, ok := <-commandC:
+				if !ok {
+					return
+				}
+
+				decoded, err := decodeF(cmd)
+				if err != nil {
+					continue
+				}
+
+				select {
+				case decodedC <- decoded:
+				case <-doneC:
+					return
+				}
+			case <-doneC:
+				return
+			}
+		}
+	}()
+
+	return decodedC, sync.OnceFunc(func() {
+		close(doneC)
+		wg.Wait()
+		cleanupF()
+		close(decodedC)
+	})
+}
@@ -0,0 +1,155 @@
+package station
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/roosterfish/dcc-ex-go/command"
+	"github.com/roosterfish/dcc-ex-go/errs"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+)
+
+// TrackMode is the mode of a track output of the track manager.
+type TrackMode string
+
+const (
+	TrackModeMain TrackMode = "MAIN"
+	TrackModeProg TrackMode = "PROG"
+	// TrackModeDC drives a DC cab with the given address.
+	TrackModeDC TrackMode = "DC"
+	// TrackModeDCX drives a DC cab with the given address using reversed polarity.
+	TrackModeDCX  TrackMode = "DCX"
+	TrackModeNone TrackMode = "NONE"
+)
+
+// TrackAssignmentC receives the track assignments broadcasted by the command station.
+type TrackAssignmentC chan *TrackAssignment
+
+// TrackAssignment is the mode of a track output (A-H).
+type TrackAssignment struct {
+	Track string
+	Mode  TrackMode
+	// Cab is the address of the DC cab for the modes DC and DCX.
+	Cab uint16
+}
+
+// ParseTrackAssignment parses an assignment in the form of MAIN, PROG, NONE, DC <cab> or DCX <cab> for the given track.
+func ParseTrackAssignment(track string, assignment string) (*TrackAssignment, error) {
+	track = strings.ToUpper(track)
+	if len(track) != 1 || track[0] < 'A' || track[0] > 'H' {
+		return nil, fmt.Errorf("invalid track %q: must be one of A-H", track)
+	}
+
+	fields := strings.Fields(strings.ToUpper(assignment))
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty assignment for track %q", track)
+	}
+
+	trackAssignment := &TrackAssignment{
+		Track: track,
+		Mode:  TrackMode(fields[0]),
+	}
+
+	switch trackAssignment.Mode {
+	case TrackModeMain, TrackModeProg, TrackModeNone:
+		if len(fields) != 1 {
+			return nil, fmt.Errorf("invalid assignment %q for track %q", assignment, track)
+		}
+	case TrackModeDC, TrackModeDCX:
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("missing cab address in assignment %q for track %q", assignment, track)
+		}
+
+		cab, err := strconv.ParseUint(fields[1], 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cab address in assignment %q for track %q: %w", assignment, track, err)
+		}
+
+		trackAssignment.Cab = uint16(cab)
+	default:
+		return nil, fmt.Errorf("unknown mode %q for track %q", fields[0], track)
+	}
+
+	return trackAssignment, nil
+}
+
+func (a *TrackAssignment) String() string {
+	if a.Mode == TrackModeDC || a.Mode == TrackModeDCX {
+		return fmt.Sprintf("%s %s %d", a.Track, a.Mode, a.Cab)
+	}
+
+	return fmt.Sprintf("%s %s", a.Track, a.Mode)
+}
+
+// parseTrackAssignment parses the <= track mode [cab]> response.
+func parseTrackAssignment(cmd *command.Command) (*TrackAssignment, error) {
+	params, err := cmd.ParametersStrings()
+	if err != nil {
+		return nil, fmt.Errorf("failed getting track manager command parameters: %w", err)
+	}
+
+	if len(params) < 2 {
+		return nil, fmt.Errorf("invalid track manager command parameter length %d", len(params))
+	}
+
+	return ParseTrackAssignment(params[0], strings.Join(params[1:], " "))
+}
+
+// TrackAssignments returns the modes of all of the track outputs keyed by track.
+func (c *CommandStation) TrackAssignments(ctx context.Context) (map[string]*TrackAssignment, error) {
+	assignments := map[string]*TrackAssignment{}
+
+	listCommand := command.NewCommand(command.OpCodeTrackManager, "")
+	err := c.channel.WriteAndReadOpCode(ctx, listCommand, command.OpCodeTrackManager, func(cmd *command.Command) error {
+		assignment, err := parseTrackAssignment(cmd)
+		if err != nil {
+			return err
+		}
+
+		assignments[assignment.Track] = assignment
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list track assignments: %w", err)
+	}
+
+	return assignments, nil
+}
+
+// AssignTrack sets the mode of a track output.
+func (c *CommandStation) AssignTrack(ctx context.Context, assignment *TrackAssignment) error {
+	assigned := false
+
+	assignCommand := command.NewCommand(command.OpCodeTrackManager, "%s", assignment.String())
+	err := c.channel.WriteAndReadOpCode(ctx, assignCommand, command.OpCodeTrackManager, func(cmd *command.Command) error {
+		response, err := parseTrackAssignment(cmd)
+		if err != nil {
+			return err
+		}
+
+		if *response == *assignment {
+			assigned = true
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to assign track %q: %w", assignment, err)
+	}
+
+	if !assigned {
+		return fmt.Errorf("failed to assign track %q: %w", assignment, errs.ErrStationFailure)
+	}
+
+	return nil
+}
+
+// TrackAssignmentUpdates returns a channel which receives the <= track mode [cab]> broadcasts sent by the
+// command station every time the mode of a track output changes.
+// Never close the channel manually but instead call the cleanup function.
+func (c *CommandStation) TrackAssignmentUpdates() (TrackAssignmentC, protocol.CleanupF) {
+	assignmentC, cleanupF := subscribe(c.channel, command.OpCodeTrackManager, parseTrackAssignment)
+	return TrackAssignmentC(assignmentC), cleanupF
+}
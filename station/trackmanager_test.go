@@ -0,0 +1,66 @@
+package station_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/channel"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+	"github.com/roosterfish/dcc-ex-go/simulator"
+	"github.com/roosterfish/dcc-ex-go/station"
+)
+
+func TestTrackManager(t *testing.T) {
+	p := protocol.NewProtocol(simulator.NewSimulator(), &protocol.Config{})
+	defer p.Close()
+
+	commandStation := station.NewStation(channel.NewChannel(p))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := station.ParseTrackAssignment("I", "MAIN")
+	if err == nil {
+		t.Error("Expected track I to be refused")
+	}
+
+	assignment, err := station.ParseTrackAssignment("c", "dc 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assignmentC, cleanupF := commandStation.TrackAssignmentUpdates()
+	defer cleanupF()
+
+	errC := make(chan error, 1)
+	go func() {
+		errC <- commandStation.AssignTrack(ctx, assignment)
+	}()
+
+	select {
+	case update := <-assignmentC:
+		if *update != *assignment {
+			t.Errorf("Expected %v but got %v", assignment, update)
+		}
+	case <-ctx.Done():
+		t.Fatal("Expected track assignment broadcast")
+	}
+
+	err = <-errC
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Listing the assignments broadcasts all of them, stop consuming the updates.
+	cleanupF()
+
+	assignments, err := commandStation.TrackAssignments(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(assignments) != 3 || assignments["C"].Mode != station.TrackModeDC || assignments["C"].Cab != 3 {
+		t.Errorf("Unexpected track assignments %v", assignments)
+	}
+}
@@ -0,0 +1,134 @@
+package station
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is the semantic version of the command station's firmware, e.g. V-5.4.0.
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+}
+
+// Feature is a capability of the command station which depends on its firmware version.
+type Feature string
+
+const (
+	// FeatureJSONCommands are the J-series commands like <JT> or <JA>.
+	FeatureJSONCommands Feature = "json commands"
+	// FeatureTrackCurrents is reading the currents of all tracks using <JI>.
+	FeatureTrackCurrents Feature = "track currents"
+	// FeatureTrackManager is assigning the tracks using <= ...>.
+	FeatureTrackManager Feature = "track manager"
+	// FeatureTurntables are the turntable commands <I>, <JO> and <JP>.
+	FeatureTurntables Feature = "turntables"
+)
+
+// featureVersions are the minimum versions of the features.
+var featureVersions = map[Feature]Version{
+	FeatureJSONCommands:  {Major: 4},
+	FeatureTrackCurrents: {Major: 4, Minor: 2},
+	FeatureTrackManager:  {Major: 5},
+	FeatureTurntables:    {Major: 5, Minor: 2},
+}
+
+// ParseVersion parses the version of the command station's status, e.g. V-5.4.0.
+// The prefix, missing minor and patch numbers as well as suffixes like V-5.2.40-Devel are accepted.
+func ParseVersion(version string) (Version, error) {
+	trimmed := strings.TrimPrefix(strings.TrimPrefix(strings.TrimPrefix(version, "V"), "v"), "-")
+	trimmed, _, _ = strings.Cut(trimmed, "-")
+
+	parts := strings.Split(trimmed, ".")
+	if len(parts) > 3 {
+		return Version{}, fmt.Errorf("invalid version %q", version)
+	}
+
+	numbers := make([]int, 3)
+	for i, part := range parts {
+		number, err := strconv.Atoi(part)
+		if err != nil || number < 0 {
+			return Version{}, fmt.Errorf("invalid version %q", version)
+		}
+
+		numbers[i] = number
+	}
+
+	return Version{
+		Major: numbers[0],
+		Minor: numbers[1],
+		Patch: numbers[2],
+	}, nil
+}
+
+// Compare returns -1 if v is older than other, 1 if it's newer and 0 if both are equal.
+func (v Version) Compare(other Version) int {
+	for _, diff := range []int{v.Major - other.Major, v.Minor - other.Minor, v.Patch - other.Patch} {
+		if diff < 0 {
+			return -1
+		}
+
+		if diff > 0 {
+			return 1
+		}
+	}
+
+	return 0
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("V-%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// MinimumVersion returns the oldest firmware version supporting the feature.
+func (f Feature) MinimumVersion() (Version, bool) {
+	version, ok := featureVersions[f]
+	return version, ok
+}
+
+// SemanticVersion parses the status' version.
+func (s *Status) SemanticVersion() (Version, error) {
+	return ParseVersion(s.Version)
+}
+
+// SupportsFeature returns whether or not the command station's firmware supports the given feature.
+// The firmware version is read using Status once and cached afterwards.
+func (c *CommandStation) SupportsFeature(ctx context.Context, feature Feature) (bool, error) {
+	minimum, ok := feature.MinimumVersion()
+	if !ok {
+		return false, fmt.Errorf("unknown feature %q", feature)
+	}
+
+	version, err := c.version(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to check support for %q: %w", feature, err)
+	}
+
+	return version.Compare(minimum) >= 0, nil
+}
+
+// version returns the cached firmware version and reads it in case it isn't known yet.
+func (c *CommandStation) version(ctx context.Context) (Version, error) {
+	c.versionLock.Lock()
+	defer c.versionLock.Unlock()
+
+	if c.firmwareVersion != nil {
+		return *c.firmwareVersion, nil
+	}
+
+	status, err := c.Status(ctx)
+	if err != nil {
+		return Version{}, err
+	}
+
+	version, err := status.SemanticVersion()
+	if err != nil {
+		return Version{}, err
+	}
+
+	c.firmwareVersion = &version
+	return version, nil
+}
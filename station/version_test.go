@@ -0,0 +1,82 @@
+package station_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/channel"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+	"github.com/roosterfish/dcc-ex-go/simulator"
+	"github.com/roosterfish/dcc-ex-go/station"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		version  string
+		expected station.Version
+		invalid  bool
+	}{
+		{version: "V-5.4.0", expected: station.Version{Major: 5, Minor: 4}},
+		{version: "V-5.2.40-Devel", expected: station.Version{Major: 5, Minor: 2, Patch: 40}},
+		{version: "4.2", expected: station.Version{Major: 4, Minor: 2}},
+		{version: "V-abc", invalid: true},
+		{version: "", invalid: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.version, func(t *testing.T) {
+			version, err := station.ParseVersion(test.version)
+			if test.invalid {
+				if err == nil {
+					t.Errorf("Expected version %q to be invalid", test.version)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if version != test.expected {
+				t.Errorf("Expected %v, got %v", test.expected, version)
+			}
+		})
+	}
+}
+
+func TestVersionCompare(t *testing.T) {
+	older := station.Version{Major: 4, Minor: 2, Patch: 28}
+	newer := station.Version{Major: 5}
+
+	if older.Compare(newer) != -1 || newer.Compare(older) != 1 || newer.Compare(newer) != 0 {
+		t.Error("Expected versions to be ordered")
+	}
+}
+
+func TestSupportsFeature(t *testing.T) {
+	sim := simulator.NewSimulator()
+
+	p := protocol.NewProtocol(sim, &protocol.Config{})
+	defer p.Close()
+
+	commandStation := station.NewStation(channel.NewChannel(p))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	supported, err := commandStation.SupportsFeature(ctx, station.FeatureTrackManager)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !supported {
+		t.Errorf("Expected %s to support the track manager", simulator.Version)
+	}
+
+	_, err = commandStation.SupportsFeature(ctx, "teleportation")
+	if err == nil {
+		t.Error("Expected unknown feature to fail")
+	}
+}
@@ -0,0 +1,256 @@
+package stationsync
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/roosterfish/dcc-ex-go/channel"
+	"github.com/roosterfish/dcc-ex-go/command"
+	"github.com/roosterfish/dcc-ex-go/station"
+	dccvpin "github.com/roosterfish/dcc-ex-go/vpin"
+)
+
+// Definition is the definition of a sensor, turnout or output stored on a command station.
+type Definition struct {
+	Kind dccvpin.Kind
+	ID   uint16
+	// Params are the parameters of the definition following the ID (e.g. vpin and pullup of a sensor).
+	Params []string
+}
+
+// DiffType describes how a definition differs between the source and target station.
+type DiffType uint8
+
+const (
+	// DiffMissing means the definition only exists on the source station.
+	DiffMissing DiffType = iota
+	// DiffExtra means the definition only exists on the target station.
+	DiffExtra
+	// DiffChanged means the definition exists on both stations but differs.
+	DiffChanged
+)
+
+// Diff is a definition which differs between the source and target station.
+type Diff struct {
+	Type DiffType
+	Kind dccvpin.Kind
+	ID   uint16
+	// Source is nil for DiffExtra.
+	Source *Definition
+	// Target is nil for DiffMissing.
+	Target *Definition
+}
+
+type definitionKey struct {
+	kind dccvpin.Kind
+	id   uint16
+}
+
+func (t DiffType) String() string {
+	switch t {
+	case DiffMissing:
+		return "missing"
+	case DiffExtra:
+		return "extra"
+	case DiffChanged:
+		return "changed"
+	default:
+		return "unknown"
+	}
+}
+
+func (d *Definition) String() string {
+	return fmt.Sprintf("%s %d (%s)", d.Kind, d.ID, strings.Join(d.Params, " "))
+}
+
+// Command returns the command creating the definition.
+func (d *Definition) Command() *command.Command {
+	opCode := command.OpCodeSensorCreate
+	switch d.Kind {
+	case dccvpin.KindTurnout:
+		opCode = command.OpCodeTurnout
+	case dccvpin.KindOutput:
+		opCode = command.OpCodeOutput
+	}
+
+	return command.NewCommand(opCode, "%d %s", d.ID, strings.Join(d.Params, " "))
+}
+
+// vpin returns the vpin used by the definition.
+// Turnouts which don't use a vpin (e.g. DCC accessory decoders) return false.
+func (d *Definition) vpin() (uint16, bool) {
+	param := ""
+	switch d.Kind {
+	case dccvpin.KindSensor, dccvpin.KindOutput:
+		if len(d.Params) < 1 {
+			return 0, false
+		}
+
+		param = d.Params[0]
+	case dccvpin.KindTurnout:
+		if len(d.Params) < 2 || (d.Params[0] != "SERVO" && d.Params[0] != "VPIN") {
+			return 0, false
+		}
+
+		param = d.Params[1]
+	}
+
+	vpin, err := strconv.ParseUint(param, 10, 16)
+	if err != nil {
+		return 0, false
+	}
+
+	return uint16(vpin), true
+}
+
+// parseDefinition parses the definition out of the params of a listing response.
+// The listings of turnouts and outputs contain the current state as last parameter which isn't part of the definition.
+// It returns nil for responses which aren't definitions (e.g. state changes).
+func parseDefinition(kind dccvpin.Kind, cmd *command.Command, minParams int, hasState bool) (*Definition, error) {
+	params, err := cmd.ParametersStrings()
+	if err != nil {
+		return nil, fmt.Errorf("failed getting %s command parameters: %w", kind, err)
+	}
+
+	if len(params) < minParams {
+		return nil, nil
+	}
+
+	id, err := strconv.ParseUint(params[0], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s id %q: %w", kind, params[0], err)
+	}
+
+	params = params[1:]
+	if hasState {
+		params = params[:len(params)-1]
+	}
+
+	return &Definition{
+		Kind:   kind,
+		ID:     uint16(id),
+		Params: params,
+	}, nil
+}
+
+// List returns the definitions of all of the sensors, turnouts and outputs of the channel's command station.
+func List(ctx context.Context, channel *channel.Channel) ([]*Definition, error) {
+	var definitions []*Definition
+
+	listF := func(ctx context.Context, kind dccvpin.Kind, listCommand *command.Command, opCode command.OpCode, minParams int, hasState bool) error {
+		err := channel.WriteAndReadOpCode(ctx, listCommand, opCode, func(cmd *command.Command) error {
+			definition, err := parseDefinition(kind, cmd, minParams, hasState)
+			if err != nil {
+				return err
+			}
+
+			if definition != nil {
+				definitions = append(definitions, definition)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to list %ss: %w", kind, err)
+		}
+
+		return nil
+	}
+
+	err := channel.SessionContext(ctx, func(ctx context.Context) error {
+		// Sensors are listed as <Q id vpin pullup>.
+		err := listF(ctx, dccvpin.KindSensor, command.NewCommand(command.OpCodeSensorCreate, ""), command.OpCode('Q'), 3, false)
+		if err != nil {
+			return err
+		}
+
+		// Turnouts are listed as <H id type ... state>, state changes as <H id state>.
+		err = listF(ctx, dccvpin.KindTurnout, command.NewCommand(command.OpCodeTurnout, ""), command.OpCodeTurnoutResponse, 3, true)
+		if err != nil {
+			return err
+		}
+
+		// Outputs are listed as <Y id vpin iflag state>, state changes as <Y id state>.
+		return listF(ctx, dccvpin.KindOutput, command.NewCommand(command.OpCodeOutput, ""), command.OpCodeOutputResponse, 4, true)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return definitions, nil
+}
+
+// Compare returns the definitions which differ between the source and target station.
+// The differences are ordered by kind and ID.
+func Compare(ctx context.Context, source *channel.Channel, target *channel.Channel) ([]*Diff, error) {
+	sourceDefinitions, err := List(ctx, source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list source definitions: %w", err)
+	}
+
+	targetDefinitions, err := List(ctx, target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list target definitions: %w", err)
+	}
+
+	targets := make(map[definitionKey]*Definition, len(targetDefinitions))
+	for _, definition := range targetDefinitions {
+		targets[definitionKey{kind: definition.Kind, id: definition.ID}] = definition
+	}
+
+	var diffs []*Diff
+	for _, sourceDefinition := range sourceDefinitions {
+		key := definitionKey{kind: sourceDefinition.Kind, id: sourceDefinition.ID}
+
+		targetDefinition, ok := targets[key]
+		delete(targets, key)
+
+		if !ok {
+			diffs = append(diffs, &Diff{Type: DiffMissing, Kind: key.kind, ID: key.id, Source: sourceDefinition})
+		} else if !slices.Equal(sourceDefinition.Params, targetDefinition.Params) {
+			diffs = append(diffs, &Diff{Type: DiffChanged, Kind: key.kind, ID: key.id, Source: sourceDefinition, Target: targetDefinition})
+		}
+	}
+
+	for key, targetDefinition := range targets {
+		diffs = append(diffs, &Diff{Type: DiffExtra, Kind: key.kind, ID: key.id, Target: targetDefinition})
+	}
+
+	slices.SortFunc(diffs, func(a *Diff, b *Diff) int {
+		if a.Kind != b.Kind {
+			return strings.Compare(string(a.Kind), string(b.Kind))
+		}
+
+		return int(a.ID) - int(b.ID)
+	})
+
+	return diffs, nil
+}
+
+// Copy persists the given definitions on the target station.
+// Existing definitions with the same ID are replaced.
+// In case a vpin is already assigned to another entity, a *vpin.ConflictError is returned.
+// In case the EEPROM is estimated to be exhausted, station.ErrEEPROMFull is returned.
+func Copy(ctx context.Context, target *channel.Channel, definitions ...*Definition) error {
+	return target.SessionContext(ctx, func(ctx context.Context) error {
+		for _, definition := range definitions {
+			vpin, ok := definition.vpin()
+			if ok {
+				err := dccvpin.Check(ctx, target, vpin, dccvpin.Owner{Kind: definition.Kind, ID: definition.ID})
+				if err != nil {
+					return fmt.Errorf("failed to copy %s: %w", definition, err)
+				}
+			}
+
+			_, err := station.PersistDefinition(ctx, target, definition.Command())
+			if err != nil {
+				return fmt.Errorf("failed to copy %s: %w", definition, err)
+			}
+		}
+
+		return nil
+	})
+}
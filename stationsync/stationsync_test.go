@@ -0,0 +1,103 @@
+package stationsync_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/channel"
+	"github.com/roosterfish/dcc-ex-go/output"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+	"github.com/roosterfish/dcc-ex-go/sensor"
+	"github.com/roosterfish/dcc-ex-go/simulator"
+	"github.com/roosterfish/dcc-ex-go/stationsync"
+	"github.com/roosterfish/dcc-ex-go/turnout"
+	dccvpin "github.com/roosterfish/dcc-ex-go/vpin"
+)
+
+func newChannel(t *testing.T) *channel.Channel {
+	p := protocol.NewProtocol(simulator.NewSimulator(), &protocol.Config{})
+	t.Cleanup(func() {
+		_ = p.Close()
+	})
+
+	return channel.NewChannel(p)
+}
+
+func TestCompareAndCopy(t *testing.T) {
+	source := newChannel(t)
+	target := newChannel(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := sensor.NewSensor(1, source).Persist(ctx, 20, sensor.PullUpOn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = turnout.NewTurnoutServo(2, source).Persist(ctx, 100, 410, 205, turnout.ProfileSlow)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = output.NewOutput(3, source).Persist(ctx, 30, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = output.NewOutput(3, target).Persist(ctx, 31, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = output.NewOutput(4, target).Persist(ctx, 40, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diffs, err := stationsync.Compare(ctx, source, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		diffType stationsync.DiffType
+		kind     dccvpin.Kind
+		id       uint16
+	}{
+		{stationsync.DiffChanged, dccvpin.KindOutput, 3},
+		{stationsync.DiffExtra, dccvpin.KindOutput, 4},
+		{stationsync.DiffMissing, dccvpin.KindSensor, 1},
+		{stationsync.DiffMissing, dccvpin.KindTurnout, 2},
+	}
+
+	if len(diffs) != len(expected) {
+		t.Fatalf("Expected %d differences but got %d", len(expected), len(diffs))
+	}
+
+	var definitions []*stationsync.Definition
+	for i, diff := range diffs {
+		if diff.Type != expected[i].diffType || diff.Kind != expected[i].kind || diff.ID != expected[i].id {
+			t.Errorf("Expected %s %s %d but got %s %s %d", expected[i].diffType, expected[i].kind, expected[i].id, diff.Type, diff.Kind, diff.ID)
+		}
+
+		if diff.Source != nil {
+			definitions = append(definitions, diff.Source)
+		}
+	}
+
+	err = stationsync.Copy(ctx, target, definitions...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diffs, err = stationsync.Compare(ctx, source, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(diffs) != 1 || diffs[0].Type != stationsync.DiffExtra {
+		t.Errorf("Expected only the extra output to differ but got %d differences", len(diffs))
+	}
+}
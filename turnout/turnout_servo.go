@@ -3,10 +3,11 @@ package turnout
 import (
 	"context"
 	"fmt"
-	"strconv"
 
 	"github.com/roosterfish/dcc-ex-go/channel"
 	"github.com/roosterfish/dcc-ex-go/command"
+	"github.com/roosterfish/dcc-ex-go/station"
+	dccvpin "github.com/roosterfish/dcc-ex-go/vpin"
 )
 
 type ID uint16
@@ -16,7 +17,7 @@ type Profile uint8
 
 type TurnoutServo struct {
 	id      ID
-	channel *channel.Channel
+	channel channel.Interface
 }
 
 type TurnoutServoStatus struct {
@@ -35,32 +36,39 @@ const (
 	ProfileBounce
 )
 
-func NewTurnoutServo(id ID, channel *channel.Channel) *TurnoutServo {
+func NewTurnoutServo(id ID, channel channel.Interface) *TurnoutServo {
 	return &TurnoutServo{
 		id:      id,
 		channel: channel,
 	}
 }
 
+// ID returns the turnout's ID.
+func (t *TurnoutServo) ID() ID {
+	return t.id
+}
+
 // Persist creates the turnout and persists its definition in the EEPROM.
+// In case a vpin.Checker is passed using vpin.WithChecker and the vpin is already assigned to another entity,
+// a *vpin.ConflictError is returned.
+// In case the EEPROM is estimated to be exhausted, station.ErrEEPROMFull is returned.
 func (t *TurnoutServo) Persist(ctx context.Context, vpin VPin, thrownPos Position, closedPos Position, profile Profile) error {
-	turnoutCommand := command.NewCommand(command.OpCodeTurnout, "%d SERVO %d %d %d %d", t.id, vpin, thrownPos, closedPos, profile)
-	persistCommand := command.NewCommand(command.OpCodeEEPROM, "")
+	return t.channel.SessionContext(ctx, func(ctx context.Context) error {
+		owner := dccvpin.Owner{Kind: dccvpin.KindTurnout, ID: uint16(t.id)}
+		err := dccvpin.CheckContext(ctx, uint16(vpin), owner)
+		if err != nil {
+			return fmt.Errorf("failed to persist turnout servo %d: %w", t.id, err)
+		}
 
-	persisted := false
-	err := t.channel.WriteAndReadOpCode(ctx, turnoutCommand.Append(persistCommand), command.OpCodeSuccess, func(cmd *command.Command) error {
-		persisted = true
+		turnoutCommand := command.NewCommand(command.OpCodeTurnout, "%d SERVO %d %d %d %d", t.id, vpin, thrownPos, closedPos, profile)
+		_, err = station.PersistDefinition(ctx, t.channel, turnoutCommand)
+		if err != nil {
+			return fmt.Errorf("failed to persist turnout servo %d: %w", t.id, err)
+		}
+
+		dccvpin.AssignContext(ctx, uint16(vpin), owner)
 		return nil
 	})
-	if err != nil {
-		return err
-	}
-
-	if !persisted {
-		return fmt.Errorf("failed to persist turnout servo %d: %w", t.id, err)
-	}
-
-	return nil
 }
 
 func (t *TurnoutServo) setStateCommand(state State) *command.Command {
@@ -68,14 +76,33 @@ func (t *TurnoutServo) setStateCommand(state State) *command.Command {
 }
 
 func (t *TurnoutServo) equalsCommandParams(cmd *command.Command) error {
-	params, err := cmd.ParametersStrings()
+	// <H id state>
+	if !command.NewMatcher(command.OpCodeTurnoutResponse, t.id, command.Wildcard).Match(cmd) {
+		return fmt.Errorf("invalid response for turnout servo %d: %q", t.id, cmd.String())
+	}
+
+	return nil
+}
+
+// setState sets the turnout's state and waits for the command station to broadcast it.
+// The command station responds with <X> instead in case the turnout isn't defined.
+func (t *TurnoutServo) setState(ctx context.Context, state State) error {
+	broadcasted := false
+	err := t.channel.WriteAndReadOpCode(ctx, t.setStateCommand(state), command.OpCodeTurnoutResponse, func(cmd *command.Command) error {
+		err := t.equalsCommandParams(cmd)
+		if err != nil {
+			return err
+		}
+
+		broadcasted = true
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed getting turnout servo command parameters: %w", err)
+		return err
 	}
 
-	turnoutMatch := len(params) == 2 && params[0] == strconv.FormatUint(uint64(t.id), 10)
-	if !turnoutMatch {
-		return fmt.Errorf("invalid response for turnout servo %d: %q", t.id, cmd.String())
+	if !broadcasted {
+		return fmt.Errorf("failed to set state of turnout servo %d: %w", t.id, channel.ErrCommandFailed)
 	}
 
 	return nil
@@ -96,8 +123,7 @@ func (t *TurnoutServo) Throw(ctx context.Context) error {
 			return nil
 		}
 
-		stateCommand := t.setStateCommand(StateThrown)
-		return t.channel.WriteAndReadOpCode(ctx, stateCommand, command.OpCodeTurnoutResponse, t.equalsCommandParams)
+		return t.setState(ctx, StateThrown)
 	})
 }
 
@@ -116,72 +142,101 @@ func (t *TurnoutServo) Close(ctx context.Context) error {
 			return nil
 		}
 
-		stateCommand := t.setStateCommand(StateClosed)
-		return t.channel.WriteAndReadOpCode(ctx, stateCommand, command.OpCodeTurnoutResponse, t.equalsCommandParams)
+		return t.setState(ctx, StateClosed)
 	})
 }
 
+// servoStatusResponse is the <H id SERVO vpin thrown closed profile state> response.
+type servoStatusResponse struct {
+	ID             ID       `dccex:"0"`
+	VPin           VPin     `dccex:"2"`
+	ThrownPosition Position `dccex:"3"`
+	ClosedPosition Position `dccex:"4"`
+	Profile        Profile  `dccex:"5"`
+	// State is returned as 0 or 1, not C and T.
+	Thrown bool `dccex:"6"`
+}
+
+// parseServoStatus parses the status of a servo turnout from the given <H> response.
+// The response is expected to look like <H id SERVO vpin thrown closed profile state>.
+func parseServoStatus(cmd *command.Command) (ID, *TurnoutServoStatus, error) {
+	params := cmd.Parameters()
+	if len(params) != 7 {
+		return 0, nil, fmt.Errorf("invalid turnout servo command parameter length %q", len(params))
+	}
+
+	response := &servoStatusResponse{}
+	err := command.Decode(cmd, response)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to parse turnout servo status: %w", err)
+	}
+
+	state := StateClosed
+	if response.Thrown {
+		state = StateThrown
+	}
+
+	return response.ID, &TurnoutServoStatus{
+		VPin:           response.VPin,
+		ThrownPosition: response.ThrownPosition,
+		ClosedPosition: response.ClosedPosition,
+		Profile:        response.Profile,
+		State:          state,
+	}, nil
+}
+
 // Examine returns the status of the servo.
 func (t *TurnoutServo) Examine(ctx context.Context) (*TurnoutServoStatus, error) {
 	var status *TurnoutServoStatus
 
 	err := t.channel.WriteAndReadOpCode(ctx, t.setStateCommand(StateExamine), command.OpCodeTurnoutResponse, func(cmd *command.Command) error {
-		params, err := cmd.ParametersStrings()
+		_, servoStatus, err := parseServoStatus(cmd)
 		if err != nil {
-			return fmt.Errorf("failed getting turnout servo command parameters: %w", err)
+			return err
 		}
 
-		if len(params) != 7 {
-			return fmt.Errorf("invalid turnout servo command parameter length %q", len(params))
-		}
+		status = servoStatus
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get turnout servo %d status: %w", t.id, err)
+	}
 
-		vPin, err := strconv.ParseUint(params[2], 10, 16)
-		if err != nil {
-			return fmt.Errorf("invalid vpin %q: %w", params[2], err)
-		}
+	if status == nil {
+		return nil, fmt.Errorf("failed to find status for turnout servo %d: %w", t.id, channel.ErrCommandFailed)
+	}
 
-		thrownPosition, err := strconv.ParseUint(params[3], 10, 16)
-		if err != nil {
-			return fmt.Errorf("invalid thrown position %q: %w", params[3], err)
-		}
+	return status, nil
+}
 
-		closedPosition, err := strconv.ParseUint(params[4], 10, 16)
-		if err != nil {
-			return fmt.Errorf("invalid closed position %q: %w", params[4], err)
-		}
+// ExamineAll returns the status of every defined servo turnout using a single listing pass.
+// Turnouts of any other type (e.g. DCC or VPIN) are skipped.
+func ExamineAll(ctx context.Context, channel channel.Interface) (map[ID]*TurnoutServoStatus, error) {
+	statuses := make(map[ID]*TurnoutServoStatus)
 
-		profile, err := strconv.ParseUint(params[5], 10, 8)
+	listCommand := command.NewCommand(command.OpCodeTurnout, "")
+	err := channel.WriteAndReadOpCode(ctx, listCommand, command.OpCodeTurnoutResponse, func(cmd *command.Command) error {
+		params, err := cmd.ParametersStrings()
 		if err != nil {
-			return fmt.Errorf("invalid profile %q: %w", params[5], err)
-		}
-
-		// State is returned as 0 or 1, not C and T.
-		if params[6] != "0" && params[6] != "1" {
-			return fmt.Errorf("invalid state %q", params[6])
+			return fmt.Errorf("failed getting turnout command parameters: %w", err)
 		}
 
-		state := StateClosed
-		if params[6] == "1" {
-			state = StateThrown
+		if len(params) < 2 || params[1] != "SERVO" {
+			// Not a servo turnout, skip it.
+			return nil
 		}
 
-		status = &TurnoutServoStatus{
-			VPin:           VPin(vPin),
-			ThrownPosition: Position(thrownPosition),
-			ClosedPosition: Position(closedPosition),
-			Profile:        Profile(profile),
-			State:          state,
+		id, status, err := parseServoStatus(cmd)
+		if err != nil {
+			return err
 		}
 
+		statuses[id] = status
 		return nil
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get turnout servo %d status: %w", t.id, err)
+		return nil, fmt.Errorf("failed to list turnout servos: %w", err)
 	}
 
-	if status == nil {
-		return nil, fmt.Errorf("failed to find status for turnout servo %d", t.id)
-	}
-
-	return status, nil
+	return statuses, nil
 }
@@ -0,0 +1,147 @@
+package turnout_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/channel"
+	"github.com/roosterfish/dcc-ex-go/channeltest"
+	"github.com/roosterfish/dcc-ex-go/command"
+	"github.com/roosterfish/dcc-ex-go/errs"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+	"github.com/roosterfish/dcc-ex-go/simulator"
+	"github.com/roosterfish/dcc-ex-go/turnout"
+)
+
+func TestThrowFailed(t *testing.T) {
+	ch := channeltest.NewChannel()
+	defer ch.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// The turnout isn't defined, so the command station responds with <X> to examining it.
+	servo := turnout.NewTurnoutServo(5, ch)
+
+	err := servo.Throw(ctx)
+	if !errors.Is(err, errs.ErrStationFailure) {
+		t.Errorf("Expected %v but got %v", errs.ErrStationFailure, err)
+	}
+
+	// The turnout is defined but the command station fails to throw it.
+	ch.Respond("<T 5 X>", "<H 5 SERVO 100 450 110 2 0>")
+
+	err = servo.Throw(ctx)
+	if !errors.Is(err, errs.ErrStationFailure) {
+		t.Errorf("Expected %v but got %v", errs.ErrStationFailure, err)
+	}
+
+	err = servo.Close(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExamineAll(t *testing.T) {
+	p := protocol.NewProtocol(simulator.NewSimulator(), &protocol.Config{})
+	defer p.Close()
+
+	ch := channel.NewChannel(p)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Without any turnout the command station responds with <X>.
+	statuses, err := turnout.ExamineAll(ctx, ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(statuses) != 0 {
+		t.Errorf("Expected no turnouts but got %v", statuses)
+	}
+
+	err = turnout.NewTurnoutServo(1, ch).Persist(ctx, 100, 450, 110, turnout.ProfileSlow)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Turnouts of other types are defined without the turnout package.
+	for _, definition := range []*command.Command{
+		command.NewCommand(command.OpCodeTurnout, "%d DCC %d %d", 2, 10, 1),
+		command.NewCommand(command.OpCodeTurnout, "%d VPIN %d", 3, 101),
+	} {
+		err = ch.WriteWithAck(ctx, definition)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = turnout.NewTurnoutServo(4, ch).Persist(ctx, 102, 400, 200, turnout.ProfileInstant)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = turnout.NewTurnoutServo(4, ch).Throw(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The listing contains all of the turnouts, the non servo ones are skipped.
+	listed := 0
+	err = ch.WriteAndReadOpCode(ctx, command.NewCommand(command.OpCodeTurnout, ""), command.OpCodeTurnoutResponse, func(cmd *command.Command) error {
+		listed++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if listed != 4 {
+		t.Errorf("Expected 4 listed turnouts but got %d", listed)
+	}
+
+	statuses, err = turnout.ExamineAll(ctx, ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[turnout.ID]turnout.TurnoutServoStatus{
+		1: {VPin: 100, ThrownPosition: 450, ClosedPosition: 110, Profile: turnout.ProfileSlow, State: turnout.StateClosed},
+		4: {VPin: 102, ThrownPosition: 400, ClosedPosition: 200, Profile: turnout.ProfileInstant, State: turnout.StateThrown},
+	}
+
+	if len(statuses) != len(expected) {
+		t.Fatalf("Expected %d servo turnouts but got %v", len(expected), statuses)
+	}
+
+	for id, status := range expected {
+		if statuses[id] == nil || *statuses[id] != status {
+			t.Errorf("Expected %+v for turnout %d but got %+v", status, id, statuses[id])
+		}
+	}
+}
+
+func TestExamineAllMalformed(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for name, responses := range map[string][]string{
+		"invalid position": {"<H 1 SERVO 100 abc 110 2 0>"},
+		"missing state":    {"<H 1 DCC 10 1 0>", "<H 2 SERVO 100 450 110 2>"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ch := channeltest.NewChannel()
+			defer ch.Close()
+
+			ch.Respond("<T>", responses...)
+
+			_, err := turnout.ExamineAll(ctx, ch)
+			if err == nil {
+				t.Error("Expected the malformed listing to fail")
+			}
+		})
+	}
+}
@@ -0,0 +1,287 @@
+package turntable
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/roosterfish/dcc-ex-go/channel"
+	"github.com/roosterfish/dcc-ex-go/command"
+	"github.com/roosterfish/dcc-ex-go/errs"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+)
+
+type ID uint16
+type Type uint8
+type Position uint8
+
+const (
+	TypeDCC Type = iota
+	TypeEXTurntable
+)
+
+// Info describes a turntable defined on the command station.
+type Info struct {
+	ID   ID
+	Type Type
+	// Position is the turntable's current position.
+	Position      Position
+	PositionCount int
+	Description   string
+}
+
+// PositionInfo describes a single position of a turntable.
+// Position 0 is the turntable's home position.
+type PositionInfo struct {
+	Position Position
+	// Angle is given in tenths of a degree.
+	Angle       uint16
+	Description string
+}
+
+type Turntable struct {
+	id      ID
+	channel channel.Interface
+}
+
+func NewTurntable(id ID, channel channel.Interface) *Turntable {
+	return &Turntable{
+		id:      id,
+		channel: channel,
+	}
+}
+
+// ID returns the turntable's ID.
+func (t *Turntable) ID() ID {
+	return t.id
+}
+
+// parseInfo parses the response to <JO id>: <jO id type position position_count "description">.
+// It returns nil in case the response is about another or an unknown turntable.
+func parseInfo(id ID, params []string) (*Info, error) {
+	if len(params) < 5 || params[1] != strconv.FormatUint(uint64(id), 10) {
+		return nil, nil
+	}
+
+	turntableType, err := strconv.ParseUint(params[2], 10, 8)
+	if err != nil {
+		return nil, fmt.Errorf("invalid turntable type %q: %w", params[2], err)
+	}
+
+	position, err := strconv.ParseUint(params[3], 10, 8)
+	if err != nil {
+		return nil, fmt.Errorf("invalid turntable position %q: %w", params[3], err)
+	}
+
+	positionCount, err := strconv.Atoi(params[4])
+	if err != nil {
+		return nil, fmt.Errorf("invalid turntable position count %q: %w", params[4], err)
+	}
+
+	info := &Info{
+		ID:            id,
+		Type:          Type(turntableType),
+		Position:      Position(position),
+		PositionCount: positionCount,
+	}
+
+	if len(params) > 5 {
+		info.Description = params[5]
+	}
+
+	return info, nil
+}
+
+// Info returns the turntable's type, current position and description.
+func (t *Turntable) Info(ctx context.Context) (*Info, error) {
+	var info *Info
+
+	infoCommand := command.NewMultiCommand(command.OpCodeTurntables, "%d", t.id)
+	err := t.channel.WriteAndReadMultiOpCode(ctx, infoCommand, command.OpCodeTurntablesResponse, func(cmd *command.Command) error {
+		params, err := cmd.ParametersStrings()
+		if err != nil {
+			return fmt.Errorf("failed getting turntable command parameters: %w", err)
+		}
+
+		parsed, err := parseInfo(t.id, params)
+		if err != nil {
+			return err
+		}
+
+		if parsed != nil {
+			info = parsed
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get turntable %d: %w", t.id, err)
+	}
+
+	if info == nil {
+		return nil, fmt.Errorf("failed to find turntable %d", t.id)
+	}
+
+	return info, nil
+}
+
+// Positions returns the turntable's positions.
+// The command station responds to <JP id> with a <jP id position angle "description"> for every position.
+func (t *Turntable) Positions(ctx context.Context) ([]*PositionInfo, error) {
+	var positions []*PositionInfo
+
+	positionsCommand := command.NewMultiCommand(command.OpCodeTurntablePositions, "%d", t.id)
+	err := t.channel.WriteAndReadMultiOpCode(ctx, positionsCommand, command.OpCodeTurntablePositionsResponse, func(cmd *command.Command) error {
+		params, err := cmd.ParametersStrings()
+		if err != nil {
+			return fmt.Errorf("failed getting turntable position command parameters: %w", err)
+		}
+
+		if len(params) < 4 || params[1] != strconv.FormatUint(uint64(t.id), 10) {
+			// Not the right turntable, skip it.
+			return nil
+		}
+
+		position, err := strconv.ParseUint(params[2], 10, 8)
+		if err != nil {
+			return fmt.Errorf("invalid turntable position %q: %w", params[2], err)
+		}
+
+		angle, err := strconv.ParseUint(params[3], 10, 16)
+		if err != nil {
+			return fmt.Errorf("invalid turntable angle %q: %w", params[3], err)
+		}
+
+		positionInfo := &PositionInfo{
+			Position: Position(position),
+			Angle:    uint16(angle),
+		}
+
+		if len(params) > 4 {
+			positionInfo.Description = params[4]
+		}
+
+		positions = append(positions, positionInfo)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get positions of turntable %d: %w", t.id, err)
+	}
+
+	return positions, nil
+}
+
+// Rotate rotates the turntable to the given position using <I id position>.
+// It waits for the command station to broadcast the completed movement as <I id position 0>.
+// EX-Turntables additionally broadcast <I id position 1> once they start moving.
+func (t *Turntable) Rotate(ctx context.Context, position Position) error {
+	return t.channel.RSession(func(reader protocol.Reader) error {
+		commandC, cleanupF := protocol.ReadFiltered(reader, command.OpCodeTurntable)
+		defer cleanupF()
+
+		completedMatcher := command.NewMatcher(command.OpCodeTurntable, t.id, position, 0)
+		completedC := make(chan struct{})
+		doneC := make(chan struct{})
+		wg := sync.WaitGroup{}
+
+		// Keep consuming the broadcasts while writing to not block the protocol's listener.
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			completed := false
+			for {
+				select {
+				case cmd, ok := <-commandC:
+					if !ok {
+						return
+					}
+
+					if !completed && completedMatcher.Match(cmd) {
+						completed = true
+						close(completedC)
+					}
+				case <-doneC:
+					return
+				}
+			}
+		}()
+
+		defer wg.Wait()
+		defer close(doneC)
+
+		accepted := false
+		movementMatcher := command.NewMatcher(command.OpCodeTurntable, t.id, position, command.Wildcard)
+		rotateCommand := command.NewCommand(command.OpCodeTurntable, "%d %d", t.id, position)
+		err := t.channel.WriteAndReadOpCode(ctx, rotateCommand, command.OpCodeTurntable, func(cmd *command.Command) error {
+			if movementMatcher.Match(cmd) {
+				accepted = true
+			}
+
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to rotate turntable %d to position %d: %w", t.id, position, err)
+		}
+
+		if !accepted {
+			return fmt.Errorf("failed to rotate turntable %d to position %d: %w", t.id, position, errs.ErrStationFailure)
+		}
+
+		select {
+		case <-completedC:
+			return nil
+		case <-ctx.Done():
+			return errs.Context(context.Cause(ctx))
+		}
+	})
+}
+
+// List returns the turntables defined on the command station.
+// The command station responds to <JO> with <jO id1 id2 ...>.
+func List(ctx context.Context, channel channel.Interface) ([]*Info, error) {
+	var infos []*Info
+
+	err := channel.SessionContext(ctx, func(ctx context.Context) error {
+		var ids []ID
+
+		listCommand := command.NewMultiCommand(command.OpCodeTurntables, "")
+		err := channel.WriteAndReadMultiOpCode(ctx, listCommand, command.OpCodeTurntablesResponse, func(cmd *command.Command) error {
+			params, err := cmd.ParametersStrings()
+			if err != nil {
+				return fmt.Errorf("failed getting turntables command parameters: %w", err)
+			}
+
+			for _, param := range params[1:] {
+				id, err := strconv.ParseUint(param, 10, 16)
+				if err != nil {
+					return fmt.Errorf("invalid turntable ID %q: %w", param, err)
+				}
+
+				ids = append(ids, ID(id))
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, id := range ids {
+			info, err := NewTurntable(id, channel).Info(ctx)
+			if err != nil {
+				return err
+			}
+
+			infos = append(infos, info)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list turntables: %w", err)
+	}
+
+	return infos, nil
+}
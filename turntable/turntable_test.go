@@ -0,0 +1,68 @@
+package turntable_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/channel"
+	"github.com/roosterfish/dcc-ex-go/errs"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+	"github.com/roosterfish/dcc-ex-go/simulator"
+	"github.com/roosterfish/dcc-ex-go/turntable"
+)
+
+func TestTurntable(t *testing.T) {
+	sim := simulator.NewSimulator()
+	sim.AddTurntable(1, true, "Roundhouse", simulator.TurntablePosition{Angle: 0, Description: "Home"}, simulator.TurntablePosition{Angle: 900, Description: "Shed"})
+
+	p := protocol.NewProtocol(sim, &protocol.Config{})
+	defer p.Close()
+
+	ch := channel.NewChannel(p)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	infos, err := turntable.List(ctx, ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedInfos := []*turntable.Info{{ID: 1, Type: turntable.TypeEXTurntable, PositionCount: 2, Description: "Roundhouse"}}
+	if !reflect.DeepEqual(infos, expectedInfos) {
+		t.Errorf("Expected turntables %+v, got %+v", expectedInfos, infos)
+	}
+
+	roundhouse := turntable.NewTurntable(1, ch)
+	positions, err := roundhouse.Positions(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedPositions := []*turntable.PositionInfo{{Position: 0, Angle: 0, Description: "Home"}, {Position: 1, Angle: 900, Description: "Shed"}}
+	if !reflect.DeepEqual(positions, expectedPositions) {
+		t.Errorf("Expected positions %+v, got %+v", expectedPositions, positions)
+	}
+
+	err = roundhouse.Rotate(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := roundhouse.Info(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Position != 1 {
+		t.Errorf("Expected position 1, got %d", info.Position)
+	}
+
+	err = roundhouse.Rotate(ctx, 5)
+	if !errors.Is(err, errs.ErrStationFailure) {
+		t.Errorf("Expected %v, got %v", errs.ErrStationFailure, err)
+	}
+}
@@ -0,0 +1,226 @@
+package vpin
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/roosterfish/dcc-ex-go/channel"
+	"github.com/roosterfish/dcc-ex-go/command"
+)
+
+type Kind string
+
+const (
+	KindSensor  Kind = "sensor"
+	KindTurnout Kind = "turnout"
+	KindOutput  Kind = "output"
+)
+
+// Owner identifies the entity a vpin is assigned to.
+type Owner struct {
+	Kind Kind
+	ID   uint16
+}
+
+// ConflictError is returned if a vpin is already assigned to another entity.
+type ConflictError struct {
+	VPin  uint16
+	Owner Owner
+}
+
+func (o Owner) String() string {
+	return fmt.Sprintf("%s %d", o.Kind, o.ID)
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("vpin %d is already assigned to %s", e.VPin, e.Owner)
+}
+
+// Owners returns the owners of all vpins by listing the defined sensors, turnouts and outputs.
+// A vpin can have multiple owners in case the command station already contains conflicting definitions.
+func Owners(ctx context.Context, channel channel.Interface) (map[uint16][]Owner, error) {
+	owners := make(map[uint16][]Owner)
+
+	addF := func(kind Kind, idParam string, vpinParam string) error {
+		id, err := strconv.ParseUint(idParam, 10, 16)
+		if err != nil {
+			return fmt.Errorf("invalid %s id %q: %w", kind, idParam, err)
+		}
+
+		vpin, err := strconv.ParseUint(vpinParam, 10, 16)
+		if err != nil {
+			return fmt.Errorf("invalid %s vpin %q: %w", kind, vpinParam, err)
+		}
+
+		owners[uint16(vpin)] = append(owners[uint16(vpin)], Owner{
+			Kind: kind,
+			ID:   uint16(id),
+		})
+
+		return nil
+	}
+
+	err := channel.SessionContext(ctx, func(ctx context.Context) error {
+		// Sensors are listed as <Q id vpin pullup>.
+		err := channel.WriteAndReadOpCode(ctx, command.NewCommand(command.OpCodeSensorCreate, ""), command.OpCodeSensorListResponse, func(cmd *command.Command) error {
+			params, err := cmd.ParametersStrings()
+			if err != nil {
+				return fmt.Errorf("failed getting sensor command parameters: %w", err)
+			}
+
+			if len(params) != 3 {
+				// Not a sensor definition, skip it.
+				return nil
+			}
+
+			return addF(KindSensor, params[0], params[1])
+		})
+		if err != nil {
+			return fmt.Errorf("failed to list sensors: %w", err)
+		}
+
+		// Turnouts using a vpin are listed as <H id SERVO vpin ...> or <H id VPIN vpin state>.
+		err = channel.WriteAndReadOpCode(ctx, command.NewCommand(command.OpCodeTurnout, ""), command.OpCodeTurnoutResponse, func(cmd *command.Command) error {
+			params, err := cmd.ParametersStrings()
+			if err != nil {
+				return fmt.Errorf("failed getting turnout command parameters: %w", err)
+			}
+
+			if len(params) < 3 || (params[1] != "SERVO" && params[1] != "VPIN") {
+				// Not a turnout using a vpin, skip it.
+				return nil
+			}
+
+			return addF(KindTurnout, params[0], params[2])
+		})
+		if err != nil {
+			return fmt.Errorf("failed to list turnouts: %w", err)
+		}
+
+		// Outputs are listed as <Y id vpin iflag state>.
+		err = channel.WriteAndReadOpCode(ctx, command.NewCommand(command.OpCodeOutput, ""), command.OpCodeOutputResponse, func(cmd *command.Command) error {
+			params, err := cmd.ParametersStrings()
+			if err != nil {
+				return fmt.Errorf("failed getting output command parameters: %w", err)
+			}
+
+			if len(params) != 4 {
+				// Not an output definition, skip it.
+				return nil
+			}
+
+			return addF(KindOutput, params[0], params[1])
+		})
+		if err != nil {
+			return fmt.Errorf("failed to list outputs: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return owners, nil
+}
+
+// Check returns a *ConflictError in case the given vpin is already assigned to an entity other than owner.
+// Redefining the owner itself using the same vpin isn't considered a conflict.
+// Every call lists all of the defined entities, use a Checker to check multiple definitions.
+// Run it within a channel's SessionContext together with the definition to make both atomic.
+func Check(ctx context.Context, channel channel.Interface, vpin uint16, owner Owner) error {
+	owners, err := Owners(ctx, channel)
+	if err != nil {
+		return fmt.Errorf("failed to get vpin owners: %w", err)
+	}
+
+	return check(owners, vpin, owner)
+}
+
+// check returns a *ConflictError in case the given vpin is assigned to an entity other than owner.
+func check(owners map[uint16][]Owner, vpin uint16, owner Owner) error {
+	for _, existing := range owners[vpin] {
+		if existing != owner {
+			return &ConflictError{
+				VPin:  vpin,
+				Owner: existing,
+			}
+		}
+	}
+
+	return nil
+}
+
+// Checker detects vpin conflicts of a batch of definitions (e.g. provisioning a layout)
+// using a single listing of the defined entities.
+// Definitions made during the batch are added using Assign.
+type Checker struct {
+	lock   sync.Mutex
+	owners map[uint16][]Owner
+}
+
+// NewChecker returns a new checker using the entities currently defined on the command station.
+func NewChecker(ctx context.Context, channel channel.Interface) (*Checker, error) {
+	owners, err := Owners(ctx, channel)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get vpin owners: %w", err)
+	}
+
+	return &Checker{
+		owners: owners,
+	}, nil
+}
+
+// Check returns a *ConflictError in case the given vpin is already assigned to an entity other than owner.
+// Redefining the owner itself using the same vpin isn't considered a conflict.
+func (c *Checker) Check(vpin uint16, owner Owner) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return check(c.owners, vpin, owner)
+}
+
+// Assign records the owner to be defined using the given vpin.
+// A previous vpin of the owner is released as redefining an entity replaces its definition.
+func (c *Checker) Assign(vpin uint16, owner Owner) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for existingVPin, owners := range c.owners {
+		c.owners[existingVPin] = slices.DeleteFunc(owners, func(existing Owner) bool {
+			return existing == owner
+		})
+	}
+
+	c.owners[vpin] = append(c.owners[vpin], owner)
+}
+
+type checkerCtxKey struct{}
+
+// WithChecker returns a context enabling the vpin conflict detection of the definitions
+// (e.g. sensor.Sensor.Persist) using the given checker.
+// Without a checker, definitions are written without checking for conflicts.
+func WithChecker(ctx context.Context, checker *Checker) context.Context {
+	return context.WithValue(ctx, checkerCtxKey{}, checker)
+}
+
+// CheckContext checks the given vpin using the checker of the context, if any.
+func CheckContext(ctx context.Context, vpin uint16, owner Owner) error {
+	checker, ok := ctx.Value(checkerCtxKey{}).(*Checker)
+	if !ok {
+		return nil
+	}
+
+	return checker.Check(vpin, owner)
+}
+
+// AssignContext records the owner's vpin using the checker of the context, if any.
+func AssignContext(ctx context.Context, vpin uint16, owner Owner) {
+	checker, ok := ctx.Value(checkerCtxKey{}).(*Checker)
+	if ok {
+		checker.Assign(vpin, owner)
+	}
+}
@@ -0,0 +1,121 @@
+package vpin_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/channel"
+	"github.com/roosterfish/dcc-ex-go/output"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+	"github.com/roosterfish/dcc-ex-go/sensor"
+	"github.com/roosterfish/dcc-ex-go/simulator"
+	"github.com/roosterfish/dcc-ex-go/turnout"
+	"github.com/roosterfish/dcc-ex-go/vpin"
+)
+
+func TestConflict(t *testing.T) {
+	p := protocol.NewProtocol(simulator.NewSimulator(), &protocol.Config{})
+	defer p.Close()
+
+	ch := channel.NewChannel(p)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// The whole batch of definitions is checked using a single listing.
+	checker, err := vpin.NewChecker(ctx, ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx = vpin.WithChecker(ctx, checker)
+
+	err = sensor.NewSensor(1, ch).Persist(ctx, 100, sensor.PullUpOn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Redefining the same sensor using the same vpin isn't a conflict.
+	err = sensor.NewSensor(1, ch).Persist(ctx, 100, sensor.PullUpOff)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = output.NewOutput(2, ch).Persist(ctx, 101, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		persist func() error
+		owner   vpin.Owner
+	}{
+		{
+			name: "sensor on sensor vpin",
+			persist: func() error {
+				return sensor.NewSensor(3, ch).Persist(ctx, 100, sensor.PullUpOn)
+			},
+			owner: vpin.Owner{Kind: vpin.KindSensor, ID: 1},
+		},
+		{
+			name: "output on sensor vpin",
+			persist: func() error {
+				return output.NewOutput(3, ch).Persist(ctx, 100, 0)
+			},
+			owner: vpin.Owner{Kind: vpin.KindSensor, ID: 1},
+		},
+		{
+			name: "turnout on output vpin",
+			persist: func() error {
+				return turnout.NewTurnoutServo(3, ch).Persist(ctx, 101, 400, 200, turnout.ProfileFast)
+			},
+			owner: vpin.Owner{Kind: vpin.KindOutput, ID: 2},
+		},
+	}
+
+	for _, test := range tests {
+		err := test.persist()
+
+		var conflictErr *vpin.ConflictError
+		if !errors.As(err, &conflictErr) {
+			t.Errorf("%s: Expected conflict error but got %v", test.name, err)
+			continue
+		}
+
+		if conflictErr.Owner != test.owner {
+			t.Errorf("%s: Expected owner %q but got %q", test.name, test.owner, conflictErr.Owner)
+		}
+	}
+
+	// A fresh listing contains the persisted definitions.
+	err = vpin.Check(ctx, ch, 101, vpin.Owner{Kind: vpin.KindSensor, ID: 3})
+
+	var conflictErr *vpin.ConflictError
+	if !errors.As(err, &conflictErr) || conflictErr.Owner != (vpin.Owner{Kind: vpin.KindOutput, ID: 2}) {
+		t.Errorf("Expected conflict with output 2 but got %v", err)
+	}
+}
+
+func TestConflictWithoutChecker(t *testing.T) {
+	p := protocol.NewProtocol(simulator.NewSimulator(), &protocol.Config{})
+	defer p.Close()
+
+	ch := channel.NewChannel(p)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := sensor.NewSensor(1, ch).Persist(ctx, 100, sensor.PullUpOn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without a checker the definitions aren't checked.
+	err = output.NewOutput(2, ch).Persist(ctx, 100, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,295 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/channel"
+	"github.com/roosterfish/dcc-ex-go/clock"
+	"github.com/roosterfish/dcc-ex-go/diagnostics"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+	"github.com/roosterfish/dcc-ex-go/route"
+	"github.com/roosterfish/dcc-ex-go/sensor"
+)
+
+// EventType is the type of event which triggers the webhooks.
+type EventType string
+
+const (
+	// EventSensor is triggered once a sensor changes its state.
+	EventSensor EventType = "sensor"
+	// EventShort is triggered once a turnout motor is suspected to be shorted.
+	EventShort EventType = "short"
+	// EventRoute is triggered once a route got activated.
+	EventRoute EventType = "route"
+)
+
+// DefaultTimeout is the timeout of a single webhook request if not configured otherwise.
+const DefaultTimeout = 10 * time.Second
+
+// Event is passed to the payload templates of the webhooks.
+type Event struct {
+	Type EventType `json:"type"`
+	// ID is the ID of the sensor or turnout. It's zero for routes.
+	ID uint16 `json:"id,omitempty"`
+	// Name is the name of the route. It's empty for sensors and turnouts.
+	Name string `json:"name,omitempty"`
+	// State is the new state of a sensor (active or inactive).
+	State string    `json:"state,omitempty"`
+	Time  time.Time `json:"time"`
+}
+
+// Hook is a HTTP callback POSTed on selected events.
+type Hook struct {
+	URL string
+	// Events are the event types triggering the hook. The hook is triggered by all events if empty.
+	Events []EventType
+	// Template is a text/template rendering the JSON payload from the *Event.
+	// The function json encodes a value, e.g. {"text": {{json .Name}}}.
+	// The event is encoded as JSON if empty.
+	Template string
+	// Header are additional headers sent with the request (e.g. authorization).
+	Header http.Header
+}
+
+// Config configures the webhooks.
+type Config struct {
+	Hooks []*Hook
+	// Client is used to send the requests. http.DefaultClient is used if nil.
+	Client *http.Client
+	// Timeout is the timeout of a single request. DefaultTimeout is used if zero.
+	Timeout time.Duration
+	// OnError is called for every request which fails.
+	OnError func(hook *Hook, event *Event, err error)
+	// Clock is the source of time used to timestamp events which aren't observed on a channel (e.g. EventRoute).
+	// It defaults to the real time.
+	Clock clock.Clock
+}
+
+// Webhooks POSTs the configured hooks on events.
+type Webhooks struct {
+	config    *Config
+	templates map[*Hook]*template.Template
+	wg        sync.WaitGroup
+}
+
+var funcs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// New returns new webhooks for the given config.
+// It returns an error in case any of the templates cannot be parsed.
+func New(config *Config) (*Webhooks, error) {
+	w := &Webhooks{
+		config:    config,
+		templates: make(map[*Hook]*template.Template, len(config.Hooks)),
+	}
+
+	for _, hook := range config.Hooks {
+		if hook.Template == "" {
+			continue
+		}
+
+		tmpl, err := template.New(hook.URL).Funcs(funcs).Parse(hook.Template)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse template of hook %q: %w", hook.URL, err)
+		}
+
+		w.templates[hook] = tmpl
+	}
+
+	return w, nil
+}
+
+// Payload renders the hook's payload for the given event.
+// It returns an error in case the rendered payload isn't valid JSON.
+func (w *Webhooks) Payload(hook *Hook, event *Event) ([]byte, error) {
+	tmpl, ok := w.templates[hook]
+	if !ok {
+		return json.Marshal(event)
+	}
+
+	payload := &bytes.Buffer{}
+	err := tmpl.Execute(payload, event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render payload: %w", err)
+	}
+
+	if !json.Valid(payload.Bytes()) {
+		return nil, errors.New("failed to render payload: invalid JSON")
+	}
+
+	return payload.Bytes(), nil
+}
+
+// Notify POSTs all of the hooks selecting the event's type.
+// The requests are sent in the background, so it never blocks.
+func (w *Webhooks) Notify(event *Event) {
+	for _, hook := range w.config.Hooks {
+		if len(hook.Events) > 0 && !slices.Contains(hook.Events, event.Type) {
+			continue
+		}
+
+		w.wg.Add(1)
+		go func() {
+			defer w.wg.Done()
+
+			err := w.post(hook, event)
+			if err != nil && w.config.OnError != nil {
+				w.config.OnError(hook, event, err)
+			}
+		}()
+	}
+}
+
+func (w *Webhooks) post(hook *Hook, event *Event) error {
+	payload, err := w.Payload(hook, event)
+	if err != nil {
+		return err
+	}
+
+	timeout := w.config.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	for key, values := range hook.Header {
+		req.Header[key] = values
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	client := w.config.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post hook: %w", err)
+	}
+
+	_ = resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("failed to post hook: unexpected status %q", resp.Status)
+	}
+
+	return nil
+}
+
+// Wait waits until all of the pending requests are done.
+func (w *Webhooks) Wait() {
+	w.wg.Wait()
+}
+
+// WatchSensors triggers EventSensor on every state change of the given sensors.
+// All of the sensors are watched if no IDs are given.
+func (w *Webhooks) WatchSensors(channel *channel.Channel, ids ...sensor.ID) protocol.CleanupF {
+	wg := sync.WaitGroup{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	watcher := func() {
+		defer wg.Done()
+
+		_ = channel.RSession(func(sessionProtocol protocol.Reader) error {
+			commandC, cleanupF := protocol.ReadFiltered(sessionProtocol, sensor.StateActive.OpCode(), sensor.StateInactive.OpCode())
+			defer cleanupF()
+
+			for {
+				select {
+				case cmd, ok := <-commandC:
+					if !ok {
+						return nil
+					}
+
+					// Listings (<Q id vpin pullup>) aren't state changes.
+					params, err := cmd.ParametersStrings()
+					if err != nil || len(params) != 1 {
+						continue
+					}
+
+					id, err := strconv.ParseUint(params[0], 10, 16)
+					if err != nil || (len(ids) > 0 && !slices.Contains(ids, sensor.ID(id))) {
+						continue
+					}
+
+					state := "inactive"
+					if sensor.State(cmd.OpCode()) == sensor.StateActive {
+						state = "active"
+					}
+
+					w.Notify(&Event{
+						Type:  EventSensor,
+						ID:    uint16(id),
+						State: state,
+						Time:  channel.Clock().Now(),
+					})
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+		})
+	}
+
+	wg.Add(1)
+	go watcher()
+
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+// WatchMaintenance triggers EventShort for every shorted turnout motor received from the maintenance events.
+// It returns once the channel is closed.
+func (w *Webhooks) WatchMaintenance(maintenanceC diagnostics.MaintenanceC) {
+	for event := range maintenanceC {
+		if event.Fault != diagnostics.MotorShorted {
+			continue
+		}
+
+		w.Notify(&Event{
+			Type: EventShort,
+			ID:   uint16(event.Turnout),
+			Time: event.Time,
+		})
+	}
+}
+
+// ActivateRoute activates the route and triggers EventRoute once all of its turnouts are set.
+func (w *Webhooks) ActivateRoute(ctx context.Context, r *route.Route) error {
+	err := r.Activate(ctx)
+	if err != nil {
+		return err
+	}
+
+	w.Notify(&Event{
+		Type: EventRoute,
+		Name: r.Name(),
+		Time: clock.Or(w.config.Clock).Now(),
+	})
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package webhooks_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/roosterfish/dcc-ex-go/channel"
+	"github.com/roosterfish/dcc-ex-go/protocol"
+	"github.com/roosterfish/dcc-ex-go/sensor"
+	"github.com/roosterfish/dcc-ex-go/simulator"
+	"github.com/roosterfish/dcc-ex-go/webhooks"
+)
+
+func TestWatchSensors(t *testing.T) {
+	sim := simulator.NewSimulator()
+	p := protocol.NewProtocol(sim, &protocol.Config{})
+	defer p.Close()
+
+	payloadC := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		payloadC <- string(body)
+	}))
+	defer server.Close()
+
+	hooks, err := webhooks.New(&webhooks.Config{
+		Hooks: []*webhooks.Hook{{
+			URL:      server.URL,
+			Events:   []webhooks.EventType{webhooks.EventSensor},
+			Template: `{"camera": "platform", "sensor": {{.ID}}, "state": {{json .State}}}`,
+		}},
+		OnError: func(hook *webhooks.Hook, event *webhooks.Event, err error) {
+			t.Error(err)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer hooks.Wait()
+
+	cleanupF := hooks.WatchSensors(channel.NewChannel(p), 2)
+
+	// Wait until subscribed to not miss any broadcast.
+	time.Sleep(50 * time.Millisecond)
+
+	sim.SetSensorState(1, sensor.StateActive)
+	sim.SetSensorState(2, sensor.StateActive)
+
+	select {
+	case payload := <-payloadC:
+		expected := `{"camera": "platform", "sensor": 2, "state": "active"}`
+		if payload != expected {
+			t.Errorf("Expected %s but got %s", expected, payload)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Webhook wasn't called")
+	}
+
+	cleanupF()
+}
+
+func TestInvalidPayload(t *testing.T) {
+	hook := &webhooks.Hook{
+		URL: "http://localhost",
+		// The name isn't quoted.
+		Template: `{"route": {{.Name}}}`,
+	}
+
+	hooks, err := webhooks.New(&webhooks.Config{
+		Hooks: []*webhooks.Hook{hook},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = hooks.Payload(hook, &webhooks.Event{Type: webhooks.EventRoute, Name: "siding"})
+	if err == nil {
+		t.Error("Expected invalid payload error")
+	}
+}